@@ -12,6 +12,7 @@ import (
 	"loyalty-backend/pkg/database"
 	"loyalty-backend/pkg/fabric"
 	"loyalty-backend/pkg/handlers"
+	"loyalty-backend/pkg/services"
 )
 
 // =========================================================================================
@@ -28,9 +29,30 @@ import (
 // - GET /health - Health check
 // - POST /api/v1/accounts - Tạo tài khoản loyalty
 // - GET /api/v1/accounts/:customerID - Truy vấn tài khoản
+// - GET /api/v1/accounts/:customerID/balance?asOf= - Số dư tại một thời điểm (RFC3339)
+// - GET /api/v1/accounts/:customerID/points - Số dư khả dụng và điểm đang chờ
+// - POST /api/v1/accounts/:customerID/pending - Ghi nhận điểm chờ hết thời hạn đổi trả
+// - DELETE /api/v1/accounts/:customerID/pending/:awardID - Hủy điểm chờ khi đơn hàng bị trả lại
 // - POST /api/v1/accounts/:customerID/issue - Phát hành điểm
 // - POST /api/v1/accounts/:customerID/redeem - Quy đổi điểm  
-// - POST /api/v1/transfer - Chuyển điểm giữa tài khoản
+// - POST /api/v1/transfer - Chuyển điểm giữa tài khoản (202 khi giao dịch bị giữ lại chờ duyệt)
+// - POST /api/v1/accounts/:customerID/security-change - Ghi nhận đổi mật khẩu/số điện thoại
+// - GET /api/v1/flagged-transfers - Giao dịch chuyển điểm chờ duyệt
+// - POST /api/v1/flagged-transfers/:transferID/approve - Duyệt giao dịch bị giữ lại
+// - POST /api/v1/flagged-transfers/:transferID/reject - Từ chối giao dịch, hoàn điểm cho người gửi
+// - GET /api/v1/accounts/:customerID/vouchers - Danh sách voucher của khách hàng
+// - GET /api/v1/vouchers/:voucherID/qr - Nội dung QR của voucher
+// - POST /api/v1/gifts - Tặng điểm, trả về link nhận quà
+// - POST /api/v1/gifts/authorization - Tạo mã nhận quà và payload để khách hàng ký
+// - POST /api/v1/gifts/claim - Nhận quà bằng mã trong link
+// - GET /api/v1/accounts/:customerID/referrals - Mã giới thiệu và trạng thái các lượt giới thiệu
+// - POST /api/v1/referrals - Đăng ký giới thiệu bằng mã của bạn bè
+// - GET /api/v1/stats?from=&to= - Thống kê chương trình (số tài khoản, tổng cung, phát hành/quy đổi)
+// - GET /api/v1/leaderboard?metric=&period=&limit= - Bảng xếp hạng điểm tích lũy/quy đổi
+// - POST /api/v1/swaps - Hoán đổi điểm lấy dặm bay của đối tác (HTLC trên hai kênh)
+// - POST /api/v1/swaps/authorization - Tạo swapID và payload để khách hàng ký
+// - GET /api/v1/swaps/:swapID - Trạng thái hoán đổi
+// - POST /api/v1/swaps/:swapID/settle - Hoàn tất hoặc hoàn lại hoán đổi
 // =========================================================================================
 func main() {
 	// 1. Load configuration
@@ -60,6 +82,29 @@ func main() {
 	} else {
 		defer fabricClient.Close()
 		log.Println("✅ Connected to Hyperledger Fabric network successfully")
+
+		// Expired gifts are refunded to their senders by a periodic job
+		fabricClient.StartGiftRefunds(cfg.GiftRefundInterval)
+		// Purchase points are released once the merchant's return window has closed
+		fabricClient.StartPendingReleases(cfg.PendingReleaseInterval)
+		// Referrals are rewarded once the referee's lifetime earned points reach the threshold
+		fabricClient.StartReferralRewards(cfg.ReferralRewardInterval)
+		// Supply counter shards of finished days are folded so that reading the counters stays cheap
+		fabricClient.StartCounterCompaction(cfg.CounterCompactionInterval)
+	}
+
+	// Swaps need a second client for the partner program's channel
+	var swapService *services.SwapService
+	if fabricClient != nil && cfg.SwapSecretKey == "" {
+		log.Println("Warning: SWAP_SECRET_KEY is not set, swaps are disabled")
+	} else if fabricClient != nil {
+		partnerClient, err := fabric.NewPartnerFabricClient(cfg)
+		if err != nil {
+			log.Printf("Warning: Failed to create partner Fabric client, swaps are disabled: %v", err)
+		} else {
+			defer partnerClient.Close()
+			swapService = services.NewSwapService(fabricClient, partnerClient, cfg.SwapPartnerAccount, cfg.SwapSecretKey)
+		}
 	}
 
 	// Determine runtime mode
@@ -95,6 +140,12 @@ func main() {
 	// 5. Initialize handlers
 	authHandler := handlers.NewAuthHandler()
 	loyaltyHandler := handlers.NewLoyaltyHandler(fabricClient)
+	giftHandler := handlers.NewGiftHandler(fabricClient, cfg.GiftClaimURL)
+	swapHandler := handlers.NewSwapHandler(swapService)
+	if cfg.ReferralCodeKey == "" {
+		log.Println("Warning: REFERRAL_CODE_KEY is not set, referrals are disabled")
+	}
+	referralHandler := handlers.NewReferralHandler(fabricClient, cfg.ReferralCodeKey)
 
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
@@ -130,16 +181,41 @@ func main() {
 			"version": "1.0.0",
 			"mode":    mode,
 			"endpoints": gin.H{
-				"health":     "GET /health",
-				"login":      "POST /api/v1/auth/login",
-				"register":   "POST /api/v1/auth/register", 
-				"profile":    "GET /api/v1/auth/profile",
-				"logout":     "POST /api/v1/auth/logout",
-				"accounts":   "POST /api/v1/accounts",
-				"query":      "GET /api/v1/accounts/:customerID",
-				"issue":      "POST /api/v1/accounts/:customerID/issue",
-				"redeem":     "POST /api/v1/accounts/:customerID/redeem",
-				"transfer":   "POST /api/v1/transfer",
+				"health":           "GET /health",
+				"login":            "POST /api/v1/auth/login",
+				"register":         "POST /api/v1/auth/register", 
+				"profile":          "GET /api/v1/auth/profile",
+				"logout":           "POST /api/v1/auth/logout",
+				"accounts":         "POST /api/v1/accounts",
+				"query":            "GET /api/v1/accounts/:customerID",
+				"balanceAt":        "GET /api/v1/accounts/:customerID/balance?asOf=",
+				"points":           "GET /api/v1/accounts/:customerID/points",
+				"awardPending":     "POST /api/v1/accounts/:customerID/pending",
+				"cancelPending":    "DELETE /api/v1/accounts/:customerID/pending/:awardID",
+				"policy":           "GET /api/v1/accounts/:customerID/policy",
+				"setPolicy":        "PUT /api/v1/accounts/:customerID/policy",
+				"signingKey":       "PUT /api/v1/accounts/:customerID/signing-key",
+				"securityChange":   "POST /api/v1/accounts/:customerID/security-change",
+				"issue":            "POST /api/v1/accounts/:customerID/issue",
+				"redeem":           "POST /api/v1/accounts/:customerID/redeem",
+				"transfer":         "POST /api/v1/transfer",
+				"prepareTransfer":  "POST /api/v1/transfer/authorization",
+				"flaggedTransfers": "GET /api/v1/flagged-transfers",
+				"approveFlagged":   "POST /api/v1/flagged-transfers/:transferID/approve",
+				"rejectFlagged":    "POST /api/v1/flagged-transfers/:transferID/reject",
+				"vouchers":         "GET /api/v1/accounts/:customerID/vouchers",
+				"voucherQR":        "GET /api/v1/vouchers/:voucherID/qr",
+				"gifts":            "POST /api/v1/gifts",
+				"prepareGift":      "POST /api/v1/gifts/authorization",
+				"claimGift":        "POST /api/v1/gifts/claim",
+				"referrals":        "GET /api/v1/accounts/:customerID/referrals",
+				"registerReferral": "POST /api/v1/referrals",
+				"stats":            "GET /api/v1/stats?from=&to=",
+				"leaderboard":      "GET /api/v1/leaderboard?metric=&period=&limit=",
+				"swaps":            "POST /api/v1/swaps",
+				"prepareSwap":      "POST /api/v1/swaps/authorization",
+				"swapStatus":       "GET /api/v1/swaps/:swapID",
+				"settleSwap":       "POST /api/v1/swaps/:swapID/settle",
 			},
 		})
 	})
@@ -165,12 +241,49 @@ func main() {
 			accounts.POST("", loyaltyHandler.CreateAccount)
 			accounts.GET("/:customerID", loyaltyHandler.GetAccount)
 			accounts.GET("/:customerID/recent-transactions", loyaltyHandler.GetRecentTransactions)
+			accounts.GET("/:customerID/balance", loyaltyHandler.GetBalanceAt)
 			accounts.POST("/:customerID/issue", loyaltyHandler.IssuePoints)
 			accounts.POST("/:customerID/redeem", loyaltyHandler.RedeemPoints)
+			accounts.GET("/:customerID/vouchers", loyaltyHandler.GetVouchers)
+			accounts.GET("/:customerID/points", loyaltyHandler.GetPointsBalance)
+			accounts.POST("/:customerID/pending", loyaltyHandler.AwardPendingPoints)
+			accounts.DELETE("/:customerID/pending/:awardID", loyaltyHandler.CancelPendingPoints)
+			accounts.GET("/:customerID/policy", loyaltyHandler.GetAccountPolicy)
+			accounts.PUT("/:customerID/policy", loyaltyHandler.SetAccountPolicy)
+			accounts.PUT("/:customerID/signing-key", loyaltyHandler.RegisterSigningKey)
+			accounts.POST("/:customerID/security-change", loyaltyHandler.FlagSecurityChange)
+			accounts.GET("/:customerID/referrals", referralHandler.GetReferrals)
 		}
 
+		// Voucher operations
+		v1.GET("/vouchers/:voucherID/qr", loyaltyHandler.GetVoucherQR)
+
 		// Transfer operations
 		v1.POST("/transfer", loyaltyHandler.TransferPoints)
+		v1.POST("/transfer/authorization", loyaltyHandler.PrepareTransfer)
+
+		// Flagged transfer review (velocity rules)
+		v1.GET("/flagged-transfers", loyaltyHandler.GetFlaggedTransfers)
+		v1.POST("/flagged-transfers/:transferID/approve", loyaltyHandler.ApproveFlagged)
+		v1.POST("/flagged-transfers/:transferID/reject", loyaltyHandler.RejectFlagged)
+
+		// Gift operations
+		v1.POST("/gifts", giftHandler.CreateGift)
+		v1.POST("/gifts/authorization", giftHandler.PrepareGift)
+		v1.POST("/gifts/claim", giftHandler.ClaimGift)
+
+		// Referral operations
+		v1.POST("/referrals", referralHandler.RegisterReferral)
+
+		// Program statistics
+		v1.GET("/stats", loyaltyHandler.GetProgramStats)
+		v1.GET("/leaderboard", loyaltyHandler.GetLeaderboard)
+
+		// Swap operations
+		v1.POST("/swaps", swapHandler.CreateSwap)
+		v1.POST("/swaps/authorization", swapHandler.PrepareSwap)
+		v1.GET("/swaps/:swapID", swapHandler.GetSwap)
+		v1.POST("/swaps/:swapID/settle", swapHandler.SettleSwap)
 	}
 
 	// 5. Start server
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"time"
 )
 
 // Config holds all configuration for the application
@@ -17,6 +18,23 @@ type Config struct {
 	PeerEndpoint   string
 	GatewayPeer    string
 	MSPID          string
+
+	PolicyCacheTTL time.Duration // how long an account's endorsement policy is cached before it is read from the ledger again
+
+	GiftClaimURL       string        // page of the web app that claims a gift; the claim code is appended as a URL fragment
+	GiftRefundInterval time.Duration // how often expired gifts are refunded to their senders
+
+	PendingReleaseInterval time.Duration // how often pending purchase points past their return window are released
+
+	ReferralCodeKey        string        // key used to sign referral codes, so codes need no storage; referrals are disabled when empty
+	ReferralRewardInterval time.Duration // how often referrals whose referee reached the threshold are rewarded
+
+	CounterCompactionInterval time.Duration // how often the previous day's counter shards are folded into the ledger's counters
+
+	PartnerChannelName   string // channel of the partner airline program used for point swaps
+	PartnerChaincodeName string
+	SwapPartnerAccount   string // account of the partner on both ledgers: receives points here, sends miles there
+	SwapSecretKey        string // key used to derive swap secrets, so a restarted backend can finish a swap; swaps are disabled when empty
 }
 
 // LoadConfig loads configuration from environment variables with defaults
@@ -33,6 +51,23 @@ func LoadConfig() *Config {
 		PeerEndpoint:   getEnv("PEER_ENDPOINT", "localhost:7051"),
 		GatewayPeer:    getEnv("GATEWAY_PEER", "peer0.bank.loyalty.com"),
 		MSPID:          getEnv("MSP_ID", "BankOrgMSP"),
+
+		PolicyCacheTTL: getDurationEnv("POLICY_CACHE_TTL", time.Minute),
+
+		GiftClaimURL:       getEnv("GIFT_CLAIM_URL", "http://localhost:3000/gifts/claim"),
+		GiftRefundInterval: getDurationEnv("GIFT_REFUND_INTERVAL", 15*time.Minute),
+
+		PendingReleaseInterval: getDurationEnv("PENDING_RELEASE_INTERVAL", 15*time.Minute),
+
+		ReferralCodeKey:        os.Getenv("REFERRAL_CODE_KEY"), // no default: a known key would let anyone forge referral codes
+		ReferralRewardInterval: getDurationEnv("REFERRAL_REWARD_INTERVAL", 15*time.Minute),
+
+		CounterCompactionInterval: getDurationEnv("COUNTER_COMPACTION_INTERVAL", 6*time.Hour),
+
+		PartnerChannelName:   getEnv("PARTNER_CHANNEL_NAME", "airlinechannel"),
+		PartnerChaincodeName: getEnv("PARTNER_CHAINCODE_NAME", "miles"),
+		SwapPartnerAccount:   getEnv("SWAP_PARTNER_ACCOUNT", "PARTNER-AIRLINE"),
+		SwapSecretKey:        os.Getenv("SWAP_SECRET_KEY"), // no default: a known key would let anyone derive swap secrets
 	}
 }
 
@@ -42,3 +77,10 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
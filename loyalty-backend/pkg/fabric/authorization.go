@@ -0,0 +1,87 @@
+package fabric
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"loyalty-backend/pkg/models"
+)
+
+// TransferPayload builds the canonical payload a customer signs to authorize a transfer.
+// It must match transferPayload in the chaincode byte for byte: one "name=value" line per field.
+func (fc *FabricClient) TransferPayload(sourceCustomerID, targetCustomerID string, amount int64, currency, nonce, expiresAt string) string {
+	return fc.authorizationPayload("TRANSFER", sourceCustomerID, targetCustomerID, amount, currencyOrDefault(currency), nonce, expiresAt)
+}
+
+// GiftPayload builds the canonical payload a customer signs to authorize a gift; the target is the hash of the claim code
+func (fc *FabricClient) GiftPayload(sourceCustomerID, hashOfSecret string, amount int64, nonce, expiresAt string) string {
+	return fc.authorizationPayload("GIFT", sourceCustomerID, hashOfSecret, amount, "POINTS", nonce, expiresAt)
+}
+
+// SwapPayload builds the canonical payload a customer signs to authorize locking points for a swap.
+// It also commits to the recipient, the hash lock and the time lock, so the lock cannot be redirected.
+func (fc *FabricClient) SwapPayload(senderID, swapID, recipientID string, amount int64, hashLock string, timeLock time.Time, nonce, expiresAt string) string {
+	return fc.authorizationPayload("SWAP", senderID, swapID, amount, "POINTS", nonce, expiresAt,
+		[2]string{"recipient", recipientID},
+		[2]string{"hashLock", strings.ToLower(hashLock)},
+		[2]string{"timeLock", timeLock.UTC().Format(time.RFC3339)})
+}
+
+// authorizationPayload writes the payload under the operation's first line, so a signature only authorizes that operation.
+// The operation's own fields (details) follow expiresAt in order.
+func (fc *FabricClient) authorizationPayload(operation, sourceID, targetID string, amount int64, currency, nonce, expiresAt string, details ...[2]string) string {
+	var payload strings.Builder
+	payload.WriteString("LOYALTY-" + operation + "-V1\n")
+	for _, field := range [][2]string{
+		{"channel", fc.ChannelName},
+		{"source", sourceID},
+		{"target", targetID},
+		{"amount", strconv.FormatInt(amount, 10)},
+		{"currency", currency},
+		{"nonce", nonce},
+		{"expiresAt", expiresAt},
+	} {
+		payload.WriteString(field[0] + "=" + field[1] + "\n")
+	}
+	for _, field := range details {
+		payload.WriteString(field[0] + "=" + field[1] + "\n")
+	}
+	return payload.String()
+}
+
+// transferAuthorizationArgs returns the nonce, expiry and signature arguments of TransferPoints, CreateGift and LockForSwap;
+// they are empty for accounts without a signing key
+func transferAuthorizationArgs(authorization *models.TransferAuthorization) []string {
+	if authorization == nil {
+		return []string{"", "", ""}
+	}
+	return []string{authorization.Nonce, authorization.ExpiresAt, authorization.Signature}
+}
+
+// RegisterSigningKey registers the public key that signs the customer's transfer authorizations.
+// Replacing a registered key requires an admin identity.
+func (fc *FabricClient) RegisterSigningKey(customerID, publicKeyPEM string) (*models.LoyaltyAccount, error) {
+	orgs, err := fc.endorsingOrgs(customerID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Registering signing key for customer: %s, endorsed by %v", customerID, orgs)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.Submit("RegisterSigningKey",
+	//     client.WithArguments(customerID, publicKeyPEM), client.WithEndorsingOrganizations(orgs...))
+
+	// Return simulated updated account
+	account := &models.LoyaltyAccount{
+		CustomerID:  customerID,
+		Balance:     1500,
+		LastUpdated: "2025-07-25T04:10:00Z",
+		SigningKey:  strings.TrimSpace(publicKeyPEM),
+	}
+
+	log.Printf("Signing key registered on blockchain for customer: %s", customerID)
+	return account, nil
+}
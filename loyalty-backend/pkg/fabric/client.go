@@ -5,10 +5,15 @@ import (
 	"log"
 	"loyalty-backend/pkg/config"
 	"loyalty-backend/pkg/models"
+	"time"
 )
 
 type FabricClient struct {
-	Contract *ContractStub
+	Contract      *ContractStub
+	ChannelName   string
+	ChaincodeName string
+
+	policies accountPolicies // key-level endorsement policies of accounts, see endorsement.go
 }
 
 type ContractStub struct{}
@@ -66,13 +71,47 @@ func (fc *FabricClient) GetLoyaltyAccount(customerID string) (*models.LoyaltyAcc
 	return account, nil
 }
 
-// IssuePoints issues loyalty points on blockchain
-func (fc *FabricClient) IssuePoints(customerID string, amount int, description string) (*models.LoyaltyAccount, error) {
-	log.Printf("Issuing %d points to customer: %s", amount, customerID)
+// GetBalanceAt retrieves the balance of a loyalty account at a point in time from the ledger history
+func (fc *FabricClient) GetBalanceAt(customerID string, asOf time.Time) (*models.BalanceAt, error) {
+	log.Printf("Getting balance for customer %s as of %s", customerID, asOf.Format(time.RFC3339))
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.EvaluateTransaction("GetBalanceAt", customerID, asOf.Format(time.RFC3339))
+
+	// Return simulated balance from blockchain history
+	balance := &models.BalanceAt{
+		CustomerID:    customerID,
+		AsOf:          asOf.Format(time.RFC3339),
+		Balance:       1500, // Simulated balance from blockchain
+		TransactionID: "a3f1c9e27b5d40e8b6f2d91c7e0a4b58c3d6e9f1a2b4c7d0e3f5a8b1c4d7e0f2",
+		Timestamp:     "2025-07-25T04:10:00Z",
+	}
+
+	log.Printf("Historical balance retrieved from blockchain: %+v", balance)
+	return balance, nil
+}
+
+// currencyOrDefault returns the currency the chaincode applies when none is given
+func currencyOrDefault(currency string) string {
+	if currency == "" {
+		return "POINTS"
+	}
+	return currency
+}
+
+// IssuePoints issues loyalty points on blockchain; an empty currency means POINTS
+func (fc *FabricClient) IssuePoints(customerID string, amount int64, description, currency string) (*models.LoyaltyAccount, error) {
+	orgs, err := fc.endorsingOrgs(customerID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Issuing %d %s to customer: %s, endorsed by %v", amount, currencyOrDefault(currency), customerID, orgs)
 	
 	// For now, simulate blockchain call
 	// In real implementation, this would call:
-	// result, err := fc.Contract.SubmitTransaction("IssuePoints", customerID, strconv.Itoa(amount), description)
+	// result, err := fc.Contract.Submit("IssuePoints",
+	//     client.WithArguments(customerID, strconv.FormatInt(amount, 10), description, currency), client.WithEndorsingOrganizations(orgs...))
 	
 	// Return simulated updated account
 	account := &models.LoyaltyAccount{
@@ -85,13 +124,18 @@ func (fc *FabricClient) IssuePoints(customerID string, amount int, description s
 	return account, nil
 }
 
-// RedeemPoints redeems loyalty points on blockchain
-func (fc *FabricClient) RedeemPoints(customerID string, amount int, description string) (*models.LoyaltyAccount, error) {
-	log.Printf("Redeeming %d points from customer: %s", amount, customerID)
+// RedeemPoints redeems loyalty points on blockchain; an empty currency means POINTS
+func (fc *FabricClient) RedeemPoints(customerID string, amount int64, description, currency string) (*models.LoyaltyAccount, error) {
+	orgs, err := fc.endorsingOrgs(customerID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Redeeming %d %s from customer: %s, endorsed by %v", amount, currencyOrDefault(currency), customerID, orgs)
 	
 	// For now, simulate blockchain call
 	// In real implementation, this would call:
-	// result, err := fc.Contract.SubmitTransaction("RedeemPoints", customerID, strconv.Itoa(amount), description)
+	// result, err := fc.Contract.Submit("RedeemPoints",
+	//     client.WithArguments(customerID, strconv.FormatInt(amount, 10), description, currency), client.WithEndorsingOrganizations(orgs...))
 	
 	// Return simulated updated account
 	account := &models.LoyaltyAccount{
@@ -104,13 +148,22 @@ func (fc *FabricClient) RedeemPoints(customerID string, amount int, description
 	return account, nil
 }
 
-// TransferPoints transfers loyalty points between accounts on blockchain
-func (fc *FabricClient) TransferPoints(sourceCustomerID, targetCustomerID string, amount int, description string) (map[string]*models.LoyaltyAccount, error) {
-	log.Printf("Transferring %d points from %s to %s", amount, sourceCustomerID, targetCustomerID)
+// TransferPoints transfers loyalty points between accounts on blockchain; an empty currency means POINTS.
+// The authorization is the customer's signed consent and is required once the source account has a signing key.
+// A non-nil flagged transfer means the transfer tripped a velocity rule: the points are held until a reviewer decides.
+func (fc *FabricClient) TransferPoints(sourceCustomerID, targetCustomerID string, amount int64, description, currency string, authorization *models.TransferAuthorization) (map[string]*models.LoyaltyAccount, *models.FlaggedTransfer, error) {
+	orgs, err := fc.endorsingOrgs(sourceCustomerID, targetCustomerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	authorizationArgs := transferAuthorizationArgs(authorization)
+	log.Printf("Transferring %d %s from %s to %s, endorsed by %v, authorization nonce %q", amount, currencyOrDefault(currency), sourceCustomerID, targetCustomerID, orgs, authorizationArgs[0])
 	
 	// For now, simulate blockchain call
 	// In real implementation, this would call:
-	// result, err := fc.Contract.SubmitTransaction("TransferPoints", sourceCustomerID, targetCustomerID, strconv.Itoa(amount), description)
+	// result, err := fc.Contract.Submit("TransferPoints",
+	//     client.WithArguments(append([]string{sourceCustomerID, targetCustomerID, strconv.FormatInt(amount, 10), description, currency}, authorizationArgs...)...),
+	//     client.WithEndorsingOrganizations(orgs...))
 	
 	// Return simulated updated accounts
 	result := map[string]*models.LoyaltyAccount{
@@ -127,7 +180,7 @@ func (fc *FabricClient) TransferPoints(sourceCustomerID, targetCustomerID string
 	}
 	
 	log.Printf("Points transferred on blockchain: %+v", result)
-	return result, nil
+	return result, nil, nil
 }
 
 // GetLoyaltyHistory retrieves transaction history from blockchain
@@ -181,6 +234,372 @@ func (fc *FabricClient) GetLoyaltyHistory(customerID string) ([]map[string]inter
 	return history, nil
 }
 
+// GetVouchersByOwner retrieves all vouchers owned by a customer from blockchain
+func (fc *FabricClient) GetVouchersByOwner(customerID string) ([]models.Voucher, error) {
+	log.Printf("Getting vouchers for customer: %s", customerID)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.EvaluateTransaction("RewardContract:GetVouchersByOwner", customerID)
+
+	// Return simulated vouchers from blockchain
+	vouchers := []models.Voucher{
+		{
+			VoucherID:  "VCH-3F2A9C1B7D4E6A80",
+			OwnerID:    customerID,
+			RewardID:   "RWD001",
+			Value:      5.00,
+			PointsCost: 500,
+			IssuedAt:   "2025-07-24T04:10:00Z",
+			ExpiresAt:  "2025-08-23T04:10:00Z",
+			Status:     "ISSUED",
+		},
+	}
+
+	log.Printf("Vouchers retrieved from blockchain: %d vouchers", len(vouchers))
+	return vouchers, nil
+}
+
+// GetVoucher retrieves a single voucher from blockchain
+func (fc *FabricClient) GetVoucher(voucherID string) (*models.Voucher, error) {
+	log.Printf("Getting voucher: %s", voucherID)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.EvaluateTransaction("RewardContract:GetVoucher", voucherID)
+
+	// Return simulated voucher from blockchain
+	voucher := &models.Voucher{
+		VoucherID:  voucherID,
+		OwnerID:    "CUST001",
+		RewardID:   "RWD001",
+		Value:      5.00,
+		PointsCost: 500,
+		IssuedAt:   "2025-07-24T04:10:00Z",
+		ExpiresAt:  "2025-08-23T04:10:00Z",
+		Status:     "ISSUED",
+	}
+
+	log.Printf("Voucher retrieved from blockchain: %+v", voucher)
+	return voucher, nil
+}
+
+// CreateGift escrows points from the source account until the gift is claimed or expires.
+// The authorization is required once the source account has a signing key (see GiftPayload).
+func (fc *FabricClient) CreateGift(sourceCustomerID string, amount int64, hashOfSecret string, expiresAt time.Time, authorization *models.TransferAuthorization) (*models.Gift, error) {
+	orgs, err := fc.endorsingOrgs(sourceCustomerID)
+	if err != nil {
+		return nil, err
+	}
+	authorizationArgs := transferAuthorizationArgs(authorization)
+	log.Printf("Creating gift of %d points from customer: %s, endorsed by %v, authorization nonce %q", amount, sourceCustomerID, orgs, authorizationArgs[0])
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.Submit("CreateGift",
+	//     client.WithArguments(append([]string{sourceCustomerID, strconv.FormatInt(amount, 10), hashOfSecret, expiresAt.UTC().Format(time.RFC3339)}, authorizationArgs...)...),
+	//     client.WithEndorsingOrganizations(orgs...))
+
+	// Return simulated gift from blockchain
+	gift := &models.Gift{
+		GiftID:    hashOfSecret,
+		SourceID:  sourceCustomerID,
+		Amount:    amount,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+		Status:    "PENDING",
+	}
+
+	log.Printf("Gift created on blockchain: %+v", gift)
+	return gift, nil
+}
+
+// ClaimGift releases the gift matching the claim code to the target account
+func (fc *FabricClient) ClaimGift(claimCode, targetCustomerID string) (*models.Gift, error) {
+	orgs, err := fc.endorsingOrgs(targetCustomerID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Claiming gift for customer: %s, endorsed by %v", targetCustomerID, orgs)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.Submit("ClaimGift", client.WithArguments(claimCode, targetCustomerID), client.WithEndorsingOrganizations(orgs...))
+
+	// Return simulated claimed gift from blockchain
+	gift := &models.Gift{
+		GiftID:    "simulated",
+		SourceID:  "CUST001",
+		Amount:    100,
+		CreatedAt: "2025-07-24T04:10:00Z",
+		ExpiresAt: "2025-08-23T04:10:00Z",
+		Status:    "CLAIMED",
+		ClaimedBy: targetCustomerID,
+		ClosedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+
+	log.Printf("Gift claimed on blockchain: %+v", gift)
+	return gift, nil
+}
+
+// RefundExpiredGifts returns up to `limit` expired gifts to their senders
+// The refunded senders are only known on-chain, so every org of an account policy on the ledger endorses the batch.
+func (fc *FabricClient) RefundExpiredGifts(limit int) ([]models.Gift, error) {
+	orgs, err := fc.batchEndorsingOrgs()
+	if err != nil {
+		return nil, err
+	}
+	if len(orgs) > 0 {
+		log.Printf("Refunding expired gifts, endorsed by %v", orgs)
+	}
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.Submit("RefundExpiredGifts", client.WithArguments(strconv.Itoa(limit)), client.WithEndorsingOrganizations(orgs...))
+
+	return []models.Gift{}, nil
+}
+
+// StartGiftRefunds periodically refunds expired gifts until the process exits.
+// The chaincode cannot act on its own, so the refund after expiry is driven from here.
+func (fc *FabricClient) StartGiftRefunds(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			processed, err := fc.RefundExpiredGifts(0)
+			if err != nil {
+				log.Printf("Error refunding expired gifts: %v", err)
+				continue
+			}
+			refunded := 0
+			for _, gift := range processed {
+				if gift.Status == "REFUND_FAILED" {
+					log.Printf("Gift %s of %s could not be refunded: %s", gift.GiftID, gift.SourceID, gift.FailureReason)
+					continue
+				}
+				refunded++
+			}
+			if refunded > 0 {
+				log.Printf("Refunded %d expired gifts", refunded)
+			}
+		}
+	}()
+}
+
+// AwardPendingPoints records purchase points that become spendable at releaseAt, after the return window
+func (fc *FabricClient) AwardPendingPoints(customerID, awardID string, amount int64, releaseAt time.Time, description string) (*models.PendingAward, error) {
+	log.Printf("Awarding %d pending points to customer %s for purchase %s", amount, customerID, awardID)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.SubmitTransaction("AwardPendingPoints", customerID, awardID, strconv.FormatInt(amount, 10), releaseAt.UTC().Format(time.RFC3339), description)
+
+	// Return simulated pending award from blockchain
+	award := &models.PendingAward{
+		AwardID:     awardID,
+		CustomerID:  customerID,
+		Amount:      amount,
+		Description: description,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+		ReleaseAt:   releaseAt.UTC().Format(time.RFC3339),
+		Status:      "PENDING",
+	}
+
+	log.Printf("Pending award recorded on blockchain: %+v", award)
+	return award, nil
+}
+
+// CancelPendingPoints cancels pending points of a returned purchase
+func (fc *FabricClient) CancelPendingPoints(customerID, awardID string) (*models.PendingAward, error) {
+	log.Printf("Cancelling pending award %s of customer %s", awardID, customerID)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.SubmitTransaction("CancelPending", customerID, awardID)
+
+	// Return simulated cancelled award from blockchain
+	award := &models.PendingAward{
+		AwardID:    awardID,
+		CustomerID: customerID,
+		Amount:     100,
+		CreatedAt:  "2025-07-24T04:10:00Z",
+		ReleaseAt:  "2025-08-23T04:10:00Z",
+		Status:     "CANCELLED",
+		ClosedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	log.Printf("Pending award cancelled on blockchain: %+v", award)
+	return award, nil
+}
+
+// ReleasePendingPoints moves up to `limit` pending awards past their release date into spendable balances.
+// Awards whose account cannot be credited come back with status FAILED and leave the release queue.
+// The credited accounts are only known on-chain, so every org of an account policy on the ledger endorses the batch.
+func (fc *FabricClient) ReleasePendingPoints(limit int) ([]models.PendingAward, error) {
+	orgs, err := fc.batchEndorsingOrgs()
+	if err != nil {
+		return nil, err
+	}
+	if len(orgs) > 0 {
+		log.Printf("Releasing pending points, endorsed by %v", orgs)
+	}
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.Submit("ReleasePending", client.WithArguments(strconv.Itoa(limit)), client.WithEndorsingOrganizations(orgs...))
+
+	return []models.PendingAward{}, nil
+}
+
+// StartPendingReleases periodically releases matured pending points until the process exits
+func (fc *FabricClient) StartPendingReleases(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			processed, err := fc.ReleasePendingPoints(0)
+			if err != nil {
+				log.Printf("Error releasing pending points: %v", err)
+				continue
+			}
+			released := 0
+			for _, award := range processed {
+				if award.Status == "FAILED" {
+					log.Printf("Pending award %s of %s could not be released: %s", award.AwardID, award.CustomerID, award.FailureReason)
+					continue
+				}
+				released++
+			}
+			if released > 0 {
+				log.Printf("Released %d pending awards", released)
+			}
+		}
+	}()
+}
+
+// GetPointsBalance retrieves the available and pending points of an account
+func (fc *FabricClient) GetPointsBalance(customerID string) (*models.PointsBalance, error) {
+	log.Printf("Getting points balance for customer: %s", customerID)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.EvaluateTransaction("GetPointsBalance", customerID)
+
+	// Return simulated balance from blockchain
+	balance := &models.PointsBalance{
+		CustomerID:    customerID,
+		Available:     1500, // Simulated balance from blockchain
+		Pending:       0,
+		PendingAwards: []models.PendingAward{},
+	}
+
+	log.Printf("Points balance retrieved from blockchain: %+v", balance)
+	return balance, nil
+}
+
+// LockForSwap locks points (or partner miles) for a hash time-locked swap.
+// The authorization is required once the sender has a signing key (see SwapPayload).
+func (fc *FabricClient) LockForSwap(swapID, senderID, recipientID string, amount int64, hashLock string, timeLock time.Time, authorization *models.TransferAuthorization) (*models.SwapLock, error) {
+	orgs, err := fc.endorsingOrgs(senderID)
+	if err != nil {
+		return nil, err
+	}
+	authorizationArgs := transferAuthorizationArgs(authorization)
+	log.Printf("Locking %d on channel %s for swap %s from %s to %s, endorsed by %v, authorization nonce %q", amount, fc.ChannelName, swapID, senderID, recipientID, orgs, authorizationArgs[0])
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.Submit("SwapContract:LockForSwap",
+	//     client.WithArguments(append([]string{swapID, senderID, recipientID, strconv.FormatInt(amount, 10), hashLock, timeLock.UTC().Format(time.RFC3339)}, authorizationArgs...)...),
+	//     client.WithEndorsingOrganizations(orgs...))
+
+	// Return simulated lock from blockchain
+	lock := &models.SwapLock{
+		SwapID:      swapID,
+		SenderID:    senderID,
+		RecipientID: recipientID,
+		Amount:      amount,
+		HashLock:    hashLock,
+		TimeLock:    timeLock.UTC().Format(time.RFC3339),
+		Status:      "LOCKED",
+		LockedAt:    time.Now().UTC().Format(time.RFC3339),
+	}
+
+	log.Printf("Swap locked on blockchain: %+v", lock)
+	return lock, nil
+}
+
+// ClaimSwap releases a swap lock to its recipient by revealing the secret
+func (fc *FabricClient) ClaimSwap(swapID, secret string) (*models.SwapLock, error) {
+	lock, err := fc.GetSwap(swapID)
+	if err != nil {
+		return nil, err
+	}
+	orgs, err := fc.endorsingOrgs(lock.RecipientID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Claiming swap %s on channel %s, endorsed by %v", swapID, fc.ChannelName, orgs)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.Submit("SwapContract:Claim", client.WithArguments(swapID, secret), client.WithEndorsingOrganizations(orgs...))
+
+	lock.Status = "CLAIMED"
+	lock.Secret = secret
+	lock.SettledAt = time.Now().UTC().Format(time.RFC3339)
+
+	log.Printf("Swap claimed on blockchain: %+v", lock)
+	return lock, nil
+}
+
+// RefundSwap returns an expired swap lock to its sender
+func (fc *FabricClient) RefundSwap(swapID string) (*models.SwapLock, error) {
+	lock, err := fc.GetSwap(swapID)
+	if err != nil {
+		return nil, err
+	}
+	orgs, err := fc.endorsingOrgs(lock.SenderID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Refunding swap %s on channel %s, endorsed by %v", swapID, fc.ChannelName, orgs)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.Submit("SwapContract:Refund", client.WithArguments(swapID), client.WithEndorsingOrganizations(orgs...))
+
+	lock.Status = "REFUNDED"
+	lock.SettledAt = time.Now().UTC().Format(time.RFC3339)
+
+	log.Printf("Swap refunded on blockchain: %+v", lock)
+	return lock, nil
+}
+
+// GetSwap retrieves a swap lock; a missing lock is reported as a SWAP_NOT_FOUND chaincode error
+func (fc *FabricClient) GetSwap(swapID string) (*models.SwapLock, error) {
+	log.Printf("Getting swap %s on channel %s", swapID, fc.ChannelName)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.EvaluateTransaction("SwapContract:GetSwap", swapID)
+
+	// Return simulated lock from blockchain
+	lock := &models.SwapLock{
+		SwapID:      swapID,
+		SenderID:    "CUST001",
+		RecipientID: "PARTNER-AIRLINE",
+		Amount:      1000,
+		TimeLock:    time.Now().UTC().Add(24 * time.Hour).Format(time.RFC3339),
+		Status:      "LOCKED",
+		LockedAt:    time.Now().UTC().Format(time.RFC3339),
+	}
+
+	log.Printf("Swap retrieved from blockchain: %+v", lock)
+	return lock, nil
+}
+
 // SubmitTransaction stub for standalone mode
 func (c *ContractStub) SubmitTransaction(name string, args ...string) ([]byte, error) {
 	return nil, fmt.Errorf("Fabric contract not available in standalone mode")
@@ -196,7 +615,23 @@ func NewFabricClient(cfg *config.Config) (*FabricClient, error) {
 	log.Println("Creating new Fabric client with simulated blockchain integration")
 	
 	return &FabricClient{
-		Contract: &ContractStub{},
+		Contract:      &ContractStub{},
+		ChannelName:   cfg.ChannelName,
+		ChaincodeName: cfg.ChaincodeName,
+		policies:      accountPolicies{ttl: cfg.PolicyCacheTTL},
+	}, nil
+}
+
+// NewPartnerFabricClient creates a Fabric client for the partner program's channel.
+// The partner chaincode is expected to expose the same SwapContract functions as the loyalty chaincode.
+func NewPartnerFabricClient(cfg *config.Config) (*FabricClient, error) {
+	log.Printf("Creating Fabric client for partner channel %s with simulated blockchain integration", cfg.PartnerChannelName)
+
+	return &FabricClient{
+		Contract:      &ContractStub{},
+		ChannelName:   cfg.PartnerChannelName,
+		ChaincodeName: cfg.PartnerChaincodeName,
+		policies:      accountPolicies{ttl: cfg.PolicyCacheTTL},
 	}, nil
 }
 
@@ -0,0 +1,140 @@
+package fabric
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"loyalty-backend/pkg/models"
+)
+
+// accountPolicies caches the key-level endorsement policies of accounts so that submissions touching
+// high-value accounts are sent to every org those accounts require. Accounts without a policy are
+// endorsed under the chaincode policy (the bank only) and are cached with an empty org list.
+// Policies can be changed by another backend instance or an admin CLI, so entries expire after ttl.
+type accountPolicies struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[string]cachedPolicy
+}
+
+type cachedPolicy struct {
+	orgs      []string
+	fetchedAt time.Time
+}
+
+func (p *accountPolicies) get(customerID string) ([]string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	entry, found := p.entries[customerID]
+	if !found || time.Since(entry.fetchedAt) >= p.ttl {
+		return nil, false
+	}
+	return entry.orgs, true
+}
+
+func (p *accountPolicies) set(customerID string, orgs []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.entries == nil {
+		p.entries = map[string]cachedPolicy{}
+	}
+	p.entries[customerID] = cachedPolicy{orgs: orgs, fetchedAt: time.Now()}
+}
+
+// GetAccountPolicy retrieves the orgs that must endorse changes to an account.
+// An empty list means the account uses the chaincode endorsement policy.
+func (fc *FabricClient) GetAccountPolicy(customerID string) (*models.AccountPolicy, error) {
+	if orgs, found := fc.policies.get(customerID); found {
+		return &models.AccountPolicy{CustomerID: customerID, EndorsingOrgs: orgs}, nil
+	}
+
+	log.Printf("Getting endorsement policy for customer: %s", customerID)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.EvaluateTransaction("GetAccountPolicy", customerID)
+
+	// Return simulated policy: no key-level policy attached
+	policy := &models.AccountPolicy{
+		CustomerID:    customerID,
+		EndorsingOrgs: []string{},
+	}
+
+	fc.policies.set(customerID, policy.EndorsingOrgs)
+	return policy, nil
+}
+
+// SetAccountPolicy attaches a key-level endorsement policy to an account; an empty list removes it.
+// Changing a policy must itself satisfy the current one, so the current orgs endorse the change.
+func (fc *FabricClient) SetAccountPolicy(customerID string, endorsingOrgs []string) (*models.AccountPolicy, error) {
+	orgs, err := fc.endorsingOrgs(customerID)
+	if err != nil {
+		return nil, err
+	}
+	orgsJSON, err := json.Marshal(endorsingOrgs)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Setting endorsement policy %s for customer %s, endorsed by %v", orgsJSON, customerID, orgs)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.Submit("AdminContract:SetAccountPolicy",
+	//     client.WithArguments(customerID, string(orgsJSON)), client.WithEndorsingOrganizations(orgs...))
+
+	// Return simulated policy from blockchain
+	policy := &models.AccountPolicy{
+		CustomerID:    customerID,
+		EndorsingOrgs: append([]string{}, mergeOrgs(nil, endorsingOrgs)...),
+	}
+
+	fc.policies.set(customerID, policy.EndorsingOrgs)
+	log.Printf("Endorsement policy set on blockchain: %+v", policy)
+	return policy, nil
+}
+
+// endorsingOrgs returns the orgs a transaction writing the given accounts must be endorsed by.
+// The result is nil when none of the accounts has a key-level policy, so the gateway applies the
+// chaincode endorsement policy as usual.
+func (fc *FabricClient) endorsingOrgs(customerIDs ...string) ([]string, error) {
+	var orgs []string
+	for _, customerID := range customerIDs {
+		policy, err := fc.GetAccountPolicy(customerID)
+		if err != nil {
+			return nil, err
+		}
+		orgs = mergeOrgs(orgs, policy.EndorsingOrgs)
+	}
+	return orgs, nil
+}
+
+// batchEndorsingOrgs returns every org required by an account policy on the ledger, for batch jobs whose
+// accounts are only known on-chain. It is read from the chaincode because the local cache only holds the
+// accounts this process has looked up since it started.
+func (fc *FabricClient) batchEndorsingOrgs() ([]string, error) {
+	log.Printf("Getting the orgs of all account endorsement policies")
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.EvaluateTransaction("GetPolicyOrgs")
+
+	// Return simulated orgs: no account has a key-level policy
+	return nil, nil
+}
+
+// mergeOrgs returns the sorted union of two org lists
+func mergeOrgs(orgs, more []string) []string {
+	for _, org := range more {
+		index := sort.SearchStrings(orgs, org)
+		if index < len(orgs) && orgs[index] == org {
+			continue
+		}
+		orgs = append(orgs, "")
+		copy(orgs[index+1:], orgs[index:])
+		orgs[index] = org
+	}
+	return orgs
+}
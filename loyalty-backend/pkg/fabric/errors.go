@@ -0,0 +1,150 @@
+package fabric
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Error codes returned by the loyalty chaincode (see loyalty-chaincode/errors.go).
+// ErrCodeInternal is used by the backend for failures that carry no chaincode error code.
+const (
+	ErrCodeInternal               = "INTERNAL"
+	ErrCodeInvalidArgument        = "INVALID_ARGUMENT"
+	ErrCodeAccessDenied           = "ACCESS_DENIED"
+	ErrCodeUnknownTransaction     = "UNKNOWN_TRANSACTION"
+	ErrCodeAccountNotFound        = "ACCOUNT_NOT_FOUND"
+	ErrCodeAccountAlreadyExists   = "ACCOUNT_ALREADY_EXISTS"
+	ErrCodeAccountClosed          = "ACCOUNT_CLOSED"
+	ErrCodeInsufficientBalance    = "INSUFFICIENT_BALANCE"
+	ErrCodeInsufficientAllowance  = "INSUFFICIENT_ALLOWANCE"
+	ErrCodeAmountLimitExceeded    = "AMOUNT_LIMIT_EXCEEDED"
+	ErrCodeRewardNotFound         = "REWARD_NOT_FOUND"
+	ErrCodeRewardAlreadyExists    = "REWARD_ALREADY_EXISTS"
+	ErrCodeRewardUnavailable      = "REWARD_UNAVAILABLE"
+	ErrCodeCampaignNotFound       = "CAMPAIGN_NOT_FOUND"
+	ErrCodeCampaignAlreadyExists  = "CAMPAIGN_ALREADY_EXISTS"
+	ErrCodeVoucherNotFound        = "VOUCHER_NOT_FOUND"
+	ErrCodeVoucherAlreadyExists   = "VOUCHER_ALREADY_EXISTS"
+	ErrCodeVoucherNotUsable       = "VOUCHER_NOT_USABLE"
+	ErrCodeHouseholdNotFound      = "HOUSEHOLD_NOT_FOUND"
+	ErrCodeHouseholdAlreadyExists = "HOUSEHOLD_ALREADY_EXISTS"
+	ErrCodeGiftNotFound           = "GIFT_NOT_FOUND"
+	ErrCodeGiftAlreadyExists      = "GIFT_ALREADY_EXISTS"
+	ErrCodeGiftNotClaimable       = "GIFT_NOT_CLAIMABLE"
+	ErrCodeSwapNotFound           = "SWAP_NOT_FOUND"
+	ErrCodeSwapAlreadyExists      = "SWAP_ALREADY_EXISTS"
+	ErrCodeSwapNotClaimable       = "SWAP_NOT_CLAIMABLE"
+	ErrCodeSwapNotRefundable      = "SWAP_NOT_REFUNDABLE"
+
+	ErrCodePendingAwardNotFound      = "PENDING_AWARD_NOT_FOUND"
+	ErrCodePendingAwardAlreadyExists = "PENDING_AWARD_ALREADY_EXISTS"
+	ErrCodePendingAwardClosed        = "PENDING_AWARD_CLOSED"
+	ErrCodeCurrencyNotAllowed        = "CURRENCY_NOT_ALLOWED"
+	ErrCodeBalanceNotExpired         = "BALANCE_NOT_EXPIRED"
+	ErrCodeInvalidSignature          = "INVALID_SIGNATURE"
+	ErrCodeNonceAlreadyUsed          = "NONCE_ALREADY_USED"
+	ErrCodeAuthorizationExpired      = "AUTHORIZATION_EXPIRED"
+	ErrCodeAuthorizationNotFound     = "AUTHORIZATION_NOT_FOUND"
+	ErrCodeFlaggedTransferNotFound   = "FLAGGED_TRANSFER_NOT_FOUND"
+	ErrCodeTransferNotFlagged        = "TRANSFER_NOT_FLAGGED"
+	ErrCodeVelocityLimitExceeded     = "VELOCITY_LIMIT_EXCEEDED"
+
+	ErrCodeReferralNotFound      = "REFERRAL_NOT_FOUND"
+	ErrCodeReferralAlreadyExists = "REFERRAL_ALREADY_EXISTS"
+	ErrCodeReferralLimitReached  = "REFERRAL_LIMIT_REACHED"
+)
+
+// errorStatuses maps chaincode error codes to HTTP statuses; unknown codes map to 500
+var errorStatuses = map[string]int{
+	ErrCodeInvalidArgument:        http.StatusUnprocessableEntity,
+	ErrCodeAccessDenied:           http.StatusForbidden,
+	ErrCodeAccountNotFound:        http.StatusNotFound,
+	ErrCodeAccountAlreadyExists:   http.StatusConflict,
+	ErrCodeAccountClosed:          http.StatusConflict,
+	ErrCodeInsufficientBalance:    http.StatusUnprocessableEntity,
+	ErrCodeInsufficientAllowance:  http.StatusUnprocessableEntity,
+	ErrCodeAmountLimitExceeded:    http.StatusUnprocessableEntity,
+	ErrCodeRewardNotFound:         http.StatusNotFound,
+	ErrCodeRewardAlreadyExists:    http.StatusConflict,
+	ErrCodeRewardUnavailable:      http.StatusConflict,
+	ErrCodeCampaignNotFound:       http.StatusNotFound,
+	ErrCodeCampaignAlreadyExists:  http.StatusConflict,
+	ErrCodeVoucherNotFound:        http.StatusNotFound,
+	ErrCodeVoucherAlreadyExists:   http.StatusConflict,
+	ErrCodeVoucherNotUsable:       http.StatusConflict,
+	ErrCodeHouseholdNotFound:      http.StatusNotFound,
+	ErrCodeHouseholdAlreadyExists: http.StatusConflict,
+	ErrCodeGiftNotFound:           http.StatusNotFound,
+	ErrCodeGiftAlreadyExists:      http.StatusConflict,
+	ErrCodeGiftNotClaimable:       http.StatusConflict,
+	ErrCodeSwapNotFound:           http.StatusNotFound,
+	ErrCodeSwapAlreadyExists:      http.StatusConflict,
+	ErrCodeSwapNotClaimable:       http.StatusConflict,
+	ErrCodeSwapNotRefundable:      http.StatusConflict,
+
+	ErrCodePendingAwardNotFound:      http.StatusNotFound,
+	ErrCodePendingAwardAlreadyExists: http.StatusConflict,
+	ErrCodePendingAwardClosed:        http.StatusConflict,
+	ErrCodeCurrencyNotAllowed:        http.StatusUnprocessableEntity,
+	ErrCodeBalanceNotExpired:         http.StatusConflict,
+	ErrCodeInvalidSignature:          http.StatusForbidden,
+	ErrCodeNonceAlreadyUsed:          http.StatusConflict,
+	ErrCodeAuthorizationExpired:      http.StatusConflict,
+	ErrCodeAuthorizationNotFound:     http.StatusNotFound,
+	ErrCodeFlaggedTransferNotFound:   http.StatusNotFound,
+	ErrCodeTransferNotFlagged:        http.StatusConflict,
+	ErrCodeVelocityLimitExceeded:     http.StatusTooManyRequests,
+
+	ErrCodeReferralNotFound:      http.StatusNotFound,
+	ErrCodeReferralAlreadyExists: http.StatusConflict,
+	ErrCodeReferralLimitReached:  http.StatusConflict,
+}
+
+// ChaincodeError is the structured error returned by the loyalty chaincode
+type ChaincodeError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Error implements the error interface
+func (e *ChaincodeError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// HTTPStatus returns the HTTP status matching the error code
+func (e *ChaincodeError) HTTPStatus() int {
+	if status, ok := errorStatuses[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// ParseChaincodeError extracts the structured chaincode error from a transaction error.
+// Fabric wraps the chaincode message in its own text (e.g. "endorsement failure ... message: {...}"),
+// so the JSON object is located inside the message. Returns nil if the error carries no chaincode error code.
+func ParseChaincodeError(err error) *ChaincodeError {
+	if err == nil {
+		return nil
+	}
+	if chaincodeErr, ok := err.(*ChaincodeError); ok {
+		return chaincodeErr
+	}
+
+	message := err.Error()
+	for offset := 0; offset < len(message); {
+		start := strings.Index(message[offset:], `{"code"`)
+		if start == -1 {
+			return nil
+		}
+		start += offset
+
+		var chaincodeErr ChaincodeError
+		if err := json.NewDecoder(strings.NewReader(message[start:])).Decode(&chaincodeErr); err == nil && chaincodeErr.Code != "" {
+			return &chaincodeErr
+		}
+		offset = start + 1
+	}
+	return nil
+}
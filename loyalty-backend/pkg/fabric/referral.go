@@ -0,0 +1,90 @@
+package fabric
+
+import (
+	"log"
+	"time"
+
+	"loyalty-backend/pkg/models"
+)
+
+// RegisterReferral records that referrerID referred refereeID.
+// The referee's account is updated, so its endorsement policy applies.
+func (fc *FabricClient) RegisterReferral(referrerID, refereeID string) (*models.Referral, error) {
+	orgs, err := fc.endorsingOrgs(refereeID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Registering referral of customer %s by %s, endorsed by %v", refereeID, referrerID, orgs)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.Submit("RegisterReferral",
+	//     client.WithArguments(referrerID, refereeID), client.WithEndorsingOrganizations(orgs...))
+
+	// Return simulated referral from blockchain
+	referral := &models.Referral{
+		RefereeID:  refereeID,
+		ReferrerID: referrerID,
+		Status:     "PENDING",
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+
+	log.Printf("Referral registered on blockchain: %+v", referral)
+	return referral, nil
+}
+
+// GetReferrals retrieves the customers referred by referrerID
+func (fc *FabricClient) GetReferrals(referrerID string) ([]models.Referral, error) {
+	log.Printf("Getting referrals of customer: %s", referrerID)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.EvaluateTransaction("QueryReferrals", referrerID)
+
+	return []models.Referral{}, nil
+}
+
+// RewardReferrals pays up to `limit` referrals whose referee has reached the program threshold
+// The rewarded accounts are only known on-chain, so every org of an account policy on the ledger endorses the batch.
+func (fc *FabricClient) RewardReferrals(limit int) ([]models.Referral, error) {
+	orgs, err := fc.batchEndorsingOrgs()
+	if err != nil {
+		return nil, err
+	}
+	if len(orgs) > 0 {
+		log.Printf("Rewarding referrals, endorsed by %v", orgs)
+	}
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.Submit("RewardReferrals", client.WithArguments(strconv.Itoa(limit)), client.WithEndorsingOrganizations(orgs...))
+
+	return []models.Referral{}, nil
+}
+
+// StartReferralRewards periodically rewards qualified referrals until the process exits.
+// Paying inside the transaction that crosses the threshold would replace its event, so rewards run as a batch.
+func (fc *FabricClient) StartReferralRewards(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			processed, err := fc.RewardReferrals(0)
+			if err != nil {
+				log.Printf("Error rewarding referrals: %v", err)
+				continue
+			}
+			rewarded := 0
+			for _, referral := range processed {
+				if referral.Status == "REWARD_FAILED" {
+					log.Printf("Referral of %s by %s could not be rewarded: %s", referral.RefereeID, referral.ReferrerID, referral.FailureReason)
+					continue
+				}
+				rewarded++
+			}
+			if rewarded > 0 {
+				log.Printf("Rewarded %d referrals", rewarded)
+			}
+		}
+	}()
+}
@@ -0,0 +1,78 @@
+package fabric
+
+import (
+	"log"
+	"time"
+
+	"loyalty-backend/pkg/models"
+)
+
+// GetProgramStats retrieves account counts, the points supply and the points issued and redeemed
+// from fromDate to toDate (YYYY-MM-DD); empty dates select the last 30 days
+func (fc *FabricClient) GetProgramStats(fromDate, toDate string) (*models.ProgramStats, error) {
+	log.Printf("Getting program statistics from %q to %q", fromDate, toDate)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.EvaluateTransaction("AdminContract:GetProgramStats", fromDate, toDate)
+
+	// Return simulated statistics from blockchain
+	stats := &models.ProgramStats{
+		Accounts: models.AccountStats{
+			Total:    3,
+			ByStatus: map[string]int{"ACTIVE": 3},
+			ByTier:   map[string]int{"BRONZE": 2, "SILVER": 1},
+		},
+		TotalSupply: 4500,
+		From:        fromDate,
+		To:          toDate,
+		Issued:      1200,
+		Redeemed:    300,
+	}
+	return stats, nil
+}
+
+// GetLeaderboard retrieves the top `limit` customers by metric (EARNED, REDEEMED) for a period (ALL or YYYY-MM)
+func (fc *FabricClient) GetLeaderboard(metric, period string, limit int) (*models.Leaderboard, error) {
+	log.Printf("Getting %s leaderboard for period %q, limit %d", metric, period, limit)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.EvaluateTransaction("AdminContract:GetLeaderboard", metric, period, strconv.Itoa(limit))
+
+	if period == "" {
+		period = "ALL"
+	}
+	return &models.Leaderboard{Metric: metric, Period: period, Entries: []models.LeaderboardEntry{}}, nil
+}
+
+// CompactCounters folds the counter shards of a finished day (YYYY-MM-DD, empty = yesterday UTC) into the ledger's counters
+func (fc *FabricClient) CompactCounters(date string) (*models.CounterCompaction, error) {
+	log.Printf("Compacting counters of %q", date)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.SubmitTransaction("AdminContract:CompactCounters", date)
+
+	return &models.CounterCompaction{Date: time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")}, nil
+}
+
+// StartCounterCompaction periodically compacts yesterday's counter shards until the process exits.
+// Every transaction that changes the supply writes its own shard, so reads stay cheap only if finished days are folded.
+func (fc *FabricClient) StartCounterCompaction(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			compaction, err := fc.CompactCounters("")
+			if err != nil {
+				log.Printf("Error compacting counters: %v", err)
+				continue
+			}
+			if compaction.SupplyShards > 0 || compaction.AccountStatsShards > 0 || compaction.CampaignBudgetShards > 0 {
+				log.Printf("Compacted %d supply counter, %d account stats and %d campaign budget shards of %s",
+					compaction.SupplyShards, compaction.AccountStatsShards, compaction.CampaignBudgetShards, compaction.Date)
+			}
+		}
+	}()
+}
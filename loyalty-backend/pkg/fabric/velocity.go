@@ -0,0 +1,112 @@
+package fabric
+
+import (
+	"log"
+	"time"
+
+	"loyalty-backend/pkg/models"
+)
+
+// FlagSecurityChange records that a customer changed their password or phone number.
+// Transfers from the account are held for review until the configured cooldown has passed.
+func (fc *FabricClient) FlagSecurityChange(customerID, change string) (*models.LoyaltyAccount, error) {
+	orgs, err := fc.endorsingOrgs(customerID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Flagging %s change for customer: %s, endorsed by %v", change, customerID, orgs)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.Submit("FlagSecurityChange",
+	//     client.WithArguments(customerID, change), client.WithEndorsingOrganizations(orgs...))
+
+	// Return simulated updated account
+	account := &models.LoyaltyAccount{
+		CustomerID:        customerID,
+		Balance:           1500,
+		LastUpdated:       "2025-07-25T04:10:00Z",
+		SecurityChange:    change,
+		SecurityChangedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	log.Printf("Security change flagged on blockchain: %+v", account)
+	return account, nil
+}
+
+// GetFlaggedTransfers retrieves up to `limit` transfers waiting for review.
+// The chaincode only accepts the query from a BankOrgMSP identity with role=reviewer.
+func (fc *FabricClient) GetFlaggedTransfers(limit int) ([]models.FlaggedTransfer, error) {
+	log.Printf("Getting flagged transfers, limit %d", limit)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.EvaluateTransaction("QueryFlaggedTransfers", strconv.Itoa(limit))
+
+	return []models.FlaggedTransfer{}, nil
+}
+
+// GetFlaggedTransfer retrieves a flagged transfer by the ID of the transfer transaction
+func (fc *FabricClient) GetFlaggedTransfer(transferID string) (*models.FlaggedTransfer, error) {
+	log.Printf("Getting flagged transfer: %s", transferID)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.EvaluateTransaction("GetFlaggedTransfer", transferID)
+
+	// Return simulated flagged transfer from blockchain
+	transfer := &models.FlaggedTransfer{
+		TransferID: transferID,
+		SourceID:   "CUST001",
+		TargetID:   "CUST002",
+		Amount:     100,
+		Currency:   "POINTS",
+		Rules:      []string{"MAX_TRANSFERS_PER_HOUR"},
+		Status:     "FLAGGED",
+		CreatedAt:  "2025-07-25T04:10:00Z",
+	}
+	return transfer, nil
+}
+
+// ApproveFlagged releases the held points of a flagged transfer to its target account
+func (fc *FabricClient) ApproveFlagged(transferID string) (*models.FlaggedTransfer, error) {
+	return fc.reviewFlagged(transferID, true, "")
+}
+
+// RejectFlagged returns the held points of a flagged transfer to its source account
+func (fc *FabricClient) RejectFlagged(transferID, reason string) (*models.FlaggedTransfer, error) {
+	return fc.reviewFlagged(transferID, false, reason)
+}
+
+// reviewFlagged submits a reviewer decision; the account credited by the decision must endorse it
+func (fc *FabricClient) reviewFlagged(transferID string, approve bool, reason string) (*models.FlaggedTransfer, error) {
+	transfer, err := fc.GetFlaggedTransfer(transferID)
+	if err != nil {
+		return nil, err
+	}
+	credited, status := transfer.SourceID, "REJECTED"
+	if approve {
+		credited, status = transfer.TargetID, "APPROVED"
+	}
+	orgs, err := fc.endorsingOrgs(credited)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Reviewing flagged transfer %s: %s, endorsed by %v", transferID, status, orgs)
+
+	// For now, simulate blockchain call
+	// In real implementation, this would call:
+	// result, err := fc.Contract.Submit("ApproveFlagged",
+	//     client.WithArguments(transferID), client.WithEndorsingOrganizations(orgs...))
+	// or
+	// result, err := fc.Contract.Submit("RejectFlagged",
+	//     client.WithArguments(transferID, reason), client.WithEndorsingOrganizations(orgs...))
+
+	// Return simulated reviewed transfer from blockchain
+	transfer.Status = status
+	transfer.ReviewNote = reason
+	transfer.ReviewedAt = time.Now().UTC().Format(time.RFC3339)
+
+	log.Printf("Flagged transfer reviewed on blockchain: %+v", transfer)
+	return transfer, nil
+}
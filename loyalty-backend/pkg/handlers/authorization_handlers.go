@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"loyalty-backend/pkg/models"
+)
+
+// transferAuthorizationLifetime is how long a prepared transfer payload can be signed and submitted.
+// The chaincode rejects authorizations that expire more than 24 hours after the transaction.
+const transferAuthorizationLifetime = 10 * time.Minute
+
+// PrepareTransfer handles POST /transfer/authorization
+// It returns the canonical payload with a fresh nonce and expiry. The customer's device signs the
+// payload with its registered key and the signature is sent back in the authorization of POST /transfer.
+func (h *LoyaltyHandler) PrepareTransfer(c *gin.Context) {
+	var req models.PrepareTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Signed transfers require the blockchain network",
+		})
+		return
+	}
+
+	nonce, err := newTransferNonce()
+	if err != nil {
+		log.Printf("Error generating transfer nonce: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to prepare transfer",
+		})
+		return
+	}
+	expiresAt := time.Now().UTC().Add(transferAuthorizationLifetime).Format(time.RFC3339)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Sign the payload with the registered key",
+		Data: models.TransferPayload{
+			Payload:   h.fabricClient.TransferPayload(req.SourceCustomerID, req.TargetCustomerID, req.Amount, req.Currency, nonce, expiresAt),
+			Nonce:     nonce,
+			ExpiresAt: expiresAt,
+		},
+	})
+}
+
+// RegisterSigningKey handles PUT /accounts/:customerID/signing-key
+// Once a key is registered, every transfer from the account must carry a signature made with it.
+func (h *LoyaltyHandler) RegisterSigningKey(c *gin.Context) {
+	var req models.RegisterSigningKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Signed transfers require the blockchain network",
+		})
+		return
+	}
+
+	account, err := h.fabricClient.RegisterSigningKey(c.Param("customerID"), req.PublicKey)
+	if err != nil {
+		log.Printf("Error registering signing key on blockchain: %v", err)
+		respondFabricError(c, err, "Failed to register signing key on blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Signing key registered",
+		Data:    account,
+	})
+}
+
+// newTransferNonce returns a random 192-bit nonce in the URL-safe alphabet the chaincode accepts
+func newTransferNonce() (string, error) {
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(nonce), nil
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"loyalty-backend/pkg/fabric"
+	"loyalty-backend/pkg/models"
+)
+
+// respondFabricError writes the error returned by a blockchain call.
+// Chaincode errors are returned with their HTTP status (404/409/422/...), stable code, message and details;
+// any other failure is reported as 500 with fallbackMessage so internal details are not leaked.
+func respondFabricError(c *gin.Context, err error, fallbackMessage string) {
+	if chaincodeErr := fabric.ParseChaincodeError(err); chaincodeErr != nil {
+		response := models.APIResponse{
+			Success: false,
+			Error:   chaincodeErr.Message,
+			Code:    chaincodeErr.Code,
+		}
+		if len(chaincodeErr.Details) > 0 {
+			response.Data = chaincodeErr.Details
+		}
+		c.JSON(chaincodeErr.HTTPStatus(), response)
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, models.APIResponse{
+		Success: false,
+		Error:   fallbackMessage,
+		Code:    fabric.ErrCodeInternal,
+	})
+}
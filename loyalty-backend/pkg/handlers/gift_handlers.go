@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"loyalty-backend/pkg/fabric"
+	"loyalty-backend/pkg/models"
+)
+
+// defaultGiftValidityDays applies when a gift request does not set validityDays
+const defaultGiftValidityDays = 30
+
+// GiftHandler handles point gifts claimed through a shareable link
+type GiftHandler struct {
+	fabricClient *fabric.FabricClient
+	claimURL     string
+}
+
+// NewGiftHandler creates a new gift handler; claimURL is the web app page that claims a gift
+func NewGiftHandler(fabricClient *fabric.FabricClient, claimURL string) *GiftHandler {
+	return &GiftHandler{
+		fabricClient: fabricClient,
+		claimURL:     claimURL,
+	}
+}
+
+// PrepareGift handles POST /gifts/authorization
+// It generates the claim code and returns the canonical gift payload, which commits to the code's hash,
+// with a fresh nonce and expiry. The customer's device signs the payload with its registered key, and the
+// claim code and signature are sent back to POST /gifts. The backend does not keep the claim code.
+func (h *GiftHandler) PrepareGift(c *gin.Context) {
+	var req models.PrepareGiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Gifts require the blockchain network",
+		})
+		return
+	}
+
+	claimCode, err := newClaimCode()
+	if err != nil {
+		log.Printf("Error generating gift claim code: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate gift claim code",
+		})
+		return
+	}
+	nonce, err := newTransferNonce()
+	if err != nil {
+		log.Printf("Error generating gift nonce: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to prepare gift",
+		})
+		return
+	}
+	hash := sha256.Sum256([]byte(claimCode))
+	expiresAt := time.Now().UTC().Add(transferAuthorizationLifetime).Format(time.RFC3339)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Sign the payload with the registered key",
+		Data: models.GiftPayload{
+			TransferPayload: models.TransferPayload{
+				Payload:   h.fabricClient.GiftPayload(req.SourceCustomerID, hex.EncodeToString(hash[:]), req.Amount, nonce, expiresAt),
+				Nonce:     nonce,
+				ExpiresAt: expiresAt,
+			},
+			ClaimCode: claimCode,
+		},
+	})
+}
+
+// CreateGift handles POST /gifts
+// A random claim code is generated here, or taken from POST /gifts/authorization, and only its SHA-256 hash
+// is sent to the ledger. The code is returned once inside the claim link and is not stored by the backend.
+func (h *GiftHandler) CreateGift(c *gin.Context) {
+	var req models.CreateGiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Gifts require the blockchain network",
+		})
+		return
+	}
+
+	claimCode := req.ClaimCode
+	if claimCode == "" {
+		var err error
+		if claimCode, err = newClaimCode(); err != nil {
+			log.Printf("Error generating gift claim code: %v", err)
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to generate gift claim code",
+			})
+			return
+		}
+	} else if !isClaimCode(claimCode) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "claimCode must be a code returned by POST /gifts/authorization",
+		})
+		return
+	}
+	hash := sha256.Sum256([]byte(claimCode))
+
+	validityDays := req.ValidityDays
+	if validityDays == 0 {
+		validityDays = defaultGiftValidityDays
+	}
+	expiresAt := time.Now().UTC().AddDate(0, 0, validityDays)
+
+	gift, err := h.fabricClient.CreateGift(req.SourceCustomerID, req.Amount, hex.EncodeToString(hash[:]), expiresAt, req.Authorization)
+	if err != nil {
+		log.Printf("Error creating gift on blockchain: %v", err)
+		respondFabricError(c, err, "Failed to create gift on blockchain")
+		return
+	}
+
+	// The code goes in the URL fragment so it never reaches web server or proxy logs
+	claimURL := h.claimURL + "#code=" + claimCode
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Gift created successfully",
+		Data: models.GiftClaimLink{
+			Gift:      gift,
+			ClaimCode: claimCode,
+			ClaimURL:  claimURL,
+			QRPayload: claimURL,
+		},
+	})
+}
+
+// ClaimGift handles POST /gifts/claim
+func (h *GiftHandler) ClaimGift(c *gin.Context) {
+	var req models.ClaimGiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Gifts require the blockchain network",
+		})
+		return
+	}
+
+	gift, err := h.fabricClient.ClaimGift(req.ClaimCode, req.TargetCustomerID)
+	if err != nil {
+		log.Printf("Error claiming gift on blockchain: %v", err)
+		respondFabricError(c, err, "Failed to claim gift on blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Gift claimed successfully",
+		Data:    gift,
+	})
+}
+
+// newClaimCode returns a random 256-bit claim code encoded for use in URLs
+func newClaimCode() (string, error) {
+	code := make([]byte, 32)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(code), nil
+}
+
+// isClaimCode reports whether code has the form of a code from newClaimCode, so a client cannot pick a guessable one
+func isClaimCode(code string) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(code)
+	return err == nil && len(decoded) == 32
+}
@@ -103,10 +103,7 @@ func (h *LoyaltyHandler) CreateAccount(c *gin.Context) {
 	result, err := h.fabricClient.CreateLoyaltyAccount(req.CustomerID)
 	if err != nil {
 		log.Printf("Error creating account on blockchain: %v", err)
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
-			Success: false,
-			Error:   "Failed to create account on blockchain",
-		})
+		respondFabricError(c, err, "Failed to create account on blockchain")
 		return
 	}
 
@@ -172,17 +169,18 @@ func (h *LoyaltyHandler) GetAccount(c *gin.Context) {
 			fabricAccount, err := h.fabricClient.GetLoyaltyAccount(customerID)
 			if err != nil {
 				log.Printf("Error getting account from blockchain: %v", err)
-				// Fallback to creating account if it doesn't exist
+				// Only a missing account is created on the fly; any other failure is reported
+				if chaincodeErr := fabric.ParseChaincodeError(err); chaincodeErr == nil || chaincodeErr.Code != fabric.ErrCodeAccountNotFound {
+					respondFabricError(c, err, "Failed to get account from blockchain")
+					return
+				}
 				log.Println("Account doesn't exist on blockchain, creating new account...")
 				
 				// Create account on blockchain with 0 initial balance
 				newAccount, createErr := h.fabricClient.CreateLoyaltyAccount(customerID)
 				if createErr != nil {
 					log.Printf("Error creating account on blockchain: %v", createErr)
-					c.JSON(http.StatusInternalServerError, models.APIResponse{
-						Success: false,
-						Error:   "Failed to create or retrieve account from blockchain",
-					})
+					respondFabricError(c, createErr, "Failed to create or retrieve account from blockchain")
 					return
 				}
 				fabricAccount = newAccount
@@ -258,10 +256,7 @@ func (h *LoyaltyHandler) GetAccount(c *gin.Context) {
 	result, err := h.fabricClient.GetLoyaltyAccount(customerID)
 	if err != nil {
 		log.Printf("Error getting account from blockchain: %v", err)
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
-			Success: false,
-			Error:   "Failed to get account from blockchain",
-		})
+		respondFabricError(c, err, "Failed to get account from blockchain")
 		return
 	}
 
@@ -310,13 +305,10 @@ func (h *LoyaltyHandler) IssuePoints(c *gin.Context) {
 	}
 
 	// Submit transaction to issue points (for Fabric mode)
-	result, err := h.fabricClient.IssuePoints(customerID, req.Amount, req.Description)
+	result, err := h.fabricClient.IssuePoints(customerID, req.Amount, req.Description, req.Currency)
 	if err != nil {
 		log.Printf("Error issuing points on blockchain: %v", err)
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
-			Success: false,
-			Error:   "Failed to issue points on blockchain",
-		})
+		respondFabricError(c, err, "Failed to issue points on blockchain")
 		return
 	}
 
@@ -351,7 +343,7 @@ func (h *LoyaltyHandler) RedeemPoints(c *gin.Context) {
 	// Check if running in standalone mode (fabricClient is nil)
 	if h.fabricClient == nil {
 		// Check if user has enough points
-		currentBalance := 1250 // Mock current balance
+		currentBalance := int64(1250) // Mock current balance
 		if req.Amount > currentBalance {
 			c.JSON(http.StatusBadRequest, models.APIResponse{
 				Success: false,
@@ -376,13 +368,10 @@ func (h *LoyaltyHandler) RedeemPoints(c *gin.Context) {
 	}
 
 	// Submit transaction to redeem points (for Fabric mode)
-	result, err := h.fabricClient.RedeemPoints(customerID, req.Amount, req.Description)
+	result, err := h.fabricClient.RedeemPoints(customerID, req.Amount, req.Description, req.Currency)
 	if err != nil {
 		log.Printf("Error redeeming points on blockchain: %v", err)
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
-			Success: false,
-			Error:   "Failed to redeem points on blockchain",
-		})
+		respondFabricError(c, err, "Failed to redeem points on blockchain")
 		return
 	}
 
@@ -432,12 +421,17 @@ func (h *LoyaltyHandler) TransferPoints(c *gin.Context) {
 	}
 
 	// Submit transaction to transfer points (for Fabric mode)
-	result, err := h.fabricClient.TransferPoints(req.SourceCustomerID, req.TargetCustomerID, req.Amount, req.Description)
+	result, flagged, err := h.fabricClient.TransferPoints(req.SourceCustomerID, req.TargetCustomerID, req.Amount, req.Description, req.Currency, req.Authorization)
 	if err != nil {
 		log.Printf("Error transferring points on blockchain: %v", err)
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
-			Success: false,
-			Error:   "Failed to transfer points on blockchain",
+		respondFabricError(c, err, "Failed to transfer points on blockchain")
+		return
+	}
+	if flagged != nil {
+		c.JSON(http.StatusAccepted, models.APIResponse{
+			Success: true,
+			Message: "Transfer is held for review",
+			Data:    flagged,
 		})
 		return
 	}
@@ -532,10 +526,7 @@ func (h *LoyaltyHandler) GetRecentTransactions(c *gin.Context) {
 		transactions, err := h.fabricClient.GetLoyaltyHistory(customerID)
 		if err != nil {
 			log.Printf("Error getting transaction history from blockchain: %v", err)
-			c.JSON(http.StatusInternalServerError, models.APIResponse{
-				Success: false,
-				Error:   "Failed to get transaction history from blockchain",
-			})
+			respondFabricError(c, err, "Failed to get transaction history from blockchain")
 			return
 		}
 		
@@ -558,3 +549,51 @@ func (h *LoyaltyHandler) GetRecentTransactions(c *gin.Context) {
 		Data:    emptyTransactions,
 	})
 }
+
+// GetBalanceAt handles GET /accounts/:customerID/balance?asOf=
+// asOf is an RFC3339 timestamp and defaults to now; the balance is read from the ledger history.
+func (h *LoyaltyHandler) GetBalanceAt(c *gin.Context) {
+	customerID := c.Param("customerID")
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Customer ID is required",
+		})
+		return
+	}
+
+	asOf := time.Now().UTC()
+	if value := c.Query("asOf"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "asOf must be an RFC3339 timestamp",
+			})
+			return
+		}
+		asOf = parsed.UTC()
+	}
+
+	// Standalone mode has no ledger history to read
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Historical balances require the blockchain network",
+		})
+		return
+	}
+
+	balance, err := h.fabricClient.GetBalanceAt(customerID, asOf)
+	if err != nil {
+		log.Printf("Error getting historical balance from blockchain: %v", err)
+		respondFabricError(c, err, "Failed to get historical balance from blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Balance retrieved successfully",
+		Data:    balance,
+	})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"loyalty-backend/pkg/models"
+)
+
+// GetPointsBalance handles GET /accounts/:customerID/points
+// Available points can be spent now; pending points unlock when their return window closes.
+func (h *LoyaltyHandler) GetPointsBalance(c *gin.Context) {
+	customerID := c.Param("customerID")
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Customer ID is required",
+		})
+		return
+	}
+
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Pending points require the blockchain network",
+		})
+		return
+	}
+
+	balance, err := h.fabricClient.GetPointsBalance(customerID)
+	if err != nil {
+		log.Printf("Error getting points balance from blockchain: %v", err)
+		respondFabricError(c, err, "Failed to get points balance from blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Points balance retrieved successfully",
+		Data:    balance,
+	})
+}
+
+// AwardPendingPoints handles POST /accounts/:customerID/pending
+// The points are released by the periodic job once the merchant's return window has closed.
+func (h *LoyaltyHandler) AwardPendingPoints(c *gin.Context) {
+	customerID := c.Param("customerID")
+	var req models.AwardPendingPointsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Pending points require the blockchain network",
+		})
+		return
+	}
+
+	releaseAt := time.Now().UTC().AddDate(0, 0, req.ReturnWindowDays)
+	award, err := h.fabricClient.AwardPendingPoints(customerID, req.AwardID, req.Amount, releaseAt, req.Description)
+	if err != nil {
+		log.Printf("Error awarding pending points on blockchain: %v", err)
+		respondFabricError(c, err, "Failed to award pending points on blockchain")
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Pending points awarded successfully",
+		Data:    award,
+	})
+}
+
+// CancelPendingPoints handles DELETE /accounts/:customerID/pending/:awardID
+// Called when the purchase is returned before its points were released.
+func (h *LoyaltyHandler) CancelPendingPoints(c *gin.Context) {
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Pending points require the blockchain network",
+		})
+		return
+	}
+
+	award, err := h.fabricClient.CancelPendingPoints(c.Param("customerID"), c.Param("awardID"))
+	if err != nil {
+		log.Printf("Error cancelling pending points on blockchain: %v", err)
+		respondFabricError(c, err, "Failed to cancel pending points on blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Pending points cancelled",
+		Data:    award,
+	})
+}
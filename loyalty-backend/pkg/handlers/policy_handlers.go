@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"loyalty-backend/pkg/models"
+)
+
+// GetAccountPolicy handles GET /accounts/:customerID/policy
+// An empty org list means the account only needs the bank's endorsement.
+func (h *LoyaltyHandler) GetAccountPolicy(c *gin.Context) {
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Endorsement policies require the blockchain network",
+		})
+		return
+	}
+
+	policy, err := h.fabricClient.GetAccountPolicy(c.Param("customerID"))
+	if err != nil {
+		log.Printf("Error getting endorsement policy from blockchain: %v", err)
+		respondFabricError(c, err, "Failed to get endorsement policy from blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Endorsement policy retrieved successfully",
+		Data:    policy,
+	})
+}
+
+// SetAccountPolicy handles PUT /accounts/:customerID/policy
+// Used for corporate and merchant fee accounts whose changes must be endorsed by more than one org.
+// The chaincode only accepts the call from a BankOrgMSP admin identity.
+func (h *LoyaltyHandler) SetAccountPolicy(c *gin.Context) {
+	var req models.SetAccountPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Endorsement policies require the blockchain network",
+		})
+		return
+	}
+
+	policy, err := h.fabricClient.SetAccountPolicy(c.Param("customerID"), req.EndorsingOrgs)
+	if err != nil {
+		log.Printf("Error setting endorsement policy on blockchain: %v", err)
+		respondFabricError(c, err, "Failed to set endorsement policy on blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Endorsement policy updated",
+		Data:    policy,
+	})
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"loyalty-backend/pkg/fabric"
+	"loyalty-backend/pkg/models"
+)
+
+// referralCodeTagLength is the number of hex characters of the HMAC kept in a referral code
+const referralCodeTagLength = 8
+
+// ReferralHandler handles the referral program.
+// A referral code is the referrer's customer ID followed by a short HMAC tag, e.g. CUST001-1A2B3C4D,
+// so codes can be checked without storing them and cannot be guessed from a customer ID alone.
+type ReferralHandler struct {
+	fabricClient *fabric.FabricClient
+	codeKey      []byte
+}
+
+// NewReferralHandler creates a new referral handler; codeKey signs the referral codes and referrals are disabled when it is empty
+func NewReferralHandler(fabricClient *fabric.FabricClient, codeKey string) *ReferralHandler {
+	return &ReferralHandler{
+		fabricClient: fabricClient,
+		codeKey:      []byte(codeKey),
+	}
+}
+
+// GetReferrals handles GET /accounts/:customerID/referrals
+// Returns the customer's referral code, who referred them and the status of the friends they referred.
+func (h *ReferralHandler) GetReferrals(c *gin.Context) {
+	customerID := c.Param("customerID")
+
+	if !h.available(c) {
+		return
+	}
+
+	account, err := h.fabricClient.GetLoyaltyAccount(customerID)
+	if err != nil {
+		log.Printf("Error getting account from blockchain: %v", err)
+		respondFabricError(c, err, "Failed to get account from blockchain")
+		return
+	}
+	referrals, err := h.fabricClient.GetReferrals(customerID)
+	if err != nil {
+		log.Printf("Error getting referrals from blockchain: %v", err)
+		respondFabricError(c, err, "Failed to get referrals from blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: models.ReferralSummary{
+			CustomerID:   customerID,
+			ReferralCode: h.referralCode(customerID),
+			ReferredBy:   account.ReferredBy,
+			Referrals:    referrals,
+		},
+	})
+}
+
+// RegisterReferral handles POST /referrals
+// The referee enters the code shared by a friend; the chaincode checks uniqueness and the referrer's cap.
+func (h *ReferralHandler) RegisterReferral(c *gin.Context) {
+	var req models.RegisterReferralRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if !h.available(c) {
+		return
+	}
+	referrerID, ok := h.parseReferralCode(req.ReferralCode)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid referral code",
+		})
+		return
+	}
+
+	referral, err := h.fabricClient.RegisterReferral(referrerID, req.RefereeCustomerID)
+	if err != nil {
+		log.Printf("Error registering referral on blockchain: %v", err)
+		respondFabricError(c, err, "Failed to register referral on blockchain")
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Referral registered successfully",
+		Data:    referral,
+	})
+}
+
+// available writes 503 and returns false when referral codes cannot be issued or checked
+func (h *ReferralHandler) available(c *gin.Context) bool {
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Referrals require the blockchain network",
+		})
+		return false
+	}
+	if len(h.codeKey) == 0 {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Referrals are not configured",
+		})
+		return false
+	}
+	return true
+}
+
+// referralCode returns the referral code of a customer
+func (h *ReferralHandler) referralCode(customerID string) string {
+	return customerID + "-" + h.referralTag(customerID)
+}
+
+// parseReferralCode returns the referrer of a referral code, or false if the code was not issued by this backend
+func (h *ReferralHandler) parseReferralCode(code string) (string, bool) {
+	separator := strings.LastIndex(code, "-")
+	if separator <= 0 {
+		return "", false
+	}
+	customerID, tag := code[:separator], strings.ToUpper(code[separator+1:])
+	if !hmac.Equal([]byte(tag), []byte(h.referralTag(customerID))) {
+		return "", false
+	}
+	return customerID, true
+}
+
+// referralTag derives the HMAC tag of a customer's referral code
+func (h *ReferralHandler) referralTag(customerID string) string {
+	mac := hmac.New(sha256.New, h.codeKey)
+	mac.Write([]byte(customerID))
+	return strings.ToUpper(hex.EncodeToString(mac.Sum(nil))[:referralCodeTagLength])
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"loyalty-backend/pkg/models"
+)
+
+// GetProgramStats handles GET /stats?from=&to=
+// Dates are YYYY-MM-DD (UTC, both included); the chaincode defaults to the last 30 days and allows at most 366.
+func (h *LoyaltyHandler) GetProgramStats(c *gin.Context) {
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Program statistics require the blockchain network",
+		})
+		return
+	}
+
+	stats, err := h.fabricClient.GetProgramStats(c.Query("from"), c.Query("to"))
+	if err != nil {
+		log.Printf("Error getting program statistics from blockchain: %v", err)
+		respondFabricError(c, err, "Failed to get program statistics from blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Program statistics retrieved successfully",
+		Data:    stats,
+	})
+}
+
+// GetLeaderboard handles GET /leaderboard?metric=&period=&limit=
+// metric is EARNED (default) or REDEEMED; period is ALL (default) or a month as YYYY-MM.
+func (h *LoyaltyHandler) GetLeaderboard(c *gin.Context) {
+	metric := c.DefaultQuery("metric", "EARNED")
+	if metric != "EARNED" && metric != "REDEEMED" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "metric must be EARNED or REDEEMED",
+		})
+		return
+	}
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 100 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "limit must be an integer between 1 and 100",
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Leaderboards require the blockchain network",
+		})
+		return
+	}
+
+	leaderboard, err := h.fabricClient.GetLeaderboard(metric, c.Query("period"), limit)
+	if err != nil {
+		log.Printf("Error getting leaderboard from blockchain: %v", err)
+		respondFabricError(c, err, "Failed to get leaderboard from blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Leaderboard retrieved successfully",
+		Data:    leaderboard,
+	})
+}
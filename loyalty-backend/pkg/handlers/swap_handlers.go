@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"loyalty-backend/pkg/models"
+	"loyalty-backend/pkg/services"
+)
+
+// SwapHandler handles points-for-miles swaps with the partner airline program
+type SwapHandler struct {
+	swapService *services.SwapService
+}
+
+// NewSwapHandler creates a new swap handler; swapService is nil when the Fabric networks are unavailable
+func NewSwapHandler(swapService *services.SwapService) *SwapHandler {
+	return &SwapHandler{
+		swapService: swapService,
+	}
+}
+
+// PrepareSwap handles POST /swaps/authorization
+// It picks the swap ID and returns the canonical swap payload with a fresh nonce and expiry. The customer's
+// device signs the payload with its registered key, and the swap ID and signature are sent back to POST /swaps.
+func (h *SwapHandler) PrepareSwap(c *gin.Context) {
+	var req models.PrepareSwapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if !h.available(c) {
+		return
+	}
+
+	nonce, err := newTransferNonce()
+	if err != nil {
+		log.Printf("Error generating swap nonce: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to prepare swap",
+		})
+		return
+	}
+	payload, err := h.swapService.PrepareSwap(req, nonce, time.Now().UTC().Add(transferAuthorizationLifetime).Format(time.RFC3339))
+	if err != nil {
+		log.Printf("Error preparing swap: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to prepare swap",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Sign the payload with the registered key",
+		Data:    payload,
+	})
+}
+
+// CreateSwap handles POST /swaps
+func (h *SwapHandler) CreateSwap(c *gin.Context) {
+	var req models.CreateSwapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if req.SwapID != "" && !services.IsSwapID(req.SwapID) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "swapID must be an ID returned by POST /swaps/authorization",
+		})
+		return
+	}
+	if req.TimeLock != "" {
+		if _, err := time.Parse(time.RFC3339, req.TimeLock); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "timeLock must be the RFC3339 time lock returned by POST /swaps/authorization",
+			})
+			return
+		}
+	}
+	if !h.available(c) {
+		return
+	}
+
+	status, err := h.swapService.CreateSwap(req)
+	if err != nil {
+		log.Printf("Error creating swap: %v", err)
+		respondFabricError(c, err, "Failed to create swap")
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Swap created successfully",
+		Data:    status,
+	})
+}
+
+// GetSwap handles GET /swaps/:swapID
+func (h *SwapHandler) GetSwap(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	status, err := h.swapService.GetSwapStatus(c.Param("swapID"))
+	if err != nil {
+		log.Printf("Error getting swap status: %v", err)
+		respondFabricError(c, err, "Failed to get swap status")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+// SettleSwap handles POST /swaps/:swapID/settle
+// Completes a swap that was interrupted, or refunds legs whose time lock has passed.
+func (h *SwapHandler) SettleSwap(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+
+	status, err := h.swapService.Settle(c.Param("swapID"))
+	if err != nil {
+		log.Printf("Error settling swap: %v", err)
+		respondFabricError(c, err, "Failed to settle swap")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Swap settled",
+		Data:    status,
+	})
+}
+
+// available writes 503 and returns false when swaps cannot be coordinated
+func (h *SwapHandler) available(c *gin.Context) bool {
+	if h.swapService == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Swaps require the loyalty and partner blockchain networks",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"loyalty-backend/pkg/models"
+)
+
+// FlagSecurityChange handles POST /accounts/:customerID/security-change
+// Called by the identity service after a password or phone change; transfers from the account
+// are held for review during the cooldown configured in the chaincode.
+func (h *LoyaltyHandler) FlagSecurityChange(c *gin.Context) {
+	var req models.SecurityChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Velocity rules require the blockchain network",
+		})
+		return
+	}
+
+	account, err := h.fabricClient.FlagSecurityChange(c.Param("customerID"), req.Change)
+	if err != nil {
+		log.Printf("Error flagging security change on blockchain: %v", err)
+		respondFabricError(c, err, "Failed to flag security change on blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Security change recorded",
+		Data:    account,
+	})
+}
+
+// GetFlaggedTransfers handles GET /flagged-transfers?limit=
+// Returns the transfers waiting for review, at most 100 per request.
+func (h *LoyaltyHandler) GetFlaggedTransfers(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "limit must be a positive integer",
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Velocity rules require the blockchain network",
+		})
+		return
+	}
+
+	transfers, err := h.fabricClient.GetFlaggedTransfers(limit)
+	if err != nil {
+		log.Printf("Error getting flagged transfers from blockchain: %v", err)
+		respondFabricError(c, err, "Failed to get flagged transfers from blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Flagged transfers retrieved successfully",
+		Data:    transfers,
+	})
+}
+
+// ApproveFlagged handles POST /flagged-transfers/:transferID/approve
+// The held points are credited to the target account.
+func (h *LoyaltyHandler) ApproveFlagged(c *gin.Context) {
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Velocity rules require the blockchain network",
+		})
+		return
+	}
+
+	transfer, err := h.fabricClient.ApproveFlagged(c.Param("transferID"))
+	if err != nil {
+		log.Printf("Error approving flagged transfer on blockchain: %v", err)
+		respondFabricError(c, err, "Failed to approve flagged transfer on blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Flagged transfer approved",
+		Data:    transfer,
+	})
+}
+
+// RejectFlagged handles POST /flagged-transfers/:transferID/reject
+// The held points are returned to the source account.
+func (h *LoyaltyHandler) RejectFlagged(c *gin.Context) {
+	var req models.RejectFlaggedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.fabricClient == nil {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Velocity rules require the blockchain network",
+		})
+		return
+	}
+
+	transfer, err := h.fabricClient.RejectFlagged(c.Param("transferID"), req.Reason)
+	if err != nil {
+		log.Printf("Error rejecting flagged transfer on blockchain: %v", err)
+		respondFabricError(c, err, "Failed to reject flagged transfer on blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Flagged transfer rejected",
+		Data:    transfer,
+	})
+}
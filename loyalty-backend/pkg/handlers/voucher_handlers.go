@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"loyalty-backend/pkg/models"
+)
+
+// GetVouchers handles GET /accounts/:customerID/vouchers
+func (h *LoyaltyHandler) GetVouchers(c *gin.Context) {
+	customerID := c.Param("customerID")
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Customer ID is required",
+		})
+		return
+	}
+
+	// Standalone mode has no ledger to issue vouchers on
+	if h.fabricClient == nil {
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Message: "No vouchers available",
+			Data:    []models.Voucher{},
+		})
+		return
+	}
+
+	vouchers, err := h.fabricClient.GetVouchersByOwner(customerID)
+	if err != nil {
+		log.Printf("Error getting vouchers from blockchain: %v", err)
+		respondFabricError(c, err, "Failed to get vouchers from blockchain")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Vouchers retrieved successfully",
+		Data:    vouchers,
+	})
+}
+
+// GetVoucherQR handles GET /vouchers/:voucherID/qr
+// The payload only identifies the voucher; partner stores must still call VerifyVoucher
+// on the ledger before accepting it.
+func (h *LoyaltyHandler) GetVoucherQR(c *gin.Context) {
+	voucherID := c.Param("voucherID")
+	if voucherID == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Voucher ID is required",
+		})
+		return
+	}
+
+	if h.fabricClient == nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Voucher not found",
+		})
+		return
+	}
+
+	voucher, err := h.fabricClient.GetVoucher(voucherID)
+	if err != nil {
+		log.Printf("Error getting voucher from blockchain: %v", err)
+		respondFabricError(c, err, "Failed to get voucher from blockchain")
+		return
+	}
+
+	payload, err := json.Marshal(models.VoucherQRPayload{
+		Type:      "loyalty-voucher",
+		Version:   1,
+		VoucherID: voucher.VoucherID,
+		RewardID:  voucher.RewardID,
+		OwnerID:   voucher.OwnerID,
+		ExpiresAt: voucher.ExpiresAt,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to build voucher QR payload",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"voucher":   voucher,
+			"qrPayload": string(payload),
+		},
+	})
+}
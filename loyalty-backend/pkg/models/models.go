@@ -3,20 +3,142 @@ package models
 // LoyaltyAccount represents a loyalty account on the ledger
 type LoyaltyAccount struct {
 	CustomerID  string `json:"customerID"`
-	Balance     int    `json:"balance"`
+	Balance     int64  `json:"balance"` // POINTS balance
 	LastUpdated string `json:"lastUpdated"`
+
+	Currencies    map[string]CurrencyBalance `json:"currencies,omitempty"`    // balances of other currencies, e.g. STATUS
+	EndorsingOrgs []string                   `json:"endorsingOrgs,omitempty"` // orgs that must endorse changes, when a key-level policy is attached
+	SigningKey    string                     `json:"signingKey,omitempty"`    // PEM public key that signs transfer authorizations
+
+	SecurityChange    string `json:"securityChange,omitempty"`    // last security change: PASSWORD or PHONE
+	SecurityChangedAt string `json:"securityChangedAt,omitempty"` // transfers are held for review during the cooldown after it
+
+	ReferredBy string `json:"referredBy,omitempty"` // customer who referred this account
+}
+
+// CurrencyBalance represents an account's balance in a currency other than POINTS
+type CurrencyBalance struct {
+	Balance          int64  `json:"balance"`
+	LifetimeEarned   int64  `json:"lifetimeEarned"`
+	LifetimeRedeemed int64  `json:"lifetimeRedeemed"`
+	LastActivity     string `json:"lastActivity"`
 }
 
 // LoyaltyTransaction represents a loyalty transaction
 type LoyaltyTransaction struct {
 	TransactionID string `json:"transactionID"`
 	CustomerID    string `json:"customerID"`
-	Type          string `json:"type"` // ISSUE, REDEEM, TRANSFER_IN, TRANSFER_OUT, CREATE_ACCOUNT
-	Amount        int    `json:"amount"`
+	Type          string `json:"type"` // ISSUE, REDEEM, TRANSFER_IN, TRANSFER_OUT, CREATE_ACCOUNT, ADJUSTMENT, EXPIRE
+	Currency      string `json:"currency,omitempty"`
+	Amount        int64  `json:"amount"`
 	Timestamp     string `json:"timestamp"`
 	Description   string `json:"description"`
 }
 
+// Voucher represents a non-fungible voucher issued on the ledger when a reward is redeemed
+type Voucher struct {
+	VoucherID  string  `json:"voucherID"`
+	OwnerID    string  `json:"ownerID"`
+	RewardID   string  `json:"rewardID"`
+	Value      float64 `json:"value"`
+	PointsCost int64   `json:"pointsCost"`
+	IssuedAt   string  `json:"issuedAt"`
+	ExpiresAt  string  `json:"expiresAt"`
+	Status     string  `json:"status"` // ISSUED, CONSUMED, EXPIRED
+	ConsumedBy string  `json:"consumedBy,omitempty"`
+	ConsumedAt string  `json:"consumedAt,omitempty"`
+}
+
+// VoucherQRPayload is the content encoded into a voucher QR code scanned at the point of sale
+type VoucherQRPayload struct {
+	Type      string `json:"type"`
+	Version   int    `json:"v"`
+	VoucherID string `json:"voucherID"`
+	RewardID  string `json:"rewardID"`
+	OwnerID   string `json:"ownerID"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// Gift represents points held on the ledger until the recipient presents the claim code.
+// GiftID is the hex SHA-256 hash of the claim code.
+type Gift struct {
+	GiftID        string `json:"giftID"`
+	SourceID      string `json:"sourceID"`
+	Amount        int64  `json:"amount"`
+	CreatedAt     string `json:"createdAt"`
+	ExpiresAt     string `json:"expiresAt"`
+	Status        string `json:"status"` // PENDING, CLAIMED, REFUNDED, REFUND_FAILED
+	ClaimedBy     string `json:"claimedBy,omitempty"`
+	ClosedAt      string `json:"closedAt,omitempty"`
+	FailureReason string `json:"failureReason,omitempty"` // error code of the refund that failed (REFUND_FAILED)
+}
+
+// GiftClaimLink is returned once when a gift is created; the claim code is not stored by the backend
+type GiftClaimLink struct {
+	Gift      *Gift  `json:"gift"`
+	ClaimCode string `json:"claimCode"`
+	ClaimURL  string `json:"claimURL"`
+	QRPayload string `json:"qrPayload"` // the claim URL, to be rendered as a QR code by the client
+}
+
+// BalanceAt represents the balance of an account at a point in time, read from the ledger history
+type BalanceAt struct {
+	CustomerID    string `json:"customerID"`
+	AsOf          string `json:"asOf"`
+	Balance       int64  `json:"balance"`
+	TransactionID string `json:"transactionID"` // last transaction that wrote the account at or before asOf
+	Timestamp     string `json:"timestamp"`     // block timestamp of that transaction
+	PendingDeltas int64  `json:"pendingDeltas"` // unconsolidated hot-account credits included in balance
+}
+
+// PendingAward represents purchase points held until the merchant's return window closes.
+// AwardID is the merchant's purchase reference.
+type PendingAward struct {
+	AwardID       string `json:"awardID"`
+	CustomerID    string `json:"customerID"`
+	Amount        int64  `json:"amount"`
+	Description   string `json:"description"`
+	CreatedAt     string `json:"createdAt"`
+	ReleaseAt     string `json:"releaseAt"`
+	Status        string `json:"status"` // PENDING, RELEASED, CANCELLED, FAILED
+	ReleasedTo    string `json:"releasedTo,omitempty"`
+	FailureReason string `json:"failureReason,omitempty"` // error code of the credit that failed (FAILED)
+	ClosedAt      string `json:"closedAt,omitempty"`
+}
+
+// PointsBalance reports the spendable balance and the points still pending separately
+type PointsBalance struct {
+	CustomerID    string         `json:"customerID"`
+	Available     int64          `json:"available"`
+	Pending       int64          `json:"pending"`
+	NextReleaseAt string         `json:"nextReleaseAt,omitempty"`
+	PendingAwards []PendingAward `json:"pendingAwards"`
+}
+
+// SwapLock represents one leg of a hash time-locked swap, as stored on either ledger
+type SwapLock struct {
+	SwapID      string `json:"swapID"`
+	SenderID    string `json:"senderID"`
+	RecipientID string `json:"recipientID"`
+	Amount      int64  `json:"amount"`
+	HashLock    string `json:"hashLock"`
+	TimeLock    string `json:"timeLock"`
+	Status      string `json:"status"` // LOCKED, CLAIMED, REFUNDED
+	Secret      string `json:"secret,omitempty"`
+	LockedAt    string `json:"lockedAt"`
+	SettledAt   string `json:"settledAt,omitempty"`
+}
+
+// SwapStatus combines both legs of a points-for-miles swap.
+// Status is AWAITING_PARTNER, LOCKED, SETTLING, COMPLETED, REFUNDING or REFUNDED.
+type SwapStatus struct {
+	SwapID      string    `json:"swapID"`
+	Status      string    `json:"status"`
+	PointsLeg   *SwapLock `json:"pointsLeg"`
+	MilesLeg    *SwapLock `json:"milesLeg,omitempty"` // nil until the partner leg is locked
+	LastChecked string    `json:"lastChecked"`
+}
+
 // CreateAccountRequest represents the request to create a new loyalty account
 type CreateAccountRequest struct {
 	CustomerID string `json:"customerID" binding:"required"`
@@ -25,23 +147,223 @@ type CreateAccountRequest struct {
 // IssuePointsRequest represents the request to issue loyalty points
 type IssuePointsRequest struct {
 	CustomerID  string `json:"customerID" binding:"required"`
-	Amount      int    `json:"amount" binding:"required,min=1"`
+	Amount      int64  `json:"amount" binding:"required,min=1"`
 	Description string `json:"description"`
+	Currency    string `json:"currency"` // defaults to POINTS
 }
 
 // RedeemPointsRequest represents the request to redeem loyalty points
 type RedeemPointsRequest struct {
 	CustomerID  string `json:"customerID" binding:"required"`
-	Amount      int    `json:"amount" binding:"required,min=1"`
+	Amount      int64  `json:"amount" binding:"required,min=1"`
 	Description string `json:"description"`
+	Currency    string `json:"currency"` // defaults to POINTS
 }
 
 // TransferPointsRequest represents the request to transfer loyalty points
 type TransferPointsRequest struct {
 	SourceCustomerID string `json:"sourceCustomerID" binding:"required"`
 	TargetCustomerID string `json:"targetCustomerID" binding:"required"`
-	Amount           int    `json:"amount" binding:"required,min=1"`
+	Amount           int64  `json:"amount" binding:"required,min=1"`
 	Description      string `json:"description"`
+	Currency         string `json:"currency"` // defaults to POINTS; the currency must be transferable
+
+	Authorization *TransferAuthorization `json:"authorization,omitempty"` // required once the source account has a signing key
+}
+
+// TransferAuthorization carries the customer's signature over the canonical transfer payload
+type TransferAuthorization struct {
+	Nonce     string `json:"nonce" binding:"required"`
+	ExpiresAt string `json:"expiresAt" binding:"required"` // RFC3339, as returned with the payload
+	Signature string `json:"signature" binding:"required"` // base64 ECDSA P-256 (ASN.1, SHA-256) or Ed25519 signature
+}
+
+// PrepareTransferRequest represents the request for a transfer payload for the customer to sign
+type PrepareTransferRequest struct {
+	SourceCustomerID string `json:"sourceCustomerID" binding:"required"`
+	TargetCustomerID string `json:"targetCustomerID" binding:"required"`
+	Amount           int64  `json:"amount" binding:"required,min=1"`
+	Currency         string `json:"currency"`
+}
+
+// TransferPayload is the canonical transfer payload the customer signs with the registered key
+type TransferPayload struct {
+	Payload   string `json:"payload"`
+	Nonce     string `json:"nonce"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// RegisterSigningKeyRequest represents the request to register the public key of a customer's device
+type RegisterSigningKeyRequest struct {
+	PublicKey string `json:"publicKey" binding:"required"` // PEM encoded PUBLIC KEY (ECDSA P-256 or Ed25519)
+}
+
+// FlaggedTransfer represents a transfer held for review because it tripped a velocity rule.
+// The points have left the source account and reach the target only when a reviewer approves.
+type FlaggedTransfer struct {
+	TransferID  string   `json:"transferID"`
+	SourceID    string   `json:"sourceID"`
+	TargetID    string   `json:"targetID"`
+	Amount      int64    `json:"amount"`
+	Currency    string   `json:"currency"`
+	Description string   `json:"description"`
+	Rules       []string `json:"rules"`  // MAX_TRANSFERS_PER_HOUR, MAX_TRANSFERS_PER_DAY, MAX_RECIPIENTS_PER_DAY, SECURITY_COOLDOWN
+	Status      string   `json:"status"` // FLAGGED, APPROVED, REJECTED
+	CreatedAt   string   `json:"createdAt"`
+	ReviewedBy  string   `json:"reviewedBy,omitempty"`
+	ReviewedAt  string   `json:"reviewedAt,omitempty"`
+	ReviewNote  string   `json:"reviewNote,omitempty"`
+}
+
+// RejectFlaggedRequest represents a reviewer's rejection of a flagged transfer
+type RejectFlaggedRequest struct {
+	Reason string `json:"reason" binding:"required,max=256"`
+}
+
+// SecurityChangeRequest reports that a customer changed their password or phone number
+type SecurityChangeRequest struct {
+	Change string `json:"change" binding:"required,oneof=PASSWORD PHONE"`
+}
+
+// Referral represents a customer referred by another one.
+// Both sides are rewarded once the referee's lifetime earned points first reach the program threshold.
+type Referral struct {
+	RefereeID      string `json:"refereeID"`
+	ReferrerID     string `json:"referrerID"`
+	Status         string `json:"status"` // PENDING, REWARDED, REWARD_FAILED
+	CreatedAt      string `json:"createdAt"`
+	QualifiedAt    string `json:"qualifiedAt,omitempty"`
+	RewardedAt     string `json:"rewardedAt,omitempty"`
+	ReferrerReward int64  `json:"referrerReward,omitempty"`
+	RefereeReward  int64  `json:"refereeReward,omitempty"`
+	FailureReason  string `json:"failureReason,omitempty"` // error code of the credit that failed (REWARD_FAILED)
+}
+
+// ReferralSummary is the referral section of a customer's dashboard
+type ReferralSummary struct {
+	CustomerID   string     `json:"customerID"`
+	ReferralCode string     `json:"referralCode"` // shared with friends, who enter it to register the referral
+	ReferredBy   string     `json:"referredBy,omitempty"`
+	Referrals    []Referral `json:"referrals"`
+}
+
+// RegisterReferralRequest represents a customer entering the referral code of a friend
+type RegisterReferralRequest struct {
+	RefereeCustomerID string `json:"refereeCustomerID" binding:"required"`
+	ReferralCode      string `json:"referralCode" binding:"required"`
+}
+
+// AccountStats represents the number of accounts by status and tier
+type AccountStats struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"byStatus"`
+	ByTier   map[string]int `json:"byTier"`
+}
+
+// ProgramStats represents the program statistics for a period of days (UTC, both ends included)
+type ProgramStats struct {
+	Accounts    AccountStats `json:"accounts"`
+	TotalSupply int64        `json:"totalSupply"` // POINTS in circulation
+	From        string       `json:"from"`
+	To          string       `json:"to"`
+	Issued      int64        `json:"issued"`
+	Redeemed    int64        `json:"redeemed"`
+}
+
+// CounterCompaction represents the counter shards of one day (UTC) folded into the ledger's counters
+type CounterCompaction struct {
+	Date         string `json:"date"`
+	SupplyShards int    `json:"supplyShards"`
+
+	AccountStatsShards   int `json:"accountStatsShards"`
+	CampaignBudgetShards int `json:"campaignBudgetShards"`
+}
+
+// LeaderboardEntry represents one customer on a leaderboard
+type LeaderboardEntry struct {
+	Rank       int    `json:"rank"`
+	CustomerID string `json:"customerID"`
+	Score      int64  `json:"score"`
+}
+
+// Leaderboard represents the top customers by earned or redeemed points
+type Leaderboard struct {
+	Metric  string             `json:"metric"` // EARNED or REDEEMED
+	Period  string             `json:"period"` // ALL or a month as YYYY-MM
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+// AccountPolicy represents the key-level endorsement policy of an account
+type AccountPolicy struct {
+	CustomerID    string   `json:"customerID"`
+	EndorsingOrgs []string `json:"endorsingOrgs"` // empty: the chaincode endorsement policy (bank only) applies
+}
+
+// SetAccountPolicyRequest represents the request to attach an endorsement policy to an account
+type SetAccountPolicyRequest struct {
+	EndorsingOrgs []string `json:"endorsingOrgs"` // must include BankOrgMSP; empty removes the policy
+}
+
+// AwardPendingPointsRequest represents the request to award purchase points that unlock after the return window
+type AwardPendingPointsRequest struct {
+	AwardID          string `json:"awardID" binding:"required"` // merchant's purchase reference
+	Amount           int64  `json:"amount" binding:"required,min=1"`
+	ReturnWindowDays int    `json:"returnWindowDays" binding:"required,min=1,max=180"`
+	Description      string `json:"description"`
+}
+
+// CreateGiftRequest represents the request to gift points to someone who may not have an account yet
+type CreateGiftRequest struct {
+	SourceCustomerID string `json:"sourceCustomerID" binding:"required"`
+	Amount           int64  `json:"amount" binding:"required,min=1"`
+	ValidityDays     int    `json:"validityDays" binding:"omitempty,min=1,max=90"` // defaults to 30 days
+	ClaimCode        string `json:"claimCode"`                                     // from POST /gifts/authorization; generated when empty
+
+	Authorization *TransferAuthorization `json:"authorization,omitempty"` // required once the source account has a signing key
+}
+
+// PrepareGiftRequest represents the request for a gift payload for the customer to sign
+type PrepareGiftRequest struct {
+	SourceCustomerID string `json:"sourceCustomerID" binding:"required"`
+	Amount           int64  `json:"amount" binding:"required,min=1"`
+}
+
+// GiftPayload is the canonical gift payload the customer signs, with the claim code it commits to.
+// The claim code is sent back with the gift and is not stored by the backend.
+type GiftPayload struct {
+	TransferPayload
+	ClaimCode string `json:"claimCode"`
+}
+
+// ClaimGiftRequest represents the request to claim a gift with the code from its claim link
+type ClaimGiftRequest struct {
+	ClaimCode        string `json:"claimCode" binding:"required"`
+	TargetCustomerID string `json:"targetCustomerID" binding:"required"`
+}
+
+// CreateSwapRequest represents the request to swap loyalty points for partner miles
+type CreateSwapRequest struct {
+	CustomerID      string `json:"customerID" binding:"required"`
+	PartnerMemberID string `json:"partnerMemberID" binding:"required"` // member account in the partner program
+	Points          int64  `json:"points" binding:"required,min=1"`
+	Miles           int64  `json:"miles" binding:"required,min=1"`
+	SwapID          string `json:"swapID"`   // from POST /swaps/authorization; generated when empty
+	TimeLock        string `json:"timeLock"` // from POST /swaps/authorization; the signed authorization commits to it
+
+	Authorization *TransferAuthorization `json:"authorization,omitempty"` // required once the customer's account has a signing key
+}
+
+// PrepareSwapRequest represents the request for a swap payload for the customer to sign
+type PrepareSwapRequest struct {
+	CustomerID string `json:"customerID" binding:"required"`
+	Points     int64  `json:"points" binding:"required,min=1"`
+}
+
+// SwapPayload is the canonical swap payload the customer signs, with the swap ID and time lock it commits to
+type SwapPayload struct {
+	TransferPayload
+	SwapID   string `json:"swapID"`
+	TimeLock string `json:"timeLock"`
 }
 
 // LoginRequest represents the request to login
@@ -56,4 +378,5 @@ type APIResponse struct {
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"` // stable error code, e.g. INSUFFICIENT_BALANCE
 }
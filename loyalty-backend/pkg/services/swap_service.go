@@ -0,0 +1,222 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"loyalty-backend/pkg/fabric"
+	"loyalty-backend/pkg/models"
+)
+
+// Time locks of the two swap legs. The points leg is locked first by the side that knows the secret,
+// so it must stay locked longer than the miles leg: once the secret is revealed on the partner channel
+// there is still time to claim the points before they can be refunded.
+const (
+	pointsLegTimeLock = 48 * time.Hour
+	milesLegTimeLock  = 24 * time.Hour
+)
+
+// Swap statuses derived from both legs
+const (
+	SwapStatusAwaitingPartner = "AWAITING_PARTNER" // points locked, partner leg not locked yet
+	SwapStatusLocked          = "LOCKED"           // both legs locked
+	SwapStatusSettling        = "SETTLING"         // miles claimed, secret public, points claim pending
+	SwapStatusCompleted       = "COMPLETED"        // both legs claimed
+	SwapStatusRefunding       = "REFUNDING"        // a leg expired and waits to be refunded
+	SwapStatusRefunded        = "REFUNDED"         // points returned to the customer
+)
+
+// SwapService coordinates points-for-miles swaps across the loyalty channel and the partner channel.
+// Each leg is a hash time-locked contract (SwapContract) on its own ledger, so neither side has to
+// trust the backend: the customer's points only move to the partner once the miles have been claimed.
+type SwapService struct {
+	loyalty        *fabric.FabricClient
+	partner        *fabric.FabricClient
+	partnerAccount string
+	secretKey      []byte
+}
+
+// NewSwapService creates a swap coordinator; partnerAccount is the partner's account on both ledgers
+func NewSwapService(loyalty, partner *fabric.FabricClient, partnerAccount, secretKey string) *SwapService {
+	return &SwapService{
+		loyalty:        loyalty,
+		partner:        partner,
+		partnerAccount: partnerAccount,
+		secretKey:      []byte(secretKey),
+	}
+}
+
+// PrepareSwap picks the swap ID and the points leg time lock and returns the payload the customer signs
+// to authorize locking their points. The signed swap is then created with CreateSwap using the same swap ID and time lock.
+func (s *SwapService) PrepareSwap(req models.PrepareSwapRequest, nonce, expiresAt string) (*models.SwapPayload, error) {
+	swapID, err := newSwapID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate swap ID: %w", err)
+	}
+	timeLock := time.Now().UTC().Add(pointsLegTimeLock).Truncate(time.Second)
+	return &models.SwapPayload{
+		TransferPayload: models.TransferPayload{
+			Payload:   s.loyalty.SwapPayload(req.CustomerID, swapID, s.partnerAccount, req.Points, s.hashLock(swapID), timeLock, nonce, expiresAt),
+			Nonce:     nonce,
+			ExpiresAt: expiresAt,
+		},
+		SwapID:   swapID,
+		TimeLock: timeLock.Format(time.RFC3339),
+	}, nil
+}
+
+// CreateSwap locks the customer's points, then the partner's miles under the same hash, and settles the swap.
+// The swap ID and time lock from PrepareSwap are used when the request carries them, otherwise new ones are generated.
+// If the partner leg cannot be locked the points stay locked until their time lock and are then refunded by Settle.
+func (s *SwapService) CreateSwap(req models.CreateSwapRequest) (*models.SwapStatus, error) {
+	swapID := req.SwapID
+	if swapID == "" {
+		var err error
+		if swapID, err = newSwapID(); err != nil {
+			return nil, fmt.Errorf("failed to generate swap ID: %w", err)
+		}
+	}
+	hashLock := s.hashLock(swapID)
+	now := time.Now().UTC()
+	timeLock := now.Add(pointsLegTimeLock)
+	if req.TimeLock != "" {
+		var err error
+		if timeLock, err = time.Parse(time.RFC3339, req.TimeLock); err != nil {
+			return nil, fmt.Errorf("invalid time lock %q: %w", req.TimeLock, err)
+		}
+	}
+
+	if _, err := s.loyalty.LockForSwap(swapID, req.CustomerID, s.partnerAccount, req.Points, hashLock, timeLock, req.Authorization); err != nil {
+		return nil, err
+	}
+	// The partner account has no signing key
+	if _, err := s.partner.LockForSwap(swapID, s.partnerAccount, req.PartnerMemberID, req.Miles, hashLock, now.Add(milesLegTimeLock), nil); err != nil {
+		return nil, fmt.Errorf("points are locked for swap %s but the partner leg failed: %w", swapID, err)
+	}
+	return s.Settle(swapID)
+}
+
+// Settle moves the swap forward from the state of both legs: claims the miles and then the points once both
+// are locked, finishes a claim interrupted after the secret was revealed, or refunds legs whose time lock passed.
+// It is safe to call repeatedly.
+func (s *SwapService) Settle(swapID string) (*models.SwapStatus, error) {
+	pointsLeg, milesLeg, err := s.legs(swapID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+
+	if milesLeg != nil && milesLeg.Status == "LOCKED" && pointsLeg.Status == "LOCKED" && !expired(milesLeg, now) {
+		if milesLeg, err = s.partner.ClaimSwap(swapID, s.secret(swapID)); err != nil {
+			return nil, err
+		}
+	}
+	if milesLeg != nil && milesLeg.Status == "CLAIMED" && pointsLeg.Status == "LOCKED" {
+		if pointsLeg, err = s.loyalty.ClaimSwap(swapID, s.secret(swapID)); err != nil {
+			return nil, err
+		}
+	}
+	if milesLeg != nil && milesLeg.Status == "LOCKED" && expired(milesLeg, now) {
+		if milesLeg, err = s.partner.RefundSwap(swapID); err != nil {
+			return nil, err
+		}
+	}
+	if pointsLeg.Status == "LOCKED" && expired(pointsLeg, now) && (milesLeg == nil || milesLeg.Status != "CLAIMED") {
+		if pointsLeg, err = s.loyalty.RefundSwap(swapID); err != nil {
+			return nil, err
+		}
+	}
+	return swapStatus(swapID, pointsLeg, milesLeg, now), nil
+}
+
+// GetSwapStatus reads both legs and reports the combined status without changing either ledger
+func (s *SwapService) GetSwapStatus(swapID string) (*models.SwapStatus, error) {
+	pointsLeg, milesLeg, err := s.legs(swapID)
+	if err != nil {
+		return nil, err
+	}
+	return swapStatus(swapID, pointsLeg, milesLeg, time.Now().UTC()), nil
+}
+
+// legs reads the points leg (required) and the miles leg (nil if the partner has not locked it)
+func (s *SwapService) legs(swapID string) (*models.SwapLock, *models.SwapLock, error) {
+	pointsLeg, err := s.loyalty.GetSwap(swapID)
+	if err != nil {
+		return nil, nil, err
+	}
+	milesLeg, err := s.partner.GetSwap(swapID)
+	if err != nil {
+		if chaincodeErr := fabric.ParseChaincodeError(err); chaincodeErr != nil && chaincodeErr.Code == fabric.ErrCodeSwapNotFound {
+			return pointsLeg, nil, nil
+		}
+		return nil, nil, err
+	}
+	return pointsLeg, milesLeg, nil
+}
+
+// secret derives the swap secret from the swap ID, so it never has to be stored
+func (s *SwapService) secret(swapID string) string {
+	mac := hmac.New(sha256.New, s.secretKey)
+	mac.Write([]byte(swapID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hashLock is the hex SHA-256 of the swap secret that locks both legs
+func (s *SwapService) hashLock(swapID string) string {
+	hash := sha256.Sum256([]byte(s.secret(swapID)))
+	return hex.EncodeToString(hash[:])
+}
+
+// swapStatus derives the combined status of a swap from its legs
+func swapStatus(swapID string, pointsLeg, milesLeg *models.SwapLock, now time.Time) *models.SwapStatus {
+	status := &models.SwapStatus{
+		SwapID:      swapID,
+		PointsLeg:   pointsLeg,
+		MilesLeg:    milesLeg,
+		LastChecked: now.Format(time.RFC3339),
+	}
+	switch {
+	case pointsLeg.Status == "CLAIMED":
+		status.Status = SwapStatusCompleted
+	case pointsLeg.Status == "REFUNDED":
+		status.Status = SwapStatusRefunded
+	case milesLeg != nil && milesLeg.Status == "CLAIMED":
+		status.Status = SwapStatusSettling
+	case expired(pointsLeg, now) || (milesLeg != nil && (milesLeg.Status == "REFUNDED" || expired(milesLeg, now))):
+		status.Status = SwapStatusRefunding
+	case milesLeg != nil:
+		status.Status = SwapStatusLocked
+	default:
+		status.Status = SwapStatusAwaitingPartner
+	}
+	return status
+}
+
+// expired reports whether the time lock of a leg has passed
+func expired(lock *models.SwapLock, now time.Time) bool {
+	timeLock, err := time.Parse(time.RFC3339, lock.TimeLock)
+	return err != nil || !now.Before(timeLock)
+}
+
+// swapIDPattern matches the swap IDs generated by newSwapID
+var swapIDPattern = regexp.MustCompile(`^SWP-[0-9A-F]{24}$`)
+
+// IsSwapID reports whether id has the form of a generated swap ID
+func IsSwapID(id string) bool {
+	return swapIDPattern.MatchString(id)
+}
+
+// newSwapID returns a random swap ID shared by both legs
+func newSwapID() (string, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return "SWP-" + strings.ToUpper(hex.EncodeToString(id)), nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// adjustmentReason mô tả một mã lý do điều chỉnh: chiều điều chỉnh được phép và việc cho phép số dư âm
+type adjustmentReason struct {
+	credit        bool // cho phép cộng điểm
+	debit         bool // cho phép trừ điểm
+	allowNegative bool // cho phép số dư âm sau khi trừ (ví dụ thu hồi điểm gian lận đã được sử dụng)
+}
+
+// adjustmentReasons là danh mục mã lý do điều chỉnh hợp lệ
+var adjustmentReasons = map[string]adjustmentReason{
+	"GOODWILL":       {credit: true},
+	"CORRECTION":     {credit: true, debit: true},
+	"FRAUD_CLAWBACK": {debit: true, allowNegative: true},
+}
+
+// BalanceAdjustment định nghĩa bản ghi điều chỉnh số dư do nhân viên hỗ trợ thực hiện
+type BalanceAdjustment struct {
+	TransactionID string `json:"transactionID"`
+	CustomerID    string `json:"customerID"`
+	Type          string `json:"type"` // luôn là "ADJUSTMENT"
+	Currency      string `json:"currency"`
+	Amount        int64  `json:"amount"` // có dấu: dương = cộng điểm, âm = trừ điểm
+	ReasonCode    string `json:"reasonCode"`
+	TicketRef     string `json:"ticketRef"`
+	AdjustedBy    string `json:"adjustedBy"` // ID của identity thực hiện điều chỉnh
+	BalanceBefore int64  `json:"balanceBefore"`
+	BalanceAfter  int64  `json:"balanceAfter"`
+	Timestamp     string `json:"timestamp"`
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+// =========================================================================================
+// UC-018: Điều chỉnh số dư bởi nhân viên hỗ trợ
+// Chỉ identity của BankOrgMSP có thuộc tính role=adjuster được phép gọi (kiểm tra trong BeforeTransaction).
+//
+// Logic chính:
+// 1. Kiểm tra `signedAmount` khác 0 và trong giới hạn mỗi giao dịch, `reasonCode` thuộc danh mục
+//    và cho phép chiều điều chỉnh, `ticketRef` không rỗng, `currency` (rỗng = POINTS) đã được cấu hình.
+// 2. Gộp các delta đang chờ nếu điều chỉnh POINTS trên tài khoản ở chế độ delta để điều chỉnh trên số dư nhất quán.
+// 3. Cộng hoặc trừ điểm. Số dư không được âm, trừ khi mã lý do cho phép (FRAUD_CLAWBACK).
+//    LifetimeEarned và LifetimeRedeemed không thay đổi.
+// 4. Lưu tài khoản, bản ghi BalanceAdjustment và cập nhật bộ đếm tổng cung (TotalAdjusted) của đơn vị điểm.
+// 5. Phát ra sự kiện "AdjustBalanceEvent" với giao dịch loại ADJUSTMENT.
+// =========================================================================================
+func (s *AccountContract) AdjustBalance(ctx contractapi.TransactionContextInterface, customerID string, signedAmount int64, reasonCode string, ticketRef string, currency string) (*BalanceAdjustment, error) {
+	// 1. Kiểm tra đầu vào
+	if signedAmount == 0 || signedAmount == math.MinInt64 {
+		return nil, errInvalidArgument("signed amount must be a non-zero integer, got: %d", signedAmount)
+	}
+	reason, found := adjustmentReasons[reasonCode]
+	if !found {
+		return nil, errInvalidArgument("unknown reason code: %s", reasonCode)
+	}
+	if signedAmount > 0 && !reason.credit {
+		return nil, errInvalidArgument("reason code %s does not allow crediting points", reasonCode)
+	}
+	if signedAmount < 0 && !reason.debit {
+		return nil, errInvalidArgument("reason code %s does not allow debiting points", reasonCode)
+	}
+	if ticketRef == "" {
+		return nil, errInvalidArgument("ticket reference cannot be empty")
+	}
+	magnitude := signedAmount
+	if magnitude < 0 {
+		magnitude = -magnitude
+	}
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.checkTransactionAmount(magnitude); err != nil {
+		return nil, err
+	}
+	if currency, _, err = config.currencyRule(currency); err != nil {
+		return nil, err
+	}
+
+	// 2. Số dư nhất quán
+	account, err := getAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(account); err != nil {
+		return nil, err
+	}
+	balance := &account.Balance
+	if currency == DefaultCurrency {
+		if account.DeltaMode {
+			if _, err := foldDeltas(ctx, account, true); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		currencyBalance := account.currencyBalance(currency)
+		if err := touchCurrency(ctx, currencyBalance); err != nil {
+			return nil, err
+		}
+		balance = &currencyBalance.Balance
+	}
+	balanceBefore := *balance
+
+	// 3. Cộng hoặc trừ điểm
+	switch {
+	case signedAmount > 0:
+		err = config.creditBalance(customerID, balance, signedAmount)
+	case reason.allowNegative:
+		*balance, err = subPoints(*balance, magnitude)
+	default:
+		err = debitBalance(customerID, balance, magnitude)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Lưu tài khoản, bản ghi điều chỉnh và bộ đếm tổng cung
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	adjustedBy, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client identity: %v", err)
+	}
+	account.LastUpdated = now.Format(time.RFC3339)
+	if err := putAccount(ctx, account); err != nil {
+		return nil, err
+	}
+	if err := updateCurrencySupplyCounters(ctx, currency, 0, 0, 0, signedAmount); err != nil {
+		return nil, err
+	}
+
+	adjustment := BalanceAdjustment{
+		TransactionID: ctx.GetStub().GetTxID(),
+		CustomerID:    customerID,
+		Type:          "ADJUSTMENT",
+		Currency:      currency,
+		Amount:        signedAmount,
+		ReasonCode:    reasonCode,
+		TicketRef:     ticketRef,
+		AdjustedBy:    adjustedBy,
+		BalanceBefore: balanceBefore,
+		BalanceAfter:  *balance,
+		Timestamp:     account.LastUpdated,
+		SchemaVersion: adjustmentSchemaVersion,
+	}
+	adjustmentJSON, err := json.Marshal(adjustment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal balance adjustment: %v", err)
+	}
+	adjustmentKey, err := ledgerKey(ctx, adjustmentObjectType, customerID, adjustment.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(adjustmentKey, adjustmentJSON); err != nil {
+		return nil, fmt.Errorf("failed to put state for balance adjustment: %v", err)
+	}
+
+	// 5. Sự kiện giao dịch loại ADJUSTMENT
+	transaction := LoyaltyTransaction{
+		TransactionID: adjustment.TransactionID,
+		CustomerID:    customerID,
+		Type:          "ADJUSTMENT",
+		Currency:      currency,
+		Amount:        signedAmount,
+		Timestamp:     adjustment.Timestamp,
+		Description:   fmt.Sprintf("%s (ticket %s)", reasonCode, ticketRef),
+	}
+	transactionJSON, err := json.Marshal(transaction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transaction event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent("AdjustBalanceEvent", transactionJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for adjustment: %v", err)
+	}
+
+	return &adjustment, nil
+}
+
+// QueryAdjustments trả về các bản ghi điều chỉnh số dư của một khách hàng
+func (s *AccountContract) QueryAdjustments(ctx contractapi.TransactionContextInterface, customerID string) ([]*BalanceAdjustment, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(adjustmentObjectType, []string{customerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query balance adjustments: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	adjustments := []*BalanceAdjustment{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate balance adjustments: %v", err)
+		}
+		var adjustment BalanceAdjustment
+		if err := json.Unmarshal(queryResult.Value, &adjustment); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal balance adjustment: %v", err)
+		}
+		adjustments = append(adjustments, &adjustment)
+	}
+	return adjustments, nil
+}
@@ -0,0 +1,597 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Campaign định nghĩa cấu trúc cho một chương trình khuyến mãi (double-points, bonus theo ngành hàng...)
+type Campaign struct {
+	CampaignID        string   `json:"campaignID"`
+	Name              string   `json:"name"`
+	StartDate         string   `json:"startDate"`
+	EndDate           string   `json:"endDate"`
+	EligibleTiers     []string `json:"eligibleTiers"`     // rỗng = áp dụng cho mọi hạng
+	EligibleMerchants []string `json:"eligibleMerchants"` // rỗng = áp dụng cho mọi merchant
+	Multiplier        float64  `json:"multiplier"`        // 2.0 = nhân đôi điểm cơ bản, 0 = không nhân
+	FixedBonus        int64    `json:"fixedBonus"`
+	BudgetCap         int64    `json:"budgetCap"`      // tổng điểm tối đa của chương trình, 0 = không giới hạn
+	PerCustomerCap    int64    `json:"perCustomerCap"` // điểm tối đa mỗi khách hàng, 0 = không giới hạn
+	PointsAwarded     int64    `json:"pointsAwarded"`  // trên sổ cái chỉ gồm phần đã gộp, xem campaignPointsAwarded
+	Status            string   `json:"status"`
+	SchemaVersion     int      `json:"schemaVersion"`
+}
+
+// CampaignUsage lưu số điểm một chương trình đã thưởng cho một khách hàng
+type CampaignUsage struct {
+	CampaignID    string `json:"campaignID"`
+	CustomerID    string `json:"customerID"`
+	PointsAwarded int64  `json:"pointsAwarded"`
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+// CampaignContribution ghi nhận phần điểm thưởng mà một chương trình đóng góp vào giao dịch
+type CampaignContribution struct {
+	CampaignID string `json:"campaignID"`
+	Points     int64  `json:"points"`
+}
+
+// PurchaseAward định nghĩa cấu trúc cho điểm thưởng từ một giao dịch mua hàng
+type PurchaseAward struct {
+	TransactionID string                 `json:"transactionID"`
+	CustomerID    string                 `json:"customerID"`
+	MerchantID    string                 `json:"merchantID"`
+	SpendAmount   float64                `json:"spendAmount"`
+	Tier          string                 `json:"tier"`
+	BasePoints    int64                  `json:"basePoints"`
+	BonusPoints   int64                  `json:"bonusPoints"`
+	TotalPoints   int64                  `json:"totalPoints"`
+	Campaigns     []CampaignContribution `json:"campaigns"`
+	ReleaseAt     string                 `json:"releaseAt,omitempty"` // thời điểm điểm được giải phóng vào số dư (xem UC-025)
+	Timestamp     string                 `json:"timestamp"`
+	Description   string                 `json:"description"`
+	SchemaVersion int                    `json:"schemaVersion"`
+}
+
+// isActiveAt kiểm tra chương trình có đang hoạt động tại thời điểm t hay không
+func (c *Campaign) isActiveAt(t time.Time) bool {
+	if c.Status != "ACTIVE" {
+		return false
+	}
+	start, err := ParseTimestamp(c.StartDate)
+	if err != nil {
+		return false
+	}
+	end, err := ParseTimestamp(c.EndDate)
+	if err != nil {
+		return false
+	}
+	return !t.Before(start) && t.Before(end)
+}
+
+// matches kiểm tra hạng khách hàng và merchant có thuộc phạm vi của chương trình hay không
+func (c *Campaign) matches(tier, merchantID string) bool {
+	return containsOrEmpty(c.EligibleTiers, tier) && containsOrEmpty(c.EligibleMerchants, merchantID)
+}
+
+// bonusFor tính điểm thưởng thêm của chương trình trên số điểm cơ bản, chưa áp dụng giới hạn
+func (c *Campaign) bonusFor(basePoints int64) (int64, error) {
+	bonus := c.FixedBonus
+	if c.Multiplier > 1 {
+		multiplied := float64(basePoints) * (c.Multiplier - 1)
+		if multiplied >= math.MaxInt64 {
+			return 0, newChaincodeError(ErrCodeAmountLimitExceeded, map[string]interface{}{"campaignID": c.CampaignID},
+				"bonus of campaign '%s' exceeds the 64-bit point range", c.CampaignID)
+		}
+		return addPoints(bonus, int64(multiplied))
+	}
+	return bonus, nil
+}
+
+// =========================================================================================
+// UC-007: Tạo chương trình khuyến mãi
+//
+// Logic chính:
+// 1. Chỉ thành viên của `BankOrgMSP` mới có quyền tạo chương trình.
+// 2. Kiểm tra dữ liệu đầu vào (khung thời gian, hệ số nhân, bonus, giới hạn ngân sách).
+// 3. Kiểm tra chương trình với `campaignID` chưa tồn tại.
+// 4. Lưu chương trình với trạng thái ACTIVE và phát ra sự kiện "CreateCampaignEvent".
+// =========================================================================================
+func (s *RewardContract) CreateCampaign(ctx contractapi.TransactionContextInterface, campaignID string, name string, startDate string, endDate string, eligibleTiers []string, eligibleMerchants []string, multiplier float64, fixedBonus int64, budgetCap int64, perCustomerCap int64) (*Campaign, error) {
+	campaign := Campaign{
+		CampaignID:        campaignID,
+		Name:              name,
+		StartDate:         startDate,
+		EndDate:           endDate,
+		EligibleTiers:     eligibleTiers,
+		EligibleMerchants: eligibleMerchants,
+		Multiplier:        multiplier,
+		FixedBonus:        fixedBonus,
+		BudgetCap:         budgetCap,
+		PerCustomerCap:    perCustomerCap,
+		PointsAwarded:     0,
+		Status:            "ACTIVE",
+	}
+	if err := ValidateCampaignData(&campaign); err != nil {
+		return nil, errInvalidArgument("%v", err)
+	}
+
+	campaignKey, err := ledgerKey(ctx, campaignObjectType, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	existingCampaignJSON, err := ctx.GetStub().GetState(campaignKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existingCampaignJSON != nil {
+		return nil, newChaincodeError(ErrCodeCampaignAlreadyExists, map[string]interface{}{"campaignID": campaignID}, "campaign with ID '%s' already exists", campaignID)
+	}
+
+	if err := putCampaign(ctx, &campaign); err != nil {
+		return nil, err
+	}
+
+	campaignJSON, err := json.Marshal(campaign)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal campaign event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent("CreateCampaignEvent", campaignJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for campaign: %v", err)
+	}
+
+	return &campaign, nil
+}
+
+// SetCampaignStatus bật/tắt một chương trình khuyến mãi (ACTIVE hoặc INACTIVE)
+func (s *RewardContract) SetCampaignStatus(ctx contractapi.TransactionContextInterface, campaignID string, status string) (*Campaign, error) {
+	if status != "ACTIVE" && status != "INACTIVE" {
+		return nil, errInvalidArgument("invalid campaign status: %s", status)
+	}
+
+	campaign, err := getCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	campaign.Status = status
+	if err := putCampaign(ctx, campaign); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// GetCampaign truy vấn một chương trình khuyến mãi theo ID
+func (s *RewardContract) GetCampaign(ctx contractapi.TransactionContextInterface, campaignID string) (*Campaign, error) {
+	if campaignID == "" {
+		return nil, errInvalidArgument("campaign ID cannot be empty")
+	}
+	campaign, err := getCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.PointsAwarded, err = campaignPointsAwarded(ctx, campaign); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// GetActiveCampaigns trả về các chương trình đang hoạt động tại thời điểm giao dịch
+func (s *RewardContract) GetActiveCampaigns(ctx contractapi.TransactionContextInterface) ([]*Campaign, error) {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	campaigns, err := getAllCampaigns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var active []*Campaign
+	for _, campaign := range campaigns {
+		if campaign.isActiveAt(now) {
+			if campaign.PointsAwarded, err = campaignPointsAwarded(ctx, campaign); err != nil {
+				return nil, err
+			}
+			active = append(active, campaign)
+		}
+	}
+	return active, nil
+}
+
+// =========================================================================================
+// UC-008: Tích điểm khi mua hàng
+//
+// Logic chính:
+// 1. Chỉ thành viên của `BankOrgMSP` mới có quyền ghi nhận giao dịch mua hàng.
+// 2. Tính điểm cơ bản theo số tiền chi tiêu và hạng của khách hàng (dựa trên LifetimeEarned).
+// 3. Duyệt tất cả chương trình khuyến mãi đang hoạt động và khớp hạng/merchant:
+//    - Tính điểm thưởng thêm (hệ số nhân và/hoặc bonus cố định).
+//    - Giới hạn theo ngân sách còn lại của chương trình và giới hạn mỗi khách hàng.
+//    - Cập nhật số điểm đã thưởng của khách hàng; ngân sách đã dùng được ghi vào phần ngân sách riêng của giao dịch
+//      thay vì bản ghi chương trình, nên các giao dịch mua hàng đồng thời không xung đột trên bản ghi đó.
+// 4. Treo tổng điểm thành một khoản điểm chờ (PendingAward với awardID là mã giao dịch) đến hết thời hạn
+//    đổi trả của merchant; điểm chỉ vào số dư khi ReleasePending giải phóng (xem UC-025).
+//    Lưu bản ghi PurchaseAward kèm danh sách chương trình đã đóng góp.
+// 5. Phát ra sự kiện "PurchaseAwardEvent".
+// =========================================================================================
+func (s *AccountContract) RecordPurchase(ctx contractapi.TransactionContextInterface, customerID string, merchantID string, spendAmount float64, description string) (*PurchaseAward, error) {
+	if customerID == "" {
+		return nil, errInvalidArgument("customer ID cannot be empty")
+	}
+	if merchantID == "" {
+		return nil, errInvalidArgument("merchant ID cannot be empty")
+	}
+	if spendAmount <= 0 {
+		return nil, errInvalidArgument("spend amount must be positive, got: %.2f", spendAmount)
+	}
+
+	account, err := getAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(account); err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Chặn số tiền quá lớn trước khi quy đổi sang int64
+	if spendAmount > float64(config.MaxTransactionAmount) {
+		return nil, config.checkTransactionAmount(int64(math.Ceil(spendAmount)))
+	}
+
+	// 2. Điểm cơ bản theo hạng
+	tier := CalculateTierFromPoints(account.tierPoints())
+	basePoints := CalculatePointsEarned(spendAmount, tier)
+
+	// 3. Áp dụng mọi chương trình khuyến mãi phù hợp
+	campaigns, err := getAllCampaigns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	contributions := []CampaignContribution{}
+	var bonusPoints int64
+	for _, campaign := range campaigns {
+		if !campaign.isActiveAt(now) || !campaign.matches(tier, merchantID) {
+			continue
+		}
+
+		bonus, err := campaign.bonusFor(basePoints)
+		if err != nil {
+			return nil, err
+		}
+		if campaign.BudgetCap > 0 {
+			pointsAwarded, err := campaignPointsAwarded(ctx, campaign)
+			if err != nil {
+				return nil, err
+			}
+			bonus = minInt64(bonus, campaign.BudgetCap-pointsAwarded)
+		}
+
+		usage, err := getCampaignUsage(ctx, campaign.CampaignID, customerID)
+		if err != nil {
+			return nil, err
+		}
+		if campaign.PerCustomerCap > 0 {
+			bonus = minInt64(bonus, campaign.PerCustomerCap-usage.PointsAwarded)
+		}
+		if bonus <= 0 {
+			continue
+		}
+
+		if err := putCampaignBudgetShard(ctx, campaign.CampaignID, bonus, now); err != nil {
+			return nil, err
+		}
+		if usage.PointsAwarded, err = addPoints(usage.PointsAwarded, bonus); err != nil {
+			return nil, err
+		}
+		if err := putCampaignUsage(ctx, usage); err != nil {
+			return nil, err
+		}
+
+		contributions = append(contributions, CampaignContribution{CampaignID: campaign.CampaignID, Points: bonus})
+		if bonusPoints, err = addPoints(bonusPoints, bonus); err != nil {
+			return nil, err
+		}
+	}
+
+	// 4. Treo điểm đến hết thời hạn đổi trả (kiểm tra giới hạn giao dịch; số dư tối đa được kiểm tra khi giải phóng).
+	// Tài khoản không bị ghi và điểm chỉ được tính là phát hành khi ReleasePending giải phóng chúng.
+	totalPoints, err := addPoints(basePoints, bonusPoints)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.checkTransactionAmount(totalPoints); err != nil {
+		return nil, err
+	}
+
+	award := PurchaseAward{
+		TransactionID: ctx.GetStub().GetTxID(),
+		CustomerID:    customerID,
+		MerchantID:    merchantID,
+		SpendAmount:   spendAmount,
+		Tier:          tier,
+		BasePoints:    basePoints,
+		BonusPoints:   bonusPoints,
+		TotalPoints:   totalPoints,
+		Campaigns:     contributions,
+		Timestamp:     now.Format(time.RFC3339),
+		Description:   description,
+		SchemaVersion: purchaseAwardSchemaVersion,
+	}
+	if totalPoints > 0 {
+		pending := PendingAward{
+			AwardID:     award.TransactionID,
+			CustomerID:  customerID,
+			Amount:      totalPoints,
+			Description: description,
+			CreatedAt:   award.Timestamp,
+			ReleaseAt:   now.AddDate(0, 0, config.ReturnWindows.daysFor(merchantID)).Format(time.RFC3339),
+			Status:      PendingAwardStatusPending,
+			Campaigns:   contributions,
+		}
+		if err := putPendingAward(ctx, &pending); err != nil {
+			return nil, err
+		}
+		if err := putPendingReleaseIndex(ctx, &pending); err != nil {
+			return nil, err
+		}
+		award.ReleaseAt = pending.ReleaseAt
+	}
+	awardJSON, err := json.Marshal(award)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal purchase award: %v", err)
+	}
+	awardKey, err := ledgerKey(ctx, purchaseAwardObjectType, customerID, award.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(awardKey, awardJSON); err != nil {
+		return nil, fmt.Errorf("failed to put state for purchase award: %v", err)
+	}
+
+	// 5. Phát ra sự kiện
+	if err := ctx.GetStub().SetEvent("PurchaseAwardEvent", awardJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for purchase award: %v", err)
+	}
+
+	return &award, nil
+}
+
+// QueryPurchaseAwards trả về các bản ghi tích điểm mua hàng của một khách hàng
+func (s *AccountContract) QueryPurchaseAwards(ctx contractapi.TransactionContextInterface, customerID string) ([]*PurchaseAward, error) {
+	if customerID == "" {
+		return nil, errInvalidArgument("customer ID cannot be empty")
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(purchaseAwardObjectType, []string{customerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purchase awards: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var awards []*PurchaseAward
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate purchase awards: %v", err)
+		}
+		var award PurchaseAward
+		if err := json.Unmarshal(queryResult.Value, &award); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal purchase award: %v", err)
+		}
+		awards = append(awards, &award)
+	}
+	return awards, nil
+}
+
+// getCampaign đọc một chương trình khuyến mãi từ World State
+func getCampaign(ctx contractapi.TransactionContextInterface, campaignID string) (*Campaign, error) {
+	campaignKey, err := ledgerKey(ctx, campaignObjectType, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	campaignJSON, err := ctx.GetStub().GetState(campaignKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read campaign from world state: %v", err)
+	}
+	if campaignJSON == nil {
+		return nil, newChaincodeError(ErrCodeCampaignNotFound, map[string]interface{}{"campaignID": campaignID}, "campaign with ID '%s' does not exist", campaignID)
+	}
+
+	var campaign Campaign
+	if err := json.Unmarshal(campaignJSON, &campaign); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal campaign data: %v", err)
+	}
+	return &campaign, nil
+}
+
+// getAllCampaigns đọc toàn bộ chương trình khuyến mãi từ World State
+func getAllCampaigns(ctx contractapi.TransactionContextInterface) ([]*Campaign, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(campaignObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaigns: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var campaigns []*Campaign
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate campaigns: %v", err)
+		}
+		var campaign Campaign
+		if err := json.Unmarshal(queryResult.Value, &campaign); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal campaign data: %v", err)
+		}
+		campaigns = append(campaigns, &campaign)
+	}
+	return campaigns, nil
+}
+
+// putCampaign ghi một chương trình khuyến mãi vào World State
+func putCampaign(ctx contractapi.TransactionContextInterface, campaign *Campaign) error {
+	campaign.SchemaVersion = campaignSchemaVersion
+	campaignKey, err := ledgerKey(ctx, campaignObjectType, campaign.CampaignID)
+	if err != nil {
+		return err
+	}
+	campaignJSON, err := json.Marshal(campaign)
+	if err != nil {
+		return fmt.Errorf("failed to marshal campaign: %v", err)
+	}
+	if err := ctx.GetStub().PutState(campaignKey, campaignJSON); err != nil {
+		return fmt.Errorf("failed to put state for campaign: %v", err)
+	}
+	return nil
+}
+
+// refundCampaignContributions hoàn điểm thưởng của một giao dịch mua hàng bị hủy vào ngân sách
+// và số điểm đã thưởng cho khách hàng của từng chương trình
+func refundCampaignContributions(ctx contractapi.TransactionContextInterface, customerID string, contributions []CampaignContribution, now time.Time) error {
+	for _, contribution := range contributions {
+		if err := putCampaignBudgetShard(ctx, contribution.CampaignID, -contribution.Points, now); err != nil {
+			return err
+		}
+		usage, err := getCampaignUsage(ctx, contribution.CampaignID, customerID)
+		if err != nil {
+			return err
+		}
+		usage.PointsAwarded -= contribution.Points
+		if err := putCampaignUsage(ctx, usage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getCampaignUsage đọc số điểm chương trình đã thưởng cho khách hàng (0 nếu chưa có)
+func getCampaignUsage(ctx contractapi.TransactionContextInterface, campaignID, customerID string) (*CampaignUsage, error) {
+	usageKey, err := ledgerKey(ctx, campaignUsageObjectType, campaignID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	usageJSON, err := ctx.GetStub().GetState(usageKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read campaign usage from world state: %v", err)
+	}
+
+	usage := CampaignUsage{CampaignID: campaignID, CustomerID: customerID}
+	if usageJSON != nil {
+		if err := json.Unmarshal(usageJSON, &usage); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal campaign usage: %v", err)
+		}
+	}
+	return &usage, nil
+}
+
+// putCampaignUsage ghi số điểm chương trình đã thưởng cho khách hàng vào World State
+func putCampaignUsage(ctx contractapi.TransactionContextInterface, usage *CampaignUsage) error {
+	usage.SchemaVersion = campaignUsageSchemaVersion
+	usageKey, err := ledgerKey(ctx, campaignUsageObjectType, usage.CampaignID, usage.CustomerID)
+	if err != nil {
+		return err
+	}
+	usageJSON, err := json.Marshal(usage)
+	if err != nil {
+		return fmt.Errorf("failed to marshal campaign usage: %v", err)
+	}
+	if err := ctx.GetStub().PutState(usageKey, usageJSON); err != nil {
+		return fmt.Errorf("failed to put state for campaign usage: %v", err)
+	}
+	return nil
+}
+
+// campaignPointsAwarded trả về tổng điểm chương trình đã thưởng: phần đã gộp trong bản ghi chương trình
+// cộng các phần ngân sách chưa gộp
+func campaignPointsAwarded(ctx contractapi.TransactionContextInterface, campaign *Campaign) (int64, error) {
+	shards, _, err := sumCampaignBudgetShards(ctx, false, campaign.CampaignID)
+	if err != nil {
+		return 0, err
+	}
+	return addPoints(campaign.PointsAwarded, shards)
+}
+
+// putCampaignBudgetShard ghi số điểm ngân sách mà giao dịch đã dùng (âm khi hoàn lại) của một chương trình
+// dưới campaignBudget~<campaignID>~<ngày>~<txID>. Một giao dịch chỉ ghi một phần cho mỗi chương trình.
+func putCampaignBudgetShard(ctx contractapi.TransactionContextInterface, campaignID string, points int64, now time.Time) error {
+	shardKey, err := ledgerKey(ctx, campaignBudgetObjectType, campaignID, now.UTC().Format(statsDateLayout), ctx.GetStub().GetTxID())
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(shardKey, []byte(strconv.FormatInt(points, 10))); err != nil {
+		return fmt.Errorf("failed to put state for campaign budget: %v", err)
+	}
+	return nil
+}
+
+// sumCampaignBudgetShards cộng các phần ngân sách có tiền tố key `attributes` (chương trình, ngày).
+// consume = true xóa các phần đã cộng (chỉ dùng trong CompactCounters).
+func sumCampaignBudgetShards(ctx contractapi.TransactionContextInterface, consume bool, attributes ...string) (int64, int, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(campaignBudgetObjectType, attributes)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query campaign budget shards: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var total int64
+	shards := 0
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to iterate campaign budget shards: %v", err)
+		}
+		points, err := strconv.ParseInt(string(queryResult.Value), 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to parse campaign budget shard: %v", err)
+		}
+		if total, err = addPoints(total, points); err != nil {
+			return 0, 0, err
+		}
+		if consume {
+			if err := ctx.GetStub().DelState(queryResult.Key); err != nil {
+				return 0, 0, fmt.Errorf("failed to delete campaign budget shard: %v", err)
+			}
+		}
+		shards++
+	}
+	return total, shards, nil
+}
+
+// compactCampaignBudgets gộp các phần ngân sách của ngày `date` vào PointsAwarded của từng chương trình
+// và xóa chúng (xem CompactCounters), trả về số phần đã gộp
+func compactCampaignBudgets(ctx contractapi.TransactionContextInterface, date string) (int, error) {
+	campaigns, err := getAllCampaigns(ctx)
+	if err != nil {
+		return 0, err
+	}
+	compacted := 0
+	for _, campaign := range campaigns {
+		points, shards, err := sumCampaignBudgetShards(ctx, true, campaign.CampaignID, date)
+		if err != nil {
+			return 0, err
+		}
+		if shards == 0 {
+			continue
+		}
+		if campaign.PointsAwarded, err = addPoints(campaign.PointsAwarded, points); err != nil {
+			return 0, err
+		}
+		if err := putCampaign(ctx, campaign); err != nil {
+			return 0, err
+		}
+		compacted += shards
+	}
+	return compacted, nil
+}
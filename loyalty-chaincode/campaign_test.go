@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordPurchaseCampaigns(t *testing.T) {
+	// ALICE (BRONZE) mua 100 tại SHOP mỗi lần; các chương trình chạy từ hôm trước đến 30 ngày sau testTime
+	type purchase struct {
+		day     int  // số ngày sau testTime
+		compact bool // gộp các bộ đếm của những ngày trước đó trước khi mua
+		cancel  bool // đơn hàng bị trả lại: CancelPending hủy khoản điểm chờ
+	}
+	tests := []struct {
+		name        string
+		campaigns   []Campaign
+		inactive    string // chương trình bị tắt trước khi mua
+		purchases   []purchase
+		wantBonus   []int64 // điểm thưởng thêm của từng lần mua
+		wantAwarded map[string]int64
+		// PointsAwarded đã gộp trong bản ghi của chương trình đầu tiên
+		wantCompacted int64
+	}{
+		{
+			name:        "multiplier",
+			campaigns:   []Campaign{{CampaignID: "DOUBLE", Multiplier: 2}},
+			purchases:   []purchase{{}},
+			wantBonus:   []int64{100},
+			wantAwarded: map[string]int64{"DOUBLE": 100},
+		},
+		{
+			name:        "several campaigns",
+			campaigns:   []Campaign{{CampaignID: "DOUBLE", Multiplier: 2}, {CampaignID: "FIXED", FixedBonus: 10}},
+			purchases:   []purchase{{}},
+			wantBonus:   []int64{110},
+			wantAwarded: map[string]int64{"DOUBLE": 100, "FIXED": 10},
+		},
+		{
+			name:        "tier not eligible",
+			campaigns:   []Campaign{{CampaignID: "GOLDONLY", FixedBonus: 50, EligibleTiers: []string{"GOLD"}}},
+			purchases:   []purchase{{}},
+			wantBonus:   []int64{0},
+			wantAwarded: map[string]int64{"GOLDONLY": 0},
+		},
+		{
+			name:        "merchant not eligible",
+			campaigns:   []Campaign{{CampaignID: "OTHERSHOP", FixedBonus: 50, EligibleMerchants: []string{"OTHER"}}},
+			purchases:   []purchase{{}},
+			wantBonus:   []int64{0},
+			wantAwarded: map[string]int64{"OTHERSHOP": 0},
+		},
+		{
+			name:        "campaign ended",
+			campaigns:   []Campaign{{CampaignID: "FIXED", FixedBonus: 50}},
+			purchases:   []purchase{{day: 31}},
+			wantBonus:   []int64{0},
+			wantAwarded: map[string]int64{"FIXED": 0},
+		},
+		{
+			name:        "inactive campaign",
+			campaigns:   []Campaign{{CampaignID: "FIXED", FixedBonus: 50}},
+			inactive:    "FIXED",
+			purchases:   []purchase{{}},
+			wantBonus:   []int64{0},
+			wantAwarded: map[string]int64{"FIXED": 0},
+		},
+		{
+			name:        "budget cap",
+			campaigns:   []Campaign{{CampaignID: "FIXED", FixedBonus: 50, BudgetCap: 120}},
+			purchases:   []purchase{{}, {}, {}, {}},
+			wantBonus:   []int64{50, 50, 20, 0},
+			wantAwarded: map[string]int64{"FIXED": 120},
+		},
+		{
+			name:          "budget cap across compaction",
+			campaigns:     []Campaign{{CampaignID: "FIXED", FixedBonus: 50, BudgetCap: 120}},
+			purchases:     []purchase{{}, {day: 1}, {day: 2, compact: true}, {day: 3, compact: true}},
+			wantBonus:     []int64{50, 50, 20, 0},
+			wantAwarded:   map[string]int64{"FIXED": 120},
+			wantCompacted: 120,
+		},
+		{
+			name:        "per-customer cap",
+			campaigns:   []Campaign{{CampaignID: "FIXED", FixedBonus: 50, PerCustomerCap: 80}},
+			purchases:   []purchase{{}, {}, {}},
+			wantBonus:   []int64{50, 30, 0},
+			wantAwarded: map[string]int64{"FIXED": 80},
+		},
+		{
+			name:        "cancelled purchase refunds the budget",
+			campaigns:   []Campaign{{CampaignID: "FIXED", FixedBonus: 50, BudgetCap: 120}},
+			purchases:   []purchase{{}, {cancel: true}, {}, {}},
+			wantBonus:   []int64{50, 50, 50, 20},
+			wantAwarded: map[string]int64{"FIXED": 120},
+		},
+		{
+			name:          "cancelled purchase refunds the compacted budget",
+			campaigns:     []Campaign{{CampaignID: "FIXED", FixedBonus: 50, BudgetCap: 120}},
+			purchases:     []purchase{{cancel: true}, {day: 1, compact: true}, {day: 1}, {day: 2, compact: true}},
+			wantBonus:     []int64{50, 50, 50, 20},
+			wantAwarded:   map[string]int64{"FIXED": 120},
+			wantCompacted: 100,
+		},
+		{
+			name:        "cancelled purchase refunds the per-customer cap",
+			campaigns:   []Campaign{{CampaignID: "FIXED", FixedBonus: 50, PerCustomerCap: 80}},
+			purchases:   []purchase{{cancel: true}, {}, {}},
+			wantBonus:   []int64{50, 50, 30},
+			wantAwarded: map[string]int64{"FIXED": 80},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			rewards := &RewardContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 0})
+			for _, c := range tt.campaigns {
+				c := c
+				ctx.mustTx(testTime, func() error {
+					_, err := rewards.CreateCampaign(ctx, c.CampaignID, c.CampaignID, testTime.AddDate(0, 0, -1).Format(time.RFC3339), testTime.AddDate(0, 0, 30).Format(time.RFC3339),
+						c.EligibleTiers, c.EligibleMerchants, c.Multiplier, c.FixedBonus, c.BudgetCap, c.PerCustomerCap)
+					return err
+				})
+			}
+			if tt.inactive != "" {
+				ctx.mustTx(testTime, func() error {
+					_, err := rewards.SetCampaignStatus(ctx, tt.inactive, "INACTIVE")
+					return err
+				})
+			}
+
+			var bonuses []int64
+			for _, p := range tt.purchases {
+				at := testTime.AddDate(0, 0, p.day)
+				if p.compact {
+					for day := 0; day < p.day; day++ {
+						ctx.mustTx(at, func() error {
+							_, err := (&AdminContract{}).CompactCounters(ctx, testTime.AddDate(0, 0, day).Format(statsDateLayout))
+							return err
+						})
+					}
+				}
+				var award *PurchaseAward
+				ctx.mustTx(at, func() error {
+					var err error
+					award, err = (&AccountContract{}).RecordPurchase(ctx, "ALICE", "SHOP", 100, "groceries")
+					return err
+				})
+				if award.BasePoints != 100 || award.TotalPoints != award.BasePoints+award.BonusPoints {
+					t.Errorf("award = base %d, total %d; want base 100 and total = base + bonus", award.BasePoints, award.TotalPoints)
+				}
+				bonuses = append(bonuses, award.BonusPoints)
+				if p.cancel {
+					ctx.mustTx(at, func() error {
+						_, err := (&AccountContract{}).CancelPending(ctx, "ALICE", award.TransactionID)
+						return err
+					})
+				}
+			}
+			if !reflect.DeepEqual(bonuses, tt.wantBonus) {
+				t.Errorf("bonuses = %v, want %v", bonuses, tt.wantBonus)
+			}
+
+			var compacted Campaign
+			if err := json.Unmarshal(ctx.ledgerState(campaignObjectType, tt.campaigns[0].CampaignID), &compacted); err != nil {
+				t.Fatal(err)
+			}
+			if compacted.PointsAwarded != tt.wantCompacted {
+				t.Errorf("compacted points awarded = %d, want %d", compacted.PointsAwarded, tt.wantCompacted)
+			}
+			for campaignID, want := range tt.wantAwarded {
+				var campaign *Campaign
+				ctx.mustTx(testTime.AddDate(0, 0, 4), func() error {
+					var err error
+					campaign, err = rewards.GetCampaign(ctx, campaignID)
+					return err
+				})
+				if campaign.PointsAwarded != want {
+					t.Errorf("campaign %s awarded %d points, want %d", campaignID, campaign.PointsAwarded, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+)
+
+// contractVersion là phiên bản công bố trong metadata của các contract
+const contractVersion = "1.1.0"
+
+// AccountContract quản lý tài khoản, điểm loyalty, giao diện token và ghi nhận mua hàng.
+// Là contract mặc định: các lời gọi không có tiền tố "<Contract>:" được chuyển đến contract này.
+type AccountContract struct {
+	contractapi.Contract
+}
+
+// RewardContract quản lý danh mục phần thưởng, voucher và chương trình khuyến mãi
+type RewardContract struct {
+	contractapi.Contract
+}
+
+// AdminContract chứa các chức năng vận hành: tổng cung, đối soát và di chuyển dữ liệu
+type AdminContract struct {
+	contractapi.Contract
+}
+
+// SwapContract quản lý các khoản khóa điểm (HTLC) để hoán đổi nguyên tử với chương trình đối tác
+type SwapContract struct {
+	contractapi.Contract
+}
+
+// accessLevel xác định ai được phép gọi một giao dịch
+type accessLevel int
+
+const (
+	accessAny      accessLevel = iota // mọi identity trên kênh
+	accessBankOrg                     // chỉ BankOrgMSP
+	accessAdmin                       // BankOrgMSP với thuộc tính role=admin
+	accessAdjuster                    // BankOrgMSP với thuộc tính role=adjuster (nhân viên hỗ trợ)
+	accessReviewer                    // BankOrgMSP với thuộc tính role=reviewer (duyệt giao dịch bị giữ lại)
+)
+
+// transactionRule mô tả quyền truy cập và các tham số định danh của một giao dịch
+type transactionRule struct {
+	access   accessLevel
+	action   string // mô tả dùng trong thông báo lỗi, ví dụ "issue points"
+	idParams []int  // vị trí các tham số là ID (customerID, rewardID, ...) cần chuẩn hóa
+}
+
+// accountTransactionRules là bảng quyền truy cập của AccountContract
+var accountTransactionRules = map[string]transactionRule{
+	"CreateLoyaltyAccount": {access: accessAny, idParams: []int{0}},
+	"IssuePoints":          {access: accessBankOrg, action: "issue points", idParams: []int{0}},
+	"RedeemPoints":         {access: accessAny, idParams: []int{0}},
+	"TransferPoints":       {access: accessAny, idParams: []int{0, 1}},
+	"QueryLoyaltyAccount":  {access: accessAny, idParams: []int{0}},
+	"QueryLoyaltyHistory":  {access: accessAny, idParams: []int{0}},
+	"GetBalanceAt":         {access: accessAny, idParams: []int{0}},
+	"QueryAccounts":        {access: accessAny},
+	"QueryAccountsByRange": {access: accessAny},
+	"RecordPurchase":       {access: accessBankOrg, action: "record purchases", idParams: []int{0, 1}},
+	"Consolidate":          {access: accessBankOrg, action: "consolidate balances", idParams: []int{0}},
+	"AdjustBalance":        {access: accessAdjuster, idParams: []int{0, 3}},
+	"MergeAccounts":        {access: accessAdjuster, idParams: []int{0, 1}},
+	"QueryAdjustments":     {access: accessAny, idParams: []int{0}},
+	"QueryPurchaseAwards":  {access: accessAny, idParams: []int{0}},
+	"Name":                 {access: accessAny},
+	"Symbol":               {access: accessAny},
+	"Decimals":             {access: accessAny},
+	"TotalSupply":          {access: accessAny},
+	"BalanceOf":            {access: accessAny, idParams: []int{0}},
+	"ClientAccountID":      {access: accessAny},
+	"Transfer":             {access: accessAny, idParams: []int{0}},
+	"Approve":              {access: accessAny, idParams: []int{0}},
+	"Allowance":            {access: accessAny, idParams: []int{0, 1}},
+	"TransferFrom":         {access: accessAny, idParams: []int{0, 1}},
+
+	// Hộ gia đình: quyền đại diện cho chủ hộ/thành viên được kiểm tra trong từng hàm (requireActingFor)
+	"CreateHousehold":             {access: accessAny, idParams: []int{0, 1}},
+	"InviteHouseholdMember":       {access: accessAny, idParams: []int{0, 1}},
+	"AcceptHouseholdInvite":       {access: accessAny, idParams: []int{0, 1}},
+	"LeaveHousehold":              {access: accessAny, idParams: []int{0, 1}},
+	"SetHouseholdRules":           {access: accessAny, idParams: []int{0}},
+	"GetHousehold":                {access: accessAny, idParams: []int{0}},
+	"QueryHouseholdContributions": {access: accessAny, idParams: []int{0}},
+
+	// Quà tặng: quyền đại diện cho người gửi/người nhận được kiểm tra trong từng hàm (requireActingFor)
+	"CreateGift":         {access: accessAny, idParams: []int{0, 2, 3}},
+	"ClaimGift":          {access: accessAny, idParams: []int{1}},
+	"RefundGift":         {access: accessAny, idParams: []int{0}},
+	"RefundExpiredGifts": {access: accessBankOrg, action: "refund expired gifts"},
+	"GetGift":            {access: accessAny, idParams: []int{0}},
+
+	// Điểm chờ hết thời hạn đổi trả
+	"AwardPendingPoints": {access: accessBankOrg, action: "award pending points", idParams: []int{0, 1}},
+	"ReleasePending":     {access: accessBankOrg, action: "release pending points"},
+	"RetryPending":       {access: accessBankOrg, action: "retry pending points", idParams: []int{0, 1}},
+	"CancelPending":      {access: accessBankOrg, action: "cancel pending points", idParams: []int{0, 1}},
+	"GetPendingAward":    {access: accessAny, idParams: []int{0, 1}},
+	"GetPointsBalance":   {access: accessAny, idParams: []int{0}},
+
+	// Đơn vị điểm: tham số currency có thể rỗng (= POINTS) nên không thuộc idParams
+	"ExpireBalance": {access: accessBankOrg, action: "expire balances", idParams: []int{0}},
+
+	"GetAccountPolicy": {access: accessAny, idParams: []int{0}},
+	"GetPolicyOrgs":    {access: accessAny},
+
+	// Ủy quyền chuyển điểm: quyền đại diện cho khách hàng được kiểm tra trong RegisterSigningKey (requireActingFor)
+	"RegisterSigningKey":       {access: accessAny, idParams: []int{0}},
+	"GetTransferAuthorization": {access: accessAny, idParams: []int{0, 1}},
+
+	// Quy tắc tốc độ: giao dịch chuyển điểm bị giữ lại chờ người duyệt (role=reviewer)
+	"FlagSecurityChange":    {access: accessBankOrg, action: "flag security changes", idParams: []int{0}},
+	"ApproveFlagged":        {access: accessReviewer, idParams: []int{0}},
+	"RejectFlagged":         {access: accessReviewer, idParams: []int{0}},
+	"GetFlaggedTransfer":    {access: accessAny, idParams: []int{0}},
+	"QueryFlaggedTransfers": {access: accessReviewer},
+
+	// Giới thiệu bạn bè: quyền đại diện cho người được giới thiệu được kiểm tra trong RegisterReferral
+	"RegisterReferral":    {access: accessAny, idParams: []int{0, 1}},
+	"RewardReferrals":     {access: accessBankOrg, action: "reward referrals"},
+	"RetryReferralReward": {access: accessBankOrg, action: "retry referral rewards", idParams: []int{0}},
+	"GetReferral":         {access: accessAny, idParams: []int{0}},
+	"QueryReferrals":      {access: accessAny, idParams: []int{0}},
+}
+
+// rewardTransactionRules là bảng quyền truy cập của RewardContract
+var rewardTransactionRules = map[string]transactionRule{
+	"CreateReward":       {access: accessBankOrg, action: "create rewards", idParams: []int{0}},
+	"GetReward":          {access: accessAny, idParams: []int{0}},
+	"RedeemReward":       {access: accessAny, idParams: []int{0, 1}},
+	"TransferVoucher":    {access: accessAny, idParams: []int{0, 1}}, // chủ sở hữu: requireActingFor
+	"ConsumeVoucher":     {access: accessAny, idParams: []int{0, 1}}, // merchant hoặc BankOrgMSP: requireMerchant
+	"VerifyVoucher":      {access: accessAny, idParams: []int{0}},
+	"GetVoucher":         {access: accessAny, idParams: []int{0}},
+	"GetVouchersByOwner": {access: accessAny, idParams: []int{0}},
+	"CreateCampaign":     {access: accessBankOrg, action: "create campaigns", idParams: []int{0}},
+	"SetCampaignStatus":  {access: accessBankOrg, action: "update campaigns", idParams: []int{0}},
+	"GetCampaign":        {access: accessAny, idParams: []int{0}},
+	"GetActiveCampaigns": {access: accessAny},
+	"PooledRedeemReward": {access: accessAny, idParams: []int{0, 1}},
+}
+
+// adminTransactionRules là bảng quyền truy cập của AdminContract
+var adminTransactionRules = map[string]transactionRule{
+	"GetSupplyCounters": {access: accessAny},
+	"ReconcileSupply":   {access: accessAny},
+	"MigrateState":      {access: accessAdmin},
+	"GetLedgerConfig":   {access: accessAny},
+	"SetLedgerConfig":   {access: accessAdmin},
+	"SetDeltaMode":      {access: accessAdmin, idParams: []int{0}},
+
+	// Giai đoạn 2 của việc di chuyển dữ liệu: đọc theo bookmark khi evaluate, ghi theo danh sách ID
+	"PlanAccountMigration": {access: accessAdmin},
+	"MigrateAccounts":      {access: accessAdmin},
+
+	"GetCurrencySupplyCounters": {access: accessAny},
+	"CompactCounters":           {access: accessBankOrg, action: "compact counters"},
+	"SetCurrencyRule":           {access: accessAdmin, idParams: []int{0}},
+
+	"SetAccountPolicy": {access: accessAdmin, idParams: []int{0}},
+
+	"SetVelocityRules": {access: accessAdmin},
+	"SetReferralRules": {access: accessAdmin},
+	"SetReturnWindow":  {access: accessAdmin},
+
+	"GetProgramStats": {access: accessAny},
+	"GetLeaderboard":  {access: accessBankOrg, action: "view leaderboards"},
+}
+
+// swapTransactionRules là bảng quyền truy cập của SwapContract.
+// Quyền đại diện cho người gửi được kiểm tra trong LockForSwap; Claim và Refund mở cho mọi identity
+// vì điểm chỉ có thể được chuyển cho người nhận hoặc hoàn lại cho người gửi.
+var swapTransactionRules = map[string]transactionRule{
+	"LockForSwap": {access: accessAny, idParams: []int{0, 1, 2, 4, 5}},
+	"Claim":       {access: accessAny, idParams: []int{0}},
+	"Refund":      {access: accessAny, idParams: []int{0}},
+	"GetSwap":     {access: accessAny, idParams: []int{0}},
+}
+
+// NewAccountContract tạo AccountContract cùng metadata và các hook giao dịch
+func NewAccountContract() *AccountContract {
+	return &AccountContract{Contract: newContract("AccountContract", "Loyalty Accounts",
+		"Loyalty accounts, points issuance/redemption/transfer, fungible token interface and purchase earning",
+		accountTransactionRules)}
+}
+
+// NewRewardContract tạo RewardContract cùng metadata và các hook giao dịch
+func NewRewardContract() *RewardContract {
+	return &RewardContract{Contract: newContract("RewardContract", "Loyalty Rewards",
+		"Reward catalogue, non-fungible vouchers and promotional campaigns",
+		rewardTransactionRules)}
+}
+
+// NewAdminContract tạo AdminContract cùng metadata và các hook giao dịch
+func NewAdminContract() *AdminContract {
+	return &AdminContract{Contract: newContract("AdminContract", "Loyalty Administration",
+		"Supply accounting, reconciliation and ledger migration",
+		adminTransactionRules)}
+}
+
+// NewSwapContract tạo SwapContract cùng metadata và các hook giao dịch
+func NewSwapContract() *SwapContract {
+	return &SwapContract{Contract: newContract("SwapContract", "Loyalty Swaps",
+		"Hash time-locked point swaps with partner programs on other channels",
+		swapTransactionRules)}
+}
+
+// newContract cấu hình contractapi.Contract dùng chung cho các contract
+func newContract(name, title, description string, rules map[string]transactionRule) contractapi.Contract {
+	return contractapi.Contract{
+		Name: name,
+		Info: metadata.InfoMetadata{
+			Title:       title,
+			Description: description,
+			Version:     contractVersion,
+		},
+		BeforeTransaction: func(ctx contractapi.TransactionContextInterface) error {
+			return beforeTransaction(ctx, rules)
+		},
+		AfterTransaction: afterTransaction,
+		UnknownTransaction: func(ctx contractapi.TransactionContextInterface) error {
+			return unknownTransaction(ctx, name)
+		},
+	}
+}
+
+// =========================================================================================
+// Hook trước giao dịch (BeforeTransaction)
+//
+// Logic chính:
+// 1. Xác định tên hàm được gọi (bỏ tiền tố "<Contract>:").
+// 2. Tra bảng quyền truy cập của contract; hàm không có trong bảng được chuyển cho
+//    UnknownTransaction xử lý.
+// 3. Kiểm tra quyền của người gọi theo accessLevel.
+// 4. Kiểm tra các tham số định danh đã được chuẩn hóa: không rỗng, không có khoảng trắng ở đầu/cuối.
+// =========================================================================================
+func beforeTransaction(ctx contractapi.TransactionContextInterface, rules map[string]transactionRule) error {
+	// 1. Tên hàm
+	function, params := transactionFunction(ctx)
+
+	// 2. Bảng quyền truy cập
+	rule, found := rules[function]
+	if !found {
+		return nil
+	}
+
+	// 3. Kiểm soát truy cập
+	switch rule.access {
+	case accessBankOrg:
+		if err := requireBankOrg(ctx, rule.action); err != nil {
+			return err
+		}
+	case accessAdmin:
+		if err := requireRole(ctx, "admin"); err != nil {
+			return err
+		}
+	case accessAdjuster:
+		if err := requireRole(ctx, "adjuster"); err != nil {
+			return err
+		}
+	case accessReviewer:
+		if err := requireRole(ctx, "reviewer"); err != nil {
+			return err
+		}
+	}
+
+	// 4. Chuẩn hóa tham số định danh
+	for _, index := range rule.idParams {
+		if index >= len(params) {
+			continue
+		}
+		if strings.TrimSpace(params[index]) == "" {
+			return errInvalidArgument("%s: parameter %d must not be empty", function, index+1)
+		}
+		if strings.TrimSpace(params[index]) != params[index] {
+			return errInvalidArgument("%s: parameter %d must not have leading or trailing whitespace, got: %q", function, index+1, params[index])
+		}
+	}
+	return nil
+}
+
+// afterTransaction ghi log mỗi giao dịch thành công
+func afterTransaction(ctx contractapi.TransactionContextInterface) {
+	function, _ := transactionFunction(ctx)
+	log.Printf("transaction %s completed: %s", ctx.GetStub().GetTxID(), function)
+}
+
+// unknownTransaction trả về lỗi có cấu trúc khi hàm được gọi không tồn tại trong contract
+func unknownTransaction(ctx contractapi.TransactionContextInterface, contractName string) error {
+	function, _ := transactionFunction(ctx)
+	return newChaincodeError(ErrCodeUnknownTransaction, map[string]interface{}{"contract": contractName, "function": function},
+		"function '%s' does not exist in contract '%s'", function, contractName)
+}
+
+// transactionFunction trả về tên hàm (không có tiền tố contract, chữ cái đầu viết hoa như contractapi) và tham số
+func transactionFunction(ctx contractapi.TransactionContextInterface) (string, []string) {
+	function, params := ctx.GetStub().GetFunctionAndParameters()
+	if separator := strings.LastIndex(function, ":"); separator != -1 {
+		function = function[separator+1:]
+	}
+	if functionRunes := []rune(function); len(functionRunes) > 0 && unicode.IsLower(functionRunes[0]) {
+		functionRunes[0] = unicode.ToUpper(functionRunes[0])
+		function = string(functionRunes)
+	}
+	return function, params
+}
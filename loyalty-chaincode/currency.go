@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// DefaultCurrency là đơn vị điểm thưởng có thể sử dụng. Số dư của nó nằm ở LoyaltyAccount.Balance
+	// để chỉ mục CouchDB, chế độ delta và các chức năng chỉ hỗ trợ POINTS (quà tặng, hoán đổi, phần thưởng...) không đổi.
+	DefaultCurrency = "POINTS"
+	// StatusCurrency là điểm hạng: chỉ dùng để xét hạng, không quy đổi hay chuyển được
+	StatusCurrency = "STATUS"
+)
+
+// currencyCodePattern giới hạn mã đơn vị điểm: chữ in hoa, số và gạch dưới, tối đa 16 ký tự
+var currencyCodePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{1,15}$`)
+
+// CurrencyRule định nghĩa quy tắc của một đơn vị điểm trong cấu hình sổ cái
+type CurrencyRule struct {
+	Spendable    bool `json:"spendable"`    // có thể quy đổi (RedeemPoints)
+	Transferable bool `json:"transferable"` // có thể chuyển giữa các tài khoản (TransferPoints)
+	ExpiryDays   int  `json:"expiryDays"`   // số dư hết hạn sau số ngày không có giao dịch; 0 = không hết hạn
+}
+
+// CurrencyBalance định nghĩa số dư của một đơn vị điểm khác POINTS trong tài khoản
+type CurrencyBalance struct {
+	Balance          int64  `json:"balance"`
+	LifetimeEarned   int64  `json:"lifetimeEarned"`
+	LifetimeRedeemed int64  `json:"lifetimeRedeemed"`
+	LastActivity     string `json:"lastActivity"` // thời điểm giao dịch gần nhất, dùng để xét hết hạn
+}
+
+// setDefaultCurrencies bổ sung quy tắc mặc định của POINTS và STATUS nếu chưa được cấu hình
+func (c *LedgerConfig) setDefaultCurrencies() {
+	if c.Currencies == nil {
+		c.Currencies = map[string]CurrencyRule{}
+	}
+	if _, found := c.Currencies[DefaultCurrency]; !found {
+		c.Currencies[DefaultCurrency] = CurrencyRule{Spendable: true, Transferable: true}
+	}
+	if _, found := c.Currencies[StatusCurrency]; !found {
+		c.Currencies[StatusCurrency] = CurrencyRule{}
+	}
+}
+
+// currencyRule chuẩn hóa mã đơn vị điểm (rỗng = POINTS) và trả về quy tắc của nó
+func (c *LedgerConfig) currencyRule(currency string) (string, CurrencyRule, error) {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	rule, found := c.Currencies[currency]
+	if !found {
+		return "", CurrencyRule{}, errInvalidArgument("unknown currency %q", currency)
+	}
+	return currency, rule, nil
+}
+
+// =========================================================================================
+// UC-026: Nhiều đơn vị điểm trên một tài khoản
+// Điểm thưởng (POINTS, dùng để quy đổi) và điểm hạng (STATUS, chỉ để xét hạng) có số dư riêng;
+// các đơn vị điểm của thương hiệu có thể được bổ sung sau bằng SetCurrencyRule.
+//
+// Logic chính:
+// 1. SetCurrencyRule (chỉ quản trị viên): ghi quy tắc quy đổi, chuyển nhượng và hết hạn của một đơn vị điểm
+//    vào cấu hình sổ cái. POINTS và STATUS luôn tồn tại với quy tắc mặc định cho đến khi được cấu hình.
+// 2. IssuePoints, RedeemPoints, TransferPoints và AdjustBalance nhận tham số `currency` (rỗng = POINTS).
+//    RedeemPoints yêu cầu đơn vị điểm Spendable, TransferPoints yêu cầu Transferable.
+// 3. POINTS nằm ở LoyaltyAccount.Balance; các đơn vị khác nằm trong LoyaltyAccount.Currencies.
+//    Hạng được xét trên tổng LifetimeEarned của POINTS và STATUS. Chế độ delta chỉ áp dụng cho POINTS.
+// 4. Mỗi đơn vị điểm có bộ đếm tổng cung riêng (GetCurrencySupplyCounters); ReconcileSupply chỉ đối soát POINTS.
+// 5. ExpireBalance (chỉ BankOrgMSP): xóa số dư dương của một đơn vị điểm có ExpiryDays > 0 khi tài khoản
+//    không có giao dịch với đơn vị đó trong ExpiryDays ngày (POINTS: theo LastUpdated của tài khoản),
+//    cộng vào bộ đếm hết hạn và phát ra sự kiện "ExpireBalanceEvent".
+// =========================================================================================
+func (s *AdminContract) SetCurrencyRule(ctx contractapi.TransactionContextInterface, currency string, spendable bool, transferable bool, expiryDays int) (*LedgerConfig, error) {
+	// 1. Kiểm tra quy tắc
+	if !currencyCodePattern.MatchString(currency) {
+		return nil, errInvalidArgument("currency must be 2-16 uppercase letters, digits or underscores, got: %q", currency)
+	}
+	if expiryDays < 0 {
+		return nil, errInvalidArgument("expiry days cannot be negative, got: %d", expiryDays)
+	}
+
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config.Currencies[currency] = CurrencyRule{Spendable: spendable, Transferable: transferable, ExpiryDays: expiryDays}
+	if err := putLedgerConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ExpireBalance xóa số dư đã hết hạn của một đơn vị điểm (xem UC-026)
+func (s *AccountContract) ExpireBalance(ctx contractapi.TransactionContextInterface, customerID string, currency string) (*LoyaltyTransaction, error) {
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	currency, rule, err := config.currencyRule(currency)
+	if err != nil {
+		return nil, err
+	}
+	if rule.ExpiryDays == 0 {
+		return nil, errInvalidArgument("currency %s does not expire", currency)
+	}
+	account, err := getAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 5. Giao dịch gần nhất với đơn vị điểm
+	lastActivity := account.LastUpdated
+	var balance *int64
+	if currency == DefaultCurrency {
+		// Delta chưa gộp là các khoản ghi có chưa phản ánh vào LastUpdated: tài khoản vẫn đang hoạt động
+		if account.DeltaMode {
+			pending, err := foldDeltas(ctx, account, false)
+			if err != nil {
+				return nil, err
+			}
+			if pending > 0 {
+				lastActivity = now.Format(time.RFC3339)
+			}
+		}
+		balance = &account.Balance
+	} else {
+		currencyBalance := account.currencyBalance(currency)
+		lastActivity = currencyBalance.LastActivity
+		balance = &currencyBalance.Balance
+	}
+	lastActivityTime, err := ParseTimestamp(lastActivity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse last activity of account '%s': %v", customerID, err)
+	}
+	expiresAt := lastActivityTime.AddDate(0, 0, rule.ExpiryDays)
+	if now.Before(expiresAt) || *balance <= 0 {
+		return nil, newChaincodeError(ErrCodeBalanceNotExpired,
+			map[string]interface{}{"customerID": customerID, "currency": currency, "balance": *balance, "expiresAt": expiresAt.Format(time.RFC3339)},
+			"%s balance of '%s' has not expired", currency, customerID)
+	}
+
+	// Xóa số dư và ghi nhận điểm hết hạn
+	expired := *balance
+	*balance = 0
+	account.LastUpdated = now.Format(time.RFC3339)
+	if err := putAccount(ctx, account); err != nil {
+		return nil, err
+	}
+	if err := updateCurrencySupplyCounters(ctx, currency, 0, 0, expired, 0); err != nil {
+		return nil, err
+	}
+
+	transaction := LoyaltyTransaction{
+		TransactionID: ctx.GetStub().GetTxID(),
+		CustomerID:    customerID,
+		Type:          "EXPIRE",
+		Currency:      currency,
+		Amount:        expired,
+		Timestamp:     account.LastUpdated,
+		Description:   fmt.Sprintf("No %s activity since %s", currency, lastActivity),
+	}
+	transactionJSON, err := json.Marshal(transaction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transaction event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent("ExpireBalanceEvent", transactionJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for transaction: %v", err)
+	}
+	return &transaction, nil
+}
+
+// currencyBalance trả về số dư của một đơn vị điểm khác POINTS, tạo mới nếu tài khoản chưa có
+func (a *LoyaltyAccount) currencyBalance(currency string) *CurrencyBalance {
+	if a.Currencies == nil {
+		a.Currencies = map[string]*CurrencyBalance{}
+	}
+	currencyBalance, found := a.Currencies[currency]
+	if !found {
+		currencyBalance = &CurrencyBalance{LastActivity: a.LastUpdated}
+		a.Currencies[currency] = currencyBalance
+	}
+	return currencyBalance
+}
+
+// tierPoints trả về số điểm dùng để xét hạng: tổng LifetimeEarned của POINTS và STATUS
+func (a *LoyaltyAccount) tierPoints() int64 {
+	status, found := a.Currencies[StatusCurrency]
+	if !found {
+		return a.LifetimeEarned
+	}
+	total, err := addPoints(a.LifetimeEarned, status.LifetimeEarned)
+	if err != nil {
+		return a.LifetimeEarned
+	}
+	return total
+}
+
+// creditCurrency ghi có `amount` vào đơn vị điểm `currency` (đã chuẩn hóa).
+// POINTS dùng creditAccount (có thể được ghi dưới dạng BalanceDelta, deferred = true);
+// các đơn vị khác luôn được cập nhật trên `account` và người gọi phải ghi lại tài khoản.
+func creditCurrency(ctx contractapi.TransactionContextInterface, config *LedgerConfig, account *LoyaltyAccount, currency string, amount int64, earned bool) (bool, error) {
+	if currency == DefaultCurrency {
+		return creditAccount(ctx, config, account, amount, earned)
+	}
+	if err := requireOpenAccount(account); err != nil {
+		return false, err
+	}
+	currencyBalance := account.currencyBalance(currency)
+	if err := config.creditBalance(account.CustomerID, &currencyBalance.Balance, amount); err != nil {
+		return false, err
+	}
+	if earned {
+		var err error
+		if currencyBalance.LifetimeEarned, err = addPoints(currencyBalance.LifetimeEarned, amount); err != nil {
+			return false, err
+		}
+	}
+	return false, touchCurrency(ctx, currencyBalance)
+}
+
+// debitCurrency trừ `amount` khỏi đơn vị điểm `currency` (đã chuẩn hóa); `redeemed` = true cộng vào LifetimeRedeemed.
+// Người gọi luôn phải ghi lại tài khoản.
+func debitCurrency(ctx contractapi.TransactionContextInterface, account *LoyaltyAccount, currency string, amount int64, redeemed bool) error {
+	var err error
+	if currency == DefaultCurrency {
+		if err := debitAccount(ctx, account, amount); err != nil {
+			return err
+		}
+		if redeemed {
+			account.LifetimeRedeemed, err = addPoints(account.LifetimeRedeemed, amount)
+		}
+		return err
+	}
+	if err := requireOpenAccount(account); err != nil {
+		return err
+	}
+	currencyBalance := account.currencyBalance(currency)
+	if err := debitBalance(account.CustomerID, &currencyBalance.Balance, amount); err != nil {
+		return err
+	}
+	if redeemed {
+		if currencyBalance.LifetimeRedeemed, err = addPoints(currencyBalance.LifetimeRedeemed, amount); err != nil {
+			return err
+		}
+	}
+	return touchCurrency(ctx, currencyBalance)
+}
+
+// touchCurrency ghi nhận thời điểm giao dịch gần nhất của một đơn vị điểm
+func touchCurrency(ctx contractapi.TransactionContextInterface, currencyBalance *CurrencyBalance) error {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	currencyBalance.LastActivity = now.Format(time.RFC3339)
+	return nil
+}
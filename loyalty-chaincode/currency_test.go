@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestClosedAccountCannotSpendAnyCurrency(t *testing.T) {
+	for _, currency := range []string{DefaultCurrency, "MILES"} {
+		t.Run(currency, func(t *testing.T) {
+			ctx := newTestContext(t)
+			contract := &AccountContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 0})
+			ctx.mustTx(testTime, func() error {
+				_, err := (&AdminContract{}).SetCurrencyRule(ctx, "MILES", true, true, 0)
+				return err
+			})
+			ctx.mustTx(testTime, func() error {
+				_, err := contract.IssuePoints(ctx, "ALICE", 500, "setup", currency)
+				return err
+			})
+			setAccountStatus(ctx, "ALICE", "CLOSED")
+
+			err := ctx.tx(testTime, func() error {
+				_, err := contract.RedeemPoints(ctx, "ALICE", 100, "coffee", currency)
+				return err
+			})
+			checkErrorCode(t, err, ErrCodeAccountClosed)
+		})
+	}
+}
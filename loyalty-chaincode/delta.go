@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// BalanceDelta là một khoản ghi có chưa được gộp vào số dư của tài khoản ở chế độ delta.
+// Mỗi giao dịch ghi có vào tài khoản "nóng" (merchant, tài khoản thu phí...) ghi một key riêng
+// balanceDelta~<customerID>~<txID> thay vì ghi đè key tài khoản, nên các giao dịch ghi có đồng thời
+// không gây MVCC_READ_CONFLICT với nhau.
+type BalanceDelta struct {
+	CustomerID     string `json:"customerID"`
+	TransactionID  string `json:"transactionID"`
+	Amount         int64  `json:"amount"`
+	LifetimeEarned int64  `json:"lifetimeEarned"` // phần được cộng vào LifetimeEarned (điểm phát hành, không gồm điểm nhận chuyển)
+	Timestamp      string `json:"timestamp"`
+	SchemaVersion  int    `json:"schemaVersion"`
+}
+
+// FoldedDelta ghi lại một delta đã được gộp vào số dư, để GetBalanceAt tính được các khoản ghi có
+// trước một thời điểm nhưng chỉ được gộp sau thời điểm đó
+type FoldedDelta struct {
+	TransactionID string `json:"transactionID"`
+	Amount        int64  `json:"amount"`
+	Timestamp     string `json:"timestamp"`
+}
+
+// =========================================================================================
+// UC-017: Gộp số dư của tài khoản ở chế độ delta
+// Được gọi định kỳ (ví dụ bởi một job của backend) cho các tài khoản nóng.
+//
+// Logic chính:
+// 1. Chỉ thành viên của `BankOrgMSP` mới có quyền gộp số dư (kiểm tra trong BeforeTransaction).
+// 2. Đọc tất cả delta đang chờ của tài khoản, cộng vào Balance và LifetimeEarned (có kiểm tra tràn số).
+// 3. Xóa các delta đã gộp và ghi lại tài khoản; bản ghi tài khoản của giao dịch gộp liệt kê chúng trong FoldedDeltas.
+// 4. Phát ra sự kiện "ConsolidateEvent" với số delta đã gộp.
+// Lưu ý: Consolidate ghi key tài khoản nên các giao dịch ghi có cùng block với nó có thể bị từ chối;
+// nên gọi định kỳ thay vì sau mỗi giao dịch.
+// =========================================================================================
+func (s *AccountContract) Consolidate(ctx contractapi.TransactionContextInterface, customerID string) (*LoyaltyAccount, error) {
+	account, err := getAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. & 3. Gộp và xóa delta
+	folded, err := foldDeltas(ctx, account, true)
+	if err != nil {
+		return nil, err
+	}
+	if folded == 0 {
+		return account, nil
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	account.LastUpdated = now.Format(time.RFC3339)
+	if err := putAccount(ctx, account); err != nil {
+		return nil, err
+	}
+
+	// 4. Sự kiện
+	eventJSON, err := json.Marshal(map[string]interface{}{"customerID": customerID, "deltas": folded, "balance": account.Balance})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal consolidate event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent("ConsolidateEvent", eventJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for consolidate: %v", err)
+	}
+	return account, nil
+}
+
+// SetDeltaMode bật/tắt chế độ delta cho một tài khoản (chỉ quản trị viên).
+// Khi tắt, các delta đang chờ được gộp vào số dư trước. Tài khoản có chính sách xác nhận riêng không thể bật
+// chế độ delta vì delta nằm ngoài key tài khoản (xem endorsement.go).
+func (s *AdminContract) SetDeltaMode(ctx contractapi.TransactionContextInterface, customerID string, enabled bool) (*LoyaltyAccount, error) {
+	account, err := getAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled && len(account.EndorsingOrgs) > 0 {
+		return nil, errInvalidArgument("account '%s' has an endorsement policy and cannot use delta mode", customerID)
+	}
+	if !enabled {
+		if _, err := foldDeltas(ctx, account, true); err != nil {
+			return nil, err
+		}
+	}
+	account.DeltaMode = enabled
+	if err := putAccount(ctx, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// creditAccount ghi có `amount` điểm vào tài khoản; `earned` = true nếu khoản này được tính vào LifetimeEarned.
+// Ở chế độ thường, số dư được cập nhật trên `account` và người gọi phải ghi lại tài khoản (deferred = false).
+// Ở chế độ delta, khoản ghi có được lưu thành một BalanceDelta và người gọi KHÔNG được ghi tài khoản
+// (deferred = true); `account` vẫn được cập nhật trong bộ nhớ để trả về cho client.
+// Số dư tối đa được kiểm tra so với số dư đã gộp tại thời điểm ghi có.
+func creditAccount(ctx contractapi.TransactionContextInterface, config *LedgerConfig, account *LoyaltyAccount, amount int64, earned bool) (bool, error) {
+	if err := requireOpenAccount(account); err != nil {
+		return false, err
+	}
+	if err := config.credit(account, amount); err != nil {
+		return false, err
+	}
+	var lifetimeEarned int64
+	if earned {
+		lifetimeEarned = amount
+		earnedBefore := account.LifetimeEarned
+		var err error
+		if account.LifetimeEarned, err = addPoints(account.LifetimeEarned, amount); err != nil {
+			return false, err
+		}
+		// Ở chế độ delta, việc vượt ngưỡng giới thiệu được phát hiện khi các delta được gộp
+		if !account.DeltaMode {
+			if err := markReferralQualified(ctx, config, account, earnedBefore); err != nil {
+				return false, err
+			}
+		}
+	}
+	if !account.DeltaMode {
+		return false, nil
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return false, err
+	}
+	delta := BalanceDelta{
+		CustomerID:     account.CustomerID,
+		TransactionID:  ctx.GetStub().GetTxID(),
+		Amount:         amount,
+		LifetimeEarned: lifetimeEarned,
+		Timestamp:      now.Format(time.RFC3339),
+		SchemaVersion:  balanceDeltaSchemaVersion,
+	}
+	deltaJSON, err := json.Marshal(delta)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal balance delta: %v", err)
+	}
+	deltaKey, err := ledgerKey(ctx, balanceDeltaObjectType, delta.CustomerID, delta.TransactionID)
+	if err != nil {
+		return false, err
+	}
+	if err := ctx.GetStub().PutState(deltaKey, deltaJSON); err != nil {
+		return false, fmt.Errorf("failed to put state for balance delta: %v", err)
+	}
+	return true, nil
+}
+
+// checkCredit kiểm tra, không ghi gì, rằng creditAccount sẽ chấp nhận ghi có `amount` điểm cho `customerID`.
+// Các job hàng loạt dùng nó để bỏ qua tài khoản không nhận được điểm (không tồn tại, đã đóng, vượt số dư tối đa)
+// thay vì hủy cả lô: lỗi nghiệp vụ được trả về ở kết quả thứ nhất, lỗi đọc World State ở kết quả thứ hai.
+func checkCredit(ctx contractapi.TransactionContextInterface, config *LedgerConfig, customerID string, amount int64) (*ChaincodeError, error) {
+	account, err := getAccount(ctx, customerID)
+	if err == nil {
+		err = requireOpenAccount(account)
+	}
+	if err == nil {
+		err = config.credit(account, amount)
+	}
+	var chaincodeErr *ChaincodeError
+	if errors.As(err, &chaincodeErr) {
+		return chaincodeErr, nil
+	}
+	return nil, err
+}
+
+// debitAccount trừ `amount` điểm khỏi tài khoản. Ở chế độ delta, các delta đang chờ được gộp (và xóa) trước
+// để kiểm tra trên số dư nhất quán; người gọi luôn phải ghi lại tài khoản.
+func debitAccount(ctx contractapi.TransactionContextInterface, account *LoyaltyAccount, amount int64) error {
+	if err := requireOpenAccount(account); err != nil {
+		return err
+	}
+	if account.DeltaMode {
+		if _, err := foldDeltas(ctx, account, true); err != nil {
+			return err
+		}
+	}
+	return debit(account, amount)
+}
+
+// foldDeltas cộng các delta đang chờ vào `account` và trả về số delta đã gộp.
+// consume = true xóa các delta đã gộp (người gọi phải ghi lại tài khoản trong cùng giao dịch), liệt kê chúng
+// trong FoldedDeltas và ghi dấu đạt ngưỡng giới thiệu nếu LifetimeEarned vừa vượt ngưỡng (xem referral.go);
+// consume = false chỉ dùng cho truy vấn để trả về số dư bao gồm các delta chưa gộp.
+func foldDeltas(ctx contractapi.TransactionContextInterface, account *LoyaltyAccount, consume bool) (int, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(balanceDeltaObjectType, []string{account.CustomerID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query balance deltas: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	earnedBefore := account.LifetimeEarned
+	folded := 0
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return folded, fmt.Errorf("failed to iterate balance deltas: %v", err)
+		}
+		var delta BalanceDelta
+		if err := json.Unmarshal(queryResult.Value, &delta); err != nil {
+			return folded, fmt.Errorf("failed to unmarshal balance delta: %v", err)
+		}
+		if account.Balance, err = addPoints(account.Balance, delta.Amount); err != nil {
+			return folded, err
+		}
+		if account.LifetimeEarned, err = addPoints(account.LifetimeEarned, delta.LifetimeEarned); err != nil {
+			return folded, err
+		}
+		if consume {
+			if err := ctx.GetStub().DelState(queryResult.Key); err != nil {
+				return folded, fmt.Errorf("failed to delete balance delta: %v", err)
+			}
+			if !account.foldedInTx {
+				account.FoldedDeltas = nil
+				account.foldedInTx = true
+			}
+			account.FoldedDeltas = append(account.FoldedDeltas, FoldedDelta{TransactionID: delta.TransactionID, Amount: delta.Amount, Timestamp: delta.Timestamp})
+		}
+		folded++
+	}
+	if consume {
+		if err := markReferralQualified(ctx, nil, account, earnedBefore); err != nil {
+			return folded, err
+		}
+	}
+	return folded, nil
+}
+
+// sumPendingDeltas cộng tất cả delta chưa gộp của mọi tài khoản bằng range query phân trang (chỉ dùng khi evaluate)
+func sumPendingDeltas(ctx contractapi.TransactionContextInterface, pageSize int) (int64, error) {
+	return sumObjectPages(ctx, balanceDeltaObjectType, pageSize, func(value []byte) (int64, error) {
+		var delta BalanceDelta
+		if err := json.Unmarshal(value, &delta); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal balance delta: %v", err)
+		}
+		return delta.Amount, nil
+	})
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDeltaModeCredits(t *testing.T) {
+	// MERCHANT (500 điểm) ở chế độ delta nhận 100 điểm phát hành và 50 điểm ALICE chuyển sang, mỗi khoản trong một giao dịch
+	tests := []struct {
+		name        string
+		then        func(ctx *testContext) error // thao tác sau hai lần ghi có
+		wantStored  int64                        // số dư trên key tài khoản
+		wantEarned  int64                        // LifetimeEarned trên key tài khoản
+		wantDeltas  int                          // số delta còn chờ gộp
+		wantFolded  int                          // số delta trong FoldedDeltas
+		wantBalance int64                        // số dư trả về cho truy vấn
+	}{
+		{name: "credits held as deltas", wantStored: 500, wantEarned: 500, wantDeltas: 2, wantBalance: 650},
+		{
+			name: "consolidated",
+			then: func(ctx *testContext) error {
+				_, err := (&AccountContract{}).Consolidate(ctx, "MERCHANT")
+				return err
+			},
+			wantStored: 650, wantEarned: 600, wantFolded: 2, wantBalance: 650,
+		},
+		{
+			name: "folded before a debit",
+			then: func(ctx *testContext) error {
+				_, err := (&AccountContract{}).RedeemPoints(ctx, "MERCHANT", 600, "coffee", "")
+				return err
+			},
+			wantStored: 50, wantEarned: 600, wantFolded: 2, wantBalance: 50,
+		},
+		{
+			name: "folded when delta mode is turned off",
+			then: func(ctx *testContext) error {
+				_, err := (&AdminContract{}).SetDeltaMode(ctx, "MERCHANT", false)
+				return err
+			},
+			wantStored: 650, wantEarned: 600, wantFolded: 2, wantBalance: 650,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			contract := &AccountContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000, "MERCHANT": 500})
+			ctx.mustTx(testTime, func() error {
+				_, err := (&AdminContract{}).SetDeltaMode(ctx, "MERCHANT", true)
+				return err
+			})
+			accountKey, err := ctx.stub.CreateCompositeKey(accountObjectType, []string{"MERCHANT"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, credit := range []func() error{
+				func() error {
+					_, err := contract.IssuePoints(ctx, "MERCHANT", 100, "sale", "")
+					return err
+				},
+				func() error {
+					_, err := contract.TransferPoints(ctx, "ALICE", "MERCHANT", 50, "payment", "", "", "", "")
+					return err
+				},
+			} {
+				ctx.begin(testTime)
+				if err := credit(); err != nil {
+					t.Fatal(err)
+				}
+				// Giao dịch ghi có không ghi key tài khoản, nên không xung đột với các giao dịch ghi có khác
+				if _, written := ctx.stub.writes[accountKey]; written {
+					t.Error("credit wrote the account key of a delta mode account")
+				}
+				ctx.commit()
+			}
+			if tt.then != nil {
+				ctx.mustTx(testTime, func() error { return tt.then(ctx) })
+			}
+
+			var stored LoyaltyAccount
+			if err := json.Unmarshal(ctx.ledgerState(accountObjectType, "MERCHANT"), &stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored.Balance != tt.wantStored || stored.LifetimeEarned != tt.wantEarned || len(stored.FoldedDeltas) != tt.wantFolded {
+				t.Errorf("stored account = balance %d, earned %d, %d folded deltas; want %d, %d, %d",
+					stored.Balance, stored.LifetimeEarned, len(stored.FoldedDeltas), tt.wantStored, tt.wantEarned, tt.wantFolded)
+			}
+			deltas := 0
+			for key := range ctx.stub.State {
+				if objectType, _, err := ctx.stub.SplitCompositeKey(key); err == nil && objectType == balanceDeltaObjectType {
+					deltas++
+				}
+			}
+			if deltas != tt.wantDeltas {
+				t.Errorf("%d deltas pending, want %d", deltas, tt.wantDeltas)
+			}
+			if ctx.balance("MERCHANT") != tt.wantBalance {
+				t.Errorf("queried balance = %d, want %d", ctx.balance("MERCHANT"), tt.wantBalance)
+			}
+		})
+	}
+}
+
+func TestConsolidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		credits    int   // số lần ghi có 100 điểm trước khi gộp
+		wantEvent  bool  // ConsolidateEvent được phát ra
+		wantDeltas int   // số delta trong sự kiện
+		wantStored int64 // số dư trên key tài khoản sau khi gộp
+	}{
+		{name: "pending deltas", credits: 3, wantEvent: true, wantDeltas: 3, wantStored: 300},
+		{name: "nothing to fold", wantStored: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			contract := &AccountContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"MERCHANT": 0})
+			ctx.mustTx(testTime, func() error {
+				_, err := (&AdminContract{}).SetDeltaMode(ctx, "MERCHANT", true)
+				return err
+			})
+			for i := 0; i < tt.credits; i++ {
+				ctx.mustTx(testTime, func() error {
+					_, err := contract.IssuePoints(ctx, "MERCHANT", 100, "sale", "")
+					return err
+				})
+			}
+
+			ctx.begin(testTime)
+			account, err := contract.Consolidate(ctx, "MERCHANT")
+			if err != nil {
+				t.Fatal(err)
+			}
+			event := ctx.commit()
+			if account.Balance != tt.wantStored {
+				t.Errorf("returned balance = %d, want %d", account.Balance, tt.wantStored)
+			}
+			if (event != nil) != tt.wantEvent {
+				t.Fatalf("event = %v, want event: %v", event, tt.wantEvent)
+			}
+			if event != nil {
+				var payload struct {
+					Deltas  int   `json:"deltas"`
+					Balance int64 `json:"balance"`
+				}
+				if event.EventName != "ConsolidateEvent" || json.Unmarshal(event.Payload, &payload) != nil ||
+					payload.Deltas != tt.wantDeltas || payload.Balance != tt.wantStored {
+					t.Errorf("event = %s %s, want ConsolidateEvent with %d deltas and balance %d", event.EventName, event.Payload, tt.wantDeltas, tt.wantStored)
+				}
+			}
+			var stored LoyaltyAccount
+			if err := json.Unmarshal(ctx.ledgerState(accountObjectType, "MERCHANT"), &stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored.Balance != tt.wantStored {
+				t.Errorf("stored balance = %d, want %d", stored.Balance, tt.wantStored)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// bankOrgMSP là tổ chức xác nhận mặc định của mọi tài khoản (chính sách xác nhận của chaincode)
+const bankOrgMSP = "BankOrgMSP"
+
+// mspIDPattern giới hạn MSP ID trong chính sách xác nhận của tài khoản
+var mspIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// AccountPolicy định nghĩa chính sách xác nhận (endorsement) cấp key của một tài khoản
+type AccountPolicy struct {
+	CustomerID    string   `json:"customerID"`
+	EndorsingOrgs []string `json:"endorsingOrgs"` // mọi tổ chức trong danh sách phải xác nhận; rỗng = chính sách của chaincode
+}
+
+// =========================================================================================
+// UC-027: Chính sách xác nhận cho tài khoản giá trị cao
+// Tài khoản doanh nghiệp và tài khoản phí của merchant cần nhiều tổ chức xác nhận khi thay đổi;
+// tài khoản thông thường chỉ cần BankOrgMSP theo chính sách xác nhận của chaincode.
+// Chỉ identity của BankOrgMSP có thuộc tính role=admin được phép gọi (kiểm tra trong BeforeTransaction).
+//
+// Logic chính:
+// 1. Kiểm tra `endorsingOrgs`: MSP ID hợp lệ, không trùng lặp và phải có BankOrgMSP.
+//    Danh sách rỗng -> gỡ chính sách cấp key, tài khoản trở lại chính sách của chaincode.
+// 2. Tài khoản phải tồn tại, chưa bị đóng và không ở chế độ delta
+//    (delta được ghi vào key riêng nên sẽ không chịu chính sách của key tài khoản).
+// 3. Lưu danh sách tổ chức vào LoyaltyAccount.EndorsingOrgs để client chọn đúng peer xác nhận,
+//    và vào chỉ mục accountPolicy~customerID để GetPolicyOrgs trả về tổ chức của mọi chính sách.
+//    Tài khoản cũ được di chuyển khỏi key đơn giản sang key account~customerID.
+// 4. Tạo chính sách "tất cả tổ chức trong danh sách" (vai trò peer) bằng statebased và gắn vào key
+//    account~customerID bằng SetStateValidationParameter. Việc thay đổi chính sách cũng phải thỏa chính sách cũ.
+// 5. Phát ra sự kiện "AccountPolicyEvent".
+// =========================================================================================
+func (s *AdminContract) SetAccountPolicy(ctx contractapi.TransactionContextInterface, customerID string, endorsingOrgs []string) (*AccountPolicy, error) {
+	// 1. Kiểm tra danh sách tổ chức
+	orgs := append([]string{}, endorsingOrgs...)
+	sort.Strings(orgs)
+	for i, org := range orgs {
+		if !mspIDPattern.MatchString(org) {
+			return nil, errInvalidArgument("invalid MSP ID in endorsing orgs: %q", org)
+		}
+		if i > 0 && orgs[i-1] == org {
+			return nil, errInvalidArgument("duplicate MSP ID in endorsing orgs: %s", org)
+		}
+	}
+	if !containsOrEmpty(orgs, bankOrgMSP) {
+		return nil, errInvalidArgument("endorsing orgs must include %s", bankOrgMSP)
+	}
+
+	// 2. Tài khoản
+	account, err := getAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(account); err != nil {
+		return nil, err
+	}
+	if account.DeltaMode {
+		return nil, errInvalidArgument("account '%s' is in delta mode; disable it before attaching an endorsement policy", customerID)
+	}
+
+	// 3. Lưu danh sách tổ chức
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	account.EndorsingOrgs = nil
+	if len(orgs) > 0 {
+		account.EndorsingOrgs = orgs
+	}
+	account.LastUpdated = now.Format(time.RFC3339)
+	if err := putAccount(ctx, account); err != nil {
+		return nil, err
+	}
+	if err := putAccountPolicyIndex(ctx, customerID, orgs); err != nil {
+		return nil, err
+	}
+
+	// 4. Chính sách cấp key
+	var policy []byte
+	if len(orgs) > 0 {
+		endorsementPolicy, err := statebased.NewStateEP(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create endorsement policy: %v", err)
+		}
+		if err := endorsementPolicy.AddOrgs(statebased.RoleTypePeer, orgs...); err != nil {
+			return nil, fmt.Errorf("failed to add orgs to endorsement policy: %v", err)
+		}
+		if policy, err = endorsementPolicy.Policy(); err != nil {
+			return nil, fmt.Errorf("failed to marshal endorsement policy: %v", err)
+		}
+	}
+	accountKey, err := ledgerKey(ctx, accountObjectType, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().SetStateValidationParameter(accountKey, policy); err != nil {
+		return nil, fmt.Errorf("failed to set endorsement policy for account '%s': %v", customerID, err)
+	}
+
+	// 5. Sự kiện
+	accountPolicy := AccountPolicy{CustomerID: customerID, EndorsingOrgs: orgs}
+	policyJSON, err := json.Marshal(accountPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal account policy: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent("AccountPolicyEvent", policyJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for account policy: %v", err)
+	}
+	return &accountPolicy, nil
+}
+
+// GetAccountPolicy trả về các tổ chức phải xác nhận thay đổi trên tài khoản, đọc từ chính sách cấp key.
+// Danh sách rỗng nghĩa là tài khoản dùng chính sách xác nhận của chaincode.
+func (s *AccountContract) GetAccountPolicy(ctx contractapi.TransactionContextInterface, customerID string) (*AccountPolicy, error) {
+	if _, err := getAccount(ctx, customerID); err != nil {
+		return nil, err
+	}
+	accountKey, err := ledgerKey(ctx, accountObjectType, customerID)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := ctx.GetStub().GetStateValidationParameter(accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endorsement policy for account '%s': %v", customerID, err)
+	}
+	accountPolicy := AccountPolicy{CustomerID: customerID, EndorsingOrgs: []string{}}
+	if len(policy) == 0 {
+		return &accountPolicy, nil
+	}
+	endorsementPolicy, err := statebased.NewStateEP(policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode endorsement policy for account '%s': %v", customerID, err)
+	}
+	accountPolicy.EndorsingOrgs = endorsementPolicy.ListOrgs()
+	sort.Strings(accountPolicy.EndorsingOrgs)
+	return &accountPolicy, nil
+}
+
+// GetPolicyOrgs trả về hợp (đã sắp xếp) các tổ chức trong chính sách xác nhận của mọi tài khoản.
+// Các job hàng loạt (hoàn quà tặng, giải phóng điểm chờ, thưởng giới thiệu) chỉ biết tài khoản được ghi
+// trên chuỗi, nên backend gửi chúng tới mọi tổ chức này để thỏa chính sách của bất kỳ tài khoản nào.
+func (s *AccountContract) GetPolicyOrgs(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(accountPolicyObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account policies: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	seen := map[string]bool{}
+	orgs := []string{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate account policies: %v", err)
+		}
+		for _, org := range strings.Split(string(queryResult.Value), ",") {
+			if !seen[org] {
+				seen[org] = true
+				orgs = append(orgs, org)
+			}
+		}
+	}
+	sort.Strings(orgs)
+	return orgs, nil
+}
+
+// putAccountPolicyIndex ghi chỉ mục accountPolicy~<customerID> với các tổ chức (phân tách bằng dấu phẩy),
+// hoặc xóa nó khi tài khoản không còn chính sách cấp key
+func putAccountPolicyIndex(ctx contractapi.TransactionContextInterface, customerID string, orgs []string) error {
+	indexKey, err := ledgerKey(ctx, accountPolicyObjectType, customerID)
+	if err != nil {
+		return err
+	}
+	if len(orgs) == 0 {
+		if err := ctx.GetStub().DelState(indexKey); err != nil {
+			return fmt.Errorf("failed to delete account policy index: %v", err)
+		}
+		return nil
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte(strings.Join(orgs, ","))); err != nil {
+		return fmt.Errorf("failed to put account policy index: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetPolicyOrgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies [][]string // chính sách lần lượt của ALICE, BOB; nil = không đặt
+		clear    []string   // gỡ chính sách sau khi đặt
+		want     []string
+	}{
+		{name: "no policies", want: []string{}},
+		{
+			name:     "union of every policy",
+			policies: [][]string{{"BankOrgMSP", "MerchantOrgMSP"}, {"PartnerOrgMSP", "BankOrgMSP"}},
+			want:     []string{"BankOrgMSP", "MerchantOrgMSP", "PartnerOrgMSP"},
+		},
+		{
+			name:     "removed policy is dropped",
+			policies: [][]string{{"BankOrgMSP", "MerchantOrgMSP"}, {"BankOrgMSP", "PartnerOrgMSP"}},
+			clear:    []string{"BOB"},
+			want:     []string{"BankOrgMSP", "MerchantOrgMSP"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			admin := &AdminContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 0, "BOB": 0})
+			for i, orgs := range tt.policies {
+				customerID := []string{"ALICE", "BOB"}[i]
+				ctx.mustTx(testTime, func() error {
+					_, err := admin.SetAccountPolicy(ctx, customerID, orgs)
+					return err
+				})
+			}
+			for _, customerID := range tt.clear {
+				ctx.mustTx(testTime, func() error {
+					_, err := admin.SetAccountPolicy(ctx, customerID, nil)
+					return err
+				})
+			}
+
+			var orgs []string
+			ctx.mustTx(testTime, func() error {
+				var err error
+				orgs, err = (&AccountContract{}).GetPolicyOrgs(ctx)
+				return err
+			})
+			if !reflect.DeepEqual(orgs, tt.want) {
+				t.Errorf("GetPolicyOrgs = %v, want %v", orgs, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountWritesUseTxTime(t *testing.T) {
+	// Mọi peer xác nhận phải ghi cùng một giá trị, nên thời điểm ghi là thời điểm của giao dịch
+	at := testTime.Add(90 * time.Minute)
+	tests := []struct {
+		name  string
+		write func(ctx *testContext) error
+	}{
+		{name: "create", write: func(ctx *testContext) error {
+			_, err := (&AccountContract{}).CreateLoyaltyAccount(ctx, "CAROL")
+			return err
+		}},
+		{name: "issue", write: func(ctx *testContext) error {
+			_, err := (&AccountContract{}).IssuePoints(ctx, "ALICE", 10, "sale", "")
+			return err
+		}},
+		{name: "redeem", write: func(ctx *testContext) error {
+			_, err := (&AccountContract{}).RedeemPoints(ctx, "ALICE", 10, "coffee", "")
+			return err
+		}},
+		{name: "transfer", write: func(ctx *testContext) error {
+			_, err := (&AccountContract{}).TransferPoints(ctx, "ALICE", "BOB", 10, "gift", "", "", "", "")
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 100, "BOB": 0})
+			ctx.mustTx(at, func() error { return tt.write(ctx) })
+
+			for _, customerID := range []string{"ALICE", "BOB", "CAROL"} {
+				accountJSON := ctx.ledgerState(accountObjectType, customerID)
+				if accountJSON == nil {
+					continue
+				}
+				var account LoyaltyAccount
+				if err := json.Unmarshal(accountJSON, &account); err != nil {
+					t.Fatal(err)
+				}
+				if account.LastUpdated != testTime.Format(time.RFC3339) && account.LastUpdated != at.Format(time.RFC3339) {
+					t.Errorf("%s lastUpdated = %s, want the transaction time %s", customerID, account.LastUpdated, at.Format(time.RFC3339))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Danh mục mã lỗi trả về cho client. Mã lỗi là một phần của API: không đổi tên hoặc xóa mã đã công bố,
+// backend ánh xạ chúng sang HTTP status (xem loyalty-backend/pkg/fabric/errors.go).
+// Lỗi hạ tầng (đọc/ghi World State, marshal JSON) vẫn dùng fmt.Errorf và được client coi là lỗi nội bộ.
+const (
+	ErrCodeInvalidArgument        = "INVALID_ARGUMENT"
+	ErrCodeAccessDenied           = "ACCESS_DENIED"
+	ErrCodeUnknownTransaction     = "UNKNOWN_TRANSACTION"
+	ErrCodeAccountNotFound        = "ACCOUNT_NOT_FOUND"
+	ErrCodeAccountAlreadyExists   = "ACCOUNT_ALREADY_EXISTS"
+	ErrCodeAccountClosed          = "ACCOUNT_CLOSED"
+	ErrCodeInsufficientBalance    = "INSUFFICIENT_BALANCE"
+	ErrCodeInsufficientAllowance  = "INSUFFICIENT_ALLOWANCE"
+	ErrCodeAmountLimitExceeded    = "AMOUNT_LIMIT_EXCEEDED"
+	ErrCodeRewardNotFound         = "REWARD_NOT_FOUND"
+	ErrCodeRewardAlreadyExists    = "REWARD_ALREADY_EXISTS"
+	ErrCodeRewardUnavailable      = "REWARD_UNAVAILABLE"
+	ErrCodeCampaignNotFound       = "CAMPAIGN_NOT_FOUND"
+	ErrCodeCampaignAlreadyExists  = "CAMPAIGN_ALREADY_EXISTS"
+	ErrCodeVoucherNotFound        = "VOUCHER_NOT_FOUND"
+	ErrCodeVoucherAlreadyExists   = "VOUCHER_ALREADY_EXISTS"
+	ErrCodeVoucherNotUsable       = "VOUCHER_NOT_USABLE"
+	ErrCodeHouseholdNotFound      = "HOUSEHOLD_NOT_FOUND"
+	ErrCodeHouseholdAlreadyExists = "HOUSEHOLD_ALREADY_EXISTS"
+	ErrCodeGiftNotFound           = "GIFT_NOT_FOUND"
+	ErrCodeGiftAlreadyExists      = "GIFT_ALREADY_EXISTS"
+	ErrCodeGiftNotClaimable       = "GIFT_NOT_CLAIMABLE"
+	ErrCodeSwapNotFound           = "SWAP_NOT_FOUND"
+	ErrCodeSwapAlreadyExists      = "SWAP_ALREADY_EXISTS"
+	ErrCodeSwapNotClaimable       = "SWAP_NOT_CLAIMABLE"
+	ErrCodeSwapNotRefundable      = "SWAP_NOT_REFUNDABLE"
+
+	ErrCodePendingAwardNotFound      = "PENDING_AWARD_NOT_FOUND"
+	ErrCodePendingAwardAlreadyExists = "PENDING_AWARD_ALREADY_EXISTS"
+	ErrCodePendingAwardClosed        = "PENDING_AWARD_CLOSED"
+	ErrCodeCurrencyNotAllowed        = "CURRENCY_NOT_ALLOWED"
+	ErrCodeBalanceNotExpired         = "BALANCE_NOT_EXPIRED"
+	ErrCodeInvalidSignature          = "INVALID_SIGNATURE"
+	ErrCodeNonceAlreadyUsed          = "NONCE_ALREADY_USED"
+	ErrCodeAuthorizationExpired      = "AUTHORIZATION_EXPIRED"
+	ErrCodeAuthorizationNotFound     = "AUTHORIZATION_NOT_FOUND"
+	ErrCodeFlaggedTransferNotFound   = "FLAGGED_TRANSFER_NOT_FOUND"
+	ErrCodeTransferNotFlagged        = "TRANSFER_NOT_FLAGGED"
+	ErrCodeVelocityLimitExceeded     = "VELOCITY_LIMIT_EXCEEDED"
+
+	ErrCodeReferralNotFound      = "REFERRAL_NOT_FOUND"
+	ErrCodeReferralAlreadyExists = "REFERRAL_ALREADY_EXISTS"
+	ErrCodeReferralLimitReached  = "REFERRAL_LIMIT_REACHED"
+)
+
+// ChaincodeError là lỗi có cấu trúc trả về cho client, được tuần tự hóa thành JSON trong thông báo lỗi, ví dụ:
+// {"code":"INSUFFICIENT_BALANCE","message":"insufficient balance: ...","details":{"balance":10,"requested":50}}
+type ChaincodeError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Error trả về lỗi dưới dạng JSON để client có thể giải mã
+func (e *ChaincodeError) Error() string {
+	errorJSON, err := json.Marshal(e)
+	if err != nil {
+		return e.Message
+	}
+	return string(errorJSON)
+}
+
+// newChaincodeError tạo lỗi có cấu trúc với mã lỗi, chi tiết (có thể nil) và thông báo
+func newChaincodeError(code string, details map[string]interface{}, format string, args ...interface{}) *ChaincodeError {
+	return &ChaincodeError{Code: code, Message: fmt.Sprintf(format, args...), Details: details}
+}
+
+// errInvalidArgument tạo lỗi INVALID_ARGUMENT cho tham số đầu vào không hợp lệ
+func errInvalidArgument(format string, args ...interface{}) *ChaincodeError {
+	return newChaincodeError(ErrCodeInvalidArgument, nil, format, args...)
+}
+
+// errAccessDenied tạo lỗi ACCESS_DENIED khi người gọi không có quyền
+func errAccessDenied(format string, args ...interface{}) *ChaincodeError {
+	return newChaincodeError(ErrCodeAccessDenied, nil, "access denied: "+format, args...)
+}
+
+// errAccountNotFound tạo lỗi ACCOUNT_NOT_FOUND
+func errAccountNotFound(customerID string) *ChaincodeError {
+	return newChaincodeError(ErrCodeAccountNotFound, map[string]interface{}{"customerID": customerID},
+		"loyalty account with customer ID '%s' does not exist", customerID)
+}
+
+// errInsufficientBalance tạo lỗi INSUFFICIENT_BALANCE kèm số dư hiện tại và số điểm yêu cầu
+func errInsufficientBalance(customerID string, balance, requested int64) *ChaincodeError {
+	return newChaincodeError(ErrCodeInsufficientBalance,
+		map[string]interface{}{"customerID": customerID, "balance": balance, "requested": requested},
+		"insufficient balance: current balance is %d, requested amount is %d", balance, requested)
+}
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	GiftStatusPending  = "PENDING"
+	GiftStatusClaimed  = "CLAIMED"
+	GiftStatusRefunded = "REFUNDED"
+	// GiftStatusRefundFailed: không hoàn được điểm về tài khoản nguồn, chờ RefundGift thử lại
+	GiftStatusRefundFailed = "REFUND_FAILED"
+
+	// maxGiftValidityDays giới hạn thời hạn của một quà tặng để điểm không bị giữ quá lâu
+	maxGiftValidityDays = 90
+	// defaultGiftRefundLimit áp dụng khi RefundExpiredGifts được gọi với limit <= 0
+	defaultGiftRefundLimit = 50
+)
+
+// Gift định nghĩa một khoản điểm quà tặng được giữ (escrow) cho đến khi người nhận trình bày bí mật.
+// GiftID chính là hash SHA-256 (hex) của bí mật, nên ClaimGift chỉ cần bí mật để tìm quà tặng.
+type Gift struct {
+	GiftID        string `json:"giftID"`
+	SourceID      string `json:"sourceID"`
+	Amount        int64  `json:"amount"`
+	CreatedAt     string `json:"createdAt"`
+	ExpiresAt     string `json:"expiresAt"`
+	Status        string `json:"status"` // PENDING, CLAIMED, REFUNDED, REFUND_FAILED
+	ClaimedBy     string `json:"claimedBy,omitempty"`
+	ClosedAt      string `json:"closedAt,omitempty"`
+	FailureReason string `json:"failureReason,omitempty"` // mã lỗi khi hoàn điểm thất bại (REFUND_FAILED)
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+// isExpiredAt kiểm tra quà tặng đã hết hạn tại thời điểm t hay chưa
+func (g *Gift) isExpiredAt(t time.Time) bool {
+	expiresAt, err := ParseTimestamp(g.ExpiresAt)
+	if err != nil {
+		return true
+	}
+	return !t.Before(expiresAt)
+}
+
+// =========================================================================================
+// UC-022: Tặng điểm bằng mã nhận quà khóa bằng hash
+// Người nhận chưa cần có tài khoản: người gửi chia sẻ bí mật (qua link/QR do backend tạo),
+// người nhận tạo tài khoản rồi nhận quà bằng bí mật đó.
+//
+// Logic chính:
+// 1. CreateGift: người gọi phải được phép đại diện cho `sourceID`. `hashOfSecret` là SHA-256 (hex)
+//    của bí mật, chưa được dùng; `expiry` (RFC3339) phải sau thời điểm giao dịch và trong vòng 90 ngày.
+//    Điểm được trừ khỏi tài khoản nguồn và giữ trong bản ghi Gift trạng thái PENDING.
+//    Tài khoản nguồn đã đăng ký khóa phải kèm ủy quyền đã ký với `target` là hash của quà tặng (UC-028).
+// 2. ClaimGift: hash của `secret` phải khớp một quà tặng PENDING chưa hết hạn; điểm được cộng vào
+//    `targetID` (người gọi phải được phép đại diện cho tài khoản đích) và quà tặng chuyển sang CLAIMED.
+// 3. Quà tặng hết hạn được hoàn lại cho người gửi (REFUNDED) bởi RefundGift hoặc RefundExpiredGifts
+//    (job định kỳ của backend). Nếu tài khoản nguồn không ghi có được (đã đóng mà chưa gộp, vượt số dư
+//    tối đa...), RefundExpiredGifts chuyển các quà tặng của người gửi đó sang REFUND_FAILED cùng mã lỗi,
+//    chuyển chúng sang chỉ mục giftRefundFailed~giftID và vẫn hoàn lại các quà tặng khác.
+//    RefundGift hoàn lại quà tặng REFUND_FAILED sau khi tài khoản đã được xử lý.
+// 4. Mỗi thao tác phát ra sự kiện "CreateGiftEvent", "ClaimGiftEvent", "RefundGiftEvent" hoặc
+//    "RefundExpiredGiftsEvent" (danh sách các quà tặng đã hoàn lại).
+// Lưu ý: bí mật trở nên công khai trên sổ cái khi quà tặng được nhận.
+// =========================================================================================
+func (s *AccountContract) CreateGift(ctx contractapi.TransactionContextInterface, sourceID string, amount int64, hashOfSecret string, expiry string, nonce string, expiresAt string, signature string) (*Gift, error) {
+	// 1. Kiểm tra đầu vào
+	if amount <= 0 {
+		return nil, errInvalidArgument("amount must be a positive integer, got: %d", amount)
+	}
+	hashLock := strings.ToLower(hashOfSecret)
+	if decoded, err := hex.DecodeString(hashLock); err != nil || len(decoded) != sha256.Size {
+		return nil, errInvalidArgument("hash of secret must be a hex-encoded SHA-256 digest, got: %q", hashOfSecret)
+	}
+	giftExpiresAt, err := ParseTimestamp(expiry)
+	if err != nil {
+		return nil, errInvalidArgument("expiry must be an RFC3339 timestamp, got: %q", expiry)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !giftExpiresAt.After(now) || giftExpiresAt.After(now.AddDate(0, 0, maxGiftValidityDays)) {
+		return nil, errInvalidArgument("expiry must be within %d days after the transaction time, got: %s", maxGiftValidityDays, expiry)
+	}
+	if err := requireActingFor(ctx, sourceID); err != nil {
+		return nil, err
+	}
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.checkTransactionAmount(amount); err != nil {
+		return nil, err
+	}
+
+	giftKey, err := ledgerKey(ctx, giftObjectType, hashLock)
+	if err != nil {
+		return nil, err
+	}
+	existingGiftJSON, err := ctx.GetStub().GetState(giftKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existingGiftJSON != nil {
+		return nil, newChaincodeError(ErrCodeGiftAlreadyExists, map[string]interface{}{"giftID": hashLock}, "a gift with this hash already exists")
+	}
+
+	// Giữ điểm của người gửi; mỗi quà tặng được tính là một người nhận khác nhau theo quy tắc tốc độ (UC-029)
+	account, err := getAccount(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyTransferAuthorization(ctx, authorizationGift, account, hashLock, amount, DefaultCurrency, nonce, expiresAt, signature); err != nil {
+		return nil, err
+	}
+	if err := config.Velocity.checkOutbound(account, hashLock, now); err != nil {
+		return nil, err
+	}
+	if err := debitAccount(ctx, account, amount); err != nil {
+		return nil, err
+	}
+	account.LastUpdated = now.Format(time.RFC3339)
+	if err := putAccount(ctx, account); err != nil {
+		return nil, err
+	}
+
+	gift := Gift{
+		GiftID:    hashLock,
+		SourceID:  sourceID,
+		Amount:    amount,
+		CreatedAt: account.LastUpdated,
+		ExpiresAt: giftExpiresAt.UTC().Format(time.RFC3339),
+		Status:    GiftStatusPending,
+	}
+	if err := putGift(ctx, &gift); err != nil {
+		return nil, err
+	}
+	if err := putGiftExpiryIndex(ctx, &gift); err != nil {
+		return nil, err
+	}
+
+	// 4. Sự kiện
+	if err := setGiftEvent(ctx, "CreateGiftEvent", &gift); err != nil {
+		return nil, err
+	}
+	return &gift, nil
+}
+
+// ClaimGift nhận quà tặng có hash của `secret` vào tài khoản `targetID` (xem UC-022)
+func (s *AccountContract) ClaimGift(ctx contractapi.TransactionContextInterface, secret string, targetID string) (*Gift, error) {
+	if secret == "" {
+		return nil, errInvalidArgument("secret cannot be empty")
+	}
+	if err := requireActingFor(ctx, targetID); err != nil {
+		return nil, err
+	}
+
+	// 2. Tìm quà tặng theo hash của bí mật
+	hash := sha256.Sum256([]byte(secret))
+	gift, err := getGift(ctx, hex.EncodeToString(hash[:]))
+	if err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := gift.checkClaimable(now); err != nil {
+		return nil, err
+	}
+
+	// Cộng điểm cho người nhận
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	account, err := getAccount(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	deferred, err := creditAccount(ctx, config, account, gift.Amount, false)
+	if err != nil {
+		return nil, err
+	}
+	account.LastUpdated = now.Format(time.RFC3339)
+	if !deferred {
+		if err := putAccount(ctx, account); err != nil {
+			return nil, err
+		}
+	}
+
+	gift.Status = GiftStatusClaimed
+	gift.ClaimedBy = targetID
+	gift.ClosedAt = account.LastUpdated
+	if err := closeGift(ctx, gift); err != nil {
+		return nil, err
+	}
+
+	// 4. Sự kiện
+	if err := setGiftEvent(ctx, "ClaimGiftEvent", gift); err != nil {
+		return nil, err
+	}
+	return gift, nil
+}
+
+// RefundGift hoàn lại một quà tặng đã hết hạn, hoặc thử lại một quà tặng REFUND_FAILED, cho người gửi.
+// Bất kỳ ai cũng có thể gọi vì điểm chỉ được trả về tài khoản nguồn.
+func (s *AccountContract) RefundGift(ctx contractapi.TransactionContextInterface, giftID string) (*Gift, error) {
+	gift, err := getGift(ctx, strings.ToLower(giftID))
+	if err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if gift.Status != GiftStatusPending && gift.Status != GiftStatusRefundFailed {
+		return nil, newChaincodeError(ErrCodeGiftNotClaimable, map[string]interface{}{"giftID": gift.GiftID, "status": gift.Status}, "gift is already %s", strings.ToLower(gift.Status))
+	}
+	if !gift.isExpiredAt(now) {
+		return nil, newChaincodeError(ErrCodeGiftNotClaimable, map[string]interface{}{"giftID": gift.GiftID, "expiresAt": gift.ExpiresAt}, "gift cannot be refunded before it expires at %s", gift.ExpiresAt)
+	}
+	if err := refundGift(ctx, gift, now); err != nil {
+		return nil, err
+	}
+	return gift, nil
+}
+
+// RefundExpiredGifts hoàn lại tối đa `limit` quà tặng đã hết hạn, theo thứ tự hết hạn (chỉ BankOrgMSP).
+// Được backend gọi định kỳ để việc hoàn điểm diễn ra tự động.
+func (s *AccountContract) RefundExpiredGifts(ctx contractapi.TransactionContextInterface, limit int) ([]*Gift, error) {
+	if limit <= 0 {
+		limit = defaultGiftRefundLimit
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Chỉ mục giftExpiry~<expiresAt>~<giftID> được sắp xếp theo thời điểm hết hạn (RFC3339 UTC)
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(giftExpiryObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query gift expiry index: %v", err)
+	}
+	var expiredIDs []string
+	for resultsIterator.HasNext() && len(expiredIDs) < limit {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			resultsIterator.Close()
+			return nil, fmt.Errorf("failed to iterate gift expiry index: %v", err)
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			resultsIterator.Close()
+			return nil, fmt.Errorf("failed to split gift expiry key: %v", err)
+		}
+		expiresAt, err := ParseTimestamp(keyParts[0])
+		if err != nil || now.Before(expiresAt) {
+			break
+		}
+		expiredIDs = append(expiredIDs, keyParts[1])
+	}
+	resultsIterator.Close()
+
+	// Gom điểm theo tài khoản nguồn: trong một giao dịch GetState không thấy các lần ghi trước đó,
+	// nên một người gửi có nhiều quà tặng hết hạn chỉ được ghi có một lần.
+	processed := []*Gift{}
+	giftSources := map[string]string{} // giftID -> tài khoản nhận hoàn
+	var sourceIDs []string
+	sourceAmounts := map[string]int64{}
+	for _, giftID := range expiredIDs {
+		gift, err := getGift(ctx, giftID)
+		if err != nil {
+			return nil, err
+		}
+		// Tài khoản nguồn có thể đã được gộp vào tài khoản khác sau khi tạo quà tặng
+		sourceID, err := resolveAccountID(ctx, gift.SourceID)
+		if err != nil {
+			return nil, err
+		}
+		if _, seen := sourceAmounts[sourceID]; !seen {
+			sourceIDs = append(sourceIDs, sourceID)
+		}
+		if sourceAmounts[sourceID], err = addPoints(sourceAmounts[sourceID], gift.Amount); err != nil {
+			return nil, err
+		}
+		giftSources[gift.GiftID] = sourceID
+		processed = append(processed, gift)
+	}
+	if len(processed) == 0 {
+		return processed, nil
+	}
+
+	// Người gửi không ghi có được không chặn các người gửi khác: quà tặng của họ chuyển sang REFUND_FAILED
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	failures := map[string]*ChaincodeError{}
+	for _, sourceID := range sourceIDs {
+		failure, err := checkCredit(ctx, config, sourceID, sourceAmounts[sourceID])
+		if err != nil {
+			return nil, err
+		}
+		if failure != nil {
+			failures[sourceID] = failure
+			continue
+		}
+		if err := refundToAccount(ctx, config, sourceID, sourceAmounts[sourceID], now); err != nil {
+			return nil, err
+		}
+	}
+	for _, gift := range processed {
+		if failure := failures[giftSources[gift.GiftID]]; failure != nil {
+			gift.Status = GiftStatusRefundFailed
+			gift.FailureReason = failure.Code
+			if err := failGiftRefund(ctx, gift); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		gift.Status = GiftStatusRefunded
+		gift.ClosedAt = now.Format(time.RFC3339)
+		if err := closeGift(ctx, gift); err != nil {
+			return nil, err
+		}
+	}
+
+	// Một giao dịch chỉ giữ một sự kiện: phát ra danh sách các quà tặng đã xử lý
+	processedJSON, err := json.Marshal(processed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal gift refund event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent("RefundExpiredGiftsEvent", processedJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for gift refunds: %v", err)
+	}
+	return processed, nil
+}
+
+// GetGift truy vấn một quà tặng theo ID (hash của bí mật)
+func (s *AccountContract) GetGift(ctx contractapi.TransactionContextInterface, giftID string) (*Gift, error) {
+	return getGift(ctx, strings.ToLower(giftID))
+}
+
+// checkClaimable trả về lỗi nếu quà tặng không còn nhận được tại thời điểm t
+func (g *Gift) checkClaimable(t time.Time) error {
+	if g.Status != GiftStatusPending {
+		return newChaincodeError(ErrCodeGiftNotClaimable, map[string]interface{}{"giftID": g.GiftID, "status": g.Status}, "gift is already %s", strings.ToLower(g.Status))
+	}
+	if g.isExpiredAt(t) {
+		return newChaincodeError(ErrCodeGiftNotClaimable, map[string]interface{}{"giftID": g.GiftID, "expiresAt": g.ExpiresAt}, "gift expired at %s", g.ExpiresAt)
+	}
+	return nil
+}
+
+// refundGift trả điểm của quà tặng về tài khoản nguồn và chuyển quà tặng sang REFUNDED
+func refundGift(ctx contractapi.TransactionContextInterface, gift *Gift, now time.Time) error {
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return err
+	}
+	// Tài khoản nguồn có thể đã được gộp vào tài khoản khác sau khi tạo quà tặng
+	sourceID, err := resolveAccountID(ctx, gift.SourceID)
+	if err != nil {
+		return err
+	}
+	if err := refundToAccount(ctx, config, sourceID, gift.Amount, now); err != nil {
+		return err
+	}
+
+	gift.Status = GiftStatusRefunded
+	gift.ClosedAt = now.Format(time.RFC3339)
+	gift.FailureReason = ""
+	if err := closeGift(ctx, gift); err != nil {
+		return err
+	}
+	return setGiftEvent(ctx, "RefundGiftEvent", gift)
+}
+
+// refundToAccount ghi có `amount` điểm quà tặng hoàn lại vào tài khoản `sourceID` (không tính vào lifetimeEarned)
+func refundToAccount(ctx contractapi.TransactionContextInterface, config *LedgerConfig, sourceID string, amount int64, now time.Time) error {
+	account, err := getAccount(ctx, sourceID)
+	if err != nil {
+		return err
+	}
+	deferred, err := creditAccount(ctx, config, account, amount, false)
+	if err != nil {
+		return err
+	}
+	account.LastUpdated = now.Format(time.RFC3339)
+	if !deferred {
+		return putAccount(ctx, account)
+	}
+	return nil
+}
+
+// getGift đọc một quà tặng từ World State
+func getGift(ctx contractapi.TransactionContextInterface, giftID string) (*Gift, error) {
+	giftKey, err := ledgerKey(ctx, giftObjectType, giftID)
+	if err != nil {
+		return nil, err
+	}
+	giftJSON, err := ctx.GetStub().GetState(giftKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gift from world state: %v", err)
+	}
+	if giftJSON == nil {
+		return nil, newChaincodeError(ErrCodeGiftNotFound, map[string]interface{}{"giftID": giftID}, "gift does not exist")
+	}
+
+	var gift Gift
+	if err := json.Unmarshal(giftJSON, &gift); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal gift data: %v", err)
+	}
+	return &gift, nil
+}
+
+// putGift ghi một quà tặng vào World State
+func putGift(ctx contractapi.TransactionContextInterface, gift *Gift) error {
+	gift.SchemaVersion = giftSchemaVersion
+	giftKey, err := ledgerKey(ctx, giftObjectType, gift.GiftID)
+	if err != nil {
+		return err
+	}
+	giftJSON, err := json.Marshal(gift)
+	if err != nil {
+		return fmt.Errorf("failed to marshal gift: %v", err)
+	}
+	if err := ctx.GetStub().PutState(giftKey, giftJSON); err != nil {
+		return fmt.Errorf("failed to put state for gift: %v", err)
+	}
+	return nil
+}
+
+// putGiftExpiryIndex ghi chỉ mục giftExpiry~<expiresAt>~<giftID> của quà tặng đang chờ nhận.
+// Giá trị là số điểm đang giữ để ReconcileSupply cộng dồn mà không cần đọc từng quà tặng.
+func putGiftExpiryIndex(ctx contractapi.TransactionContextInterface, gift *Gift) error {
+	indexKey, err := ledgerKey(ctx, giftExpiryObjectType, gift.ExpiresAt, gift.GiftID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte(strconv.FormatInt(gift.Amount, 10))); err != nil {
+		return fmt.Errorf("failed to put gift expiry index: %v", err)
+	}
+	return nil
+}
+
+// failGiftRefund ghi quà tặng REFUND_FAILED và chuyển nó từ chỉ mục hết hạn sang giftRefundFailed~<giftID>,
+// để RefundExpiredGifts không xử lý lại nó mà điểm vẫn được tính là đang giữ
+func failGiftRefund(ctx contractapi.TransactionContextInterface, gift *Gift) error {
+	indexKey, err := ledgerKey(ctx, giftExpiryObjectType, gift.ExpiresAt, gift.GiftID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(indexKey); err != nil {
+		return fmt.Errorf("failed to delete gift expiry index: %v", err)
+	}
+	failedKey, err := ledgerKey(ctx, giftRefundFailedObjectType, gift.GiftID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(failedKey, []byte(strconv.FormatInt(gift.Amount, 10))); err != nil {
+		return fmt.Errorf("failed to put failed gift refund: %v", err)
+	}
+	return putGift(ctx, gift)
+}
+
+// closeGift ghi quà tặng đã nhận/hoàn lại và xóa chỉ mục hết hạn hoặc hoàn điểm thất bại của nó
+func closeGift(ctx contractapi.TransactionContextInterface, gift *Gift) error {
+	indexKey, err := ledgerKey(ctx, giftExpiryObjectType, gift.ExpiresAt, gift.GiftID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(indexKey); err != nil {
+		return fmt.Errorf("failed to delete gift expiry index: %v", err)
+	}
+	failedKey, err := ledgerKey(ctx, giftRefundFailedObjectType, gift.GiftID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(failedKey); err != nil {
+		return fmt.Errorf("failed to delete failed gift refund: %v", err)
+	}
+	return putGift(ctx, gift)
+}
+
+// sumEscrowedGifts cộng số điểm đang giữ trong các quà tặng chờ nhận hoặc hoàn điểm thất bại
+// từ hai chỉ mục của chúng (chỉ dùng khi evaluate)
+func sumEscrowedGifts(ctx contractapi.TransactionContextInterface, pageSize int) (int64, error) {
+	amountOf := func(value []byte) (int64, error) {
+		amount, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse escrowed gift amount: %v", err)
+		}
+		return amount, nil
+	}
+	pending, err := sumObjectPages(ctx, giftExpiryObjectType, pageSize, amountOf)
+	if err != nil {
+		return 0, err
+	}
+	failed, err := sumObjectPages(ctx, giftRefundFailedObjectType, pageSize, amountOf)
+	if err != nil {
+		return 0, err
+	}
+	return addPoints(pending, failed)
+}
+
+// setGiftEvent phát ra sự kiện với dữ liệu quà tặng
+func setGiftEvent(ctx contractapi.TransactionContextInterface, eventName string, gift *Gift) error {
+	giftJSON, err := json.Marshal(gift)
+	if err != nil {
+		return fmt.Errorf("failed to marshal gift event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent(eventName, giftJSON); err != nil {
+		return fmt.Errorf("failed to set event for gift: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// giftHash trả về hashOfSecret của một bí mật như backend tính
+func giftHash(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestRefundExpiredGifts(t *testing.T) {
+	type giftSpec struct {
+		sourceID string
+		amount   int64
+		validFor time.Duration
+	}
+	tests := []struct {
+		name         string
+		deltaMode    []string  // tài khoản chuyển sang chế độ delta trước khi tạo quà tặng
+		merge        [2]string // {survivorID, mergedID} gộp sau khi tạo quà tặng
+		closed       []string  // đóng mà chưa gộp sau khi tạo quà tặng
+		gifts        []giftSpec
+		wantRefunded int               // số quà tặng đã xử lý, gồm cả quà tặng hoàn điểm thất bại
+		wantFailed   map[string]string // sourceID -> failureReason của các quà tặng của người gửi đó
+		wantBalances map[string]int64
+		fix          func(ctx *testContext) // sửa tài khoản trước khi RefundGift thử lại
+		wantRetried  map[string]int64       // số dư sau khi thử lại
+	}{
+		{
+			name: "two expired gifts from the same sender",
+			gifts: []giftSpec{
+				{sourceID: "ALICE", amount: 300, validFor: 24 * time.Hour},
+				{sourceID: "ALICE", amount: 200, validFor: 48 * time.Hour},
+			},
+			wantRefunded: 2,
+			wantBalances: map[string]int64{"ALICE": 1000, "BOB": 1000},
+		},
+		{
+			name: "senders are credited separately",
+			gifts: []giftSpec{
+				{sourceID: "ALICE", amount: 300, validFor: 24 * time.Hour},
+				{sourceID: "BOB", amount: 100, validFor: 24 * time.Hour},
+				{sourceID: "ALICE", amount: 50, validFor: 24 * time.Hour},
+			},
+			wantRefunded: 3,
+			wantBalances: map[string]int64{"ALICE": 1000, "BOB": 1000},
+		},
+		{
+			name:      "delta-mode sender",
+			deltaMode: []string{"ALICE"},
+			gifts: []giftSpec{
+				{sourceID: "ALICE", amount: 300, validFor: 24 * time.Hour},
+				{sourceID: "ALICE", amount: 200, validFor: 24 * time.Hour},
+			},
+			wantRefunded: 2,
+			wantBalances: map[string]int64{"ALICE": 1000, "BOB": 1000},
+		},
+		{
+			name:  "merged sender is refunded through the survivor",
+			merge: [2]string{"BOB", "ALICE"},
+			gifts: []giftSpec{
+				{sourceID: "ALICE", amount: 300, validFor: 24 * time.Hour},
+				{sourceID: "BOB", amount: 200, validFor: 24 * time.Hour},
+			},
+			wantRefunded: 2,
+			wantBalances: map[string]int64{"BOB": 2000},
+		},
+		{
+			name: "gifts that have not expired stay pending",
+			gifts: []giftSpec{
+				{sourceID: "ALICE", amount: 300, validFor: 24 * time.Hour},
+				{sourceID: "ALICE", amount: 200, validFor: 30 * 24 * time.Hour},
+			},
+			wantRefunded: 1,
+			wantBalances: map[string]int64{"ALICE": 800, "BOB": 1000},
+		},
+		{
+			name:   "closed sender fails without blocking the batch",
+			closed: []string{"ALICE"},
+			gifts: []giftSpec{
+				{sourceID: "ALICE", amount: 300, validFor: 24 * time.Hour},
+				{sourceID: "BOB", amount: 100, validFor: 24 * time.Hour},
+				{sourceID: "ALICE", amount: 50, validFor: 24 * time.Hour},
+			},
+			wantRefunded: 3,
+			wantFailed:   map[string]string{"ALICE": ErrCodeAccountClosed},
+			wantBalances: map[string]int64{"ALICE": 650, "BOB": 1000},
+			fix:          func(ctx *testContext) { setAccountStatus(ctx, "ALICE", "ACTIVE") },
+			wantRetried:  map[string]int64{"ALICE": 1000, "BOB": 1000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			contract := &AccountContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000, "BOB": 1000})
+			for _, customerID := range tt.deltaMode {
+				ctx.mustTx(testTime, func() error {
+					_, err := (&AdminContract{}).SetDeltaMode(ctx, customerID, true)
+					return err
+				})
+			}
+			for i, spec := range tt.gifts {
+				secret := tt.name + string(rune('a'+i))
+				ctx.mustTx(testTime, func() error {
+					_, err := contract.CreateGift(ctx, spec.sourceID, spec.amount, giftHash(secret), testTime.Add(spec.validFor).Format(time.RFC3339), "", "", "")
+					return err
+				})
+			}
+			if tt.merge[0] != "" {
+				ctx.mustTx(testTime, func() error {
+					_, err := contract.MergeAccounts(ctx, tt.merge[0], tt.merge[1])
+					return err
+				})
+			}
+			for _, customerID := range tt.closed {
+				setAccountStatus(ctx, customerID, "CLOSED")
+			}
+
+			ctx.begin(testTime.Add(72 * time.Hour))
+			refunded, err := contract.RefundExpiredGifts(ctx, 0)
+			if err != nil {
+				t.Fatalf("RefundExpiredGifts failed: %v", err)
+			}
+			event := ctx.commit()
+
+			if len(refunded) != tt.wantRefunded {
+				t.Fatalf("refunded %d gifts, want %d", len(refunded), tt.wantRefunded)
+			}
+			if event == nil || event.EventName != "RefundExpiredGiftsEvent" {
+				t.Errorf("event = %v, want RefundExpiredGiftsEvent", event)
+			} else {
+				var eventGifts []*Gift
+				if err := json.Unmarshal(event.Payload, &eventGifts); err != nil || len(eventGifts) != tt.wantRefunded {
+					t.Errorf("event lists %d gifts (%v), want %d", len(eventGifts), err, tt.wantRefunded)
+				}
+			}
+			for customerID, want := range tt.wantBalances {
+				if got := ctx.balance(customerID); got != want {
+					t.Errorf("balance of %s = %d, want %d", customerID, got, want)
+				}
+			}
+			var failedAmount int64
+			for _, gift := range refunded {
+				wantStatus, wantReason := GiftStatusRefunded, ""
+				if reason, failed := tt.wantFailed[gift.SourceID]; failed {
+					wantStatus, wantReason = GiftStatusRefundFailed, reason
+					failedAmount += gift.Amount
+				}
+				if gift.Status != wantStatus || gift.FailureReason != wantReason {
+					t.Errorf("gift %s = %s (%q), want %s (%q)", gift.GiftID, gift.Status, gift.FailureReason, wantStatus, wantReason)
+				}
+			}
+
+			// Chạy lại không hoàn tiền lần thứ hai
+			ctx.begin(testTime.Add(72 * time.Hour))
+			again, err := contract.RefundExpiredGifts(ctx, 0)
+			if err != nil {
+				t.Fatalf("second RefundExpiredGifts failed: %v", err)
+			}
+			ctx.commit()
+			if len(again) != 0 {
+				t.Errorf("second run refunded %d gifts, want 0", len(again))
+			}
+
+			// Quà tặng hoàn điểm thất bại vẫn được tính là đang giữ
+			var escrowed int64
+			ctx.mustTx(testTime.Add(72*time.Hour), func() error {
+				var err error
+				escrowed, err = sumEscrowedGifts(ctx, 10)
+				return err
+			})
+			var wantEscrowed int64
+			for _, spec := range tt.gifts {
+				if spec.validFor > 72*time.Hour {
+					wantEscrowed += spec.amount
+				}
+			}
+			if escrowed != wantEscrowed+failedAmount {
+				t.Errorf("escrowed gifts = %d, want %d", escrowed, wantEscrowed+failedAmount)
+			}
+			if tt.fix == nil {
+				return
+			}
+
+			tt.fix(ctx)
+			for _, gift := range refunded {
+				if gift.Status != GiftStatusRefundFailed {
+					continue
+				}
+				ctx.mustTx(testTime.Add(72*time.Hour), func() error {
+					_, err := contract.RefundGift(ctx, gift.GiftID)
+					return err
+				})
+			}
+			for customerID, want := range tt.wantRetried {
+				if got := ctx.balance(customerID); got != want {
+					t.Errorf("balance of %s after retry = %d, want %d", customerID, got, want)
+				}
+			}
+			ctx.mustTx(testTime.Add(72*time.Hour), func() error {
+				var err error
+				escrowed, err = sumEscrowedGifts(ctx, 10)
+				return err
+			})
+			if escrowed != wantEscrowed {
+				t.Errorf("escrowed gifts after retry = %d, want %d", escrowed, wantEscrowed)
+			}
+		})
+	}
+}
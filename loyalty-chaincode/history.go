@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// BalanceAt định nghĩa số dư của một tài khoản tại một thời điểm trong quá khứ
+type BalanceAt struct {
+	CustomerID    string    `json:"customerID"` // tài khoản giữ số dư (tài khoản giữ lại nếu đã gộp trước AsOf)
+	AsOf          time.Time `json:"asOf"`
+	Balance       int64     `json:"balance"`
+	TransactionID string    `json:"transactionID"` // giao dịch cuối cùng ghi tài khoản trước hoặc tại AsOf
+	Timestamp     time.Time `json:"timestamp"`     // thời điểm block của giao dịch đó
+	PendingDeltas int64     `json:"pendingDeltas"` // delta được ghi có trước hoặc tại AsOf nhưng chưa gộp tại AsOf
+}
+
+// =========================================================================================
+// UC-024: Tra cứu số dư tại một thời điểm
+// Dùng cho giải quyết khiếu nại và sao kê cuối tháng.
+//
+// Logic chính:
+// 1. `timestamp` phải là RFC3339.
+// 2. Duyệt lịch sử tài khoản bằng GetHistoryForKey (cả key cũ và key account~customerID, mới nhất trước),
+//    lấy bản ghi (không phải bản ghi xóa) đầu tiên có thời điểm block trước hoặc tại `timestamp`.
+//    Tài khoản đã được gộp trước `timestamp` được phân giải sang tài khoản giữ lại (CustomerID của kết quả).
+// 3. Nếu không có bản ghi nào -> tài khoản chưa tồn tại tại thời điểm đó, trả về ACCOUNT_NOT_FOUND.
+// 4. Cộng thêm các delta được ghi có trước hoặc tại `timestamp` nhưng chưa được gộp tại thời điểm đó:
+//    delta hiện vẫn chưa gộp (trên World State) và delta đã gộp sau `timestamp`. Delta đã gộp bị xóa khỏi
+//    World State, nên chúng được đọc từ FoldedDeltas của các bản ghi tài khoản mới hơn `timestamp`.
+// 5. Trả về số dư, giao dịch đã tạo ra số dư đó và thời điểm block của giao dịch.
+// =========================================================================================
+func (s *AccountContract) GetBalanceAt(ctx contractapi.TransactionContextInterface, customerID string, timestamp string) (*BalanceAt, error) {
+	// 1. Thời điểm tra cứu
+	asOf, err := ParseTimestamp(timestamp)
+	if err != nil {
+		return nil, errInvalidArgument("timestamp must be an RFC3339 timestamp, got: %q", timestamp)
+	}
+
+	// 2. Lịch sử tài khoản
+	historyRecords, err := getResolvedAccountHistory(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	// Delta đã gộp sau `timestamp`, theo tài khoản (lịch sử đã phân giải có thể gồm nhiều tài khoản)
+	foldedLater := map[string]int64{}
+	for _, historyRecord := range historyRecords {
+		if historyRecord.IsDelete {
+			continue
+		}
+		account, err := decodeAccount(historyRecord.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode history record: %v", err)
+		}
+		if historyRecord.Timestamp.After(asOf) {
+			if foldedLater[historyRecord.CustomerID], err = sumFoldedBefore(foldedLater[historyRecord.CustomerID], account.FoldedDeltas, asOf); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		result := BalanceAt{
+			CustomerID:    historyRecord.CustomerID,
+			AsOf:          asOf,
+			Balance:       account.Balance,
+			TransactionID: historyRecord.TxID,
+			Timestamp:     historyRecord.Timestamp,
+		}
+
+		// 4. Delta chưa gộp tại thời điểm tra cứu
+		result.PendingDeltas = foldedLater[historyRecord.CustomerID]
+		if account.DeltaMode {
+			pending, err := sumDeltasBefore(ctx, historyRecord.CustomerID, asOf)
+			if err != nil {
+				return nil, err
+			}
+			if result.PendingDeltas, err = addPoints(result.PendingDeltas, pending); err != nil {
+				return nil, err
+			}
+		}
+		if result.Balance, err = addPoints(result.Balance, result.PendingDeltas); err != nil {
+			return nil, err
+		}
+		return &result, nil
+	}
+
+	// 3. Tài khoản chưa tồn tại
+	return nil, newChaincodeError(ErrCodeAccountNotFound, map[string]interface{}{"customerID": customerID, "asOf": asOf.Format(time.RFC3339)},
+		"loyalty account '%s' did not exist at %s", customerID, asOf.Format(time.RFC3339))
+}
+
+// sumFoldedBefore cộng vào `total` các delta đã gộp được ghi có trước hoặc tại `asOf`
+func sumFoldedBefore(total int64, folded []FoldedDelta, asOf time.Time) (int64, error) {
+	for _, delta := range folded {
+		credited, err := ParseTimestamp(delta.Timestamp)
+		if err != nil || credited.After(asOf) {
+			continue
+		}
+		if total, err = addPoints(total, delta.Amount); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+// sumDeltasBefore cộng các delta chưa gộp của tài khoản được ghi có trước hoặc tại `asOf`
+func sumDeltasBefore(ctx contractapi.TransactionContextInterface, customerID string, asOf time.Time) (int64, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(balanceDeltaObjectType, []string{customerID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query balance deltas: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var total int64
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return 0, fmt.Errorf("failed to iterate balance deltas: %v", err)
+		}
+		var delta BalanceDelta
+		if err := json.Unmarshal(queryResult.Value, &delta); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal balance delta: %v", err)
+		}
+		credited, err := ParseTimestamp(delta.Timestamp)
+		if err != nil || credited.After(asOf) {
+			continue
+		}
+		if total, err = addPoints(total, delta.Amount); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
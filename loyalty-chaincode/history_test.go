@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetBalanceAtCountsConsolidatedDeltas(t *testing.T) {
+	// MERCHANT có 1000 điểm, chuyển sang chế độ delta lúc testTime; ghi có 100 sau 1 giờ và 200 sau 2 giờ,
+	// gộp sau 3 giờ, quy đổi 50 sau 4 giờ và ghi có 300 sau 5 giờ
+	at := func(hours float64) time.Time { return testTime.Add(time.Duration(hours * float64(time.Hour))) }
+	tests := []struct {
+		name        string
+		asOf        time.Time
+		wantBalance int64
+		wantPending int64
+	}{
+		{name: "before the first credit", asOf: at(0.5), wantBalance: 1000},
+		{name: "credit consolidated later", asOf: at(1.5), wantBalance: 1100, wantPending: 100},
+		{name: "credits consolidated later", asOf: at(2.5), wantBalance: 1300, wantPending: 300},
+		{name: "after consolidation", asOf: at(3.5), wantBalance: 1300},
+		{name: "after a redemption", asOf: at(4.5), wantBalance: 1250},
+		{name: "credit still pending", asOf: at(5.5), wantBalance: 1550, wantPending: 300},
+	}
+
+	ctx := newTestContext(t)
+	contract := &AccountContract{}
+	ctx.setupAccounts(testTime, map[string]int64{"MERCHANT": 1000})
+	ctx.mustTx(testTime, func() error {
+		_, err := (&AdminContract{}).SetDeltaMode(ctx, "MERCHANT", true)
+		return err
+	})
+	ctx.mustTx(at(1), func() error {
+		_, err := contract.IssuePoints(ctx, "MERCHANT", 100, "sale", "")
+		return err
+	})
+	ctx.mustTx(at(2), func() error {
+		_, err := contract.IssuePoints(ctx, "MERCHANT", 200, "sale", "")
+		return err
+	})
+	ctx.mustTx(at(3), func() error {
+		_, err := contract.Consolidate(ctx, "MERCHANT")
+		return err
+	})
+	ctx.mustTx(at(4), func() error {
+		_, err := contract.RedeemPoints(ctx, "MERCHANT", 50, "coffee", "")
+		return err
+	})
+	ctx.mustTx(at(5), func() error {
+		_, err := contract.IssuePoints(ctx, "MERCHANT", 300, "sale", "")
+		return err
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var balance *BalanceAt
+			ctx.mustTx(at(6), func() error {
+				var err error
+				balance, err = contract.GetBalanceAt(ctx, "MERCHANT", tt.asOf.Format(time.RFC3339))
+				return err
+			})
+			if balance.Balance != tt.wantBalance || balance.PendingDeltas != tt.wantPending {
+				t.Errorf("balance/pending at %s = %d/%d, want %d/%d", tt.asOf.Format(time.RFC3339), balance.Balance, balance.PendingDeltas, tt.wantBalance, tt.wantPending)
+			}
+		})
+	}
+}
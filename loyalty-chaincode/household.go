@@ -0,0 +1,549 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Trạng thái thành viên hộ gia đình
+const (
+	HouseholdMemberInvited = "INVITED" // đã được chủ hộ mời, chờ thành viên đồng ý
+	HouseholdMemberActive  = "ACTIVE"  // đã đồng ý, điểm có thể được dùng cho quy đổi chung
+)
+
+// Household định nghĩa một nhóm gia đình góp điểm để quy đổi chung
+type Household struct {
+	HouseholdID   string            `json:"householdID"`
+	OwnerID       string            `json:"ownerID"`
+	Members       []HouseholdMember `json:"members"` // gồm cả chủ hộ
+	Rules         HouseholdRules    `json:"rules"`
+	CreatedAt     string            `json:"createdAt"`
+	SchemaVersion int               `json:"schemaVersion"`
+}
+
+// HouseholdMember định nghĩa một thành viên của hộ gia đình
+type HouseholdMember struct {
+	CustomerID string `json:"customerID"`
+	Status     string `json:"status"` // INVITED, ACTIVE
+	JoinedAt   string `json:"joinedAt,omitempty"`
+}
+
+// HouseholdRules định nghĩa quy tắc đóng góp điểm của các thành viên khi quy đổi chung
+type HouseholdRules struct {
+	MaxContributionPercent int   `json:"maxContributionPercent"` // phần trăm tối đa giá phần thưởng mà một thành viên đóng góp (0 = không giới hạn)
+	MinRemainingBalance    int64 `json:"minRemainingBalance"`    // số dư tối thiểu thành viên phải giữ lại sau khi đóng góp
+}
+
+// HouseholdContribution là bản ghi đóng góp của một thành viên trong một lần quy đổi chung,
+// lưu theo thành viên để lịch sử của từng người thể hiện phần đóng góp của họ
+type HouseholdContribution struct {
+	TransactionID string `json:"transactionID"`
+	HouseholdID   string `json:"householdID"`
+	CustomerID    string `json:"customerID"`
+	RewardID      string `json:"rewardID"`
+	VoucherID     string `json:"voucherID"`
+	Points        int64  `json:"points"`
+	Timestamp     string `json:"timestamp"`
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+// ContributionAuthorization là ủy quyền đã ký của một thành viên cho phần đóng góp của mình (xem UC-028, bước 6)
+type ContributionAuthorization struct {
+	Nonce     string `json:"nonce"`
+	ExpiresAt string `json:"expiresAt"`
+	Signature string `json:"signature"`
+}
+
+// PooledRedemption định nghĩa kết quả của một lần quy đổi chung
+type PooledRedemption struct {
+	HouseholdID   string                   `json:"householdID"`
+	Voucher       *Voucher                 `json:"voucher"`
+	Contributions []*HouseholdContribution `json:"contributions"`
+}
+
+// validate kiểm tra quy tắc đóng góp
+func (r HouseholdRules) validate() error {
+	if r.MaxContributionPercent < 0 || r.MaxContributionPercent > 100 {
+		return errInvalidArgument("max contribution percent must be between 0 and 100, got: %d", r.MaxContributionPercent)
+	}
+	if r.MinRemainingBalance < 0 {
+		return errInvalidArgument("min remaining balance cannot be negative, got: %d", r.MinRemainingBalance)
+	}
+	return nil
+}
+
+// maxContribution trả về số điểm tối đa một thành viên được đóng góp cho phần thưởng giá `cost`
+func (r HouseholdRules) maxContribution(cost int64) int64 {
+	if r.MaxContributionPercent == 0 {
+		return cost
+	}
+	// Tính theo phần nguyên và phần dư để tránh tràn số khi nhân
+	percent := int64(r.MaxContributionPercent)
+	return cost/100*percent + cost%100*percent/100
+}
+
+// member trả về thành viên theo customerID (nil nếu không thuộc hộ)
+func (h *Household) member(customerID string) *HouseholdMember {
+	for i := range h.Members {
+		if h.Members[i].CustomerID == customerID {
+			return &h.Members[i]
+		}
+	}
+	return nil
+}
+
+// removeMember xóa một thành viên khỏi danh sách
+func (h *Household) removeMember(customerID string) {
+	members := h.Members[:0]
+	for _, member := range h.Members {
+		if member.CustomerID != customerID {
+			members = append(members, member)
+		}
+	}
+	h.Members = members
+}
+
+// =========================================================================================
+// UC-020: Quản lý hộ gia đình
+//
+// Logic chính:
+// 1. CreateHousehold: chủ hộ (người gọi phải được phép đại diện cho `ownerID`) tạo hộ với quy tắc đóng góp,
+//    chủ hộ là thành viên ACTIVE đầu tiên. Mỗi khách hàng chỉ thuộc tối đa một hộ.
+// 2. InviteHouseholdMember: chủ hộ mời một tài khoản, thành viên ở trạng thái INVITED.
+// 3. AcceptHouseholdInvite: thành viên đồng ý (người gọi đại diện cho thành viên) -> ACTIVE.
+//    Chỉ thành viên ACTIVE mới có thể đóng góp điểm.
+// 4. LeaveHousehold: thành viên tự rời hộ hoặc bị chủ hộ xóa; chủ hộ không thể rời hộ.
+// 5. SetHouseholdRules: chủ hộ thay đổi quy tắc đóng góp.
+// =========================================================================================
+func (s *AccountContract) CreateHousehold(ctx contractapi.TransactionContextInterface, householdID string, ownerID string, maxContributionPercent int, minRemainingBalance int64) (*Household, error) {
+	rules := HouseholdRules{MaxContributionPercent: maxContributionPercent, MinRemainingBalance: minRemainingBalance}
+	if err := rules.validate(); err != nil {
+		return nil, err
+	}
+	if err := requireActingFor(ctx, ownerID); err != nil {
+		return nil, err
+	}
+	owner, err := getAccount(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(owner); err != nil {
+		return nil, err
+	}
+	if err := requireNoHousehold(ctx, ownerID); err != nil {
+		return nil, err
+	}
+
+	householdKey, err := ledgerKey(ctx, householdObjectType, householdID)
+	if err != nil {
+		return nil, err
+	}
+	existingHouseholdJSON, err := ctx.GetStub().GetState(householdKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existingHouseholdJSON != nil {
+		return nil, newChaincodeError(ErrCodeHouseholdAlreadyExists, map[string]interface{}{"householdID": householdID}, "household with ID '%s' already exists", householdID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	household := Household{
+		HouseholdID: householdID,
+		OwnerID:     ownerID,
+		Members:     []HouseholdMember{{CustomerID: ownerID, Status: HouseholdMemberActive, JoinedAt: now.Format(time.RFC3339)}},
+		Rules:       rules,
+		CreatedAt:   now.Format(time.RFC3339),
+	}
+	if err := putHousehold(ctx, &household); err != nil {
+		return nil, err
+	}
+	if err := putHouseholdMemberIndex(ctx, ownerID, householdID); err != nil {
+		return nil, err
+	}
+	return &household, nil
+}
+
+// InviteHouseholdMember mời một tài khoản vào hộ (chỉ chủ hộ)
+func (s *AccountContract) InviteHouseholdMember(ctx contractapi.TransactionContextInterface, householdID string, customerID string) (*Household, error) {
+	household, err := getHousehold(ctx, householdID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireActingFor(ctx, household.OwnerID); err != nil {
+		return nil, err
+	}
+	if household.member(customerID) != nil {
+		return nil, errInvalidArgument("customer '%s' is already a member of household '%s'", customerID, householdID)
+	}
+	account, err := getAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(account); err != nil {
+		return nil, err
+	}
+
+	household.Members = append(household.Members, HouseholdMember{CustomerID: customerID, Status: HouseholdMemberInvited})
+	if err := putHousehold(ctx, household); err != nil {
+		return nil, err
+	}
+	return household, nil
+}
+
+// AcceptHouseholdInvite ghi nhận sự đồng ý của thành viên được mời (người gọi phải đại diện cho thành viên)
+func (s *AccountContract) AcceptHouseholdInvite(ctx contractapi.TransactionContextInterface, householdID string, customerID string) (*Household, error) {
+	if err := requireActingFor(ctx, customerID); err != nil {
+		return nil, err
+	}
+	household, err := getHousehold(ctx, householdID)
+	if err != nil {
+		return nil, err
+	}
+	member := household.member(customerID)
+	if member == nil || member.Status != HouseholdMemberInvited {
+		return nil, errInvalidArgument("customer '%s' has no pending invite to household '%s'", customerID, householdID)
+	}
+	if err := requireNoHousehold(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	member.Status = HouseholdMemberActive
+	member.JoinedAt = now.Format(time.RFC3339)
+	if err := putHousehold(ctx, household); err != nil {
+		return nil, err
+	}
+	if err := putHouseholdMemberIndex(ctx, customerID, householdID); err != nil {
+		return nil, err
+	}
+	return household, nil
+}
+
+// LeaveHousehold xóa một thành viên (hoặc lời mời) khỏi hộ; người gọi là thành viên đó hoặc chủ hộ
+func (s *AccountContract) LeaveHousehold(ctx contractapi.TransactionContextInterface, householdID string, customerID string) (*Household, error) {
+	household, err := getHousehold(ctx, householdID)
+	if err != nil {
+		return nil, err
+	}
+	if customerID == household.OwnerID {
+		return nil, errInvalidArgument("the owner cannot leave household '%s'", householdID)
+	}
+	// Thành viên tự rời hộ, hoặc chủ hộ xóa thành viên
+	if err := requireActingFor(ctx, customerID); err != nil {
+		if ownerErr := requireActingFor(ctx, household.OwnerID); ownerErr != nil {
+			return nil, err
+		}
+	}
+	member := household.member(customerID)
+	if member == nil {
+		return nil, errInvalidArgument("customer '%s' is not a member of household '%s'", customerID, householdID)
+	}
+
+	if member.Status == HouseholdMemberActive {
+		indexKey, err := ledgerKey(ctx, householdMemberObjectType, customerID)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.GetStub().DelState(indexKey); err != nil {
+			return nil, fmt.Errorf("failed to delete household member index: %v", err)
+		}
+	}
+	household.removeMember(customerID)
+	if err := putHousehold(ctx, household); err != nil {
+		return nil, err
+	}
+	return household, nil
+}
+
+// SetHouseholdRules thay đổi quy tắc đóng góp của hộ (chỉ chủ hộ)
+func (s *AccountContract) SetHouseholdRules(ctx contractapi.TransactionContextInterface, householdID string, maxContributionPercent int, minRemainingBalance int64) (*Household, error) {
+	rules := HouseholdRules{MaxContributionPercent: maxContributionPercent, MinRemainingBalance: minRemainingBalance}
+	if err := rules.validate(); err != nil {
+		return nil, err
+	}
+	household, err := getHousehold(ctx, householdID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireActingFor(ctx, household.OwnerID); err != nil {
+		return nil, err
+	}
+	household.Rules = rules
+	if err := putHousehold(ctx, household); err != nil {
+		return nil, err
+	}
+	return household, nil
+}
+
+// GetHousehold truy vấn một hộ gia đình
+func (s *AccountContract) GetHousehold(ctx contractapi.TransactionContextInterface, householdID string) (*Household, error) {
+	return getHousehold(ctx, householdID)
+}
+
+// QueryHouseholdContributions trả về các lần đóng góp điểm vào quy đổi chung của một khách hàng
+func (s *AccountContract) QueryHouseholdContributions(ctx contractapi.TransactionContextInterface, customerID string) ([]*HouseholdContribution, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(householdContributionObjectType, []string{customerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query household contributions: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	contributions := []*HouseholdContribution{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate household contributions: %v", err)
+		}
+		var contribution HouseholdContribution
+		if err := json.Unmarshal(queryResult.Value, &contribution); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal household contribution: %v", err)
+		}
+		contributions = append(contributions, &contribution)
+	}
+	return contributions, nil
+}
+
+// =========================================================================================
+// UC-021: Quy đổi phần thưởng bằng điểm góp chung của hộ gia đình
+// Người gọi phải được phép đại diện cho chủ hộ; voucher được phát hành cho chủ hộ.
+//
+// Logic chính:
+// 1. Giải mã `contributionsJSON` ({"<customerID>": <điểm>, ...}); tổng đóng góp phải bằng giá phần thưởng.
+// 2. Mỗi người đóng góp phải là thành viên ACTIVE và tuân thủ quy tắc của hộ
+//    (phần trăm tối đa của giá phần thưởng, số dư tối thiểu còn lại). Thành viên khác chủ hộ đã đăng ký khóa
+//    phải kèm ủy quyền đã ký trong `authorizationsJSON` ({"<customerID>": {"nonce", "expiresAt", "signature"}}),
+//    xem UC-028, bước 6; chủ hộ đổi điểm của mình lấy voucher của mình nên không cần ký.
+// 3. Trừ điểm của từng thành viên (phần của thành viên khác chủ hộ tuân theo quy tắc tốc độ, xem UC-029)
+//    và cập nhật LifetimeRedeemed của họ. Mọi bước nằm trong cùng một giao dịch
+//    nên hoặc tất cả thành viên bị trừ điểm, hoặc không ai bị trừ.
+// 4. Phát hành voucher cho chủ hộ, lưu bản ghi HouseholdContribution cho từng thành viên.
+// 5. Phát ra sự kiện "PooledRedeemRewardEvent".
+// =========================================================================================
+func (s *RewardContract) PooledRedeemReward(ctx contractapi.TransactionContextInterface, householdID string, rewardID string, contributionsJSON string, authorizationsJSON string) (*PooledRedemption, error) {
+	household, err := getHousehold(ctx, householdID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireActingFor(ctx, household.OwnerID); err != nil {
+		return nil, err
+	}
+	reward, err := getReward(ctx, rewardID)
+	if err != nil {
+		return nil, err
+	}
+	if err := reward.checkAvailable(); err != nil {
+		return nil, err
+	}
+
+	// 1. Đóng góp
+	var contributions map[string]int64
+	if err := json.Unmarshal([]byte(contributionsJSON), &contributions); err != nil {
+		return nil, errInvalidArgument("invalid contributions JSON: %v", err)
+	}
+	memberIDs := make([]string, 0, len(contributions))
+	var total int64
+	for memberID, points := range contributions {
+		if points <= 0 {
+			return nil, errInvalidArgument("contribution of '%s' must be positive, got: %d", memberID, points)
+		}
+		if total, err = addPoints(total, points); err != nil {
+			return nil, err
+		}
+		memberIDs = append(memberIDs, memberID)
+	}
+	if total != reward.PointsCost {
+		return nil, errInvalidArgument("contributions total %d points, reward '%s' costs %d", total, rewardID, reward.PointsCost)
+	}
+	authorizations := map[string]ContributionAuthorization{}
+	if authorizationsJSON != "" {
+		if err := json.Unmarshal([]byte(authorizationsJSON), &authorizations); err != nil {
+			return nil, errInvalidArgument("invalid authorizations JSON: %v", err)
+		}
+	}
+	for memberID := range authorizations {
+		if _, ok := contributions[memberID]; !ok || memberID == household.OwnerID {
+			return nil, errInvalidArgument("authorization of '%s' does not match a member contribution", memberID)
+		}
+	}
+	// Duyệt theo thứ tự cố định để mọi peer endorse cùng một kết quả
+	sort.Strings(memberIDs)
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	voucher, err := issueRewardVoucher(ctx, reward, household.OwnerID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	redemption := PooledRedemption{HouseholdID: householdID, Voucher: voucher, Contributions: []*HouseholdContribution{}}
+	for _, memberID := range memberIDs {
+		points := contributions[memberID]
+
+		// 2. Thành viên và quy tắc
+		member := household.member(memberID)
+		if member == nil || member.Status != HouseholdMemberActive {
+			return nil, errInvalidArgument("customer '%s' is not an active member of household '%s'", memberID, householdID)
+		}
+		if maxPoints := household.Rules.maxContribution(reward.PointsCost); points > maxPoints {
+			return nil, errInvalidArgument("contribution of '%s' is %d points, household maximum for this reward is %d", memberID, points, maxPoints)
+		}
+
+		// 3. Trừ điểm
+		account, err := getAccount(ctx, memberID)
+		if err != nil {
+			return nil, err
+		}
+		// Phần đóng góp của thành viên khác chủ hộ là điểm chuyển cho chủ hộ (ủy quyền đã ký, UC-028; quy tắc tốc độ, UC-029)
+		if memberID != household.OwnerID {
+			authorization := authorizations[memberID]
+			if err := verifyTransferAuthorization(ctx, authorizationHouseholdContribution, account, householdID, points, DefaultCurrency,
+				authorization.Nonce, authorization.ExpiresAt, authorization.Signature,
+				[2]string{"reward", rewardID}, [2]string{"owner", household.OwnerID}); err != nil {
+				return nil, err
+			}
+			if err := config.Velocity.checkOutbound(account, household.OwnerID, now); err != nil {
+				return nil, err
+			}
+		}
+		if err := debitAccount(ctx, account, points); err != nil {
+			return nil, err
+		}
+		if account.Balance < household.Rules.MinRemainingBalance {
+			return nil, errInvalidArgument("contribution of '%s' would leave %d points, household minimum is %d", memberID, account.Balance, household.Rules.MinRemainingBalance)
+		}
+		if account.LifetimeRedeemed, err = addPoints(account.LifetimeRedeemed, points); err != nil {
+			return nil, err
+		}
+		account.LastUpdated = now.Format(time.RFC3339)
+		if err := putAccount(ctx, account); err != nil {
+			return nil, err
+		}
+
+		// 4. Bản ghi đóng góp
+		contribution := HouseholdContribution{
+			TransactionID: ctx.GetStub().GetTxID(),
+			HouseholdID:   householdID,
+			CustomerID:    memberID,
+			RewardID:      rewardID,
+			VoucherID:     voucher.VoucherID,
+			Points:        points,
+			Timestamp:     account.LastUpdated,
+			SchemaVersion: householdContributionSchemaVersion,
+		}
+		if err := putHouseholdContribution(ctx, &contribution); err != nil {
+			return nil, err
+		}
+		redemption.Contributions = append(redemption.Contributions, &contribution)
+	}
+	if err := updateSupplyCounters(ctx, 0, total, 0, 0); err != nil {
+		return nil, err
+	}
+
+	// 5. Sự kiện
+	redemptionJSON, err := json.Marshal(redemption)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pooled redemption: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent("PooledRedeemRewardEvent", redemptionJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for pooled redemption: %v", err)
+	}
+	return &redemption, nil
+}
+
+// getHousehold đọc một hộ gia đình từ World State
+func getHousehold(ctx contractapi.TransactionContextInterface, householdID string) (*Household, error) {
+	householdKey, err := ledgerKey(ctx, householdObjectType, householdID)
+	if err != nil {
+		return nil, err
+	}
+	householdJSON, err := ctx.GetStub().GetState(householdKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read household from world state: %v", err)
+	}
+	if householdJSON == nil {
+		return nil, newChaincodeError(ErrCodeHouseholdNotFound, map[string]interface{}{"householdID": householdID}, "household with ID '%s' does not exist", householdID)
+	}
+
+	var household Household
+	if err := json.Unmarshal(householdJSON, &household); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal household data: %v", err)
+	}
+	return &household, nil
+}
+
+// putHousehold ghi một hộ gia đình vào World State
+func putHousehold(ctx contractapi.TransactionContextInterface, household *Household) error {
+	household.SchemaVersion = householdSchemaVersion
+	householdKey, err := ledgerKey(ctx, householdObjectType, household.HouseholdID)
+	if err != nil {
+		return err
+	}
+	householdJSON, err := json.Marshal(household)
+	if err != nil {
+		return fmt.Errorf("failed to marshal household: %v", err)
+	}
+	if err := ctx.GetStub().PutState(householdKey, householdJSON); err != nil {
+		return fmt.Errorf("failed to put state for household: %v", err)
+	}
+	return nil
+}
+
+// putHouseholdMemberIndex ghi chỉ mục householdMember~<customerID> -> householdID của thành viên ACTIVE
+func putHouseholdMemberIndex(ctx contractapi.TransactionContextInterface, customerID, householdID string) error {
+	indexKey, err := ledgerKey(ctx, householdMemberObjectType, customerID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte(householdID)); err != nil {
+		return fmt.Errorf("failed to put household member index: %v", err)
+	}
+	return nil
+}
+
+// requireNoHousehold trả về lỗi nếu khách hàng đã là thành viên ACTIVE của một hộ khác
+func requireNoHousehold(ctx contractapi.TransactionContextInterface, customerID string) error {
+	indexKey, err := ledgerKey(ctx, householdMemberObjectType, customerID)
+	if err != nil {
+		return err
+	}
+	householdID, err := ctx.GetStub().GetState(indexKey)
+	if err != nil {
+		return fmt.Errorf("failed to read household member index: %v", err)
+	}
+	if householdID != nil {
+		return errInvalidArgument("customer '%s' already belongs to household '%s'", customerID, string(householdID))
+	}
+	return nil
+}
+
+// putHouseholdContribution lưu bản ghi đóng góp dưới householdContribution~<customerID>~<txID>
+func putHouseholdContribution(ctx contractapi.TransactionContextInterface, contribution *HouseholdContribution) error {
+	contributionKey, err := ledgerKey(ctx, householdContributionObjectType, contribution.CustomerID, contribution.TransactionID)
+	if err != nil {
+		return err
+	}
+	contributionJSON, err := json.Marshal(contribution)
+	if err != nil {
+		return fmt.Errorf("failed to marshal household contribution: %v", err)
+	}
+	if err := ctx.GetStub().PutState(contributionKey, contributionJSON); err != nil {
+		return fmt.Errorf("failed to put state for household contribution: %v", err)
+	}
+	return nil
+}
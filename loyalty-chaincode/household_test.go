@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHouseholdMembership(t *testing.T) {
+	// ALICE lập hộ HOME và mời BOB; CAROL là chủ hộ OTHER. Mỗi bước chạy với danh tính của khách hàng `caller`.
+	type step struct {
+		caller   string
+		run      func(ctx *testContext) error
+		wantCode string
+	}
+	contract := &AccountContract{}
+	invite := func(customerID string) func(ctx *testContext) error {
+		return func(ctx *testContext) error {
+			_, err := contract.InviteHouseholdMember(ctx, "HOME", customerID)
+			return err
+		}
+	}
+	accept := func(householdID, customerID string) func(ctx *testContext) error {
+		return func(ctx *testContext) error {
+			_, err := contract.AcceptHouseholdInvite(ctx, householdID, customerID)
+			return err
+		}
+	}
+	leave := func(customerID string) func(ctx *testContext) error {
+		return func(ctx *testContext) error {
+			_, err := contract.LeaveHousehold(ctx, "HOME", customerID)
+			return err
+		}
+	}
+	create := func(householdID, ownerID string) func(ctx *testContext) error {
+		return func(ctx *testContext) error {
+			_, err := contract.CreateHousehold(ctx, householdID, ownerID, 50, 0)
+			return err
+		}
+	}
+
+	tests := []struct {
+		name        string
+		steps       []step
+		wantMembers map[string]string // customerID -> trạng thái trong HOME
+	}{
+		{
+			name:        "invite pending",
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive, "BOB": HouseholdMemberInvited},
+		},
+		{
+			name:        "invite accepted",
+			steps:       []step{{caller: "BOB", run: accept("HOME", "BOB")}},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive, "BOB": HouseholdMemberActive},
+		},
+		{
+			name:        "invite accepted by another customer",
+			steps:       []step{{caller: "CAROL", run: accept("HOME", "BOB"), wantCode: ErrCodeAccessDenied}},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive, "BOB": HouseholdMemberInvited},
+		},
+		{
+			name:        "accept without an invite",
+			steps:       []step{{caller: "DAVE", run: accept("HOME", "DAVE"), wantCode: ErrCodeInvalidArgument}},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive, "BOB": HouseholdMemberInvited},
+		},
+		{
+			name: "accept while in another household",
+			steps: []step{
+				{caller: "ALICE", run: invite("CAROL")},
+				{caller: "CAROL", run: accept("HOME", "CAROL"), wantCode: ErrCodeInvalidArgument},
+			},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive, "BOB": HouseholdMemberInvited, "CAROL": HouseholdMemberInvited},
+		},
+		{
+			name:        "invite by a member who is not the owner",
+			steps:       []step{{caller: "BOB", run: invite("DAVE"), wantCode: ErrCodeAccessDenied}},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive, "BOB": HouseholdMemberInvited},
+		},
+		{
+			name:        "member invited twice",
+			steps:       []step{{caller: "ALICE", run: invite("BOB"), wantCode: ErrCodeInvalidArgument}},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive, "BOB": HouseholdMemberInvited},
+		},
+		{
+			name:        "unknown account invited",
+			steps:       []step{{caller: "ALICE", run: invite("ZOE"), wantCode: ErrCodeAccountNotFound}},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive, "BOB": HouseholdMemberInvited},
+		},
+		{
+			name: "member leaves and can join another household",
+			steps: []step{
+				{caller: "BOB", run: accept("HOME", "BOB")},
+				{caller: "BOB", run: leave("BOB")},
+				{caller: "BOB", run: create("BOBS", "BOB")},
+			},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive},
+		},
+		{
+			name: "owner removes a member",
+			steps: []step{
+				{caller: "BOB", run: accept("HOME", "BOB")},
+				{caller: "ALICE", run: leave("BOB")},
+			},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive},
+		},
+		{
+			name:        "another customer removes a member",
+			steps:       []step{{caller: "CAROL", run: leave("BOB"), wantCode: ErrCodeAccessDenied}},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive, "BOB": HouseholdMemberInvited},
+		},
+		{
+			name:        "owner cannot leave",
+			steps:       []step{{caller: "ALICE", run: leave("ALICE"), wantCode: ErrCodeInvalidArgument}},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive, "BOB": HouseholdMemberInvited},
+		},
+		{
+			name:        "household ID taken",
+			steps:       []step{{caller: "DAVE", run: create("HOME", "DAVE"), wantCode: ErrCodeHouseholdAlreadyExists}},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive, "BOB": HouseholdMemberInvited},
+		},
+		{
+			name:        "owner already in a household",
+			steps:       []step{{caller: "ALICE", run: create("SECOND", "ALICE"), wantCode: ErrCodeInvalidArgument}},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive, "BOB": HouseholdMemberInvited},
+		},
+		{
+			name:        "household created for another customer",
+			steps:       []step{{caller: "ALICE", run: create("DAVES", "DAVE"), wantCode: ErrCodeAccessDenied}},
+			wantMembers: map[string]string{"ALICE": HouseholdMemberActive, "BOB": HouseholdMemberInvited},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 0, "BOB": 0, "CAROL": 0, "DAVE": 0})
+			ctx.as(customer("ALICE")).mustTx(testTime, func() error { return create("HOME", "ALICE")(ctx) })
+			ctx.mustTx(testTime, func() error { return invite("BOB")(ctx) })
+			ctx.as(customer("CAROL")).mustTx(testTime, func() error { return create("OTHER", "CAROL")(ctx) })
+
+			for i, s := range tt.steps {
+				err := ctx.as(customer(s.caller)).tx(testTime, func() error { return s.run(ctx) })
+				if i == len(tt.steps)-1 {
+					checkErrorCode(t, err, s.wantCode)
+				} else if err != nil {
+					t.Fatalf("step %d failed: %v", i, err)
+				}
+			}
+			ctx.as(bankAdmin())
+
+			var household *Household
+			ctx.mustTx(testTime, func() error {
+				var err error
+				household, err = contract.GetHousehold(ctx, "HOME")
+				return err
+			})
+			members := map[string]string{}
+			for _, member := range household.Members {
+				members[member.CustomerID] = member.Status
+			}
+			if len(members) != len(tt.wantMembers) {
+				t.Errorf("members = %v, want %v", members, tt.wantMembers)
+			}
+			for customerID, status := range tt.wantMembers {
+				if members[customerID] != status {
+					t.Errorf("members = %v, want %v", members, tt.wantMembers)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestPooledRedeemReward(t *testing.T) {
+	// HOME: ALICE (chủ hộ, 500 điểm) và BOB (500 điểm) là thành viên ACTIVE, CAROL (500 điểm) mới được mời.
+	// Quy tắc: tối đa 60% giá phần thưởng mỗi thành viên, giữ lại ít nhất `minRemaining` điểm. DINNER giá 300 điểm.
+	tests := []struct {
+		name          string
+		minRemaining  int64
+		contributions string
+		wantCode      string
+		want          map[string]int64 // số dư sau khi quy đổi
+	}{
+		{name: "split between members", contributions: `{"ALICE": 150, "BOB": 150}`, want: map[string]int64{"ALICE": 350, "BOB": 350, "CAROL": 500}},
+		{name: "largest share allowed", contributions: `{"ALICE": 120, "BOB": 180}`, want: map[string]int64{"ALICE": 380, "BOB": 320, "CAROL": 500}},
+		{name: "balance left at the minimum", minRemaining: 350, contributions: `{"ALICE": 150, "BOB": 150}`, want: map[string]int64{"ALICE": 350, "BOB": 350, "CAROL": 500}},
+		{name: "balance left below the minimum", minRemaining: 351, contributions: `{"ALICE": 150, "BOB": 150}`, wantCode: ErrCodeInvalidArgument},
+		{name: "share above the maximum", contributions: `{"ALICE": 100, "BOB": 200}`, wantCode: ErrCodeInvalidArgument},
+		{name: "total below the cost", contributions: `{"ALICE": 100, "BOB": 100}`, wantCode: ErrCodeInvalidArgument},
+		{name: "zero share", contributions: `{"ALICE": 300, "BOB": 0}`, wantCode: ErrCodeInvalidArgument},
+		{name: "invited member", contributions: `{"ALICE": 150, "CAROL": 150}`, wantCode: ErrCodeInvalidArgument},
+		{name: "non-member", contributions: `{"ALICE": 150, "DAVE": 150}`, wantCode: ErrCodeInvalidArgument},
+		{name: "malformed contributions", contributions: `["ALICE"]`, wantCode: ErrCodeInvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			accounts := &AccountContract{}
+			rewards := &RewardContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 500, "BOB": 500, "CAROL": 500, "DAVE": 500})
+			ctx.mustTx(testTime, func() error {
+				_, err := rewards.CreateReward(ctx, "DINNER", "Dinner", 300, 30, 10, 30)
+				return err
+			})
+			ctx.mustTx(testTime, func() error {
+				_, err := accounts.CreateHousehold(ctx, "HOME", "ALICE", 60, tt.minRemaining)
+				return err
+			})
+			for _, memberID := range []string{"BOB", "CAROL"} {
+				ctx.mustTx(testTime, func() error {
+					_, err := accounts.InviteHouseholdMember(ctx, "HOME", memberID)
+					return err
+				})
+			}
+			ctx.mustTx(testTime, func() error {
+				_, err := accounts.AcceptHouseholdInvite(ctx, "HOME", "BOB")
+				return err
+			})
+
+			var redemption *PooledRedemption
+			err := ctx.as(customer("ALICE")).tx(testTime, func() error {
+				var err error
+				redemption, err = rewards.PooledRedeemReward(ctx, "HOME", "DINNER", tt.contributions, "")
+				return err
+			})
+			ctx.as(bankAdmin())
+			checkErrorCode(t, err, tt.wantCode)
+
+			want := tt.want
+			if err != nil {
+				want = map[string]int64{"ALICE": 500, "BOB": 500, "CAROL": 500}
+			}
+			for customerID, balance := range want {
+				if got := ctx.balance(customerID); got != balance {
+					t.Errorf("balance of %s = %d, want %d", customerID, got, balance)
+				}
+			}
+			if err != nil {
+				return
+			}
+
+			if redemption.Voucher.OwnerID != "ALICE" || redemption.Voucher.RewardID != "DINNER" {
+				t.Errorf("voucher = owner %s, reward %s; want ALICE, DINNER", redemption.Voucher.OwnerID, redemption.Voucher.RewardID)
+			}
+			var shares map[string]int64
+			if err := json.Unmarshal([]byte(tt.contributions), &shares); err != nil {
+				t.Fatal(err)
+			}
+			for customerID, points := range shares {
+				var contributions []*HouseholdContribution
+				ctx.mustTx(testTime, func() error {
+					var err error
+					contributions, err = accounts.QueryHouseholdContributions(ctx, customerID)
+					return err
+				})
+				if len(contributions) != 1 || contributions[0].Points != points || contributions[0].VoucherID != redemption.Voucher.VoucherID {
+					t.Errorf("contributions of %s = %v, want one of %d points for voucher %s", customerID, contributions, points, redemption.Voucher.VoucherID)
+				}
+				var account LoyaltyAccount
+				if err := json.Unmarshal(ctx.ledgerState(accountObjectType, customerID), &account); err != nil {
+					t.Fatal(err)
+				}
+				if account.LifetimeRedeemed != points {
+					t.Errorf("lifetime redeemed of %s = %d, want %d", customerID, account.LifetimeRedeemed, points)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Loại đối tượng (object type) dùng làm tiền tố composite key, ví dụ account~<customerID>.
+// Mọi đối tượng trên sổ cái phải được lưu dưới một composite key có kiểu để tránh trùng key
+// giữa các loại đối tượng (ví dụ khách hàng có ID "config").
+const (
+	accountObjectType        = "account"
+	accountPolicyObjectType  = "accountPolicy"
+	rewardObjectType         = "reward"
+	campaignObjectType       = "campaign"
+	campaignUsageObjectType  = "campaignUsage"
+	campaignBudgetObjectType = "campaignBudget"
+	purchaseAwardObjectType  = "purchaseAward"
+	voucherObjectType        = "voucher"
+	voucherOwnerObjectType   = "voucherOwner"
+	allowanceObjectType      = "allowance"
+	supplyObjectType         = "supply"
+	supplyShardObjectType    = "supplyShard"
+	configObjectType         = "config"
+	balanceDeltaObjectType   = "balanceDelta"
+	adjustmentObjectType     = "adjustment"
+	mergedAccountObjectType  = "mergedAccount"
+
+	householdObjectType             = "household"
+	householdMemberObjectType       = "householdMember"
+	householdContributionObjectType = "householdContribution"
+	giftObjectType                  = "gift"
+	giftExpiryObjectType            = "giftExpiry"
+	giftRefundFailedObjectType      = "giftRefundFailed"
+	swapObjectType                  = "swap"
+	pendingAwardObjectType          = "pendingAward"
+	pendingReleaseObjectType        = "pendingRelease"
+	transferAuthObjectType          = "transferAuth"
+	flaggedTransferObjectType       = "flaggedTransfer"
+	flaggedHoldObjectType           = "flaggedHold"
+
+	referralObjectType          = "referral"
+	referrerReferralObjectType  = "referrerReferral"
+	referralQualifiedObjectType = "referralQualified"
+
+	statsObjectType             = "stats"
+	accountStatsShardObjectType = "accountStatsShard"
+	leaderboardScoreObjectType  = "leaderboardScore"
+	leaderboardRankObjectType   = "leaderboardRank"
+)
+
+// ledgerKey tạo composite key cho một đối tượng trên sổ cái. Đây là hàm duy nhất dùng để tạo key.
+func ledgerKey(ctx contractapi.TransactionContextInterface, objectType string, attributes ...string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s key: %v", objectType, err)
+	}
+	return key, nil
+}
+
+// getAccountState đọc JSON của tài khoản tại account~<customerID>.
+// Tài khoản tạo bởi phiên bản chaincode cũ nằm dưới key đơn giản customerID cho đến khi MigrateState
+// di chuyển chúng, vì vậy hàm sẽ đọc key cũ nếu chưa có key mới.
+func getAccountState(ctx contractapi.TransactionContextInterface, customerID string) ([]byte, error) {
+	accountKey, err := ledgerKey(ctx, accountObjectType, customerID)
+	if err != nil {
+		return nil, err
+	}
+	accountJSON, err := ctx.GetStub().GetState(accountKey)
+	if err != nil || accountJSON != nil {
+		return accountJSON, err
+	}
+	return ctx.GetStub().GetState(customerID)
+}
+
+// putAccountState ghi JSON của tài khoản vào account~<customerID> và xóa key đơn giản cũ nếu còn tồn tại
+func putAccountState(ctx contractapi.TransactionContextInterface, customerID string, accountJSON []byte) error {
+	accountKey, err := ledgerKey(ctx, accountObjectType, customerID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(accountKey, accountJSON); err != nil {
+		return err
+	}
+
+	legacyAccountJSON, err := ctx.GetStub().GetState(customerID)
+	if err != nil {
+		return err
+	}
+	if legacyAccountJSON != nil {
+		return ctx.GetStub().DelState(customerID)
+	}
+	return nil
+}
+
+// accountHistoryRecord là một bản ghi lịch sử thô của tài khoản (trên key cũ hoặc key mới)
+type accountHistoryRecord struct {
+	CustomerID string // tài khoản của bản ghi (lịch sử đã phân giải có thể gồm nhiều tài khoản, xem merge.go)
+	Value      []byte
+	TxID       string
+	Timestamp  time.Time
+	IsDelete   bool
+}
+
+// getAccountHistory trả về lịch sử của tài khoản trên cả key đơn giản cũ và key account~<customerID>,
+// sắp xếp từ mới đến cũ như GetHistoryForKey, để lịch sử không bị đứt đoạn sau khi di chuyển key.
+func getAccountHistory(ctx contractapi.TransactionContextInterface, customerID string) ([]accountHistoryRecord, error) {
+	accountKey, err := ledgerKey(ctx, accountObjectType, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []accountHistoryRecord
+	for _, key := range []string{customerID, accountKey} {
+		historyIterator, err := ctx.GetStub().GetHistoryForKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get history for account '%s': %v", customerID, err)
+		}
+		for historyIterator.HasNext() {
+			historyRecord, err := historyIterator.Next()
+			if err != nil {
+				historyIterator.Close()
+				return nil, fmt.Errorf("failed to iterate history: %v", err)
+			}
+			records = append(records, accountHistoryRecord{
+				CustomerID: customerID,
+				Value:      historyRecord.Value,
+				TxID:       historyRecord.TxId,
+				Timestamp:  historyRecord.Timestamp.AsTime(),
+				IsDelete:   historyRecord.IsDelete,
+			})
+		}
+		historyIterator.Close()
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.After(records[j].Timestamp)
+	})
+	return records, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// Giới hạn mặc định khi cấu hình chưa được ghi lên sổ cái
+	defaultMaxTransactionAmount int64 = 1000000000    // 1 tỷ điểm mỗi giao dịch
+	defaultMaxAccountBalance    int64 = 1000000000000 // 1 nghìn tỷ điểm mỗi tài khoản
+)
+
+// LedgerConfig định nghĩa cấu hình giới hạn số điểm, lưu trên sổ cái để mọi peer áp dụng giống nhau
+type LedgerConfig struct {
+	MaxTransactionAmount int64 `json:"maxTransactionAmount"` // số điểm tối đa của một giao dịch
+	MaxAccountBalance    int64 `json:"maxAccountBalance"`    // số dư tối đa của một tài khoản
+	SchemaVersion        int   `json:"schemaVersion"`
+
+	Currencies map[string]CurrencyRule `json:"currencies,omitempty"` // quy tắc của từng đơn vị điểm, xem currency.go
+
+	Velocity VelocityRules `json:"velocity"` // quy tắc tốc độ chuyển điểm, xem velocity.go
+
+	Referrals ReferralRules `json:"referrals"` // chương trình giới thiệu, xem referral.go
+
+	ReturnWindows ReturnWindowRules `json:"returnWindows"` // thời hạn đổi trả của merchant, xem pending.go
+}
+
+// GetLedgerConfig truy vấn cấu hình giới hạn hiện tại (giá trị mặc định nếu chưa được thiết lập)
+func (s *AdminContract) GetLedgerConfig(ctx contractapi.TransactionContextInterface) (*LedgerConfig, error) {
+	return getLedgerConfig(ctx)
+}
+
+// =========================================================================================
+// UC-016: Cấu hình giới hạn số điểm
+// Chỉ quản trị viên (BankOrgMSP, thuộc tính role=admin) được phép gọi.
+//
+// Logic chính:
+// 1. Kiểm tra các giới hạn phải là số dương và giới hạn giao dịch không vượt quá giới hạn số dư.
+// 2. Ghi cấu hình lên sổ cái; các giao dịch sau đó áp dụng giới hạn mới.
+// 3. Phát ra sự kiện "LedgerConfigEvent".
+// =========================================================================================
+func (s *AdminContract) SetLedgerConfig(ctx contractapi.TransactionContextInterface, maxTransactionAmount int64, maxAccountBalance int64) (*LedgerConfig, error) {
+	// 1. Kiểm tra giới hạn
+	if maxTransactionAmount <= 0 || maxAccountBalance <= 0 {
+		return nil, errInvalidArgument("limits must be positive, got maxTransactionAmount %d and maxAccountBalance %d", maxTransactionAmount, maxAccountBalance)
+	}
+	if maxTransactionAmount > maxAccountBalance {
+		return nil, errInvalidArgument("maxTransactionAmount %d cannot exceed maxAccountBalance %d", maxTransactionAmount, maxAccountBalance)
+	}
+
+	// 2. Ghi cấu hình (giữ nguyên quy tắc các đơn vị điểm)
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config.MaxTransactionAmount = maxTransactionAmount
+	config.MaxAccountBalance = maxAccountBalance
+	if err := putLedgerConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// putLedgerConfig ghi cấu hình lên sổ cái và phát ra sự kiện "LedgerConfigEvent"
+func putLedgerConfig(ctx contractapi.TransactionContextInterface, config *LedgerConfig) error {
+	config.SchemaVersion = ledgerConfigSchemaVersion
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ledger config: %v", err)
+	}
+	configKey, err := ledgerKey(ctx, configObjectType, "limits")
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(configKey, configJSON); err != nil {
+		return fmt.Errorf("failed to put state for ledger config: %v", err)
+	}
+
+	// 3. Sự kiện
+	if err := ctx.GetStub().SetEvent("LedgerConfigEvent", configJSON); err != nil {
+		return fmt.Errorf("failed to set event for ledger config: %v", err)
+	}
+	return nil
+}
+
+// getLedgerConfig đọc cấu hình giới hạn từ sổ cái, dùng giá trị mặc định cho trường chưa được thiết lập
+func getLedgerConfig(ctx contractapi.TransactionContextInterface) (*LedgerConfig, error) {
+	configKey, err := ledgerKey(ctx, configObjectType, "limits")
+	if err != nil {
+		return nil, err
+	}
+	configJSON, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger config from world state: %v", err)
+	}
+
+	config := LedgerConfig{}
+	if configJSON != nil {
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal ledger config: %v", err)
+		}
+	}
+	if config.MaxTransactionAmount <= 0 {
+		config.MaxTransactionAmount = defaultMaxTransactionAmount
+	}
+	if config.MaxAccountBalance <= 0 {
+		config.MaxAccountBalance = defaultMaxAccountBalance
+	}
+	config.setDefaultCurrencies()
+	return &config, nil
+}
+
+// checkTransactionAmount kiểm tra số điểm của một giao dịch không vượt quá giới hạn cấu hình
+func (c *LedgerConfig) checkTransactionAmount(amount int64) error {
+	if amount > c.MaxTransactionAmount {
+		return newChaincodeError(ErrCodeAmountLimitExceeded, map[string]interface{}{"amount": amount, "maxTransactionAmount": c.MaxTransactionAmount},
+			"amount %d exceeds the per-transaction maximum of %d", amount, c.MaxTransactionAmount)
+	}
+	return nil
+}
+
+// credit cộng `amount` vào số dư tài khoản, kiểm tra tràn số và số dư tối đa theo cấu hình
+func (c *LedgerConfig) credit(account *LoyaltyAccount, amount int64) error {
+	return c.creditBalance(account.CustomerID, &account.Balance, amount)
+}
+
+// creditBalance cộng `amount` vào một số dư của tài khoản `customerID` (POINTS hoặc đơn vị điểm khác, xem currency.go)
+func (c *LedgerConfig) creditBalance(customerID string, balance *int64, amount int64) error {
+	newBalance, err := addPoints(*balance, amount)
+	if err != nil {
+		return err
+	}
+	if newBalance > c.MaxAccountBalance {
+		return newChaincodeError(ErrCodeAmountLimitExceeded,
+			map[string]interface{}{"customerID": customerID, "balance": *balance, "amount": amount, "maxAccountBalance": c.MaxAccountBalance},
+			"crediting %d points would raise the balance of '%s' above the maximum of %d", amount, customerID, c.MaxAccountBalance)
+	}
+	*balance = newBalance
+	return nil
+}
+
+// debit trừ `amount` khỏi số dư tài khoản, trả về lỗi INSUFFICIENT_BALANCE nếu không đủ điểm
+func debit(account *LoyaltyAccount, amount int64) error {
+	return debitBalance(account.CustomerID, &account.Balance, amount)
+}
+
+// debitBalance trừ `amount` khỏi một số dư của tài khoản `customerID`
+func debitBalance(customerID string, balance *int64, amount int64) error {
+	if *balance < amount {
+		return errInsufficientBalance(customerID, *balance, amount)
+	}
+	newBalance, err := subPoints(*balance, amount)
+	if err != nil {
+		return err
+	}
+	*balance = newBalance
+	return nil
+}
+
+// addPoints cộng hai lượng điểm, trả về lỗi thay vì tràn số int64
+func addPoints(a, b int64) (int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, newChaincodeError(ErrCodeAmountLimitExceeded, nil, "point arithmetic overflow: %d + %d", a, b)
+	}
+	return a + b, nil
+}
+
+// subPoints trừ hai lượng điểm, trả về lỗi thay vì tràn số int64
+func subPoints(a, b int64) (int64, error) {
+	if (b > 0 && a < math.MinInt64+b) || (b < 0 && a > math.MaxInt64+b) {
+		return 0, newChaincodeError(ErrCodeAmountLimitExceeded, nil, "point arithmetic overflow: %d - %d", a, b)
+	}
+	return a - b, nil
+}
@@ -8,19 +8,32 @@ import (
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
-// SmartContract cung cấp các hàm logic để quản lý điểm loyalty
-type SmartContract struct {
-	contractapi.Contract
-}
-
 // LoyaltyAccount định nghĩa cấu trúc cho một tài khoản loyalty trên sổ cái
 type LoyaltyAccount struct {
+	DocType          string `json:"docType"` // luôn là "account", dùng trong CouchDB selector
 	CustomerID       string `json:"customerID"`
-	Balance          int    `json:"balance"`
+	Balance          int64  `json:"balance"`
 	LastUpdated      string `json:"lastUpdated"`
-	LifetimeEarned   int    `json:"lifetimeEarned"`
-	LifetimeRedeemed int    `json:"lifetimeRedeemed"`
+	LifetimeEarned   int64  `json:"lifetimeEarned"`
+	LifetimeRedeemed int64  `json:"lifetimeRedeemed"`
+	Tier             string `json:"tier"` // suy ra từ LifetimeEarned (POINTS và STATUS) mỗi khi ghi tài khoản
 	Status           string `json:"status"`
+	DeltaMode        bool   `json:"deltaMode,omitempty"`  // ghi có dưới dạng BalanceDelta, xem delta.go
+	MergedInto       string `json:"mergedInto,omitempty"` // ID tài khoản giữ lại sau khi gộp, xem merge.go
+	SchemaVersion    int    `json:"schemaVersion"`
+
+	Currencies    map[string]*CurrencyBalance `json:"currencies,omitempty"`    // số dư các đơn vị điểm khác POINTS, xem currency.go
+	EndorsingOrgs []string                    `json:"endorsingOrgs,omitempty"` // chính sách xác nhận cấp key, xem endorsement.go
+	SigningKey    string                      `json:"signingKey,omitempty"`    // khóa công khai PEM để ký ủy quyền chuyển điểm, xem signature.go
+
+	RecentTransfers   []TransferStamp `json:"recentTransfers,omitempty"`   // các lần chuyển điểm trong 24 giờ gần nhất, xem velocity.go
+	SecurityChange    string          `json:"securityChange,omitempty"`    // thay đổi bảo mật gần nhất: PASSWORD hoặc PHONE
+	SecurityChangedAt string          `json:"securityChangedAt,omitempty"` // thời điểm thay đổi bảo mật gần nhất
+
+	ReferredBy string `json:"referredBy,omitempty"` // ID người giới thiệu, xem referral.go
+
+	FoldedDeltas []FoldedDelta `json:"foldedDeltas,omitempty"` // delta được gộp bởi giao dịch ghi bản ghi này, xem GetBalanceAt
+	foldedInTx   bool          // foldDeltas đã gộp delta vào tài khoản trong giao dịch hiện tại
 }
 
 // LoyaltyTransaction định nghĩa cấu trúc cho một giao dịch loyalty
@@ -28,35 +41,43 @@ type LoyaltyAccount struct {
 type LoyaltyTransaction struct {
 	TransactionID string `json:"transactionID"`
 	CustomerID    string `json:"customerID"`
-	Type          string `json:"type"` // ISSUE, REDEEM, TRANSFER_IN, TRANSFER_OUT, CREATE_ACCOUNT
-	Amount        int    `json:"amount"`
+	Type          string `json:"type"`               // ISSUE, REDEEM, TRANSFER_IN, TRANSFER_OUT, CREATE_ACCOUNT, ADJUSTMENT, EXPIRE
+	Currency      string `json:"currency,omitempty"` // đơn vị điểm, xem currency.go
+	Amount        int64  `json:"amount"`
 	Timestamp     string `json:"timestamp"`
 	Description   string `json:"description"`
 }
 
 // Customer định nghĩa cấu trúc cho thông tin khách hàng
 type Customer struct {
-	CustomerID string `json:"customerID"`
-	FullName   string `json:"fullName"`
-	Email      string `json:"email"`
-	Phone      string `json:"phone"`
-	Tier       string `json:"tier"`
-	Status     string `json:"status"`
+	CustomerID    string `json:"customerID"`
+	FullName      string `json:"fullName"`
+	Email         string `json:"email"`
+	Phone         string `json:"phone"`
+	Tier          string `json:"tier"`
+	Status        string `json:"status"`
+	SchemaVersion int    `json:"schemaVersion"`
 }
 
 // Reward định nghĩa cấu trúc cho phần thưởng
 type Reward struct {
-	RewardID    string  `json:"rewardID"`
-	Name        string  `json:"name"`
-	PointsCost  int     `json:"pointsCost"`
-	CashValue   float64 `json:"cashValue"`
-	Quantity    int     `json:"quantity"`
-	Status      string  `json:"status"`
+	RewardID            string  `json:"rewardID"`
+	Name                string  `json:"name"`
+	PointsCost          int64   `json:"pointsCost"`
+	CashValue           float64 `json:"cashValue"`
+	Quantity            int     `json:"quantity"`
+	Status              string  `json:"status"`
+	VoucherValidityDays int     `json:"voucherValidityDays"` // 0 = mặc định 30 ngày
+	SchemaVersion       int     `json:"schemaVersion"`
 }
 
-// GetCurrentTimestamp trả về timestamp hiện tại
-func GetCurrentTimestamp() string {
-	return time.Now().Format(time.RFC3339)
+// GetCurrentTimestamp trả về timestamp của giao dịch (giống nhau trên mọi peer xác nhận, khác với time.Now())
+func GetCurrentTimestamp(ctx contractapi.TransactionContextInterface) (string, error) {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	return now.Format(time.RFC3339), nil
 }
 
 // =========================================================================================
@@ -68,32 +89,44 @@ func GetCurrentTimestamp() string {
 // 2. Nếu chưa tồn tại, tạo một đối tượng LoyaltyAccount mới.
 // 3. Gán CustomerID từ tham số đầu vào, Balance là 0, và LastUpdated là thời gian hiện tại.
 // 4. Chuyển đổi đối tượng thành dạng JSON.
-// 5. Lưu đối tượng JSON này vào World State của sổ cái với key là customerID.
+// 5. Lưu đối tượng JSON này vào World State của sổ cái với key là account~customerID.
 // 6. Trả về đối tượng LoyaltyAccount vừa tạo.
 // =========================================================================================
 // Gợi ý cho Copilot:
 // CreateLoyaltyAccount tạo một tài khoản loyalty mới trên sổ cái.
 // Đồng thời phát ra một sự kiện "CreateAccountEvent" để ghi nhận giao dịch.
-func (s *SmartContract) CreateLoyaltyAccount(ctx contractapi.TransactionContextInterface, customerID string) (*LoyaltyAccount, error) {
+func (s *AccountContract) CreateLoyaltyAccount(ctx contractapi.TransactionContextInterface, customerID string) (*LoyaltyAccount, error) {
 	// === Validation: Thêm bước kiểm tra đầu vào ===
 	if customerID == "" {
-		return nil, fmt.Errorf("customer ID cannot be empty")
+		return nil, errInvalidArgument("customer ID cannot be empty")
 	}
 
 	// 1. Kiểm tra xem tài khoản với customerID đã tồn tại trên sổ cái chưa
-	existingAccountJSON, err := ctx.GetStub().GetState(customerID)
+	existingAccountJSON, err := getAccountState(ctx, customerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
 	}
 	if existingAccountJSON != nil {
-		return nil, fmt.Errorf("loyalty account with customer ID '%s' already exists", customerID)
+		return nil, newChaincodeError(ErrCodeAccountAlreadyExists, map[string]interface{}{"customerID": customerID}, "loyalty account with customer ID '%s' already exists", customerID)
 	}
 
 	// 2. & 3. Tạo một đối tượng LoyaltyAccount mới
+	now, err := GetCurrentTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
 	account := LoyaltyAccount{
-		CustomerID:  customerID,
-		Balance:     0,
-		LastUpdated: time.Now().UTC().Format(time.RFC3339),
+		CustomerID:       customerID,
+		Balance:          0,
+		LastUpdated:      now,
+		LifetimeEarned:   0,
+		LifetimeRedeemed: 0,
+		Status:           "ACTIVE",
+		SchemaVersion:    accountSchemaVersion,
+	}
+	account.setIndexedFields()
+	if err := updateAccountStats(ctx, &account, 0, 0); err != nil {
+		return nil, err
 	}
 
 	// 4. Chuyển đổi đối tượng thành dạng JSON
@@ -103,7 +136,7 @@ func (s *SmartContract) CreateLoyaltyAccount(ctx contractapi.TransactionContextI
 	}
 
 	// 5. Lưu đối tượng JSON này vào World State
-	err = ctx.GetStub().PutState(customerID, accountJSON)
+	err = putAccountState(ctx, customerID, accountJSON)
 	if err != nil {
 		return nil, fmt.Errorf("failed to put state for account: %v", err)
 	}
@@ -138,41 +171,46 @@ func (s *SmartContract) CreateLoyaltyAccount(ctx contractapi.TransactionContextI
 // Yêu cầu: FRS-002
 //
 // Logic chính:
-// 1. Kiểm tra định danh của người gọi. Chỉ có thành viên của `BankOrgMSP` mới có quyền phát hành điểm.
+// 1. Kiểm tra định danh của người gọi. Chỉ có thành viên của `BankOrgMSP` mới có quyền phát hành điểm
+//    (thực hiện trong BeforeTransaction, xem contracts.go).
 // 2. Tìm tài khoản Loyalty theo `customerID`. Nếu không tồn tại -> trả về lỗi.
-// 3. Kiểm tra `amount` (số điểm) phải là số nguyên dương (>0). Nếu không -> trả về lỗi.
-// 4. Đọc số dư hiện tại, tính số dư mới = số dư cũ + amount.
+// 3. Kiểm tra `amount` (số điểm) phải là số nguyên dương (>0) và không vượt quá giới hạn mỗi giao dịch
+//    trong LedgerConfig, `currency` là đơn vị điểm đã cấu hình (rỗng = POINTS, xem currency.go). Nếu không -> trả về lỗi.
+// 4. Đọc số dư hiện tại của đơn vị điểm, tính số dư mới = số dư cũ + amount (có kiểm tra tràn số và số dư tối đa).
 // 5. Cập nhật lại đối tượng LoyaltyAccount với số dư mới vào World State.
 // 6. Tạo và phát ra một sự kiện "IssuePointsEvent" với thông tin chi tiết của giao dịch.
 // 7. Trả về đối tượng LoyaltyAccount đã được cập nhật.
 // =========================================================================================
 // Gợi ý cho Copilot:
-func (s *SmartContract) IssuePoints(ctx contractapi.TransactionContextInterface, customerID string, amount int, description string) (*LoyaltyAccount, error) {
-	// 1. Kiểm tra định danh của người gọi - chỉ BankOrgMSP mới có quyền phát hành điểm
-	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
-	}
-	if clientMSPID != "BankOrgMSP" {
-		return nil, fmt.Errorf("access denied: only BankOrgMSP can issue points, got MSP ID: %s", clientMSPID)
-	}
+func (s *AccountContract) IssuePoints(ctx contractapi.TransactionContextInterface, customerID string, amount int64, description string, currency string) (*LoyaltyAccount, error) {
+	// 1. Định danh của người gọi (chỉ BankOrgMSP) đã được kiểm tra trong BeforeTransaction
 
 	// === Validation đầu vào ===
 	if customerID == "" {
-		return nil, fmt.Errorf("customer ID cannot be empty")
+		return nil, errInvalidArgument("customer ID cannot be empty")
 	}
-	// 3. Kiểm tra amount phải là số nguyên dương
+	// 3. Kiểm tra amount phải là số nguyên dương và trong giới hạn mỗi giao dịch
 	if amount <= 0 {
-		return nil, fmt.Errorf("amount must be a positive integer, got: %d", amount)
+		return nil, errInvalidArgument("amount must be a positive integer, got: %d", amount)
+	}
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.checkTransactionAmount(amount); err != nil {
+		return nil, err
+	}
+	if currency, _, err = config.currencyRule(currency); err != nil {
+		return nil, err
 	}
 
 	// 2. Tìm tài khoản Loyalty theo customerID
-	accountJSON, err := ctx.GetStub().GetState(customerID)
+	accountJSON, err := getAccountState(ctx, customerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read account from world state: %v", err)
 	}
 	if accountJSON == nil {
-		return nil, fmt.Errorf("loyalty account with customer ID '%s' does not exist", customerID)
+		return nil, errAccountNotFound(customerID)
 	}
 
 	// Deserialize tài khoản hiện tại
@@ -181,21 +219,38 @@ func (s *SmartContract) IssuePoints(ctx contractapi.TransactionContextInterface,
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal account data: %v", err)
 	}
+	upgradeAccount(&account)
 
 	// 4. Tính số dư mới = số dư cũ + amount
-	oldBalance := account.Balance
-	account.Balance = oldBalance + amount
-	account.LastUpdated = time.Now().UTC().Format(time.RFC3339)
-
-	// 5. Cập nhật lại đối tượng LoyaltyAccount vào World State
-	updatedAccountJSON, err := json.Marshal(account)
+	deferred, err := creditCurrency(ctx, config, &account, currency, amount, true)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal updated account: %v", err)
+		return nil, err
+	}
+	if account.LastUpdated, err = GetCurrentTimestamp(ctx); err != nil {
+		return nil, err
+	}
+
+	// 5. Cập nhật lại đối tượng LoyaltyAccount vào World State (tài khoản ở chế độ delta đã được ghi có qua BalanceDelta)
+	if !deferred {
+		account.setIndexedFields()
+		if err := updateAccountStats(ctx, &account, 0, 0); err != nil {
+			return nil, err
+		}
+		updatedAccountJSON, err := json.Marshal(account)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal updated account: %v", err)
+		}
+
+		err = putAccountState(ctx, customerID, updatedAccountJSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update account in world state: %v", err)
+		}
 	}
 
-	err = ctx.GetStub().PutState(customerID, updatedAccountJSON)
+	// Cập nhật bộ đếm tổng cung
+	err = updateCurrencySupplyCounters(ctx, currency, amount, 0, 0, 0)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update account in world state: %v", err)
+		return nil, err
 	}
 
 	// 6. Tạo và phát ra sự kiện "IssuePointsEvent"
@@ -203,6 +258,7 @@ func (s *SmartContract) IssuePoints(ctx contractapi.TransactionContextInterface,
 		TransactionID: ctx.GetStub().GetTxID(),
 		CustomerID:    customerID,
 		Type:          "ISSUE",
+		Currency:      currency,
 		Amount:        amount,
 		Timestamp:     account.LastUpdated,
 		Description:   description,
@@ -229,6 +285,7 @@ func (s *SmartContract) IssuePoints(ctx contractapi.TransactionContextInterface,
 // Logic chính:
 // 1. Tìm tài khoản Loyalty theo `customerID`. Nếu không tồn tại -> trả về lỗi.
 // 2. Kiểm tra `amount` (số điểm) phải là số nguyên dương (>0). Nếu không -> trả về lỗi.
+//    `currency` (rỗng = POINTS) phải là đơn vị điểm được phép quy đổi (Spendable). Nếu không -> CURRENCY_NOT_ALLOWED.
 // 3. Đọc số dư hiện tại của tài khoản.
 // 4. KIỂM TRA QUAN TRỌNG: Số dư hiện tại phải lớn hơn hoặc bằng số điểm muốn quy đổi (balance >= amount). Nếu không -> trả về lỗi "Không đủ điểm".
 // 5. Tính số dư mới = số dư cũ - amount.
@@ -237,23 +294,37 @@ func (s *SmartContract) IssuePoints(ctx contractapi.TransactionContextInterface,
 // 8. Trả về đối tượng LoyaltyAccount đã được cập nhật.
 // =========================================================================================
 // Gợi ý cho Copilot:
-func (s *SmartContract) RedeemPoints(ctx contractapi.TransactionContextInterface, customerID string, amount int, description string) (*LoyaltyAccount, error) {
+func (s *AccountContract) RedeemPoints(ctx contractapi.TransactionContextInterface, customerID string, amount int64, description string, currency string) (*LoyaltyAccount, error) {
 	// === Validation đầu vào ===
 	if customerID == "" {
-		return nil, fmt.Errorf("customer ID cannot be empty")
+		return nil, errInvalidArgument("customer ID cannot be empty")
 	}
-	// 2. Kiểm tra amount phải là số nguyên dương
+	// 2. Kiểm tra amount phải là số nguyên dương và trong giới hạn mỗi giao dịch
 	if amount <= 0 {
-		return nil, fmt.Errorf("amount must be a positive integer, got: %d", amount)
+		return nil, errInvalidArgument("amount must be a positive integer, got: %d", amount)
+	}
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.checkTransactionAmount(amount); err != nil {
+		return nil, err
+	}
+	currency, rule, err := config.currencyRule(currency)
+	if err != nil {
+		return nil, err
+	}
+	if !rule.Spendable {
+		return nil, newChaincodeError(ErrCodeCurrencyNotAllowed, map[string]interface{}{"currency": currency}, "currency %s cannot be redeemed", currency)
 	}
 
 	// 1. Tìm tài khoản Loyalty theo customerID
-	accountJSON, err := ctx.GetStub().GetState(customerID)
+	accountJSON, err := getAccountState(ctx, customerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read account from world state: %v", err)
 	}
 	if accountJSON == nil {
-		return nil, fmt.Errorf("loyalty account with customer ID '%s' does not exist", customerID)
+		return nil, errAccountNotFound(customerID)
 	}
 
 	// 3. Deserialize tài khoản để đọc số dư hiện tại
@@ -262,33 +333,44 @@ func (s *SmartContract) RedeemPoints(ctx contractapi.TransactionContextInterface
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal account data: %v", err)
 	}
+	upgradeAccount(&account)
 
-	// 4. KIỂM TRA QUAN TRỌNG: Số dư hiện tại phải >= số điểm muốn quy đổi
-	if account.Balance < amount {
-		return nil, fmt.Errorf("insufficient balance: current balance is %d, requested amount is %d", account.Balance, amount)
+	// 4. & 5. KIỂM TRA QUAN TRỌNG: Số dư hiện tại phải >= số điểm muốn quy đổi, số dư mới = số dư cũ - amount.
+	// debitAccount gộp các delta đang chờ trước nếu tài khoản ở chế độ delta, nên kiểm tra trên số dư nhất quán.
+	if err := debitCurrency(ctx, &account, currency, amount, true); err != nil {
+		return nil, err
+	}
+	if account.LastUpdated, err = GetCurrentTimestamp(ctx); err != nil {
+		return nil, err
 	}
-
-	// 5. Tính số dư mới = số dư cũ - amount
-	oldBalance := account.Balance
-	account.Balance = oldBalance - amount
-	account.LastUpdated = time.Now().UTC().Format(time.RFC3339)
 
 	// 6. Cập nhật lại đối tượng LoyaltyAccount vào World State
+	account.setIndexedFields()
+	if err := updateAccountStats(ctx, &account, 0, 0); err != nil {
+		return nil, err
+	}
 	updatedAccountJSON, err := json.Marshal(account)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal updated account: %v", err)
 	}
 
-	err = ctx.GetStub().PutState(customerID, updatedAccountJSON)
+	err = putAccountState(ctx, customerID, updatedAccountJSON)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update account in world state: %v", err)
 	}
 
+	// Cập nhật bộ đếm tổng cung
+	err = updateCurrencySupplyCounters(ctx, currency, 0, amount, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
 	// 7. Tạo và phát ra sự kiện "RedeemPointsEvent"
 	transaction := LoyaltyTransaction{
 		TransactionID: ctx.GetStub().GetTxID(),
 		CustomerID:    customerID,
 		Type:          "REDEEM",
+		Currency:      currency,
 		Amount:        amount,
 		Timestamp:     account.LastUpdated,
 		Description:   description,
@@ -316,98 +398,146 @@ func (s *SmartContract) RedeemPoints(ctx contractapi.TransactionContextInterface
 // 1. Lấy thông tin tài khoản nguồn (source) và tài khoản đích (target) từ sổ cái.
 // 2. Kiểm tra cả hai tài khoản phải tồn tại. Nếu một trong hai không tồn tại -> trả về lỗi.
 // 3. Kiểm tra các điều kiện đầu vào:
-//    - `amount` phải là số nguyên dương (>0).
+//    - `amount` phải là số nguyên dương (>0) và không vượt quá giới hạn mỗi giao dịch (LedgerConfig).
 //    - Tài khoản nguồn và đích phải khác nhau (`sourceCustomerID != targetCustomerID`).
+//    - `currency` (rỗng = POINTS) phải là đơn vị điểm được phép chuyển (Transferable), nếu không -> CURRENCY_NOT_ALLOWED.
+//    - Nếu tài khoản nguồn đã đăng ký khóa ký, `nonce`, `expiresAt` và `signature` phải là ủy quyền hợp lệ
+//      của khách hàng (xem UC-028 trong signature.go); nếu chưa đăng ký thì các tham số này để rỗng.
 // 4. KIỂM TRA QUAN TRỌNG: Số dư của tài khoản nguồn phải lớn hơn hoặc bằng số điểm muốn chuyển. Nếu không -> trả về lỗi.
-// 5. Trừ điểm từ tài khoản nguồn và cộng điểm vào tài khoản đích.
-// 6. Cập nhật lại cả hai đối tượng tài khoản vào World State.
+//    Nếu giao dịch vi phạm quy tắc tốc độ (UC-029 trong velocity.go), điểm được trừ khỏi tài khoản nguồn
+//    và giữ lại chờ duyệt; hàm trả về bản ghi FlaggedTransfer và dừng tại đây.
+// 5. Trừ điểm từ tài khoản nguồn và cộng điểm vào tài khoản đích (số dư đích không vượt quá số dư tối đa).
+// 6. Cập nhật lại cả hai đối tượng tài khoản vào World State
+//    (tài khoản đích ở chế độ delta được ghi có qua BalanceDelta, không ghi key tài khoản).
 // 7. Tạo và phát ra hai sự kiện:
 //    - "TransferOutEvent" cho tài khoản nguồn.
 //    - "TransferInEvent" cho tài khoản đích.
-// 8. Trả về thông báo thành công. (Hàm này không cần trả về đối tượng tài khoản, chỉ cần nil error là đủ).
+// 8. Trả về thông báo thành công. (Hàm này không cần trả về đối tượng tài khoản; kết quả nil nghĩa là
+//    điểm đã được chuyển, khác nil là giao dịch bị giữ lại).
 // =========================================================================================
 // Gợi ý cho Copilot:
-func (s *SmartContract) TransferPoints(ctx contractapi.TransactionContextInterface, sourceCustomerID string, targetCustomerID string, amount int, description string) error {
+func (s *AccountContract) TransferPoints(ctx contractapi.TransactionContextInterface, sourceCustomerID string, targetCustomerID string, amount int64, description string, currency string, nonce string, expiresAt string, signature string) (*FlaggedTransfer, error) {
 	// === Validation đầu vào ===
 	if sourceCustomerID == "" {
-		return fmt.Errorf("source customer ID cannot be empty")
+		return nil, errInvalidArgument("source customer ID cannot be empty")
 	}
 	if targetCustomerID == "" {
-		return fmt.Errorf("target customer ID cannot be empty")
+		return nil, errInvalidArgument("target customer ID cannot be empty")
 	}
 	// 3. Kiểm tra tài khoản nguồn và đích phải khác nhau
 	if sourceCustomerID == targetCustomerID {
-		return fmt.Errorf("source and target customer IDs must be different")
+		return nil, errInvalidArgument("source and target customer IDs must be different")
 	}
-	// 3. Kiểm tra amount phải là số nguyên dương
+	// 3. Kiểm tra amount phải là số nguyên dương và trong giới hạn mỗi giao dịch
 	if amount <= 0 {
-		return fmt.Errorf("amount must be a positive integer, got: %d", amount)
+		return nil, errInvalidArgument("amount must be a positive integer, got: %d", amount)
+	}
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.checkTransactionAmount(amount); err != nil {
+		return nil, err
+	}
+	currency, rule, err := config.currencyRule(currency)
+	if err != nil {
+		return nil, err
+	}
+	if !rule.Transferable {
+		return nil, newChaincodeError(ErrCodeCurrencyNotAllowed, map[string]interface{}{"currency": currency}, "currency %s cannot be transferred", currency)
 	}
 
 	// 1. & 2. Lấy thông tin tài khoản nguồn (source)
-	sourceAccountJSON, err := ctx.GetStub().GetState(sourceCustomerID)
+	sourceAccountJSON, err := getAccountState(ctx, sourceCustomerID)
 	if err != nil {
-		return fmt.Errorf("failed to read source account from world state: %v", err)
+		return nil, fmt.Errorf("failed to read source account from world state: %v", err)
 	}
 	if sourceAccountJSON == nil {
-		return fmt.Errorf("source loyalty account with customer ID '%s' does not exist", sourceCustomerID)
+		return nil, errAccountNotFound(sourceCustomerID)
 	}
 
 	// 1. & 2. Lấy thông tin tài khoản đích (target)
-	targetAccountJSON, err := ctx.GetStub().GetState(targetCustomerID)
+	targetAccountJSON, err := getAccountState(ctx, targetCustomerID)
 	if err != nil {
-		return fmt.Errorf("failed to read target account from world state: %v", err)
+		return nil, fmt.Errorf("failed to read target account from world state: %v", err)
 	}
 	if targetAccountJSON == nil {
-		return fmt.Errorf("target loyalty account with customer ID '%s' does not exist", targetCustomerID)
+		return nil, errAccountNotFound(targetCustomerID)
 	}
 
 	// Deserialize cả hai tài khoản
 	var sourceAccount LoyaltyAccount
 	err = json.Unmarshal(sourceAccountJSON, &sourceAccount)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal source account data: %v", err)
+		return nil, fmt.Errorf("failed to unmarshal source account data: %v", err)
+	}
+	upgradeAccount(&sourceAccount)
+	if err := verifyTransferAuthorization(ctx, authorizationTransfer, &sourceAccount, targetCustomerID, amount, currency, nonce, expiresAt, signature); err != nil {
+		return nil, err
 	}
 
 	var targetAccount LoyaltyAccount
 	err = json.Unmarshal(targetAccountJSON, &targetAccount)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal target account data: %v", err)
+		return nil, fmt.Errorf("failed to unmarshal target account data: %v", err)
 	}
+	upgradeAccount(&targetAccount)
 
-	// 4. KIỂM TRA QUAN TRỌNG: Số dư của tài khoản nguồn phải >= số điểm muốn chuyển
-	if sourceAccount.Balance < amount {
-		return fmt.Errorf("insufficient balance in source account: current balance is %d, requested amount is %d", sourceAccount.Balance, amount)
+	// 4. Quy tắc tốc độ (UC-029 trong velocity.go): giao dịch vi phạm được giữ lại chờ duyệt thay vì bị từ chối
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rules := config.Velocity.recordTransfer(&sourceAccount, targetCustomerID, txTime); len(rules) > 0 {
+		return holdFlaggedTransfer(ctx, &sourceAccount, &targetAccount, amount, currency, description, rules, txTime)
 	}
 
-	// 5. Trừ điểm từ tài khoản nguồn và cộng điểm vào tài khoản đích
-	currentTime := time.Now().UTC().Format(time.RFC3339)
+	// 4. & 5. Trừ điểm từ tài khoản nguồn và cộng điểm vào tài khoản đích.
+	// KIỂM TRA QUAN TRỌNG: debitAccount trả về lỗi nếu số dư của tài khoản nguồn < số điểm muốn chuyển.
+	currentTime := txTime.Format(time.RFC3339)
 	
-	sourceAccount.Balance -= amount
+	if err := debitCurrency(ctx, &sourceAccount, currency, amount, false); err != nil {
+		return nil, err
+	}
 	sourceAccount.LastUpdated = currentTime
 	
-	targetAccount.Balance += amount
+	targetDeferred, err := creditCurrency(ctx, config, &targetAccount, currency, amount, false)
+	if err != nil {
+		return nil, err
+	}
 	targetAccount.LastUpdated = currentTime
 
 	// 6. Cập nhật lại cả hai đối tượng tài khoản vào World State
+	sourceAccount.setIndexedFields()
+	targetAccount.setIndexedFields()
+	if err := updateAccountStats(ctx, &sourceAccount, 0, 0); err != nil {
+		return nil, err
+	}
+	if !targetDeferred {
+		if err := updateAccountStats(ctx, &targetAccount, 0, 0); err != nil {
+			return nil, err
+		}
+	}
 	updatedSourceJSON, err := json.Marshal(sourceAccount)
 	if err != nil {
-		return fmt.Errorf("failed to marshal updated source account: %v", err)
+		return nil, fmt.Errorf("failed to marshal updated source account: %v", err)
 	}
 
 	updatedTargetJSON, err := json.Marshal(targetAccount)
 	if err != nil {
-		return fmt.Errorf("failed to marshal updated target account: %v", err)
+		return nil, fmt.Errorf("failed to marshal updated target account: %v", err)
 	}
 
-	err = ctx.GetStub().PutState(sourceCustomerID, updatedSourceJSON)
+	err = putAccountState(ctx, sourceCustomerID, updatedSourceJSON)
 	if err != nil {
-		return fmt.Errorf("failed to update source account in world state: %v", err)
+		return nil, fmt.Errorf("failed to update source account in world state: %v", err)
 	}
 
-	err = ctx.GetStub().PutState(targetCustomerID, updatedTargetJSON)
-	if err != nil {
-		return fmt.Errorf("failed to update target account in world state: %v", err)
+	if !targetDeferred {
+		err = putAccountState(ctx, targetCustomerID, updatedTargetJSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update target account in world state: %v", err)
+		}
 	}
 
 	// 7. Tạo và phát ra hai sự kiện
@@ -418,6 +548,7 @@ func (s *SmartContract) TransferPoints(ctx contractapi.TransactionContextInterfa
 		TransactionID: txID,
 		CustomerID:    sourceCustomerID,
 		Type:          "TRANSFER_OUT",
+		Currency:      currency,
 		Amount:        amount,
 		Timestamp:     currentTime,
 		Description:   fmt.Sprintf("Transfer to %s: %s", targetCustomerID, description),
@@ -425,12 +556,12 @@ func (s *SmartContract) TransferPoints(ctx contractapi.TransactionContextInterfa
 
 	transferOutJSON, err := json.Marshal(transferOutTransaction)
 	if err != nil {
-		return fmt.Errorf("failed to marshal transfer out transaction: %v", err)
+		return nil, fmt.Errorf("failed to marshal transfer out transaction: %v", err)
 	}
 
 	err = ctx.GetStub().SetEvent("TransferOutEvent", transferOutJSON)
 	if err != nil {
-		return fmt.Errorf("failed to set transfer out event: %v", err)
+		return nil, fmt.Errorf("failed to set transfer out event: %v", err)
 	}
 
 	// Sự kiện "TransferInEvent" cho tài khoản đích
@@ -438,6 +569,7 @@ func (s *SmartContract) TransferPoints(ctx contractapi.TransactionContextInterfa
 		TransactionID: txID,
 		CustomerID:    targetCustomerID,
 		Type:          "TRANSFER_IN",
+		Currency:      currency,
 		Amount:        amount,
 		Timestamp:     currentTime,
 		Description:   fmt.Sprintf("Transfer from %s: %s", sourceCustomerID, description),
@@ -445,16 +577,16 @@ func (s *SmartContract) TransferPoints(ctx contractapi.TransactionContextInterfa
 
 	transferInJSON, err := json.Marshal(transferInTransaction)
 	if err != nil {
-		return fmt.Errorf("failed to marshal transfer in transaction: %v", err)
+		return nil, fmt.Errorf("failed to marshal transfer in transaction: %v", err)
 	}
 
 	err = ctx.GetStub().SetEvent("TransferInEvent", transferInJSON)
 	if err != nil {
-		return fmt.Errorf("failed to set transfer in event: %v", err)
+		return nil, fmt.Errorf("failed to set transfer in event: %v", err)
 	}
 
-	// 8. Trả về thành công (nil error)
-	return nil
+	// 8. Trả về thành công (không có giao dịch bị giữ lại)
+	return nil, nil
 }
 
 // =========================================================================================
@@ -465,24 +597,25 @@ func (s *SmartContract) TransferPoints(ctx contractapi.TransactionContextInterfa
 // 1. Lấy trạng thái của tài khoản từ sổ cái bằng `customerID`.
 // 2. Nếu không tìm thấy tài khoản -> trả về lỗi.
 // 3. Nếu tìm thấy, deserialize dữ liệu JSON thành đối tượng LoyaltyAccount.
+//    Nếu tài khoản đã được gộp (MergedInto), dùng tài khoản giữ lại.
 // 4. Trả về đối tượng LoyaltyAccount.
 // =========================================================================================
 // Gợi ý cho Copilot:
-func (s *SmartContract) QueryLoyaltyAccount(ctx contractapi.TransactionContextInterface, customerID string) (*LoyaltyAccount, error) {
+func (s *AccountContract) QueryLoyaltyAccount(ctx contractapi.TransactionContextInterface, customerID string) (*LoyaltyAccount, error) {
 	// === Validation đầu vào ===
 	if customerID == "" {
-		return nil, fmt.Errorf("customer ID cannot be empty")
+		return nil, errInvalidArgument("customer ID cannot be empty")
 	}
 
 	// 1. Lấy trạng thái của tài khoản từ sổ cái bằng customerID
-	accountJSON, err := ctx.GetStub().GetState(customerID)
+	accountJSON, err := getAccountState(ctx, customerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read account from world state: %v", err)
 	}
 
 	// 2. Nếu không tìm thấy tài khoản -> trả về lỗi
 	if accountJSON == nil {
-		return nil, fmt.Errorf("loyalty account with customer ID '%s' does not exist", customerID)
+		return nil, errAccountNotFound(customerID)
 	}
 
 	// 3. Deserialize dữ liệu JSON thành đối tượng LoyaltyAccount
@@ -491,6 +624,27 @@ func (s *SmartContract) QueryLoyaltyAccount(ctx contractapi.TransactionContextIn
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal account data: %v", err)
 	}
+	upgradeAccount(&account)
+
+	// Tài khoản đã được gộp: trả về tài khoản giữ lại (xem MergeAccounts)
+	if account.MergedInto != "" {
+		survivorID, err := resolveAccountID(ctx, account.MergedInto)
+		if err != nil {
+			return nil, err
+		}
+		survivor, err := getAccount(ctx, survivorID)
+		if err != nil {
+			return nil, err
+		}
+		account = *survivor
+	}
+
+	// Tài khoản ở chế độ delta: số dư trả về bao gồm các delta chưa gộp
+	if account.DeltaMode {
+		if _, err := foldDeltas(ctx, &account, false); err != nil {
+			return nil, err
+		}
+	}
 
 	// 4. Trả về đối tượng LoyaltyAccount
 	return &account, nil
@@ -501,8 +655,10 @@ func (s *SmartContract) QueryLoyaltyAccount(ctx contractapi.TransactionContextIn
 // Yêu cầu: FRS-005
 //
 // Logic chính:
-// 1. Sử dụng hàm `GetHistoryForKey` của Fabric để lấy một iterator cho tất cả các thay đổi lịch sử của `customerID`.
-// 2. Lặp qua iterator.
+// 1. Sử dụng hàm `GetHistoryForKey` của Fabric để lấy tất cả các thay đổi lịch sử của tài khoản
+//    (trên cả key account~customerID và key đơn giản customerID trước khi di chuyển).
+//    Nếu tài khoản đã được gộp, lịch sử được nối tiếp bằng lịch sử của tài khoản giữ lại từ giao dịch gộp.
+// 2. Lặp qua các bản ghi lịch sử.
 // 3. Với mỗi bản ghi lịch sử, bỏ qua nếu nó là bản ghi xóa (IsDelete).
 // 4. Deserialize giá trị của bản ghi thành một đối tượng LoyaltyAccount (để lấy thông tin về trạng thái tại thời điểm đó).
 // 5. Tạo một đối tượng `historyItem` chứa thông tin TransactionID, Timestamp và trạng thái tài khoản.
@@ -518,22 +674,17 @@ type HistoryQueryResult struct {
 	IsDelete  bool            `json:"isDelete"`
 }
 
-func (s *SmartContract) QueryLoyaltyHistory(ctx contractapi.TransactionContextInterface, customerID string) ([]*HistoryQueryResult, error) {
-	// 1. Sử dụng hàm `GetHistoryForKey` của Fabric để lấy một iterator cho tất cả các thay đổi lịch sử của `customerID`.
-	historyIterator, err := ctx.GetStub().GetHistoryForKey(customerID)
+func (s *AccountContract) QueryLoyaltyHistory(ctx contractapi.TransactionContextInterface, customerID string) ([]*HistoryQueryResult, error) {
+	// 1. Sử dụng hàm `GetHistoryForKey` của Fabric để lấy tất cả các thay đổi lịch sử của tài khoản.
+	historyRecords, err := getResolvedAccountHistory(ctx, customerID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get history for key '%s': %v", customerID, err)
+		return nil, err
 	}
-	defer historyIterator.Close()
 
-	// 2. Lặp qua iterator.
+	// 2. Lặp qua các bản ghi lịch sử.
 	var results []*HistoryQueryResult
-	for historyIterator.HasNext() {
+	for _, historyRecord := range historyRecords {
 		// 3. Với mỗi bản ghi lịch sử, bỏ qua nếu nó là bản ghi xóa (IsDelete).
-		historyRecord, err := historyIterator.Next()
-		if err != nil {
-			return nil, fmt.Errorf("failed to iterate history: %v", err)
-		}
 		if historyRecord.IsDelete {
 			continue
 		}
@@ -544,12 +695,13 @@ func (s *SmartContract) QueryLoyaltyHistory(ctx contractapi.TransactionContextIn
 		if err != nil {
 			return nil, fmt.Errorf("failed to unmarshal history record: %v", err)
 		}
+		upgradeAccount(&account)
 
 		// 5. Tạo một đối tượng `historyItem` chứa thông tin TransactionID, Timestamp và trạng thái tài khoản.
 		historyItem := &HistoryQueryResult{
 			Record:    &account,
-			TxId:      historyRecord.TxId,
-			Timestamp: historyRecord.Timestamp.AsTime(),
+			TxId:      historyRecord.TxID,
+			Timestamp: historyRecord.Timestamp,
 			IsDelete:  historyRecord.IsDelete,
 		}
 
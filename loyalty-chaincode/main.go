@@ -1,18 +1,93 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"os"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// Biến môi trường cho chế độ chaincode-as-a-service (CCaaS).
+// Khi CHAINCODE_SERVER_ADDRESS và CHAINCODE_ID được đặt, chaincode chạy như một server bên ngoài
+// mà peer kết nối tới (xem connection.json do package_chaincode.sh tạo ra) thay vì được peer khởi chạy.
+const (
+	chaincodeServerAddressEnv = "CHAINCODE_SERVER_ADDRESS" // ví dụ 0.0.0.0:9999
+	chaincodeIDEnv            = "CHAINCODE_ID"             // package ID, ví dụ loyalty_1.0:abc123...
+	chaincodeTLSKeyEnv        = "CHAINCODE_TLS_KEY"        // đường dẫn file private key (PEM), tùy chọn
+	chaincodeTLSCertEnv       = "CHAINCODE_TLS_CERT"       // đường dẫn file certificate (PEM), tùy chọn
+	chaincodeClientCACertEnv  = "CHAINCODE_CLIENT_CA_CERT" // đường dẫn CA xác thực client (peer), tùy chọn
+)
+
 func main() {
-	loyaltySmartContract, err := contractapi.NewChaincode(&SmartContract{})
+	// AccountContract được đăng ký đầu tiên nên là contract mặc định
+	loyaltySmartContract, err := contractapi.NewChaincode(NewAccountContract(), NewRewardContract(), NewAdminContract(), NewSwapContract())
 	if err != nil {
 		log.Panicf("Error creating loyalty chaincode: %v", err)
 	}
 
-	if err := loyaltySmartContract.Start(); err != nil {
-		log.Panicf("Error starting loyalty chaincode: %v", err)
+	serverAddress := os.Getenv(chaincodeServerAddressEnv)
+	chaincodeID := os.Getenv(chaincodeIDEnv)
+	if serverAddress == "" || chaincodeID == "" {
+		// Chế độ mặc định: peer khởi chạy chaincode
+		if err := loyaltySmartContract.Start(); err != nil {
+			log.Panicf("Error starting loyalty chaincode: %v", err)
+		}
+		return
+	}
+
+	tlsProperties, err := getTLSProperties()
+	if err != nil {
+		log.Panicf("Error loading chaincode server TLS configuration: %v", err)
+	}
+	server := &shim.ChaincodeServer{
+		CCID:     chaincodeID,
+		Address:  serverAddress,
+		CC:       loyaltySmartContract,
+		TLSProps: tlsProperties,
+	}
+
+	log.Printf("Starting loyalty chaincode as a service on %s (TLS enabled: %t)", serverAddress, !tlsProperties.Disabled)
+	if err := server.Start(); err != nil {
+		log.Panicf("Error starting loyalty chaincode server: %v", err)
+	}
+}
+
+// getTLSProperties đọc cấu hình TLS từ các file được chỉ định qua biến môi trường.
+// TLS bị tắt nếu không đặt CHAINCODE_TLS_KEY và CHAINCODE_TLS_CERT; đặt thêm CHAINCODE_CLIENT_CA_CERT
+// để bắt buộc peer xác thực bằng chứng chỉ client.
+func getTLSProperties() (shim.TLSProperties, error) {
+	keyFile := os.Getenv(chaincodeTLSKeyEnv)
+	certFile := os.Getenv(chaincodeTLSCertEnv)
+	if keyFile == "" && certFile == "" {
+		return shim.TLSProperties{Disabled: true}, nil
+	}
+	if keyFile == "" || certFile == "" {
+		return shim.TLSProperties{}, fmt.Errorf("both %s and %s must be set to enable TLS", chaincodeTLSKeyEnv, chaincodeTLSCertEnv)
+	}
+
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		return shim.TLSProperties{}, fmt.Errorf("failed to read TLS key file: %v", err)
+	}
+	cert, err := os.ReadFile(certFile)
+	if err != nil {
+		return shim.TLSProperties{}, fmt.Errorf("failed to read TLS certificate file: %v", err)
+	}
+
+	var clientCACerts []byte
+	if clientCACertFile := os.Getenv(chaincodeClientCACertEnv); clientCACertFile != "" {
+		clientCACerts, err = os.ReadFile(clientCACertFile)
+		if err != nil {
+			return shim.TLSProperties{}, fmt.Errorf("failed to read client CA certificate file: %v", err)
+		}
 	}
+
+	return shim.TLSProperties{
+		Disabled:      false,
+		Key:           key,
+		Cert:          cert,
+		ClientCACerts: clientCACerts,
+	}, nil
 }
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxMergeHops giới hạn số lần đi theo con trỏ MergedInto khi phân giải ID (phòng vòng lặp do dữ liệu hỏng)
+const maxMergeHops = 10
+
+// AccountMerge định nghĩa kết quả gộp hai tài khoản trùng lặp
+type AccountMerge struct {
+	TransactionID    string `json:"transactionID"`
+	SurvivorID       string `json:"survivorID"`
+	MergedID         string `json:"mergedID"`
+	Balance          int64  `json:"balance"` // số dư đã chuyển sang tài khoản giữ lại
+	LifetimeEarned   int64  `json:"lifetimeEarned"`
+	LifetimeRedeemed int64  `json:"lifetimeRedeemed"`
+	VouchersMoved    int    `json:"vouchersMoved"`
+	Timestamp        string `json:"timestamp"`
+
+	Currencies map[string]int64 `json:"currencies,omitempty"` // số dư các đơn vị điểm khác POINTS đã chuyển sang
+}
+
+// =========================================================================================
+// UC-019: Gộp tài khoản trùng lặp
+// Chỉ identity của BankOrgMSP có thuộc tính role=adjuster được phép gọi (kiểm tra trong BeforeTransaction).
+//
+// Logic chính:
+// 1. Hai tài khoản phải khác nhau, tồn tại và chưa bị đóng.
+// 2. Gộp các delta đang chờ của cả hai tài khoản (chế độ delta).
+// 3. Cộng số dư, LifetimeEarned và LifetimeRedeemed của tài khoản bị gộp vào tài khoản giữ lại
+//    (có kiểm tra tràn số và số dư tối đa), kể cả số dư của các đơn vị điểm khác POINTS.
+// 4. Chuyển tất cả voucher của tài khoản bị gộp sang tài khoản giữ lại.
+// 5. Đóng tài khoản bị gộp: số dư (mọi đơn vị điểm) và bộ đếm về 0, Status = CLOSED, MergedInto = survivorID.
+//    Các truy vấn theo ID cũ được phân giải sang tài khoản giữ lại. Chỉ mục mergedAccount~<survivorID>~<mergedID>
+//    cho phép GetPointsBalance của tài khoản giữ lại tính cả các khoản điểm chờ vẫn nằm dưới ID cũ.
+// 6. Phát ra sự kiện "MergeAccountsEvent".
+// =========================================================================================
+func (s *AccountContract) MergeAccounts(ctx contractapi.TransactionContextInterface, survivorID string, mergedID string) (*AccountMerge, error) {
+	// 1. Kiểm tra tài khoản
+	if survivorID == mergedID {
+		return nil, errInvalidArgument("survivor and merged accounts must be different")
+	}
+	survivor, err := getAccount(ctx, survivorID)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := getAccount(ctx, mergedID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(survivor); err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(merged); err != nil {
+		return nil, err
+	}
+
+	// 2. Số dư nhất quán
+	for _, account := range []*LoyaltyAccount{survivor, merged} {
+		if account.DeltaMode {
+			if _, err := foldDeltas(ctx, account, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 3. Chuyển số dư và bộ đếm
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.credit(survivor, merged.Balance); err != nil {
+		return nil, err
+	}
+	if survivor.LifetimeEarned, err = addPoints(survivor.LifetimeEarned, merged.LifetimeEarned); err != nil {
+		return nil, err
+	}
+	if survivor.LifetimeRedeemed, err = addPoints(survivor.LifetimeRedeemed, merged.LifetimeRedeemed); err != nil {
+		return nil, err
+	}
+
+	movedCurrencies := map[string]int64{}
+	for currency, mergedBalance := range merged.Currencies {
+		survivorBalance := survivor.currencyBalance(currency)
+		if err := config.creditBalance(survivorID, &survivorBalance.Balance, mergedBalance.Balance); err != nil {
+			return nil, err
+		}
+		if survivorBalance.LifetimeEarned, err = addPoints(survivorBalance.LifetimeEarned, mergedBalance.LifetimeEarned); err != nil {
+			return nil, err
+		}
+		if survivorBalance.LifetimeRedeemed, err = addPoints(survivorBalance.LifetimeRedeemed, mergedBalance.LifetimeRedeemed); err != nil {
+			return nil, err
+		}
+		if mergedBalance.LastActivity > survivorBalance.LastActivity {
+			survivorBalance.LastActivity = mergedBalance.LastActivity
+		}
+		movedCurrencies[currency] = mergedBalance.Balance
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	merge := AccountMerge{
+		TransactionID:    ctx.GetStub().GetTxID(),
+		SurvivorID:       survivorID,
+		MergedID:         mergedID,
+		Balance:          merged.Balance,
+		LifetimeEarned:   merged.LifetimeEarned,
+		LifetimeRedeemed: merged.LifetimeRedeemed,
+		Timestamp:        now.Format(time.RFC3339),
+		Currencies:       movedCurrencies,
+	}
+
+	// 4. Chuyển voucher
+	if merge.VouchersMoved, err = moveVouchers(ctx, mergedID, survivorID); err != nil {
+		return nil, err
+	}
+
+	// 5. Đóng tài khoản bị gộp
+	merged.Balance = 0
+	merged.LifetimeEarned = 0
+	merged.LifetimeRedeemed = 0
+	merged.Currencies = nil
+	merged.DeltaMode = false
+	merged.Status = "CLOSED"
+	merged.MergedInto = survivorID
+	merged.LastUpdated = merge.Timestamp
+	survivor.LastUpdated = merge.Timestamp
+	if err := putAccountCarrying(ctx, survivor, merge.LifetimeEarned, merge.LifetimeRedeemed); err != nil {
+		return nil, err
+	}
+	if err := putAccount(ctx, merged); err != nil {
+		return nil, err
+	}
+	mergedIndexKey, err := ledgerKey(ctx, mergedAccountObjectType, survivorID, mergedID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(mergedIndexKey, []byte{0x00}); err != nil {
+		return nil, fmt.Errorf("failed to put merged account index: %v", err)
+	}
+
+	// 6. Sự kiện
+	mergeJSON, err := json.Marshal(merge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal account merge: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent("MergeAccountsEvent", mergeJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for account merge: %v", err)
+	}
+	return &merge, nil
+}
+
+// moveVouchers chuyển tất cả voucher (kể cả đã sử dụng, để giữ lịch sử) từ `fromID` sang `toID`
+func moveVouchers(ctx contractapi.TransactionContextInterface, fromID, toID string) (int, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(voucherOwnerObjectType, []string{fromID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query vouchers by owner: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	moved := 0
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return moved, fmt.Errorf("failed to iterate vouchers: %v", err)
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return moved, fmt.Errorf("failed to split voucher owner key: %v", err)
+		}
+		voucher, err := getVoucher(ctx, keyParts[1])
+		if err != nil {
+			return moved, err
+		}
+		if err := deleteVoucherOwnerIndex(ctx, fromID, voucher.VoucherID); err != nil {
+			return moved, err
+		}
+		voucher.OwnerID = toID
+		if err := putVoucher(ctx, voucher); err != nil {
+			return moved, err
+		}
+		if err := putVoucherOwnerIndex(ctx, toID, voucher.VoucherID); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// resolveAccountID đi theo con trỏ MergedInto và trả về ID của tài khoản đang hoạt động
+func resolveAccountID(ctx contractapi.TransactionContextInterface, customerID string) (string, error) {
+	for hops := 0; hops < maxMergeHops; hops++ {
+		account, err := getAccount(ctx, customerID)
+		if err != nil {
+			return "", err
+		}
+		if account.MergedInto == "" {
+			return customerID, nil
+		}
+		customerID = account.MergedInto
+	}
+	return "", fmt.Errorf("account merge chain is longer than %d hops at '%s'", maxMergeHops, customerID)
+}
+
+// mergedAccountIDs trả về `customerID` và ID của mọi tài khoản đã được gộp vào nó (trực tiếp hoặc qua nhiều lần gộp)
+func mergedAccountIDs(ctx contractapi.TransactionContextInterface, customerID string) ([]string, error) {
+	ids := []string{customerID}
+	level := []string{customerID}
+	for hops := 0; len(level) > 0; hops++ {
+		if hops == maxMergeHops {
+			return nil, fmt.Errorf("account merge chain is longer than %d hops at '%s'", maxMergeHops, customerID)
+		}
+		var next []string
+		for _, survivorID := range level {
+			resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(mergedAccountObjectType, []string{survivorID})
+			if err != nil {
+				return nil, fmt.Errorf("failed to query merged accounts: %v", err)
+			}
+			for resultsIterator.HasNext() {
+				queryResult, err := resultsIterator.Next()
+				if err != nil {
+					resultsIterator.Close()
+					return nil, fmt.Errorf("failed to iterate merged accounts: %v", err)
+				}
+				_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+				if err != nil {
+					resultsIterator.Close()
+					return nil, fmt.Errorf("failed to split merged account key: %v", err)
+				}
+				next = append(next, keyParts[1])
+			}
+			resultsIterator.Close()
+		}
+		ids = append(ids, next...)
+		level = next
+	}
+	return ids, nil
+}
+
+// getResolvedAccountHistory trả về lịch sử của tài khoản như getAccountHistory. Nếu tài khoản đã được gộp,
+// lịch sử của nó dừng ở giao dịch gộp và được nối tiếp bằng lịch sử của tài khoản giữ lại từ giao dịch đó,
+// nên lịch sử theo ID cũ cho biết điểm của khách hàng nằm ở đâu tại mọi thời điểm.
+func getResolvedAccountHistory(ctx contractapi.TransactionContextInterface, customerID string) ([]accountHistoryRecord, error) {
+	var resolved []accountHistoryRecord
+	var mergedAt time.Time
+	for hops := 0; hops < maxMergeHops; hops++ {
+		records, err := getAccountHistory(ctx, customerID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Giao dịch gộp là bản ghi cũ nhất có MergedInto
+		var merge *accountHistoryRecord
+		var survivorID string
+		for i := len(records) - 1; i >= 0; i-- {
+			if records[i].IsDelete {
+				continue
+			}
+			account, err := decodeAccount(records[i].Value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode history record: %v", err)
+			}
+			if account.MergedInto != "" {
+				merge, survivorID = &records[i], account.MergedInto
+				break
+			}
+		}
+
+		// Bản ghi của tài khoản giữ lại bắt đầu từ giao dịch gộp trước đó (cùng thời điểm block thì đứng trước)
+		var kept []accountHistoryRecord
+		for _, record := range records {
+			if hops > 0 && record.Timestamp.Before(mergedAt) {
+				continue
+			}
+			if merge != nil && record.Timestamp.After(merge.Timestamp) {
+				continue
+			}
+			kept = append(kept, record)
+		}
+		resolved = append(kept, resolved...)
+		if merge == nil {
+			sort.SliceStable(resolved, func(i, j int) bool {
+				return resolved[i].Timestamp.After(resolved[j].Timestamp)
+			})
+			return resolved, nil
+		}
+		customerID, mergedAt = survivorID, merge.Timestamp
+	}
+	return nil, fmt.Errorf("account merge chain is longer than %d hops at '%s'", maxMergeHops, customerID)
+}
+
+// requireOpenAccount trả về lỗi ACCOUNT_CLOSED nếu tài khoản đã bị đóng (ví dụ đã được gộp vào tài khoản khác)
+func requireOpenAccount(account *LoyaltyAccount) error {
+	if account.Status != "CLOSED" {
+		return nil
+	}
+	details := map[string]interface{}{"customerID": account.CustomerID}
+	if account.MergedInto != "" {
+		details["mergedInto"] = account.MergedInto
+	}
+	return newChaincodeError(ErrCodeAccountClosed, details, "loyalty account '%s' is closed", account.CustomerID)
+}
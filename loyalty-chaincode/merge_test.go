@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeAccounts(t *testing.T) {
+	// ALICE có 500 điểm, BOB có 300 điểm; tài khoản `merged` (mặc định BOB) được gộp vào ALICE
+	tests := []struct {
+		name         string
+		merged       string
+		maxBalance   int64  // > 0: số dư tối đa của tài khoản
+		deltaCredit  int64  // > 0: BOB ở chế độ delta và có một delta chưa gộp
+		voucher      bool   // BOB quy đổi một voucher 100 điểm trước khi gộp
+		closed       string // tài khoản bị đóng trước khi gộp
+		wantCode     string
+		wantBalance  int64 // số dư của ALICE sau khi gộp
+		wantVouchers int
+	}{
+		{name: "balances moved", wantBalance: 800},
+		{name: "vouchers moved", voucher: true, wantBalance: 700, wantVouchers: 1},
+		{name: "pending deltas folded", deltaCredit: 50, wantBalance: 850},
+		{name: "same account", merged: "ALICE", wantCode: ErrCodeInvalidArgument, wantBalance: 500},
+		{name: "unknown merged account", merged: "DAVE", wantCode: ErrCodeAccountNotFound, wantBalance: 500},
+		{name: "merged account closed", closed: "BOB", wantCode: ErrCodeAccountClosed, wantBalance: 500},
+		{name: "survivor closed", closed: "ALICE", wantCode: ErrCodeAccountClosed, wantBalance: 500},
+		{name: "survivor above the maximum balance", maxBalance: 700, wantCode: ErrCodeAmountLimitExceeded, wantBalance: 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			contract := &AccountContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 500, "BOB": 300})
+			mergedID := "BOB"
+			if tt.merged != "" {
+				mergedID = tt.merged
+			}
+			if tt.maxBalance > 0 {
+				ctx.mustTx(testTime, func() error {
+					_, err := (&AdminContract{}).SetLedgerConfig(ctx, tt.maxBalance, tt.maxBalance)
+					return err
+				})
+			}
+			if tt.deltaCredit > 0 {
+				ctx.mustTx(testTime, func() error {
+					_, err := (&AdminContract{}).SetDeltaMode(ctx, "BOB", true)
+					return err
+				})
+				ctx.mustTx(testTime, func() error {
+					_, err := contract.IssuePoints(ctx, "BOB", tt.deltaCredit, "sale", "")
+					return err
+				})
+			}
+			var voucher *Voucher
+			if tt.voucher {
+				voucher = issueTestVoucher(ctx, "BOB")
+			}
+			if tt.closed != "" {
+				setAccountStatus(ctx, tt.closed, "CLOSED")
+			}
+
+			var merge *AccountMerge
+			err := ctx.tx(testTime, func() error {
+				var err error
+				merge, err = contract.MergeAccounts(ctx, "ALICE", mergedID)
+				return err
+			})
+			checkErrorCode(t, err, tt.wantCode)
+
+			var survivor LoyaltyAccount
+			if err := json.Unmarshal(ctx.ledgerState(accountObjectType, "ALICE"), &survivor); err != nil {
+				t.Fatal(err)
+			}
+			if survivor.Balance != tt.wantBalance {
+				t.Errorf("survivor balance = %d, want %d", survivor.Balance, tt.wantBalance)
+			}
+			if err != nil {
+				return
+			}
+
+			if merge.VouchersMoved != tt.wantVouchers {
+				t.Errorf("vouchers moved = %d, want %d", merge.VouchersMoved, tt.wantVouchers)
+			}
+			var merged LoyaltyAccount
+			if err := json.Unmarshal(ctx.ledgerState(accountObjectType, "BOB"), &merged); err != nil {
+				t.Fatal(err)
+			}
+			if merged.Status != "CLOSED" || merged.MergedInto != "ALICE" || merged.Balance != 0 || merged.DeltaMode {
+				t.Errorf("merged account = status %s, mergedInto %q, balance %d, delta mode %v; want CLOSED, ALICE, 0, false",
+					merged.Status, merged.MergedInto, merged.Balance, merged.DeltaMode)
+			}
+			if ctx.balance("BOB") != tt.wantBalance {
+				t.Errorf("balance queried by the merged ID = %d, want the survivor's %d", ctx.balance("BOB"), tt.wantBalance)
+			}
+			if voucher != nil {
+				var stored *Voucher
+				ctx.mustTx(testTime, func() error {
+					var err error
+					stored, err = (&RewardContract{}).GetVoucher(ctx, voucher.VoucherID)
+					return err
+				})
+				if stored.OwnerID != "ALICE" {
+					t.Errorf("voucher owner = %s, want ALICE", stored.OwnerID)
+				}
+			}
+		})
+	}
+}
+
+func TestQueriesResolveMergedIDs(t *testing.T) {
+	// BOB (300 điểm, 100 điểm chờ) được gộp vào ALICE (500) vào ngày 2, rồi ALICE được gộp vào CAROL (0) vào ngày 4
+	ctx := newTestContext(t)
+	contract := &AccountContract{}
+	ctx.setupAccounts(testTime, map[string]int64{"ALICE": 500, "BOB": 300, "CAROL": 0})
+	ctx.mustTx(testTime, func() error {
+		_, err := contract.AwardPendingPoints(ctx, "BOB", "B1", 100, testTime.AddDate(0, 0, 10).Format(time.RFC3339), "purchase")
+		return err
+	})
+	for _, merge := range []struct {
+		day                  int
+		survivorID, mergedID string
+	}{{2, "ALICE", "BOB"}, {4, "CAROL", "ALICE"}} {
+		ctx.mustTx(testTime.AddDate(0, 0, merge.day), func() error {
+			_, err := contract.MergeAccounts(ctx, merge.survivorID, merge.mergedID)
+			return err
+		})
+	}
+
+	t.Run("points balance", func(t *testing.T) {
+		for _, customerID := range []string{"BOB", "ALICE", "CAROL"} {
+			var balance *PointsBalance
+			ctx.mustTx(testTime.AddDate(0, 0, 5), func() error {
+				var err error
+				balance, err = contract.GetPointsBalance(ctx, customerID)
+				return err
+			})
+			if balance.CustomerID != "CAROL" || balance.Available != 800 || balance.Pending != 100 || len(balance.PendingAwards) != 1 {
+				t.Errorf("points balance of %s = %s available %d, pending %d in %d awards; want CAROL, 800, 100 in 1 award",
+					customerID, balance.CustomerID, balance.Available, balance.Pending, len(balance.PendingAwards))
+			}
+		}
+	})
+
+	t.Run("balance at", func(t *testing.T) {
+		tests := []struct {
+			customerID  string
+			day         int
+			wantAccount string
+			wantBalance int64
+		}{
+			{customerID: "BOB", day: 1, wantAccount: "BOB", wantBalance: 300},
+			{customerID: "BOB", day: 2, wantAccount: "ALICE", wantBalance: 800},
+			{customerID: "BOB", day: 5, wantAccount: "CAROL", wantBalance: 800},
+			{customerID: "ALICE", day: 3, wantAccount: "ALICE", wantBalance: 800},
+			{customerID: "CAROL", day: 3, wantAccount: "CAROL", wantBalance: 0},
+		}
+		for _, tt := range tests {
+			var balance *BalanceAt
+			ctx.mustTx(testTime.AddDate(0, 0, 6), func() error {
+				var err error
+				balance, err = contract.GetBalanceAt(ctx, tt.customerID, testTime.AddDate(0, 0, tt.day).Format(time.RFC3339))
+				return err
+			})
+			if balance.CustomerID != tt.wantAccount || balance.Balance != tt.wantBalance {
+				t.Errorf("balance of %s on day %d = %s %d, want %s %d",
+					tt.customerID, tt.day, balance.CustomerID, balance.Balance, tt.wantAccount, tt.wantBalance)
+			}
+		}
+	})
+
+	t.Run("history", func(t *testing.T) {
+		var history []*HistoryQueryResult
+		ctx.mustTx(testTime.AddDate(0, 0, 6), func() error {
+			var err error
+			history, err = contract.QueryLoyaltyHistory(ctx, "BOB")
+			return err
+		})
+		var got []string
+		for _, item := range history {
+			got = append(got, fmt.Sprintf("%s:%d", item.Record.CustomerID, item.Record.Balance))
+		}
+		// Mỗi giao dịch gộp ghi cả tài khoản giữ lại (đứng trước) và tài khoản bị đóng; tài khoản được tạo với số dư 0
+		want := []string{"CAROL:800", "ALICE:0", "ALICE:800", "BOB:0", "BOB:300", "BOB:0"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("history of BOB = %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// txStub bọc shimtest.MockStub với ngữ nghĩa giao dịch của Fabric mà chaincode dựa vào:
+// các lần ghi chỉ được áp dụng khi giao dịch được commit (GetState không thấy chúng), một giao dịch
+// chỉ giữ sự kiện cuối cùng, range query có phân trang và lịch sử key được ghi lại cho GetHistoryForKey.
+type txStub struct {
+	*shimtest.MockStub
+	txCount int
+	writes  map[string][]byte // nil = xóa key
+	event   *peer.ChaincodeEvent
+	history map[string][]*queryresult.KeyModification
+}
+
+func newTxStub() *txStub {
+	stub := &txStub{
+		MockStub: shimtest.NewMockStub("loyalty", nil),
+		history:  map[string][]*queryresult.KeyModification{},
+	}
+	stub.ChannelID = "loyaltychannel"
+	return stub
+}
+
+// PutState ghi tạm cho đến khi giao dịch được commit; giá trị rỗng là xóa key như trên peer
+func (s *txStub) PutState(key string, value []byte) error {
+	if s.TxID == "" {
+		return errors.New("PutState called outside a transaction")
+	}
+	if len(value) == 0 {
+		return s.DelState(key)
+	}
+	s.writes[key] = value
+	return nil
+}
+
+// DelState ghi tạm việc xóa key cho đến khi giao dịch được commit
+func (s *txStub) DelState(key string) error {
+	if s.TxID == "" {
+		return errors.New("DelState called outside a transaction")
+	}
+	s.writes[key] = nil
+	return nil
+}
+
+// SetEvent thay thế sự kiện trước đó của giao dịch
+func (s *txStub) SetEvent(name string, payload []byte) error {
+	s.event = &peer.ChaincodeEvent{EventName: name, Payload: payload}
+	return nil
+}
+
+func (s *txStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	return &kvIterator{kvs: s.scan(startKey, endKey, false)}, nil
+}
+
+func (s *txStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if bookmark != "" {
+		startKey = bookmark
+	}
+	return s.page(s.scan(startKey, endKey, false), pageSize)
+}
+
+func (s *txStub) GetStateByPartialCompositeKey(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, err := s.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	return &kvIterator{kvs: s.scan(prefix, prefix+string(utf8.MaxRune), true)}, nil
+}
+
+func (s *txStub) GetStateByPartialCompositeKeyWithPagination(objectType string, attributes []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	prefix, err := s.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, nil, err
+	}
+	startKey := prefix
+	if bookmark != "" {
+		startKey = bookmark
+	}
+	return s.page(s.scan(startKey, prefix+string(utf8.MaxRune), true), pageSize)
+}
+
+// GetHistoryForKey trả về các lần ghi đã commit của key, mới nhất trước
+func (s *txStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	modifications := s.history[key]
+	newestFirst := make([]*queryresult.KeyModification, len(modifications))
+	for i, modification := range modifications {
+		newestFirst[len(modifications)-1-i] = modification
+	}
+	return &historyIterator{modifications: newestFirst}, nil
+}
+
+// scan trả về các key đã commit trong [startKey, endKey) theo thứ tự; endKey rỗng là không giới hạn.
+// Range query trên key đơn giản không bao giờ trả về composite key.
+func (s *txStub) scan(startKey, endKey string, composite bool) []*queryresult.KV {
+	var kvs []*queryresult.KV
+	for elem := s.Keys.Front(); elem != nil; elem = elem.Next() {
+		key := elem.Value.(string)
+		if strings.HasPrefix(key, "\x00") != composite || key < startKey || (endKey != "" && key >= endKey) {
+			continue
+		}
+		kvs = append(kvs, &queryresult.KV{Key: key, Value: s.State[key]})
+	}
+	return kvs
+}
+
+// page cắt kết quả theo pageSize; bookmark là key đầu tiên của trang tiếp theo
+func (s *txStub) page(kvs []*queryresult.KV, pageSize int32) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	metadata := &peer.QueryResponseMetadata{}
+	if pageSize > 0 && len(kvs) > int(pageSize) {
+		metadata.Bookmark = kvs[pageSize].Key
+		kvs = kvs[:pageSize]
+	}
+	metadata.FetchedRecordsCount = int32(len(kvs))
+	return &kvIterator{kvs: kvs}, metadata, nil
+}
+
+type kvIterator struct {
+	kvs []*queryresult.KV
+}
+
+func (it *kvIterator) HasNext() bool { return len(it.kvs) > 0 }
+func (it *kvIterator) Close() error  { return nil }
+func (it *kvIterator) Next() (*queryresult.KV, error) {
+	if len(it.kvs) == 0 {
+		return nil, errors.New("iterator exhausted")
+	}
+	kv := it.kvs[0]
+	it.kvs = it.kvs[1:]
+	return kv, nil
+}
+
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+}
+
+func (it *historyIterator) HasNext() bool { return len(it.modifications) > 0 }
+func (it *historyIterator) Close() error  { return nil }
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if len(it.modifications) == 0 {
+		return nil, errors.New("iterator exhausted")
+	}
+	modification := it.modifications[0]
+	it.modifications = it.modifications[1:]
+	return modification, nil
+}
+
+// testIdentity là danh tính của người gọi: MSP và các thuộc tính của chứng chỉ (role, customerID, ...)
+type testIdentity struct {
+	mspID string
+	attrs map[string]string
+}
+
+func (i *testIdentity) GetID() (string, error) {
+	return fmt.Sprintf("x509::CN=%s::%s", i.attrs["customerID"], i.mspID), nil
+}
+func (i *testIdentity) GetMSPID() (string, error) { return i.mspID, nil }
+func (i *testIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	value, found := i.attrs[attrName]
+	return value, found, nil
+}
+func (i *testIdentity) AssertAttributeValue(attrName, attrValue string) error {
+	if i.attrs[attrName] != attrValue {
+		return fmt.Errorf("attribute %s does not have value %s", attrName, attrValue)
+	}
+	return nil
+}
+func (i *testIdentity) GetX509Certificate() (*x509.Certificate, error) { return nil, nil }
+
+var _ cid.ClientIdentity = (*testIdentity)(nil)
+
+// bankAdmin, bankReviewer và customer tạo danh tính cho các vai trò thường dùng trong test
+func bankAdmin() *testIdentity {
+	return &testIdentity{mspID: bankOrgMSP, attrs: map[string]string{"role": "admin"}}
+}
+
+func bankReviewer() *testIdentity {
+	return &testIdentity{mspID: bankOrgMSP, attrs: map[string]string{"role": "reviewer"}}
+}
+
+func customer(customerID string) *testIdentity {
+	return &testIdentity{mspID: "CustomerOrgMSP", attrs: map[string]string{"customerID": customerID}}
+}
+
+// testContext là TransactionContext của test; mặc định người gọi là quản trị viên của BankOrgMSP
+type testContext struct {
+	t        *testing.T
+	stub     *txStub
+	identity *testIdentity
+}
+
+func newTestContext(t *testing.T) *testContext {
+	return &testContext{t: t, stub: newTxStub(), identity: bankAdmin()}
+}
+
+func (c *testContext) GetStub() shim.ChaincodeStubInterface  { return c.stub }
+func (c *testContext) GetClientIdentity() cid.ClientIdentity { return c.identity }
+
+var _ contractapi.TransactionContextInterface = (*testContext)(nil)
+
+// begin bắt đầu một giao dịch với thời điểm block `at`
+func (c *testContext) begin(at time.Time) {
+	c.stub.txCount++
+	c.stub.MockTransactionStart(fmt.Sprintf("tx%04d", c.stub.txCount))
+	c.stub.TxTimestamp = timestamppb.New(at)
+	c.stub.writes = map[string][]byte{}
+	c.stub.event = nil
+}
+
+// commit áp dụng các lần ghi của giao dịch và trả về sự kiện của nó (nil nếu không có)
+func (c *testContext) commit() *peer.ChaincodeEvent {
+	keys := make([]string, 0, len(c.stub.writes))
+	for key := range c.stub.writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := c.stub.writes[key]
+		if value == nil {
+			c.stub.MockStub.DelState(key)
+		} else {
+			c.stub.MockStub.PutState(key, value)
+		}
+		c.stub.history[key] = append(c.stub.history[key], &queryresult.KeyModification{
+			TxId:      c.stub.TxID,
+			Value:     value,
+			Timestamp: c.stub.TxTimestamp,
+			IsDelete:  value == nil,
+		})
+	}
+	event := c.stub.event
+	c.stub.MockTransactionEnd(c.stub.TxID)
+	c.stub.writes = nil
+	return event
+}
+
+// rollback bỏ các lần ghi của một giao dịch thất bại, như peer không commit nó
+func (c *testContext) rollback() {
+	c.stub.MockTransactionEnd(c.stub.TxID)
+	c.stub.writes = nil
+}
+
+// tx chạy fn trong một giao dịch tại thời điểm `at`, commit nếu thành công và rollback nếu lỗi
+func (c *testContext) tx(at time.Time, fn func() error) error {
+	c.begin(at)
+	if err := fn(); err != nil {
+		c.rollback()
+		return err
+	}
+	c.commit()
+	return nil
+}
+
+// mustTx giống tx nhưng dừng test khi giao dịch lỗi
+func (c *testContext) mustTx(at time.Time, fn func() error) {
+	c.t.Helper()
+	if err := c.tx(at, fn); err != nil {
+		c.t.Fatalf("transaction failed: %v", err)
+	}
+}
+
+// as đổi người gọi cho các giao dịch tiếp theo
+func (c *testContext) as(identity *testIdentity) *testContext {
+	c.identity = identity
+	return c
+}
+
+// setupAccounts tạo các tài khoản với số dư ban đầu trong một giao dịch cho mỗi tài khoản
+func (c *testContext) setupAccounts(at time.Time, balances map[string]int64) {
+	c.t.Helper()
+	ids := make([]string, 0, len(balances))
+	for customerID := range balances {
+		ids = append(ids, customerID)
+	}
+	sort.Strings(ids)
+	contract := &AccountContract{}
+	for _, customerID := range ids {
+		customerID := customerID
+		c.mustTx(at, func() error {
+			_, err := contract.CreateLoyaltyAccount(c, customerID)
+			return err
+		})
+		if balances[customerID] > 0 {
+			c.mustTx(at, func() error {
+				_, err := contract.IssuePoints(c, customerID, balances[customerID], "setup", "")
+				return err
+			})
+		}
+	}
+}
+
+// balance đọc số dư hiện tại của tài khoản (gồm cả các delta chưa gộp)
+func (c *testContext) balance(customerID string) int64 {
+	c.t.Helper()
+	var account *LoyaltyAccount
+	c.mustTx(time.Now(), func() error {
+		var err error
+		account, err = (&AccountContract{}).QueryLoyaltyAccount(c, customerID)
+		return err
+	})
+	return account.Balance
+}
+
+// ledgerState đọc trực tiếp giá trị đã commit của một đối tượng trên sổ cái
+func (c *testContext) ledgerState(objectType string, attributes ...string) []byte {
+	key, err := c.stub.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return c.stub.State[key]
+}
+
+// testTime là thời điểm bắt đầu chung của các test
+var testTime = time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+// checkErrorCode kiểm tra err là ChaincodeError với mã `wantCode`, hoặc nil nếu `wantCode` rỗng
+func checkErrorCode(t *testing.T, err error, wantCode string) {
+	t.Helper()
+	if wantCode == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	var chaincodeErr *ChaincodeError
+	if !errors.As(err, &chaincodeErr) || chaincodeErr.Code != wantCode {
+		t.Fatalf("error = %v, want code %s", err, wantCode)
+	}
+}
@@ -45,29 +45,74 @@ else
 fi
 
 # Step 2: Package chaincode
+# Usage: ./package_chaincode.sh [golang|ccaas]
+#   golang (default) - peer builds and launches the chaincode from source
+#   ccaas            - chaincode runs as an external service (CHAINCODE_SERVER_ADDRESS + CHAINCODE_ID),
+#                      the package only holds connection.json
 print_status "Packaging chaincode..."
 CHAINCODE_NAME="loyalty"
 CHAINCODE_VERSION="1.0"
-PACKAGE_FILE="${CHAINCODE_NAME}_${CHAINCODE_VERSION}.tar.gz"
+PACKAGE_TYPE="${1:-golang}"
+
+if [ "${PACKAGE_TYPE}" = "ccaas" ]; then
+    PACKAGE_FILE="${CHAINCODE_NAME}_${CHAINCODE_VERSION}_ccaas.tar.gz"
+    # Address the peer dials to reach the chaincode server
+    CCAAS_ADDRESS="${CCAAS_ADDRESS:-loyalty-chaincode:9999}"
+    CCAAS_TLS_REQUIRED="${CCAAS_TLS_REQUIRED:-false}"
+    # PEM file of the CA that signed the chaincode server certificate (TLS only)
+    CCAAS_ROOT_CERT="${CCAAS_ROOT_CERT:-}"
+elif [ "${PACKAGE_TYPE}" = "golang" ]; then
+    PACKAGE_FILE="${CHAINCODE_NAME}_${CHAINCODE_VERSION}.tar.gz"
+else
+    print_error "Unknown package type: ${PACKAGE_TYPE} (expected golang or ccaas)"
+    exit 1
+fi
 
 # Create package directory structure
-mkdir -p /tmp/chaincode-package/src
-cp -r . /tmp/chaincode-package/src/
-cd /tmp/chaincode-package
+# code.tar.gz must contain src/ (golang) or connection.json (ccaas), and META-INF/ (CouchDB indexes) at its root
+mkdir -p /tmp/chaincode-package/code
+if [ "${PACKAGE_TYPE}" = "golang" ]; then
+    mkdir -p /tmp/chaincode-package/code/src
+    cp -r . /tmp/chaincode-package/code/src/
+    rm -rf /tmp/chaincode-package/code/src/META-INF
+fi
+if [ -d "META-INF" ]; then
+    cp -r META-INF /tmp/chaincode-package/code/
+    print_status "Including CouchDB indexes: $(ls META-INF/statedb/couchdb/indexes | tr '\n' ' ')"
+else
+    print_warning "META-INF not found, CouchDB indexes will not be installed"
+fi
 
-# Create connection.json for external chaincode (if needed)
-cat > connection.json << EOF
+if [ "${PACKAGE_TYPE}" = "ccaas" ]; then
+    ROOT_CERT_JSON=""
+    if [ "${CCAAS_TLS_REQUIRED}" = "true" ]; then
+        if [ ! -f "${CCAAS_ROOT_CERT}" ]; then
+            print_error "CCAAS_ROOT_CERT must point to a PEM file when CCAAS_TLS_REQUIRED=true"
+            exit 1
+        fi
+        ROOT_CERT_JSON=",
+  \"root_cert\": \"$(awk 'NF {sub(/\r/, ""); printf "%s\\n", $0}' "${CCAAS_ROOT_CERT}")\""
+    fi
+
+    # Create connection.json for external chaincode
+    cat > /tmp/chaincode-package/code/connection.json << EOF
 {
-  "address": "chaincode:7052",
+  "address": "${CCAAS_ADDRESS}",
   "dial_timeout": "10s",
-  "tls_required": false
+  "tls_required": ${CCAAS_TLS_REQUIRED}${ROOT_CERT_JSON}
 }
 EOF
+    print_status "connection.json points to ${CCAAS_ADDRESS} (TLS required: ${CCAAS_TLS_REQUIRED})"
+fi
+
+cd /tmp/chaincode-package
+tar -czf code.tar.gz -C code .
+rm -rf code
 
 # Create metadata.json
 cat > metadata.json << EOF
 {
-  "type": "golang",
+  "type": "${PACKAGE_TYPE}",
   "label": "${CHAINCODE_NAME}_${CHAINCODE_VERSION}"
 }
 EOF
@@ -80,6 +125,10 @@ cd /home/ubuntu/loyalty-project/loyalty-chaincode/
 rm -rf /tmp/chaincode-package
 
 print_status "Chaincode packaged as ${PACKAGE_FILE}"
+if [ "${PACKAGE_TYPE}" = "ccaas" ]; then
+    print_status "After installing the package, start the chaincode server with:"
+    print_status "  CHAINCODE_SERVER_ADDRESS=0.0.0.0:${CCAAS_ADDRESS##*:} CHAINCODE_ID=<package ID> go run ."
+fi
 
 # Step 3: Create test script
 print_status "Creating test script..."
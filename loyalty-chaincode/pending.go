@@ -0,0 +1,515 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	PendingAwardStatusPending   = "PENDING"
+	PendingAwardStatusReleased  = "RELEASED"
+	PendingAwardStatusCancelled = "CANCELLED"
+	PendingAwardStatusFailed    = "FAILED" // tài khoản nhận không ghi có được khi giải phóng, chờ RetryPending
+
+	// maxPendingDays giới hạn thời hạn treo điểm (thời hạn đổi trả dài nhất của merchant)
+	maxPendingDays = 180
+	// defaultPendingReleaseLimit áp dụng khi ReleasePending được gọi với limit <= 0
+	defaultPendingReleaseLimit = 50
+	// defaultReturnWindowDays áp dụng cho merchant chưa được cấu hình thời hạn đổi trả
+	defaultReturnWindowDays = 30
+)
+
+// ReturnWindowRules định nghĩa thời hạn đổi trả (số ngày) mà RecordPurchase dùng để treo điểm mua hàng
+type ReturnWindowRules struct {
+	DefaultDays int            `json:"defaultDays"`         // 0 = defaultReturnWindowDays
+	Merchants   map[string]int `json:"merchants,omitempty"` // thời hạn riêng của từng merchant
+}
+
+// daysFor trả về thời hạn đổi trả của `merchantID`
+func (r ReturnWindowRules) daysFor(merchantID string) int {
+	if days, found := r.Merchants[merchantID]; found {
+		return days
+	}
+	if r.DefaultDays > 0 {
+		return r.DefaultDays
+	}
+	return defaultReturnWindowDays
+}
+
+// PendingAward định nghĩa một khoản điểm mua hàng đang chờ hết thời hạn đổi trả của merchant.
+// Điểm chưa được phát hành: chúng chỉ vào số dư khả dụng (và bộ đếm tổng cung) khi được giải phóng.
+type PendingAward struct {
+	AwardID       string `json:"awardID"` // mã giao dịch mua hàng của merchant
+	CustomerID    string `json:"customerID"`
+	Amount        int64  `json:"amount"`
+	Description   string `json:"description"`
+	CreatedAt     string `json:"createdAt"`
+	ReleaseAt     string `json:"releaseAt"`
+	Status        string `json:"status"`                  // PENDING, RELEASED, CANCELLED, FAILED
+	ReleasedTo    string `json:"releasedTo,omitempty"`    // tài khoản nhận điểm (khác CustomerID nếu tài khoản đã được gộp)
+	FailureReason string `json:"failureReason,omitempty"` // mã lỗi khi giải phóng thất bại (FAILED)
+	ClosedAt      string `json:"closedAt,omitempty"`
+	// Campaigns lưu điểm thưởng của từng chương trình trong khoản điểm, để CancelPending hoàn lại ngân sách
+	Campaigns     []CampaignContribution `json:"campaigns,omitempty"`
+	SchemaVersion int                    `json:"schemaVersion"`
+}
+
+// PointsBalance định nghĩa số dư khả dụng và số điểm đang chờ của một tài khoản
+type PointsBalance struct {
+	CustomerID    string          `json:"customerID"`
+	Available     int64           `json:"available"` // số dư có thể sử dụng, gồm cả delta chưa gộp
+	Pending       int64           `json:"pending"`   // tổng điểm đang chờ giải phóng
+	NextReleaseAt string          `json:"nextReleaseAt,omitempty"`
+	PendingAwards []*PendingAward `json:"pendingAwards"`
+}
+
+// =========================================================================================
+// UC-025: Điểm chờ hết thời hạn đổi trả
+// Điểm từ giao dịch mua hàng được treo cho đến khi thời hạn đổi trả của merchant kết thúc.
+//
+// Logic chính:
+// 1. AwardPendingPoints (chỉ BankOrgMSP): ghi nhận khoản điểm chờ `awardID` (mã giao dịch mua hàng, chưa được dùng)
+//    cho tài khoản đang hoạt động, với `releaseDate` (RFC3339) sau thời điểm giao dịch và trong vòng 180 ngày.
+//    Số dư tài khoản không đổi, nên việc treo điểm không ghi vào tài khoản (kể cả tài khoản nóng).
+// 2. ReleasePending (chỉ BankOrgMSP, job định kỳ của backend): giải phóng tối đa `limit` khoản điểm đã đến hạn
+//    theo thứ tự ngày giải phóng: cộng vào số dư (tính vào LifetimeEarned), cập nhật bộ đếm phát hành, chuyển sang RELEASED.
+//    Khoản điểm của tài khoản không ghi có được (đã đóng mà chưa gộp, vượt số dư tối đa...) chuyển sang FAILED
+//    cùng mã lỗi và rời chỉ mục giải phóng, nên không chặn các khoản phía sau; các khoản khác vẫn được giải phóng.
+//    RetryPending (chỉ BankOrgMSP) đưa khoản FAILED về PENDING để lần chạy sau giải phóng lại.
+// 3. CancelPending (chỉ BankOrgMSP): hủy khoản điểm chưa giải phóng (PENDING hoặc FAILED) khi đơn hàng bị trả lại (CANCELLED).
+//    Điểm thưởng chương trình trong khoản điểm được hoàn vào ngân sách và giới hạn mỗi khách hàng của chương trình.
+// 4. GetPointsBalance trả về số dư khả dụng và số điểm đang chờ tách biệt. ID đã được gộp được phân giải sang
+//    tài khoản giữ lại, và số điểm chờ gồm cả các khoản vẫn nằm dưới ID của các tài khoản đã gộp vào nó.
+//    Điểm của RecordPurchase (UC-008) cũng được treo theo thời hạn đổi trả của merchant (SetReturnWindow).
+// 5. Mỗi thao tác phát ra sự kiện "AwardPendingEvent", "ReleasePendingEvent" (danh sách các khoản đã xử lý),
+//    "RetryPendingEvent" hoặc "CancelPendingEvent".
+// =========================================================================================
+func (s *AccountContract) AwardPendingPoints(ctx contractapi.TransactionContextInterface, customerID string, awardID string, amount int64, releaseDate string, description string) (*PendingAward, error) {
+	// 1. Kiểm tra đầu vào
+	if amount <= 0 {
+		return nil, errInvalidArgument("amount must be a positive integer, got: %d", amount)
+	}
+	releaseAt, err := ParseTimestamp(releaseDate)
+	if err != nil {
+		return nil, errInvalidArgument("release date must be an RFC3339 timestamp, got: %q", releaseDate)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !releaseAt.After(now) || releaseAt.After(now.AddDate(0, 0, maxPendingDays)) {
+		return nil, errInvalidArgument("release date must be within %d days after the transaction time, got: %s", maxPendingDays, releaseDate)
+	}
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.checkTransactionAmount(amount); err != nil {
+		return nil, err
+	}
+	account, err := getAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(account); err != nil {
+		return nil, err
+	}
+
+	awardKey, err := ledgerKey(ctx, pendingAwardObjectType, customerID, awardID)
+	if err != nil {
+		return nil, err
+	}
+	existingAwardJSON, err := ctx.GetStub().GetState(awardKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existingAwardJSON != nil {
+		return nil, newChaincodeError(ErrCodePendingAwardAlreadyExists, map[string]interface{}{"customerID": customerID, "awardID": awardID},
+			"pending award '%s' already exists for customer '%s'", awardID, customerID)
+	}
+
+	award := PendingAward{
+		AwardID:     awardID,
+		CustomerID:  customerID,
+		Amount:      amount,
+		Description: description,
+		CreatedAt:   now.Format(time.RFC3339),
+		ReleaseAt:   releaseAt.UTC().Format(time.RFC3339),
+		Status:      PendingAwardStatusPending,
+	}
+	if err := putPendingAward(ctx, &award); err != nil {
+		return nil, err
+	}
+	if err := putPendingReleaseIndex(ctx, &award); err != nil {
+		return nil, err
+	}
+
+	// 5. Sự kiện
+	if err := setPendingAwardEvent(ctx, "AwardPendingEvent", &award); err != nil {
+		return nil, err
+	}
+	return &award, nil
+}
+
+// SetReturnWindow đặt thời hạn đổi trả `days` của `merchantID`, hoặc thời hạn mặc định nếu `merchantID` rỗng
+// (chỉ quản trị viên, xem UC-025)
+func (s *AdminContract) SetReturnWindow(ctx contractapi.TransactionContextInterface, merchantID string, days int) (*LedgerConfig, error) {
+	if days <= 0 || days > maxPendingDays {
+		return nil, errInvalidArgument("return window must be between 1 and %d days, got: %d", maxPendingDays, days)
+	}
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if merchantID == "" {
+		config.ReturnWindows.DefaultDays = days
+	} else {
+		if config.ReturnWindows.Merchants == nil {
+			config.ReturnWindows.Merchants = map[string]int{}
+		}
+		config.ReturnWindows.Merchants[merchantID] = days
+	}
+	if err := putLedgerConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ReleasePending giải phóng tối đa `limit` khoản điểm chờ đã đến hạn, theo thứ tự ngày giải phóng (xem UC-025).
+// Được backend gọi định kỳ. Trả về các khoản đã xử lý: RELEASED, hoặc FAILED nếu tài khoản nhận không ghi có được.
+func (s *AccountContract) ReleasePending(ctx contractapi.TransactionContextInterface, limit int) ([]*PendingAward, error) {
+	if limit <= 0 {
+		limit = defaultPendingReleaseLimit
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Chỉ mục pendingRelease~<releaseAt>~<customerID>~<awardID> được sắp xếp theo ngày giải phóng (RFC3339 UTC)
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(pendingReleaseObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending release index: %v", err)
+	}
+	var matured [][2]string
+	for resultsIterator.HasNext() && len(matured) < limit {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			resultsIterator.Close()
+			return nil, fmt.Errorf("failed to iterate pending release index: %v", err)
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			resultsIterator.Close()
+			return nil, fmt.Errorf("failed to split pending release key: %v", err)
+		}
+		releaseAt, err := ParseTimestamp(keyParts[0])
+		if err != nil || now.Before(releaseAt) {
+			break
+		}
+		matured = append(matured, [2]string{keyParts[1], keyParts[2]})
+	}
+	resultsIterator.Close()
+
+	// 2. Gom điểm theo tài khoản nhận: trong một giao dịch GetState không thấy các lần ghi trước đó,
+	// nên mỗi tài khoản chỉ được ghi có một lần. Tài khoản có thể đã được gộp sau khi ghi nhận điểm chờ.
+	processed := []*PendingAward{}
+	var targetIDs []string
+	targetAmounts := map[string]int64{}
+	var total int64
+	for _, ids := range matured {
+		award, err := getPendingAward(ctx, ids[0], ids[1])
+		if err != nil {
+			return nil, err
+		}
+		targetID, err := resolveAccountID(ctx, award.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		if _, seen := targetAmounts[targetID]; !seen {
+			targetIDs = append(targetIDs, targetID)
+		}
+		if targetAmounts[targetID], err = addPoints(targetAmounts[targetID], award.Amount); err != nil {
+			return nil, err
+		}
+		award.ReleasedTo = targetID
+		processed = append(processed, award)
+	}
+
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	failures := map[string]*ChaincodeError{}
+	for _, targetID := range targetIDs {
+		failure, err := checkCredit(ctx, config, targetID, targetAmounts[targetID])
+		if err != nil {
+			return nil, err
+		}
+		if failure != nil {
+			failures[targetID] = failure
+			continue
+		}
+		if total, err = addPoints(total, targetAmounts[targetID]); err != nil {
+			return nil, err
+		}
+		account, err := getAccount(ctx, targetID)
+		if err != nil {
+			return nil, err
+		}
+		deferred, err := creditAccount(ctx, config, account, targetAmounts[targetID], true)
+		if err != nil {
+			return nil, err
+		}
+		account.LastUpdated = now.Format(time.RFC3339)
+		if !deferred {
+			if err := putAccount(ctx, account); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, award := range processed {
+		if failure, failed := failures[award.ReleasedTo]; failed {
+			award.Status = PendingAwardStatusFailed
+			award.FailureReason = failure.Code
+			award.ReleasedTo = ""
+		} else {
+			award.Status = PendingAwardStatusReleased
+			award.ClosedAt = now.Format(time.RFC3339)
+		}
+		if err := closePendingAward(ctx, award); err != nil {
+			return nil, err
+		}
+	}
+
+	// Điểm chờ chỉ được tính là phát hành khi được giải phóng
+	if total > 0 {
+		if err := updateSupplyCounters(ctx, total, 0, 0, 0); err != nil {
+			return nil, err
+		}
+	}
+	if len(processed) > 0 {
+		// Một giao dịch chỉ giữ một sự kiện: phát ra danh sách các khoản đã xử lý
+		processedJSON, err := json.Marshal(processed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal pending release event: %v", err)
+		}
+		if err := ctx.GetStub().SetEvent("ReleasePendingEvent", processedJSON); err != nil {
+			return nil, fmt.Errorf("failed to set event for pending release: %v", err)
+		}
+	}
+	return processed, nil
+}
+
+// RetryPending đưa một khoản điểm FAILED về PENDING với ngày giải phóng ban đầu, để lần chạy ReleasePending
+// tiếp theo giải phóng lại sau khi tài khoản nhận đã được xử lý (xem UC-025)
+func (s *AccountContract) RetryPending(ctx contractapi.TransactionContextInterface, customerID string, awardID string) (*PendingAward, error) {
+	award, err := getPendingAward(ctx, customerID, awardID)
+	if err != nil {
+		return nil, err
+	}
+	if award.Status != PendingAwardStatusFailed {
+		return nil, newChaincodeError(ErrCodePendingAwardClosed, map[string]interface{}{"customerID": customerID, "awardID": awardID, "status": award.Status},
+			"only a failed pending award can be retried, this one is %s", strings.ToLower(award.Status))
+	}
+
+	award.Status = PendingAwardStatusPending
+	award.FailureReason = ""
+	if err := putPendingAward(ctx, award); err != nil {
+		return nil, err
+	}
+	if err := putPendingReleaseIndex(ctx, award); err != nil {
+		return nil, err
+	}
+
+	// 5. Sự kiện
+	if err := setPendingAwardEvent(ctx, "RetryPendingEvent", award); err != nil {
+		return nil, err
+	}
+	return award, nil
+}
+
+// CancelPending hủy một khoản điểm chờ chưa giải phóng khi đơn hàng bị trả lại (xem UC-025)
+func (s *AccountContract) CancelPending(ctx contractapi.TransactionContextInterface, customerID string, awardID string) (*PendingAward, error) {
+	award, err := getPendingAward(ctx, customerID, awardID)
+	if err != nil {
+		return nil, err
+	}
+	if award.Status != PendingAwardStatusPending && award.Status != PendingAwardStatusFailed {
+		return nil, newChaincodeError(ErrCodePendingAwardClosed, map[string]interface{}{"customerID": customerID, "awardID": awardID, "status": award.Status},
+			"pending award is already %s", strings.ToLower(award.Status))
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	award.Status = PendingAwardStatusCancelled
+	award.ClosedAt = now.Format(time.RFC3339)
+	if err := closePendingAward(ctx, award); err != nil {
+		return nil, err
+	}
+	if err := refundCampaignContributions(ctx, award.CustomerID, award.Campaigns, now); err != nil {
+		return nil, err
+	}
+
+	// 5. Sự kiện
+	if err := setPendingAwardEvent(ctx, "CancelPendingEvent", award); err != nil {
+		return nil, err
+	}
+	return award, nil
+}
+
+// GetPendingAward truy vấn một khoản điểm chờ
+func (s *AccountContract) GetPendingAward(ctx contractapi.TransactionContextInterface, customerID string, awardID string) (*PendingAward, error) {
+	return getPendingAward(ctx, customerID, awardID)
+}
+
+// GetPointsBalance trả về số dư khả dụng và các khoản điểm đang chờ của tài khoản (xem UC-025)
+func (s *AccountContract) GetPointsBalance(ctx contractapi.TransactionContextInterface, customerID string) (*PointsBalance, error) {
+	accountID, err := resolveAccountID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	account, err := getAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if account.DeltaMode {
+		if _, err := foldDeltas(ctx, account, false); err != nil {
+			return nil, err
+		}
+	}
+	balance := PointsBalance{
+		CustomerID:    accountID,
+		Available:     account.Balance,
+		PendingAwards: []*PendingAward{},
+	}
+
+	// Khoản điểm chờ của tài khoản bị gộp vẫn nằm dưới ID cũ và được giải phóng vào tài khoản giữ lại
+	ownerIDs, err := mergedAccountIDs(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	for _, ownerID := range ownerIDs {
+		if err := addPendingAwards(ctx, &balance, ownerID); err != nil {
+			return nil, err
+		}
+	}
+	return &balance, nil
+}
+
+// addPendingAwards cộng các khoản điểm chưa giải phóng của `ownerID` vào `balance`
+func addPendingAwards(ctx contractapi.TransactionContextInterface, balance *PointsBalance, ownerID string) error {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(pendingAwardObjectType, []string{ownerID})
+	if err != nil {
+		return fmt.Errorf("failed to query pending awards: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("failed to iterate pending awards: %v", err)
+		}
+		var award PendingAward
+		if err := json.Unmarshal(queryResult.Value, &award); err != nil {
+			return fmt.Errorf("failed to unmarshal pending award: %v", err)
+		}
+		// Khoản FAILED vẫn chưa vào số dư, nên vẫn được tính là đang chờ
+		if award.Status != PendingAwardStatusPending && award.Status != PendingAwardStatusFailed {
+			continue
+		}
+		if balance.Pending, err = addPoints(balance.Pending, award.Amount); err != nil {
+			return err
+		}
+		// RFC3339 UTC có thể so sánh theo thứ tự chuỗi
+		if award.Status == PendingAwardStatusPending && (balance.NextReleaseAt == "" || award.ReleaseAt < balance.NextReleaseAt) {
+			balance.NextReleaseAt = award.ReleaseAt
+		}
+		balance.PendingAwards = append(balance.PendingAwards, &award)
+	}
+	return nil
+}
+
+// getPendingAward đọc một khoản điểm chờ từ World State
+func getPendingAward(ctx contractapi.TransactionContextInterface, customerID, awardID string) (*PendingAward, error) {
+	awardKey, err := ledgerKey(ctx, pendingAwardObjectType, customerID, awardID)
+	if err != nil {
+		return nil, err
+	}
+	awardJSON, err := ctx.GetStub().GetState(awardKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending award from world state: %v", err)
+	}
+	if awardJSON == nil {
+		return nil, newChaincodeError(ErrCodePendingAwardNotFound, map[string]interface{}{"customerID": customerID, "awardID": awardID},
+			"pending award '%s' does not exist for customer '%s'", awardID, customerID)
+	}
+
+	var award PendingAward
+	if err := json.Unmarshal(awardJSON, &award); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pending award data: %v", err)
+	}
+	return &award, nil
+}
+
+// putPendingAward ghi một khoản điểm chờ vào World State
+func putPendingAward(ctx contractapi.TransactionContextInterface, award *PendingAward) error {
+	award.SchemaVersion = pendingAwardSchemaVersion
+	awardKey, err := ledgerKey(ctx, pendingAwardObjectType, award.CustomerID, award.AwardID)
+	if err != nil {
+		return err
+	}
+	awardJSON, err := json.Marshal(award)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending award: %v", err)
+	}
+	if err := ctx.GetStub().PutState(awardKey, awardJSON); err != nil {
+		return fmt.Errorf("failed to put state for pending award: %v", err)
+	}
+	return nil
+}
+
+// putPendingReleaseIndex ghi chỉ mục pendingRelease~<releaseAt>~<customerID>~<awardID> của khoản điểm chờ.
+// Giá trị là số điểm đang chờ để có thể cộng dồn mà không cần đọc từng khoản.
+func putPendingReleaseIndex(ctx contractapi.TransactionContextInterface, award *PendingAward) error {
+	indexKey, err := ledgerKey(ctx, pendingReleaseObjectType, award.ReleaseAt, award.CustomerID, award.AwardID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte(strconv.FormatInt(award.Amount, 10))); err != nil {
+		return fmt.Errorf("failed to put pending release index: %v", err)
+	}
+	return nil
+}
+
+// closePendingAward ghi khoản điểm chờ đã giải phóng/hủy/thất bại và xóa chỉ mục giải phóng của nó
+func closePendingAward(ctx contractapi.TransactionContextInterface, award *PendingAward) error {
+	indexKey, err := ledgerKey(ctx, pendingReleaseObjectType, award.ReleaseAt, award.CustomerID, award.AwardID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(indexKey); err != nil {
+		return fmt.Errorf("failed to delete pending release index: %v", err)
+	}
+	return putPendingAward(ctx, award)
+}
+
+// setPendingAwardEvent phát ra sự kiện với dữ liệu khoản điểm chờ
+func setPendingAwardEvent(ctx contractapi.TransactionContextInterface, eventName string, award *PendingAward) error {
+	awardJSON, err := json.Marshal(award)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending award event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent(eventName, awardJSON); err != nil {
+		return fmt.Errorf("failed to set event for pending award: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordPurchaseHoldsPoints(t *testing.T) {
+	tests := []struct {
+		name          string
+		defaultWindow int // 0 = không cấu hình
+		merchantDays  int // 0 = không cấu hình cho SHOP
+		campaignBonus int64
+		wantPoints    int64
+		wantReleaseAt time.Time
+	}{
+		{
+			name:          "default return window",
+			wantPoints:    100,
+			wantReleaseAt: testTime.AddDate(0, 0, defaultReturnWindowDays),
+		},
+		{
+			name:          "configured default window",
+			defaultWindow: 14,
+			wantPoints:    100,
+			wantReleaseAt: testTime.AddDate(0, 0, 14),
+		},
+		{
+			name:          "merchant window overrides the default",
+			defaultWindow: 14,
+			merchantDays:  60,
+			wantPoints:    100,
+			wantReleaseAt: testTime.AddDate(0, 0, 60),
+		},
+		{
+			name:          "campaign bonus is held with the base points",
+			campaignBonus: 50,
+			wantPoints:    150,
+			wantReleaseAt: testTime.AddDate(0, 0, defaultReturnWindowDays),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			contract := &AccountContract{}
+			admin := &AdminContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 0})
+			if tt.defaultWindow > 0 {
+				ctx.mustTx(testTime, func() error {
+					_, err := admin.SetReturnWindow(ctx, "", tt.defaultWindow)
+					return err
+				})
+			}
+			if tt.merchantDays > 0 {
+				ctx.mustTx(testTime, func() error {
+					_, err := admin.SetReturnWindow(ctx, "SHOP", tt.merchantDays)
+					return err
+				})
+			}
+			if tt.campaignBonus > 0 {
+				ctx.mustTx(testTime, func() error {
+					_, err := (&RewardContract{}).CreateCampaign(ctx, "BONUS", "Bonus", testTime.Add(-time.Hour).Format(time.RFC3339),
+						testTime.AddDate(0, 1, 0).Format(time.RFC3339), nil, nil, 0, tt.campaignBonus, 0, 0)
+					return err
+				})
+			}
+
+			var award *PurchaseAward
+			ctx.mustTx(testTime, func() error {
+				var err error
+				award, err = contract.RecordPurchase(ctx, "ALICE", "SHOP", 100, "groceries")
+				return err
+			})
+			if award.TotalPoints != tt.wantPoints {
+				t.Fatalf("total points = %d, want %d", award.TotalPoints, tt.wantPoints)
+			}
+			if award.ReleaseAt != tt.wantReleaseAt.Format(time.RFC3339) {
+				t.Errorf("releaseAt = %s, want %s", award.ReleaseAt, tt.wantReleaseAt.Format(time.RFC3339))
+			}
+
+			// Điểm chưa vào số dư cho đến khi được giải phóng
+			var balance *PointsBalance
+			ctx.mustTx(testTime, func() error {
+				var err error
+				balance, err = contract.GetPointsBalance(ctx, "ALICE")
+				return err
+			})
+			if balance.Available != 0 || balance.Pending != tt.wantPoints {
+				t.Errorf("available/pending = %d/%d, want 0/%d", balance.Available, balance.Pending, tt.wantPoints)
+			}
+
+			ctx.mustTx(tt.wantReleaseAt.Add(-time.Second), func() error {
+				_, err := contract.ReleasePending(ctx, 0)
+				return err
+			})
+			if got := ctx.balance("ALICE"); got != 0 {
+				t.Errorf("balance before the return window closes = %d, want 0", got)
+			}
+			ctx.mustTx(tt.wantReleaseAt, func() error {
+				_, err := contract.ReleasePending(ctx, 0)
+				return err
+			})
+			if got := ctx.balance("ALICE"); got != tt.wantPoints {
+				t.Errorf("balance after release = %d, want %d", got, tt.wantPoints)
+			}
+		})
+	}
+}
+
+func TestSetReturnWindowRejectsInvalidDays(t *testing.T) {
+	for _, days := range []int{0, -1, maxPendingDays + 1} {
+		ctx := newTestContext(t)
+		ctx.begin(testTime)
+		if _, err := (&AdminContract{}).SetReturnWindow(ctx, "SHOP", days); err == nil {
+			t.Errorf("SetReturnWindow accepted %d days", days)
+		}
+		ctx.rollback()
+	}
+}
+
+// setAccountStatus ghi trực tiếp trạng thái tài khoản, ví dụ để có tài khoản đã đóng mà chưa được gộp
+func setAccountStatus(ctx *testContext, customerID, status string) {
+	ctx.t.Helper()
+	ctx.mustTx(testTime, func() error {
+		account, err := getAccount(ctx, customerID)
+		if err != nil {
+			return err
+		}
+		account.Status = status
+		return putAccount(ctx, account)
+	})
+}
+
+func TestReleasePending(t *testing.T) {
+	type awardSpec struct {
+		customerID string
+		awardID    string
+		amount     int64
+	}
+	tests := []struct {
+		name         string
+		balances     map[string]int64
+		maxBalance   int64     // 0 = mặc định
+		closed       []string  // đóng mà chưa gộp
+		merge        [2]string // {survivorID, mergedID} gộp sau khi ghi nhận điểm chờ
+		awards       []awardSpec
+		wantFailed   map[string]string // awardID -> failureReason
+		wantBalances map[string]int64
+		fix          func(ctx *testContext) // sửa tài khoản trước RetryPending
+		wantRetried  map[string]int64       // số dư sau khi giải phóng lại
+	}{
+		{
+			name:         "awards of one account are credited once",
+			balances:     map[string]int64{"ALICE": 0, "BOB": 0},
+			awards:       []awardSpec{{"ALICE", "A1", 100}, {"ALICE", "A2", 200}, {"BOB", "B1", 50}},
+			wantBalances: map[string]int64{"ALICE": 300, "BOB": 50},
+		},
+		{
+			name:         "merged account is released to the survivor",
+			balances:     map[string]int64{"ALICE": 0, "BOB": 0},
+			merge:        [2]string{"BOB", "ALICE"},
+			awards:       []awardSpec{{"ALICE", "A1", 100}, {"BOB", "B1", 50}},
+			wantBalances: map[string]int64{"BOB": 150},
+		},
+		{
+			name:         "closed account fails without blocking the batch",
+			balances:     map[string]int64{"ALICE": 0, "BOB": 0},
+			closed:       []string{"ALICE"},
+			awards:       []awardSpec{{"ALICE", "A1", 100}, {"ALICE", "A2", 200}, {"BOB", "B1", 50}},
+			wantFailed:   map[string]string{"A1": ErrCodeAccountClosed, "A2": ErrCodeAccountClosed},
+			wantBalances: map[string]int64{"ALICE": 0, "BOB": 50},
+			fix:          func(ctx *testContext) { setAccountStatus(ctx, "ALICE", "ACTIVE") },
+			wantRetried:  map[string]int64{"ALICE": 300, "BOB": 50},
+		},
+		{
+			name:         "balance above the maximum fails",
+			balances:     map[string]int64{"ALICE": 950, "BOB": 0},
+			maxBalance:   1000,
+			awards:       []awardSpec{{"ALICE", "A1", 100}, {"BOB", "B1", 50}},
+			wantFailed:   map[string]string{"A1": ErrCodeAmountLimitExceeded},
+			wantBalances: map[string]int64{"ALICE": 950, "BOB": 50},
+			fix: func(ctx *testContext) {
+				ctx.mustTx(testTime, func() error {
+					_, err := (&AdminContract{}).SetLedgerConfig(ctx, 1000, 10000)
+					return err
+				})
+			},
+			wantRetried: map[string]int64{"ALICE": 1050, "BOB": 50},
+		},
+	}
+
+	releaseAt := testTime.Add(24 * time.Hour)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			contract := &AccountContract{}
+			ctx.setupAccounts(testTime, tt.balances)
+			if tt.maxBalance > 0 {
+				ctx.mustTx(testTime, func() error {
+					_, err := (&AdminContract{}).SetLedgerConfig(ctx, tt.maxBalance, tt.maxBalance)
+					return err
+				})
+			}
+			for _, spec := range tt.awards {
+				ctx.mustTx(testTime, func() error {
+					_, err := contract.AwardPendingPoints(ctx, spec.customerID, spec.awardID, spec.amount, releaseAt.Format(time.RFC3339), "purchase")
+					return err
+				})
+			}
+			for _, customerID := range tt.closed {
+				setAccountStatus(ctx, customerID, "CLOSED")
+			}
+			if tt.merge[0] != "" {
+				ctx.mustTx(testTime, func() error {
+					_, err := contract.MergeAccounts(ctx, tt.merge[0], tt.merge[1])
+					return err
+				})
+			}
+
+			var processed []*PendingAward
+			ctx.mustTx(releaseAt, func() error {
+				var err error
+				processed, err = contract.ReleasePending(ctx, 0)
+				return err
+			})
+			if len(processed) != len(tt.awards) {
+				t.Fatalf("processed %d awards, want %d", len(processed), len(tt.awards))
+			}
+			for _, award := range processed {
+				wantStatus, wantReason := PendingAwardStatusReleased, ""
+				if reason, failed := tt.wantFailed[award.AwardID]; failed {
+					wantStatus, wantReason = PendingAwardStatusFailed, reason
+				}
+				if award.Status != wantStatus || award.FailureReason != wantReason {
+					t.Errorf("award %s = %s (%q), want %s (%q)", award.AwardID, award.Status, award.FailureReason, wantStatus, wantReason)
+				}
+			}
+			for customerID, want := range tt.wantBalances {
+				if got := ctx.balance(customerID); got != want {
+					t.Errorf("balance of %s = %d, want %d", customerID, got, want)
+				}
+			}
+
+			// Khoản thất bại rời chỉ mục, nên lần chạy sau không xử lý lại
+			ctx.mustTx(releaseAt, func() error {
+				again, err := contract.ReleasePending(ctx, 0)
+				if err == nil && len(again) != 0 {
+					t.Errorf("second run processed %d awards, want 0", len(again))
+				}
+				return err
+			})
+			if tt.fix == nil {
+				return
+			}
+
+			tt.fix(ctx)
+			for _, spec := range tt.awards {
+				if _, failed := tt.wantFailed[spec.awardID]; !failed {
+					continue
+				}
+				ctx.mustTx(releaseAt, func() error {
+					_, err := contract.RetryPending(ctx, spec.customerID, spec.awardID)
+					return err
+				})
+			}
+			ctx.mustTx(releaseAt, func() error {
+				_, err := contract.ReleasePending(ctx, 0)
+				return err
+			})
+			for customerID, want := range tt.wantRetried {
+				if got := ctx.balance(customerID); got != want {
+					t.Errorf("balance of %s after retry = %d, want %d", customerID, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPendingRequiresFailedAward(t *testing.T) {
+	ctx := newTestContext(t)
+	contract := &AccountContract{}
+	ctx.setupAccounts(testTime, map[string]int64{"ALICE": 0})
+	ctx.mustTx(testTime, func() error {
+		_, err := contract.AwardPendingPoints(ctx, "ALICE", "A1", 100, testTime.Add(time.Hour).Format(time.RFC3339), "purchase")
+		return err
+	})
+	err := ctx.tx(testTime, func() error {
+		_, err := contract.RetryPending(ctx, "ALICE", "A1")
+		return err
+	})
+	checkErrorCode(t, err, ErrCodePendingAwardClosed)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// defaultAccountPageSize áp dụng khi QueryAccounts được gọi với pageSize <= 0
+	defaultAccountPageSize = 20
+	// maxAccountPageSize giới hạn số tài khoản trả về trong một trang
+	maxAccountPageSize = 200
+)
+
+// AccountFilter định nghĩa điều kiện lọc tài khoản, mọi trường đều không bắt buộc.
+// Ví dụ: {"status":"ACTIVE","tier":"GOLD","minBalance":1000,"updatedAfter":"2024-01-01T00:00:00Z"}
+type AccountFilter struct {
+	Status        string `json:"status,omitempty"`
+	Tier          string `json:"tier,omitempty"`
+	MinBalance    *int64 `json:"minBalance,omitempty"`
+	MaxBalance    *int64 `json:"maxBalance,omitempty"`
+	UpdatedAfter  string `json:"updatedAfter,omitempty"`  // RFC3339, bao gồm
+	UpdatedBefore string `json:"updatedBefore,omitempty"` // RFC3339, không bao gồm
+}
+
+// AccountPage định nghĩa một trang kết quả truy vấn tài khoản
+type AccountPage struct {
+	Accounts            []*LoyaltyAccount `json:"accounts"`
+	FetchedRecordsCount int               `json:"fetchedRecordsCount"`
+	Bookmark            string            `json:"bookmark"` // rỗng khi không còn trang tiếp theo
+}
+
+// setIndexedFields cập nhật các trường chỉ dùng cho truy vấn (docType, tier) trước khi ghi tài khoản.
+// FoldedDeltas chỉ giữ các delta được gộp trong giao dịch đang ghi tài khoản (xem GetBalanceAt).
+func (a *LoyaltyAccount) setIndexedFields() {
+	a.DocType = accountObjectType
+	a.Tier = CalculateTierFromPoints(a.tierPoints())
+	if !a.foldedInTx {
+		a.FoldedDeltas = nil
+	}
+}
+
+// matches kiểm tra tài khoản có thỏa mãn điều kiện lọc hay không (dùng cho truy vấn range trên LevelDB)
+func (f *AccountFilter) matches(account *LoyaltyAccount) bool {
+	if f.Status != "" && account.Status != f.Status {
+		return false
+	}
+	if f.Tier != "" && account.Tier != f.Tier {
+		return false
+	}
+	if f.MinBalance != nil && account.Balance < *f.MinBalance {
+		return false
+	}
+	if f.MaxBalance != nil && account.Balance > *f.MaxBalance {
+		return false
+	}
+	if f.UpdatedAfter != "" && account.LastUpdated < f.UpdatedAfter {
+		return false
+	}
+	if f.UpdatedBefore != "" && account.LastUpdated >= f.UpdatedBefore {
+		return false
+	}
+	return true
+}
+
+// selector chuyển điều kiện lọc thành CouchDB selector
+func (f *AccountFilter) selector() map[string]interface{} {
+	selector := map[string]interface{}{"docType": accountObjectType}
+	if f.Status != "" {
+		selector["status"] = f.Status
+	}
+	if f.Tier != "" {
+		selector["tier"] = f.Tier
+	}
+
+	balance := map[string]interface{}{}
+	if f.MinBalance != nil {
+		balance["$gte"] = *f.MinBalance
+	}
+	if f.MaxBalance != nil {
+		balance["$lte"] = *f.MaxBalance
+	}
+	if len(balance) > 0 {
+		selector["balance"] = balance
+	}
+
+	// lastUpdated được lưu dạng RFC3339 UTC nên so sánh chuỗi tương đương so sánh thời gian
+	lastUpdated := map[string]interface{}{}
+	if f.UpdatedAfter != "" {
+		lastUpdated["$gte"] = f.UpdatedAfter
+	}
+	if f.UpdatedBefore != "" {
+		lastUpdated["$lt"] = f.UpdatedBefore
+	}
+	if len(lastUpdated) > 0 {
+		selector["lastUpdated"] = lastUpdated
+	}
+	return selector
+}
+
+// =========================================================================================
+// UC-015: Liệt kê tài khoản theo điều kiện (CouchDB rich query)
+//
+// Logic chính:
+// 1. Giải mã `filterJSON` thành AccountFilter và kiểm tra hợp lệ (chuỗi rỗng = không lọc).
+// 2. Chuyển điều kiện lọc thành CouchDB selector trên các trường docType, status, tier,
+//    balance và lastUpdated (được đánh chỉ mục trong META-INF/statedb/couchdb/indexes).
+// 3. Thực hiện truy vấn phân trang với `pageSize` và `bookmark` của trang trước.
+// 4. Trả về danh sách tài khoản cùng bookmark cho trang tiếp theo.
+// Lưu ý: chỉ hoạt động trên peer dùng CouchDB và phải được gọi dưới dạng query (evaluate).
+// Peer dùng LevelDB gọi QueryAccountsByRange.
+// =========================================================================================
+func (s *AccountContract) QueryAccounts(ctx contractapi.TransactionContextInterface, filterJSON string, pageSize int, bookmark string) (*AccountPage, error) {
+	// 1. Điều kiện lọc
+	filter, err := parseAccountFilter(filterJSON)
+	if err != nil {
+		return nil, err
+	}
+	pageSize = normalizeAccountPageSize(pageSize)
+
+	// 2. CouchDB selector
+	queryJSON, err := json.Marshal(map[string]interface{}{"selector": filter.selector()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal account query: %v", err)
+	}
+
+	// 3. Truy vấn phân trang
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(queryJSON), int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	// 4. Kết quả
+	page := AccountPage{Accounts: []*LoyaltyAccount{}}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate accounts: %v", err)
+		}
+		account, err := decodeAccount(queryResult.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode account '%s': %v", queryResult.Key, err)
+		}
+		page.Accounts = append(page.Accounts, account)
+	}
+	page.FetchedRecordsCount = len(page.Accounts)
+	if metadata != nil && int(metadata.FetchedRecordsCount) == pageSize {
+		page.Bookmark = metadata.Bookmark
+	}
+	return &page, nil
+}
+
+// QueryAccountsByRange liệt kê tài khoản theo thứ tự customerID bằng range query phân trang, dành cho peer dùng LevelDB
+// (không hỗ trợ rich query). Điều kiện lọc được áp dụng trên từng trang nên một trang có thể ít hơn `pageSize`
+// tài khoản; tiếp tục gọi với bookmark trả về cho đến khi bookmark rỗng.
+// Chỉ liệt kê tài khoản dưới key account~customerID, hãy chạy MigrateState để di chuyển tài khoản cũ.
+func (s *AccountContract) QueryAccountsByRange(ctx contractapi.TransactionContextInterface, filterJSON string, pageSize int, bookmark string) (*AccountPage, error) {
+	filter, err := parseAccountFilter(filterJSON)
+	if err != nil {
+		return nil, err
+	}
+	pageSize = normalizeAccountPageSize(pageSize)
+
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(accountObjectType, []string{}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts page: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	page := AccountPage{Accounts: []*LoyaltyAccount{}}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate accounts: %v", err)
+		}
+		page.FetchedRecordsCount++
+
+		account, err := decodeAccount(queryResult.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode account '%s': %v", queryResult.Key, err)
+		}
+		if filter.matches(account) {
+			page.Accounts = append(page.Accounts, account)
+		}
+	}
+	if metadata != nil && int(metadata.FetchedRecordsCount) == pageSize {
+		page.Bookmark = metadata.Bookmark
+	}
+	return &page, nil
+}
+
+// parseAccountFilter giải mã và kiểm tra điều kiện lọc tài khoản
+func parseAccountFilter(filterJSON string) (*AccountFilter, error) {
+	var filter AccountFilter
+	if filterJSON == "" {
+		return &filter, nil
+	}
+	if err := json.Unmarshal([]byte(filterJSON), &filter); err != nil {
+		return nil, fmt.Errorf("invalid account filter: %v", err)
+	}
+
+	if filter.Tier != "" && !isValidTier(filter.Tier) {
+		return nil, errInvalidArgument("invalid tier: %s", filter.Tier)
+	}
+	if filter.MinBalance != nil && filter.MaxBalance != nil && *filter.MinBalance > *filter.MaxBalance {
+		return nil, errInvalidArgument("minBalance %d is greater than maxBalance %d", *filter.MinBalance, *filter.MaxBalance)
+	}
+	// Chuẩn hóa về UTC để so sánh chuỗi với lastUpdated
+	for _, timestamp := range []*string{&filter.UpdatedAfter, &filter.UpdatedBefore} {
+		if *timestamp == "" {
+			continue
+		}
+		t, err := ParseTimestamp(*timestamp)
+		if err != nil {
+			return nil, errInvalidArgument("invalid timestamp '%s', expected RFC3339: %v", *timestamp, err)
+		}
+		*timestamp = t.UTC().Format(time.RFC3339)
+	}
+	return &filter, nil
+}
+
+// normalizeAccountPageSize áp dụng giá trị mặc định và giới hạn trên cho kích thước trang
+func normalizeAccountPageSize(pageSize int) int {
+	if pageSize <= 0 {
+		return defaultAccountPageSize
+	}
+	return minInt(pageSize, maxAccountPageSize)
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	ReferralStatusPending  = "PENDING"
+	ReferralStatusRewarded = "REWARDED"
+	// ReferralStatusRewardFailed: một bên không ghi có được khi trả thưởng, chờ RetryReferralReward
+	ReferralStatusRewardFailed = "REWARD_FAILED"
+
+	// defaultReferralRewardLimit áp dụng khi RewardReferrals được gọi với limit <= 0
+	defaultReferralRewardLimit = 50
+)
+
+// ReferralRules định nghĩa chương trình giới thiệu trong cấu hình sổ cái
+type ReferralRules struct {
+	Threshold      int64 `json:"threshold"`      // LifetimeEarned người được giới thiệu phải đạt để hai bên nhận thưởng
+	ReferrerReward int64 `json:"referrerReward"` // điểm thưởng cho người giới thiệu
+	RefereeReward  int64 `json:"refereeReward"`  // điểm thưởng cho người được giới thiệu
+	MaxPerReferrer int   `json:"maxPerReferrer"` // số lượt giới thiệu tối đa của một người; 0 = không giới hạn
+}
+
+// Referral định nghĩa quan hệ giới thiệu của một khách hàng; mỗi người được giới thiệu chỉ có một người giới thiệu
+type Referral struct {
+	RefereeID      string `json:"refereeID"`
+	ReferrerID     string `json:"referrerID"`
+	Status         string `json:"status"` // PENDING, REWARDED, REWARD_FAILED
+	CreatedAt      string `json:"createdAt"`
+	QualifiedAt    string `json:"qualifiedAt,omitempty"` // thời điểm LifetimeEarned của người được giới thiệu vượt ngưỡng
+	RewardedAt     string `json:"rewardedAt,omitempty"`
+	ReferrerReward int64  `json:"referrerReward,omitempty"`
+	RefereeReward  int64  `json:"refereeReward,omitempty"`
+	FailureReason  string `json:"failureReason,omitempty"` // mã lỗi khi trả thưởng thất bại (REWARD_FAILED)
+	SchemaVersion  int    `json:"schemaVersion"`
+}
+
+// enabled cho biết chương trình giới thiệu đã được cấu hình hay chưa
+func (r ReferralRules) enabled() bool {
+	return r.Threshold > 0 && (r.ReferrerReward > 0 || r.RefereeReward > 0)
+}
+
+// =========================================================================================
+// UC-030: Chương trình giới thiệu bạn bè
+// Người giới thiệu và người được giới thiệu cùng nhận thưởng khi LifetimeEarned của người được giới thiệu
+// lần đầu vượt ngưỡng cấu hình.
+//
+// Logic chính:
+// 1. SetReferralRules (chỉ quản trị viên): ngưỡng LifetimeEarned, điểm thưởng của hai bên và số lượt
+//    giới thiệu tối đa của một người.
+// 2. RegisterReferral: người gọi phải được phép đại diện cho `refereeID`. Hai tài khoản phải khác nhau,
+//    tồn tại và chưa đóng; người được giới thiệu chưa có người giới thiệu, chưa đạt ngưỡng và không phải
+//    người đã được `referrerID` giới thiệu ngược lại; người giới thiệu chưa vượt số lượt tối đa.
+//    Quan hệ được lưu ở referral~refereeID, chỉ mục referrerReferral~referrerID~refereeID và
+//    LoyaltyAccount.ReferredBy của người được giới thiệu.
+// 3. Mỗi khi LifetimeEarned của tài khoản có ReferredBy lần đầu vượt ngưỡng (creditAccount, hoặc foldDeltas
+//    với tài khoản ở chế độ delta), ghi dấu referralQualified~refereeID trong cùng giao dịch.
+// 4. RewardReferrals (chỉ BankOrgMSP, job định kỳ của backend): trả thưởng cho tối đa `limit` lượt giới thiệu
+//    đã đạt ngưỡng, gộp điểm theo tài khoản nhận, cập nhật bộ đếm phát hành, chuyển sang REWARDED
+//    và phát ra một sự kiện "ReferralRewardedEvent" với danh sách các lượt đã trả thưởng.
+//    Điểm thưởng không tính vào LifetimeEarned nên không kích hoạt thêm lượt giới thiệu khác.
+//    Lượt giới thiệu có một bên không ghi có được (đã đóng mà chưa gộp, vượt số dư tối đa...) chuyển sang
+//    REWARD_FAILED cùng mã lỗi và không bên nào được thưởng; các lượt khác vẫn được trả thưởng.
+//    RetryReferralReward (chỉ BankOrgMSP) đưa lượt đó về PENDING để lần chạy sau trả thưởng lại.
+// =========================================================================================
+func (s *AdminContract) SetReferralRules(ctx contractapi.TransactionContextInterface, threshold int64, referrerReward int64, refereeReward int64, maxPerReferrer int) (*LedgerConfig, error) {
+	// 1. Kiểm tra quy tắc
+	if threshold < 0 || referrerReward < 0 || refereeReward < 0 || maxPerReferrer < 0 {
+		return nil, errInvalidArgument("referral rules cannot be negative")
+	}
+
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.checkTransactionAmount(referrerReward); err != nil {
+		return nil, err
+	}
+	if err := config.checkTransactionAmount(refereeReward); err != nil {
+		return nil, err
+	}
+	config.Referrals = ReferralRules{
+		Threshold:      threshold,
+		ReferrerReward: referrerReward,
+		RefereeReward:  refereeReward,
+		MaxPerReferrer: maxPerReferrer,
+	}
+	if err := putLedgerConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// RegisterReferral ghi nhận `referrerID` đã giới thiệu `refereeID` (xem UC-030)
+func (s *AccountContract) RegisterReferral(ctx contractapi.TransactionContextInterface, referrerID string, refereeID string) (*Referral, error) {
+	// 2. Kiểm tra đầu vào
+	if referrerID == refereeID {
+		return nil, errInvalidArgument("customers cannot refer themselves")
+	}
+	if err := requireActingFor(ctx, refereeID); err != nil {
+		return nil, err
+	}
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !config.Referrals.enabled() {
+		return nil, errInvalidArgument("the referral program is not configured")
+	}
+
+	referee, err := getAccount(ctx, refereeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(referee); err != nil {
+		return nil, err
+	}
+	referrer, err := getAccount(ctx, referrerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(referrer); err != nil {
+		return nil, err
+	}
+	if referee.ReferredBy != "" {
+		return nil, newChaincodeError(ErrCodeReferralAlreadyExists, map[string]interface{}{"refereeID": refereeID, "referrerID": referee.ReferredBy},
+			"customer '%s' was already referred by '%s'", refereeID, referee.ReferredBy)
+	}
+	if referrer.ReferredBy == refereeID {
+		return nil, errInvalidArgument("customer '%s' was referred by '%s' and cannot refer them back", referrerID, refereeID)
+	}
+	if referee.LifetimeEarned >= config.Referrals.Threshold {
+		return nil, errInvalidArgument("customer '%s' has already earned %d points and cannot be referred", refereeID, referee.LifetimeEarned)
+	}
+
+	// Số lượt giới thiệu của người giới thiệu
+	if config.Referrals.MaxPerReferrer > 0 {
+		referrals, err := countReferrals(ctx, referrerID)
+		if err != nil {
+			return nil, err
+		}
+		if referrals >= config.Referrals.MaxPerReferrer {
+			return nil, newChaincodeError(ErrCodeReferralLimitReached, map[string]interface{}{"referrerID": referrerID, "maxPerReferrer": config.Referrals.MaxPerReferrer},
+				"customer '%s' has reached the maximum of %d referrals", referrerID, config.Referrals.MaxPerReferrer)
+		}
+	}
+
+	// Lưu quan hệ giới thiệu
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	referral := Referral{
+		RefereeID:  refereeID,
+		ReferrerID: referrerID,
+		Status:     ReferralStatusPending,
+		CreatedAt:  now.Format(time.RFC3339),
+	}
+	if err := putReferral(ctx, &referral); err != nil {
+		return nil, err
+	}
+	indexKey, err := ledgerKey(ctx, referrerReferralObjectType, referrerID, refereeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte(referral.CreatedAt)); err != nil {
+		return nil, fmt.Errorf("failed to put referrer referral index: %v", err)
+	}
+	referee.ReferredBy = referrerID
+	if err := putAccount(ctx, referee); err != nil {
+		return nil, err
+	}
+
+	referralJSON, err := json.Marshal(referral)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal referral event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent("RegisterReferralEvent", referralJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for referral: %v", err)
+	}
+	return &referral, nil
+}
+
+// RewardReferrals trả thưởng cho tối đa `limit` lượt giới thiệu đã đạt ngưỡng (chỉ BankOrgMSP, xem UC-030)
+func (s *AccountContract) RewardReferrals(ctx contractapi.TransactionContextInterface, limit int) ([]*Referral, error) {
+	if limit <= 0 {
+		limit = defaultReferralRewardLimit
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dấu referralQualified~<refereeID> được ghi khi người được giới thiệu vượt ngưỡng
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(referralQualifiedObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query qualified referrals: %v", err)
+	}
+	var qualified [][2]string
+	for resultsIterator.HasNext() && len(qualified) < limit {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			resultsIterator.Close()
+			return nil, fmt.Errorf("failed to iterate qualified referrals: %v", err)
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			resultsIterator.Close()
+			return nil, fmt.Errorf("failed to split qualified referral key: %v", err)
+		}
+		qualified = append(qualified, [2]string{keyParts[0], string(queryResult.Value)})
+	}
+	resultsIterator.Close()
+
+	// Gom điểm thưởng theo tài khoản nhận: trong một giao dịch GetState không thấy các lần ghi trước đó,
+	// nên mỗi tài khoản chỉ được ghi có một lần. Tài khoản có thể đã được gộp sau khi đăng ký giới thiệu.
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	processed := []*Referral{}
+	recipients := map[string][2]string{} // refereeID -> {tài khoản nhận của người giới thiệu, của người được giới thiệu}
+	for _, marker := range qualified {
+		referral, err := getReferral(ctx, marker[0])
+		if err != nil {
+			return nil, err
+		}
+		if err := deleteReferralQualified(ctx, referral.RefereeID); err != nil {
+			return nil, err
+		}
+		if referral.Status != ReferralStatusPending {
+			continue
+		}
+		referrerTarget, err := resolveAccountID(ctx, referral.ReferrerID)
+		if err != nil {
+			return nil, err
+		}
+		refereeTarget, err := resolveAccountID(ctx, referral.RefereeID)
+		if err != nil {
+			return nil, err
+		}
+		recipients[referral.RefereeID] = [2]string{referrerTarget, refereeTarget}
+		referral.QualifiedAt = marker[1]
+		processed = append(processed, referral)
+	}
+	rewardTotals := func(failures map[string]*ChaincodeError) ([]string, map[string]int64, error) {
+		var targetIDs []string
+		targetAmounts := map[string]int64{}
+		for _, referral := range processed {
+			targets := recipients[referral.RefereeID]
+			if failures[targets[0]] != nil || failures[targets[1]] != nil {
+				continue
+			}
+			for i, amount := range []int64{config.Referrals.ReferrerReward, config.Referrals.RefereeReward} {
+				if amount == 0 {
+					continue
+				}
+				if _, seen := targetAmounts[targets[i]]; !seen {
+					targetIDs = append(targetIDs, targets[i])
+				}
+				var err error
+				if targetAmounts[targets[i]], err = addPoints(targetAmounts[targets[i]], amount); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		return targetIDs, targetAmounts, nil
+	}
+
+	// Lượt giới thiệu có một bên không ghi có được chuyển sang REWARD_FAILED và không bên nào được thưởng.
+	// Bỏ các lượt đó chỉ làm giảm số điểm của các tài khoản còn lại, nên các tài khoản này vẫn ghi có được.
+	targetIDs, targetAmounts, err := rewardTotals(nil)
+	if err != nil {
+		return nil, err
+	}
+	failures := map[string]*ChaincodeError{}
+	for _, targetID := range targetIDs {
+		failure, err := checkCredit(ctx, config, targetID, targetAmounts[targetID])
+		if err != nil {
+			return nil, err
+		}
+		if failure != nil {
+			failures[targetID] = failure
+		}
+	}
+	if len(failures) > 0 {
+		if targetIDs, targetAmounts, err = rewardTotals(failures); err != nil {
+			return nil, err
+		}
+	}
+
+	var total int64
+	for _, targetID := range targetIDs {
+		if total, err = addPoints(total, targetAmounts[targetID]); err != nil {
+			return nil, err
+		}
+		account, err := getAccount(ctx, targetID)
+		if err != nil {
+			return nil, err
+		}
+		deferred, err := creditAccount(ctx, config, account, targetAmounts[targetID], false)
+		if err != nil {
+			return nil, err
+		}
+		account.LastUpdated = now.Format(time.RFC3339)
+		if !deferred {
+			if err := putAccount(ctx, account); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, referral := range processed {
+		targets := recipients[referral.RefereeID]
+		if failure := failures[targets[0]]; failure != nil {
+			referral.Status = ReferralStatusRewardFailed
+			referral.FailureReason = failure.Code
+		} else if failure := failures[targets[1]]; failure != nil {
+			referral.Status = ReferralStatusRewardFailed
+			referral.FailureReason = failure.Code
+		} else {
+			referral.Status = ReferralStatusRewarded
+			referral.RewardedAt = now.Format(time.RFC3339)
+			referral.ReferrerReward = config.Referrals.ReferrerReward
+			referral.RefereeReward = config.Referrals.RefereeReward
+		}
+		if err := putReferral(ctx, referral); err != nil {
+			return nil, err
+		}
+	}
+
+	// Điểm thưởng giới thiệu được tính là phát hành
+	if total > 0 {
+		if err := updateSupplyCounters(ctx, total, 0, 0, 0); err != nil {
+			return nil, err
+		}
+	}
+	if len(processed) > 0 {
+		// Một giao dịch chỉ giữ một sự kiện: phát ra danh sách các lượt đã xử lý
+		processedJSON, err := json.Marshal(processed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal referral reward event: %v", err)
+		}
+		if err := ctx.GetStub().SetEvent("ReferralRewardedEvent", processedJSON); err != nil {
+			return nil, fmt.Errorf("failed to set event for referral reward: %v", err)
+		}
+	}
+	return processed, nil
+}
+
+// RetryReferralReward đưa một lượt giới thiệu REWARD_FAILED về PENDING và ghi lại dấu đạt ngưỡng,
+// để lần chạy RewardReferrals tiếp theo trả thưởng lại sau khi tài khoản đã được xử lý (xem UC-030)
+func (s *AccountContract) RetryReferralReward(ctx contractapi.TransactionContextInterface, refereeID string) (*Referral, error) {
+	referral, err := getReferral(ctx, refereeID)
+	if err != nil {
+		return nil, err
+	}
+	if referral.Status != ReferralStatusRewardFailed {
+		return nil, errInvalidArgument("only a referral whose reward failed can be retried, the referral of '%s' is %s", refereeID, referral.Status)
+	}
+
+	referral.Status = ReferralStatusPending
+	referral.FailureReason = ""
+	if err := putReferral(ctx, referral); err != nil {
+		return nil, err
+	}
+	markerKey, err := ledgerKey(ctx, referralQualifiedObjectType, refereeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(markerKey, []byte(referral.QualifiedAt)); err != nil {
+		return nil, fmt.Errorf("failed to put qualified referral: %v", err)
+	}
+
+	referralJSON, err := json.Marshal(referral)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal referral event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent("RetryReferralRewardEvent", referralJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for referral retry: %v", err)
+	}
+	return referral, nil
+}
+
+// GetReferral truy vấn quan hệ giới thiệu của người được giới thiệu
+func (s *AccountContract) GetReferral(ctx contractapi.TransactionContextInterface, refereeID string) (*Referral, error) {
+	return getReferral(ctx, refereeID)
+}
+
+// QueryReferrals trả về các lượt giới thiệu của `referrerID`, theo thứ tự ID người được giới thiệu
+func (s *AccountContract) QueryReferrals(ctx contractapi.TransactionContextInterface, referrerID string) ([]*Referral, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(referrerReferralObjectType, []string{referrerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query referrer referral index: %v", err)
+	}
+	var refereeIDs []string
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			resultsIterator.Close()
+			return nil, fmt.Errorf("failed to iterate referrer referral index: %v", err)
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			resultsIterator.Close()
+			return nil, fmt.Errorf("failed to split referrer referral key: %v", err)
+		}
+		refereeIDs = append(refereeIDs, keyParts[1])
+	}
+	resultsIterator.Close()
+
+	referrals := []*Referral{}
+	for _, refereeID := range refereeIDs {
+		referral, err := getReferral(ctx, refereeID)
+		if err != nil {
+			return nil, err
+		}
+		referrals = append(referrals, referral)
+	}
+	return referrals, nil
+}
+
+// markReferralQualified ghi dấu referralQualified~customerID khi LifetimeEarned của một tài khoản được giới thiệu
+// lần đầu vượt ngưỡng (bước 3 của UC-030). `config` có thể nil, khi đó cấu hình chỉ được đọc nếu cần.
+func markReferralQualified(ctx contractapi.TransactionContextInterface, config *LedgerConfig, account *LoyaltyAccount, earnedBefore int64) error {
+	if account.ReferredBy == "" || account.LifetimeEarned <= earnedBefore {
+		return nil
+	}
+	if config == nil {
+		var err error
+		if config, err = getLedgerConfig(ctx); err != nil {
+			return err
+		}
+	}
+	threshold := config.Referrals.Threshold
+	if threshold <= 0 || earnedBefore >= threshold || account.LifetimeEarned < threshold {
+		return nil
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	markerKey, err := ledgerKey(ctx, referralQualifiedObjectType, account.CustomerID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(markerKey, []byte(now.Format(time.RFC3339))); err != nil {
+		return fmt.Errorf("failed to put qualified referral: %v", err)
+	}
+	return nil
+}
+
+// deleteReferralQualified xóa dấu đạt ngưỡng của một lượt giới thiệu đã được xử lý
+func deleteReferralQualified(ctx contractapi.TransactionContextInterface, refereeID string) error {
+	markerKey, err := ledgerKey(ctx, referralQualifiedObjectType, refereeID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(markerKey); err != nil {
+		return fmt.Errorf("failed to delete qualified referral: %v", err)
+	}
+	return nil
+}
+
+// countReferrals đếm số lượt giới thiệu của `referrerID` từ chỉ mục referrerReferral
+func countReferrals(ctx contractapi.TransactionContextInterface, referrerID string) (int, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(referrerReferralObjectType, []string{referrerID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to query referrer referral index: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	count := 0
+	for resultsIterator.HasNext() {
+		if _, err := resultsIterator.Next(); err != nil {
+			return count, fmt.Errorf("failed to iterate referrer referral index: %v", err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// getReferral đọc quan hệ giới thiệu của một người được giới thiệu từ World State
+func getReferral(ctx contractapi.TransactionContextInterface, refereeID string) (*Referral, error) {
+	referralKey, err := ledgerKey(ctx, referralObjectType, refereeID)
+	if err != nil {
+		return nil, err
+	}
+	referralJSON, err := ctx.GetStub().GetState(referralKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read referral from world state: %v", err)
+	}
+	if referralJSON == nil {
+		return nil, newChaincodeError(ErrCodeReferralNotFound, map[string]interface{}{"refereeID": refereeID}, "customer '%s' has no referral", refereeID)
+	}
+
+	var referral Referral
+	if err := json.Unmarshal(referralJSON, &referral); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal referral data: %v", err)
+	}
+	return &referral, nil
+}
+
+// putReferral ghi một quan hệ giới thiệu vào World State
+func putReferral(ctx contractapi.TransactionContextInterface, referral *Referral) error {
+	referral.SchemaVersion = referralSchemaVersion
+	referralKey, err := ledgerKey(ctx, referralObjectType, referral.RefereeID)
+	if err != nil {
+		return err
+	}
+	referralJSON, err := json.Marshal(referral)
+	if err != nil {
+		return fmt.Errorf("failed to marshal referral: %v", err)
+	}
+	if err := ctx.GetStub().PutState(referralKey, referralJSON); err != nil {
+		return fmt.Errorf("failed to put state for referral: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRewardReferrals(t *testing.T) {
+	// ALICE giới thiệu BOB và CAROL, DAVE giới thiệu ERIN; ngưỡng 100, thưởng 50 cho người giới thiệu và 20 cho người được giới thiệu
+	referrals := [][2]string{{"ALICE", "BOB"}, {"ALICE", "CAROL"}, {"DAVE", "ERIN"}}
+	tests := []struct {
+		name         string
+		maxBalance   int64             // 0 = mặc định
+		issued       map[string]int64  // điểm phát hành sau khi đăng ký, mặc định 100 cho mỗi người được giới thiệu
+		closed       []string          // đóng mà chưa gộp
+		merge        [2]string         // {survivorID, mergedID} gộp sau khi đăng ký
+		wantFailed   map[string]string // refereeID -> failureReason
+		wantBalances map[string]int64
+		fix          func(ctx *testContext) // sửa tài khoản trước RetryReferralReward
+		wantRetried  map[string]int64       // số dư sau khi trả thưởng lại
+	}{
+		{
+			name:         "referrer is credited once for several referrals",
+			wantBalances: map[string]int64{"ALICE": 100, "BOB": 120, "CAROL": 120, "DAVE": 50, "ERIN": 120},
+		},
+		{
+			name:         "merged referrer is rewarded on the survivor",
+			merge:        [2]string{"ALICE", "DAVE"},
+			wantBalances: map[string]int64{"ALICE": 150, "BOB": 120, "CAROL": 120, "ERIN": 120},
+		},
+		{
+			name:         "closed referrer fails its referrals without blocking the batch",
+			closed:       []string{"ALICE"},
+			wantFailed:   map[string]string{"BOB": ErrCodeAccountClosed, "CAROL": ErrCodeAccountClosed},
+			wantBalances: map[string]int64{"ALICE": 0, "BOB": 100, "CAROL": 100, "DAVE": 50, "ERIN": 120},
+			fix:          func(ctx *testContext) { setAccountStatus(ctx, "ALICE", "ACTIVE") },
+			wantRetried:  map[string]int64{"ALICE": 100, "BOB": 120, "CAROL": 120, "DAVE": 50, "ERIN": 120},
+		},
+		{
+			name:         "referee above the maximum balance fails",
+			maxBalance:   1000,
+			issued:       map[string]int64{"BOB": 100, "CAROL": 100, "ERIN": 1000},
+			wantFailed:   map[string]string{"ERIN": ErrCodeAmountLimitExceeded},
+			wantBalances: map[string]int64{"ALICE": 100, "BOB": 120, "CAROL": 120, "DAVE": 0, "ERIN": 1000},
+			fix: func(ctx *testContext) {
+				ctx.mustTx(testTime, func() error {
+					_, err := (&AdminContract{}).SetLedgerConfig(ctx, 1000, 10000)
+					return err
+				})
+			},
+			wantRetried: map[string]int64{"ALICE": 100, "BOB": 120, "CAROL": 120, "DAVE": 50, "ERIN": 1020},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			contract := &AccountContract{}
+			admin := &AdminContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 0, "BOB": 0, "CAROL": 0, "DAVE": 0, "ERIN": 0})
+			ctx.mustTx(testTime, func() error {
+				_, err := admin.SetReferralRules(ctx, 100, 50, 20, 0)
+				return err
+			})
+			if tt.maxBalance > 0 {
+				ctx.mustTx(testTime, func() error {
+					_, err := admin.SetLedgerConfig(ctx, tt.maxBalance, tt.maxBalance)
+					return err
+				})
+			}
+			for _, referral := range referrals {
+				ctx.mustTx(testTime, func() error {
+					_, err := contract.RegisterReferral(ctx, referral[0], referral[1])
+					return err
+				})
+			}
+			issued := tt.issued
+			if issued == nil {
+				issued = map[string]int64{"BOB": 100, "CAROL": 100, "ERIN": 100}
+			}
+			for _, referral := range referrals {
+				ctx.mustTx(testTime, func() error {
+					_, err := contract.IssuePoints(ctx, referral[1], issued[referral[1]], "purchase", "")
+					return err
+				})
+			}
+			for _, customerID := range tt.closed {
+				setAccountStatus(ctx, customerID, "CLOSED")
+			}
+			if tt.merge[0] != "" {
+				ctx.mustTx(testTime, func() error {
+					_, err := contract.MergeAccounts(ctx, tt.merge[0], tt.merge[1])
+					return err
+				})
+			}
+
+			rewardAt := testTime.Add(time.Hour)
+			var processed []*Referral
+			ctx.mustTx(rewardAt, func() error {
+				var err error
+				processed, err = contract.RewardReferrals(ctx, 0)
+				return err
+			})
+			if len(processed) != len(referrals) {
+				t.Fatalf("processed %d referrals, want %d", len(processed), len(referrals))
+			}
+			for _, referral := range processed {
+				wantStatus, wantReason := ReferralStatusRewarded, ""
+				if reason, failed := tt.wantFailed[referral.RefereeID]; failed {
+					wantStatus, wantReason = ReferralStatusRewardFailed, reason
+				}
+				if referral.Status != wantStatus || referral.FailureReason != wantReason {
+					t.Errorf("referral of %s = %s (%q), want %s (%q)", referral.RefereeID, referral.Status, referral.FailureReason, wantStatus, wantReason)
+				}
+			}
+			for customerID, want := range tt.wantBalances {
+				if got := ctx.balance(customerID); got != want {
+					t.Errorf("balance of %s = %d, want %d", customerID, got, want)
+				}
+			}
+
+			// Dấu đạt ngưỡng đã bị xóa, nên lần chạy sau không xử lý lại
+			ctx.mustTx(rewardAt, func() error {
+				again, err := contract.RewardReferrals(ctx, 0)
+				if err == nil && len(again) != 0 {
+					t.Errorf("second run processed %d referrals, want 0", len(again))
+				}
+				return err
+			})
+			if tt.fix == nil {
+				return
+			}
+
+			tt.fix(ctx)
+			for refereeID := range tt.wantFailed {
+				ctx.mustTx(rewardAt, func() error {
+					_, err := contract.RetryReferralReward(ctx, refereeID)
+					return err
+				})
+			}
+			ctx.mustTx(rewardAt, func() error {
+				_, err := contract.RewardReferrals(ctx, 0)
+				return err
+			})
+			for customerID, want := range tt.wantRetried {
+				if got := ctx.balance(customerID); got != want {
+					t.Errorf("balance of %s after retry = %d, want %d", customerID, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryReferralRewardRequiresFailedReferral(t *testing.T) {
+	ctx := newTestContext(t)
+	contract := &AccountContract{}
+	ctx.setupAccounts(testTime, map[string]int64{"ALICE": 0, "BOB": 0})
+	ctx.mustTx(testTime, func() error {
+		_, err := (&AdminContract{}).SetReferralRules(ctx, 100, 50, 20, 0)
+		return err
+	})
+	ctx.mustTx(testTime, func() error {
+		_, err := contract.RegisterReferral(ctx, "ALICE", "BOB")
+		return err
+	})
+	err := ctx.tx(testTime, func() error {
+		_, err := contract.RetryReferralReward(ctx, "BOB")
+		return err
+	})
+	checkErrorCode(t, err, ErrCodeInvalidArgument)
+}
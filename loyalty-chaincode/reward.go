@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// defaultVoucherValidityDays áp dụng khi phần thưởng không khai báo thời hạn voucher
+	defaultVoucherValidityDays = 30
+)
+
+// RewardRedemption định nghĩa kết quả trả về khi khách hàng quy đổi một phần thưởng
+type RewardRedemption struct {
+	Account *LoyaltyAccount `json:"account"`
+	Voucher *Voucher        `json:"voucher"`
+}
+
+// CreateReward thêm một phần thưởng vào danh mục (chỉ BankOrgMSP)
+func (s *RewardContract) CreateReward(ctx contractapi.TransactionContextInterface, rewardID string, name string, pointsCost int64, cashValue float64, quantity int, voucherValidityDays int) (*Reward, error) {
+	if voucherValidityDays < 0 {
+		return nil, errInvalidArgument("voucher validity days cannot be negative")
+	}
+
+	reward := Reward{
+		RewardID:            rewardID,
+		Name:                name,
+		PointsCost:          pointsCost,
+		CashValue:           cashValue,
+		Quantity:            quantity,
+		Status:              "ACTIVE",
+		VoucherValidityDays: voucherValidityDays,
+	}
+	if err := ValidateRewardData(&reward); err != nil {
+		return nil, errInvalidArgument("%v", err)
+	}
+
+	rewardKey, err := ledgerKey(ctx, rewardObjectType, rewardID)
+	if err != nil {
+		return nil, err
+	}
+	existingRewardJSON, err := ctx.GetStub().GetState(rewardKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existingRewardJSON != nil {
+		return nil, newChaincodeError(ErrCodeRewardAlreadyExists, map[string]interface{}{"rewardID": rewardID}, "reward with ID '%s' already exists", rewardID)
+	}
+
+	if err := putReward(ctx, &reward); err != nil {
+		return nil, err
+	}
+	return &reward, nil
+}
+
+// GetReward truy vấn một phần thưởng theo ID
+func (s *RewardContract) GetReward(ctx contractapi.TransactionContextInterface, rewardID string) (*Reward, error) {
+	if rewardID == "" {
+		return nil, errInvalidArgument("reward ID cannot be empty")
+	}
+	return getReward(ctx, rewardID)
+}
+
+// =========================================================================================
+// UC-009: Quy đổi phần thưởng và phát hành voucher
+//
+// Logic chính:
+// 1. Tìm phần thưởng theo `rewardID`, phần thưởng phải ACTIVE và còn số lượng.
+// 2. Tìm tài khoản theo `customerID`, số dư phải >= PointsCost.
+// 3. Trừ điểm, cập nhật LifetimeRedeemed và giảm số lượng phần thưởng.
+// 4. Phát hành một voucher duy nhất (owner, rewardID, value, expiry, status ISSUED).
+// 5. Phát ra sự kiện "RedeemRewardEvent" và trả về tài khoản cùng voucher.
+// =========================================================================================
+func (s *RewardContract) RedeemReward(ctx contractapi.TransactionContextInterface, customerID string, rewardID string) (*RewardRedemption, error) {
+	if customerID == "" {
+		return nil, errInvalidArgument("customer ID cannot be empty")
+	}
+	if rewardID == "" {
+		return nil, errInvalidArgument("reward ID cannot be empty")
+	}
+
+	// 1. Kiểm tra phần thưởng
+	reward, err := getReward(ctx, rewardID)
+	if err != nil {
+		return nil, err
+	}
+	if err := reward.checkAvailable(); err != nil {
+		return nil, err
+	}
+
+	// 2. Tìm tài khoản (số dư được kiểm tra khi trừ điểm)
+	account, err := getAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Trừ điểm (lỗi nếu số dư < PointsCost)
+	if err := debitAccount(ctx, account, reward.PointsCost); err != nil {
+		return nil, err
+	}
+	if account.LifetimeRedeemed, err = addPoints(account.LifetimeRedeemed, reward.PointsCost); err != nil {
+		return nil, err
+	}
+	account.LastUpdated = now.Format(time.RFC3339)
+	if err := putAccount(ctx, account); err != nil {
+		return nil, err
+	}
+	if err := updateSupplyCounters(ctx, 0, reward.PointsCost, 0, 0); err != nil {
+		return nil, err
+	}
+
+	// 4. Giảm số lượng phần thưởng và phát hành voucher
+	voucher, err := issueRewardVoucher(ctx, reward, customerID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// 5. Phát ra sự kiện
+	redemption := RewardRedemption{Account: account, Voucher: voucher}
+	redemptionJSON, err := json.Marshal(redemption)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal redemption event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent("RedeemRewardEvent", redemptionJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for redemption: %v", err)
+	}
+
+	return &redemption, nil
+}
+
+// checkAvailable trả về lỗi REWARD_UNAVAILABLE nếu phần thưởng không ACTIVE hoặc đã hết số lượng
+func (r *Reward) checkAvailable() error {
+	if r.Status != "ACTIVE" {
+		return newChaincodeError(ErrCodeRewardUnavailable, map[string]interface{}{"rewardID": r.RewardID, "status": r.Status}, "reward '%s' is not active", r.RewardID)
+	}
+	if r.Quantity <= 0 {
+		return newChaincodeError(ErrCodeRewardUnavailable, map[string]interface{}{"rewardID": r.RewardID, "quantity": r.Quantity}, "reward '%s' is out of stock", r.RewardID)
+	}
+	return nil
+}
+
+// issueRewardVoucher giảm số lượng của phần thưởng đã quy đổi và phát hành voucher cho `ownerID`
+func issueRewardVoucher(ctx contractapi.TransactionContextInterface, reward *Reward, ownerID string, now time.Time) (*Voucher, error) {
+	reward.Quantity--
+	if err := putReward(ctx, reward); err != nil {
+		return nil, err
+	}
+
+	validityDays := reward.VoucherValidityDays
+	if validityDays == 0 {
+		validityDays = defaultVoucherValidityDays
+	}
+	voucher := Voucher{
+		VoucherID:  newVoucherID(ctx.GetStub().GetTxID()),
+		OwnerID:    ownerID,
+		RewardID:   reward.RewardID,
+		Value:      reward.CashValue,
+		PointsCost: reward.PointsCost,
+		IssuedAt:   now.Format(time.RFC3339),
+		ExpiresAt:  now.AddDate(0, 0, validityDays).Format(time.RFC3339),
+		Status:     VoucherStatusIssued,
+	}
+	if err := createVoucher(ctx, &voucher); err != nil {
+		return nil, err
+	}
+	return &voucher, nil
+}
+
+// getReward đọc một phần thưởng từ World State
+func getReward(ctx contractapi.TransactionContextInterface, rewardID string) (*Reward, error) {
+	rewardKey, err := ledgerKey(ctx, rewardObjectType, rewardID)
+	if err != nil {
+		return nil, err
+	}
+	rewardJSON, err := ctx.GetStub().GetState(rewardKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reward from world state: %v", err)
+	}
+	if rewardJSON == nil {
+		return nil, newChaincodeError(ErrCodeRewardNotFound, map[string]interface{}{"rewardID": rewardID}, "reward with ID '%s' does not exist", rewardID)
+	}
+
+	var reward Reward
+	if err := json.Unmarshal(rewardJSON, &reward); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reward data: %v", err)
+	}
+	return &reward, nil
+}
+
+// putReward ghi một phần thưởng vào World State
+func putReward(ctx contractapi.TransactionContextInterface, reward *Reward) error {
+	reward.SchemaVersion = rewardSchemaVersion
+	rewardKey, err := ledgerKey(ctx, rewardObjectType, reward.RewardID)
+	if err != nil {
+		return err
+	}
+	rewardJSON, err := json.Marshal(reward)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reward: %v", err)
+	}
+	if err := ctx.GetStub().PutState(rewardKey, rewardJSON); err != nil {
+		return fmt.Errorf("failed to put state for reward: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Phiên bản schema hiện tại của từng loại đối tượng trên sổ cái.
+// Bản ghi cũ không có trường schemaVersion được đọc ra với giá trị 0.
+// Khi thay đổi cấu trúc một đối tượng: tăng hằng số tương ứng và bổ sung bước nâng cấp trong upgradeX.
+const (
+	accountSchemaVersion       = 3
+	customerSchemaVersion      = 1
+	rewardSchemaVersion        = 1
+	campaignSchemaVersion      = 1
+	campaignUsageSchemaVersion = 1
+	purchaseAwardSchemaVersion = 1
+	voucherSchemaVersion       = 1
+	allowanceSchemaVersion     = 1
+	supplySchemaVersion        = 1
+	ledgerConfigSchemaVersion  = 1
+	balanceDeltaSchemaVersion  = 1
+	adjustmentSchemaVersion    = 1
+
+	householdSchemaVersion             = 1
+	householdContributionSchemaVersion = 1
+	giftSchemaVersion                  = 1
+	swapSchemaVersion                  = 1
+	pendingAwardSchemaVersion          = 1
+	transferAuthSchemaVersion          = 1
+	flaggedTransferSchemaVersion       = 1
+	referralSchemaVersion              = 1
+	accountStatsSchemaVersion          = 1
+	dailyTotalsSchemaVersion           = 1
+
+	// defaultMigrationPageSize áp dụng khi MigrateState hoặc PlanAccountMigration được gọi với pageSize <= 0
+	defaultMigrationPageSize = 100
+	// maxMigrationPageSize giới hạn số tài khoản của một trang PlanAccountMigration và một lần MigrateAccounts
+	maxMigrationPageSize = 1000
+)
+
+// MigrationResult định nghĩa kết quả của một trang di chuyển dữ liệu
+type MigrationResult struct {
+	Scanned      int    `json:"scanned"`
+	Migrated     int    `json:"migrated"`
+	NextStartKey string `json:"nextStartKey"` // truyền vào lần gọi MigrateState tiếp theo
+	Done         bool   `json:"done"`
+}
+
+// AccountMigrationPage định nghĩa một trang tài khoản dưới key account~customerID cần được ghi lại
+type AccountMigrationPage struct {
+	CustomerIDs []string `json:"customerIDs"` // truyền vào MigrateAccounts
+	Scanned     int      `json:"scanned"`
+	Bookmark    string   `json:"bookmark"` // truyền vào lần gọi PlanAccountMigration tiếp theo
+	Done        bool     `json:"done"`
+}
+
+// decodeAccount giải mã một tài khoản và nâng cấp lên schema hiện tại
+func decodeAccount(accountJSON []byte) (*LoyaltyAccount, error) {
+	var account LoyaltyAccount
+	if err := json.Unmarshal(accountJSON, &account); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal account data: %v", err)
+	}
+	upgradeAccount(&account)
+	return &account, nil
+}
+
+// upgradeAccount nâng cấp tài khoản đọc từ sổ cái lên schema hiện tại (chỉ trong bộ nhớ).
+// Trả về true nếu bản ghi đã thay đổi và cần ghi lại.
+func upgradeAccount(account *LoyaltyAccount) bool {
+	if account.SchemaVersion >= accountSchemaVersion {
+		return false
+	}
+
+	// v0 -> v1: CreateLoyaltyAccount cũ không ghi Status, IssuePoints cũ không cập nhật LifetimeEarned.
+	// Ước lượng LifetimeEarned thấp nhất phù hợp với số dư hiện tại.
+	if account.SchemaVersion < 1 {
+		if account.Status == "" {
+			account.Status = "ACTIVE"
+		}
+		if minimumEarned, err := addPoints(account.Balance, account.LifetimeRedeemed); err == nil && account.LifetimeEarned < minimumEarned {
+			account.LifetimeEarned = minimumEarned
+		}
+	}
+
+	// v1 -> v2: bổ sung docType và tier để truy vấn bằng CouchDB selector (xem QueryAccounts)
+	if account.SchemaVersion < 2 {
+		account.setIndexedFields()
+	}
+
+	// v2 -> v3: không đổi dữ liệu; tài khoản được tính vào thống kê khi được ghi lại (xem stats.go)
+
+	account.SchemaVersion = accountSchemaVersion
+	return true
+}
+
+// =========================================================================================
+// UC-014: Di chuyển dữ liệu sổ cái sang schema mới
+// Chỉ quản trị viên (BankOrgMSP, thuộc tính role=admin) được phép gọi.
+//
+// Logic chính:
+// 1. Giai đoạn 1 - key cũ: MigrateState duyệt các tài khoản còn nằm dưới key đơn giản customerID, bắt đầu từ
+//    `startKey`, nâng cấp schema và chuyển sang key account~customerID (xóa key cũ).
+//    Mỗi lần gọi xử lý tối đa `pageSize` bản ghi và trả về `NextStartKey` cho lần gọi sau;
+//    gọi lại cho đến khi `Done` = true.
+// 2. Giai đoạn 2 - key mới: PlanAccountMigration (chỉ evaluate) đọc một trang tài khoản dưới composite key
+//    theo `bookmark` và trả về ID của những tài khoản có schema cũ hoặc chính sách xác nhận chưa có chỉ mục;
+//    MigrateAccounts ghi lại các tài khoản đó. Lặp lại với `Bookmark` trả về cho đến khi `Done` = true.
+// Lưu ý: truy vấn phân trang không được phép trong giao dịch ghi. Key đơn giản được duyệt bằng range query
+// thường từ `startKey`; composite key không dùng được range query từ một key bất kỳ, nên việc đọc theo
+// bookmark và việc ghi được tách thành hai lời gọi để mỗi trang chỉ đọc `pageSize` tài khoản.
+// =========================================================================================
+func (s *AdminContract) MigrateState(ctx contractapi.TransactionContextInterface, startKey string, pageSize int) (*MigrationResult, error) {
+	if pageSize <= 0 {
+		pageSize = defaultMigrationPageSize
+	}
+
+	// Con trỏ giai đoạn 2 của phiên bản trước: tài khoản dưới composite key được ghi lại bằng MigrateAccounts
+	if strings.HasPrefix(startKey, accountObjectType+"~") {
+		return nil, errInvalidArgument("accounts under composite keys are migrated with PlanAccountMigration and MigrateAccounts")
+	}
+	return migrateLegacyAccounts(ctx, startKey, pageSize)
+}
+
+// migrateLegacyAccounts chuyển các tài khoản dưới key đơn giản sang key account~customerID
+func migrateLegacyAccounts(ctx contractapi.TransactionContextInterface, startKey string, pageSize int) (*MigrationResult, error) {
+	// Các đối tượng khác đều dùng composite key nên range query chỉ trả về tài khoản cũ
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query legacy accounts: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	result := MigrationResult{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate legacy accounts: %v", err)
+		}
+
+		// Đã đủ một trang: key hiện tại là điểm bắt đầu của trang sau
+		if result.Scanned == pageSize {
+			result.NextStartKey = queryResult.Key
+			return &result, nil
+		}
+		result.Scanned++
+
+		account, err := decodeAccount(queryResult.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode legacy account '%s': %v", queryResult.Key, err)
+		}
+		// putAccount ghi vào key mới và xóa key cũ
+		if err := putAccount(ctx, account); err != nil {
+			return nil, err
+		}
+		result.Migrated++
+	}
+
+	// Hết key cũ: giai đoạn 2 dùng PlanAccountMigration và MigrateAccounts
+	result.Done = true
+	return &result, nil
+}
+
+// PlanAccountMigration liệt kê các tài khoản dưới key account~customerID cần MigrateAccounts ghi lại,
+// một trang `pageSize` tài khoản bắt đầu từ `bookmark` (xem UC-014). Chỉ dùng khi evaluate.
+func (s *AdminContract) PlanAccountMigration(ctx contractapi.TransactionContextInterface, bookmark string, pageSize int) (*AccountMigrationPage, error) {
+	if pageSize <= 0 {
+		pageSize = defaultMigrationPageSize
+	}
+	if pageSize > maxMigrationPageSize {
+		return nil, errInvalidArgument("page size cannot exceed %d, got: %d", maxMigrationPageSize, pageSize)
+	}
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(accountObjectType, []string{}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	page := AccountMigrationPage{CustomerIDs: []string{}}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate accounts: %v", err)
+		}
+		page.Scanned++
+		var account LoyaltyAccount
+		if err := json.Unmarshal(queryResult.Value, &account); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal account '%s': %v", queryResult.Key, err)
+		}
+		needsMigration, err := accountNeedsMigration(ctx, &account)
+		if err != nil {
+			return nil, err
+		}
+		if needsMigration {
+			page.CustomerIDs = append(page.CustomerIDs, account.CustomerID)
+		}
+	}
+	page.Bookmark = metadata.Bookmark
+	page.Done = page.Bookmark == ""
+	return &page, nil
+}
+
+// MigrateAccounts ghi lại các tài khoản do PlanAccountMigration trả về (xem UC-014).
+// Mỗi tài khoản được đọc theo key nên một lần gọi chỉ đọc và ghi `customerIDs`.
+func (s *AdminContract) MigrateAccounts(ctx contractapi.TransactionContextInterface, customerIDs []string) (*MigrationResult, error) {
+	if len(customerIDs) > maxMigrationPageSize {
+		return nil, errInvalidArgument("cannot migrate more than %d accounts at once, got: %d", maxMigrationPageSize, len(customerIDs))
+	}
+	result := MigrationResult{Done: true}
+	for _, customerID := range customerIDs {
+		accountJSON, err := getAccountState(ctx, customerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read account '%s' from world state: %v", customerID, err)
+		}
+		if accountJSON == nil {
+			return nil, errAccountNotFound(customerID)
+		}
+		result.Scanned++
+
+		var account LoyaltyAccount
+		if err := json.Unmarshal(accountJSON, &account); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal account '%s': %v", customerID, err)
+		}
+		// Tài khoản có chính sách từ trước khi có chỉ mục accountPolicy (xem UC-027)
+		if len(account.EndorsingOrgs) > 0 {
+			if err := putAccountPolicyIndex(ctx, customerID, account.EndorsingOrgs); err != nil {
+				return nil, err
+			}
+		}
+		if !upgradeAccount(&account) {
+			continue
+		}
+		if err := putAccount(ctx, &account); err != nil {
+			return nil, err
+		}
+		result.Migrated++
+	}
+	return &result, nil
+}
+
+// accountNeedsMigration kiểm tra tài khoản có schema cũ hoặc chính sách xác nhận chưa có chỉ mục accountPolicy
+func accountNeedsMigration(ctx contractapi.TransactionContextInterface, account *LoyaltyAccount) (bool, error) {
+	if account.SchemaVersion < accountSchemaVersion {
+		return true, nil
+	}
+	if len(account.EndorsingOrgs) == 0 {
+		return false, nil
+	}
+	indexKey, err := ledgerKey(ctx, accountPolicyObjectType, account.CustomerID)
+	if err != nil {
+		return false, err
+	}
+	indexed, err := ctx.GetStub().GetState(indexKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read account policy index: %v", err)
+	}
+	return indexed == nil, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestMigrateAccountsInPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		accounts  int // tài khoản dưới account~customerID, tài khoản thứ chẵn có schema v0
+		legacy    int // tài khoản dưới key đơn giản
+		pageSize  int
+		wantPages int
+	}{
+		{name: "single page", accounts: 3, pageSize: 10, wantPages: 1},
+		{name: "several pages", accounts: 7, legacy: 2, pageSize: 3, wantPages: 3},
+		{name: "last page is full", accounts: 6, pageSize: 3, wantPages: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			admin := &AdminContract{}
+			balances := map[string]int64{}
+			for i := 0; i < tt.accounts; i++ {
+				balances[fmt.Sprintf("ACC%02d", i)] = 100
+			}
+			ctx.setupAccounts(testTime, balances)
+			ctx.mustTx(testTime, func() error {
+				for i := 0; i < tt.accounts; i += 2 {
+					customerID := fmt.Sprintf("ACC%02d", i)
+					key, err := ledgerKey(ctx, accountObjectType, customerID)
+					if err != nil {
+						return err
+					}
+					if err := ctx.GetStub().PutState(key, []byte(`{"customerID":"`+customerID+`","balance":100}`)); err != nil {
+						return err
+					}
+				}
+				for i := 0; i < tt.legacy; i++ {
+					customerID := fmt.Sprintf("OLD%02d", i)
+					if err := ctx.GetStub().PutState(customerID, []byte(`{"customerID":"`+customerID+`","balance":50}`)); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+
+			// Giai đoạn 1: chuyển tài khoản khỏi key đơn giản
+			startKey := ""
+			for {
+				var result *MigrationResult
+				ctx.mustTx(testTime, func() error {
+					var err error
+					result, err = admin.MigrateState(ctx, startKey, tt.pageSize)
+					return err
+				})
+				if result.Done {
+					break
+				}
+				startKey = result.NextStartKey
+			}
+
+			// Giai đoạn 2: đọc theo bookmark rồi ghi lại các tài khoản được liệt kê
+			pages, bookmark := 0, ""
+			for {
+				var page *AccountMigrationPage
+				ctx.mustTx(testTime, func() error {
+					var err error
+					page, err = admin.PlanAccountMigration(ctx, bookmark, tt.pageSize)
+					return err
+				})
+				pages++
+				if page.Scanned > tt.pageSize {
+					t.Errorf("page %d scanned %d accounts, want at most %d", pages, page.Scanned, tt.pageSize)
+				}
+				ctx.mustTx(testTime, func() error {
+					_, err := admin.MigrateAccounts(ctx, page.CustomerIDs)
+					return err
+				})
+				if page.Done {
+					break
+				}
+				bookmark = page.Bookmark
+			}
+			if pages != tt.wantPages {
+				t.Errorf("planned %d pages, want %d", pages, tt.wantPages)
+			}
+
+			for customerID := range balances {
+				var account LoyaltyAccount
+				if err := json.Unmarshal(ctx.ledgerState(accountObjectType, customerID), &account); err != nil {
+					t.Fatalf("account %s: %v", customerID, err)
+				}
+				if account.SchemaVersion != accountSchemaVersion || account.Status != "ACTIVE" {
+					t.Errorf("account %s = schema %d, status %q; want schema %d, ACTIVE", customerID, account.SchemaVersion, account.Status, accountSchemaVersion)
+				}
+			}
+			for i := 0; i < tt.legacy; i++ {
+				customerID := fmt.Sprintf("OLD%02d", i)
+				if ctx.stub.State[customerID] != nil || ctx.ledgerState(accountObjectType, customerID) == nil {
+					t.Errorf("legacy account %s was not moved to its composite key", customerID)
+				}
+			}
+
+			// Sau khi di chuyển không còn tài khoản nào cần ghi lại
+			ctx.mustTx(testTime, func() error {
+				page, err := admin.PlanAccountMigration(ctx, "", maxMigrationPageSize)
+				if err == nil && len(page.CustomerIDs) != 0 {
+					t.Errorf("accounts still to migrate: %v", page.CustomerIDs)
+				}
+				return err
+			})
+		})
+	}
+}
+
+func TestPlanAccountMigrationListsUnindexedPolicies(t *testing.T) {
+	ctx := newTestContext(t)
+	admin := &AdminContract{}
+	ctx.setupAccounts(testTime, map[string]int64{"ALICE": 0, "BOB": 0})
+	ctx.mustTx(testTime, func() error {
+		_, err := admin.SetAccountPolicy(ctx, "ALICE", []string{"BankOrgMSP", "MerchantOrgMSP"})
+		return err
+	})
+	// Chính sách được đặt trước khi có chỉ mục accountPolicy
+	ctx.mustTx(testTime, func() error {
+		key, err := ledgerKey(ctx, accountPolicyObjectType, "ALICE")
+		if err != nil {
+			return err
+		}
+		return ctx.GetStub().DelState(key)
+	})
+
+	var page *AccountMigrationPage
+	ctx.mustTx(testTime, func() error {
+		var err error
+		page, err = admin.PlanAccountMigration(ctx, "", 0)
+		return err
+	})
+	if len(page.CustomerIDs) != 1 || page.CustomerIDs[0] != "ALICE" {
+		t.Fatalf("accounts to migrate = %v, want [ALICE]", page.CustomerIDs)
+	}
+	ctx.mustTx(testTime, func() error {
+		_, err := admin.MigrateAccounts(ctx, page.CustomerIDs)
+		return err
+	})
+	var orgs []string
+	ctx.mustTx(testTime, func() error {
+		var err error
+		orgs, err = (&AccountContract{}).GetPolicyOrgs(ctx)
+		return err
+	})
+	if len(orgs) != 2 {
+		t.Errorf("GetPolicyOrgs = %v, want [BankOrgMSP MerchantOrgMSP]", orgs)
+	}
+}
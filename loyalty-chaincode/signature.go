@@ -0,0 +1,289 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxAuthorizationLifetime giới hạn thời hạn của một ủy quyền chuyển điểm tính từ thời điểm giao dịch
+const maxAuthorizationLifetime = 24 * time.Hour
+
+// Loại thao tác được ủy quyền; mỗi loại có dòng đầu payload riêng nên chữ ký cho loại này không dùng được cho loại khác
+const (
+	authorizationTransfer              = "TRANSFER"
+	authorizationGift                  = "GIFT"
+	authorizationSwap                  = "SWAP"
+	authorizationHouseholdContribution = "HOUSEHOLD_CONTRIBUTION"
+	authorizationVoucherTransfer       = "VOUCHER_TRANSFER"
+)
+
+// noncePattern giới hạn nonce: 16-64 ký tự chữ, số, gạch ngang hoặc gạch dưới
+var noncePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{16,64}$`)
+
+// TransferAuthorization định nghĩa ủy quyền chuyển điểm đã được khách hàng ký, lưu theo nonce để chống phát lại
+// và làm bằng chứng khách hàng đã đồng ý
+
+
+type TransferAuthorization struct {
+	CustomerID    string            `json:"customerID"` // tài khoản nguồn, người ký
+	Nonce         string            `json:"nonce"`
+	Operation     string            `json:"operation,omitempty"` // TRANSFER, GIFT, SWAP, HOUSEHOLD_CONTRIBUTION, VOUCHER_TRANSFER; rỗng ở bản ghi cũ = TRANSFER
+	TargetID      string            `json:"targetID"`            // tài khoản đích, hash của quà tặng, swapID, householdID hoặc voucherID
+	Amount        int64             `json:"amount"`
+	Currency      string            `json:"currency"`
+	ExpiresAt     string            `json:"expiresAt"`
+	Details       map[string]string `json:"details,omitempty"` // các trường riêng của thao tác đã được ký, ví dụ người nhận của SWAP
+	Signature     string            `json:"signature"`         // base64 (chuẩn) của chữ ký trên payload chuẩn, xem transferPayload
+	TransactionID string            `json:"transactionID"`
+	Timestamp     string            `json:"timestamp"`
+	SchemaVersion int               `json:"schemaVersion"`
+}
+
+// =========================================================================================
+// UC-028: Ủy quyền chuyển điểm do khách hàng ký
+// Backend dùng một identity quản trị để gửi giao dịch thay mọi khách hàng, nên chữ ký của khách hàng
+// là bằng chứng trên sổ cái rằng khách hàng đã đồng ý chuyển điểm.
+//
+// Logic chính:
+// 1. RegisterSigningKey: khách hàng (hoặc BankOrgMSP thay mặt khách hàng) đăng ký khóa công khai PEM
+//    (ECDSA P-256 hoặc Ed25519) vào LoyaltyAccount.SigningKey. Thay khóa đã đăng ký chỉ được thực hiện
+//    bởi quản trị viên (role=admin), ví dụ khi khách hàng mất thiết bị.
+// 2. Khi tài khoản nguồn đã có khóa, TransferPoints yêu cầu `nonce`, `expiresAt` và `signature`:
+//    - `expiresAt` (RFC3339) phải sau thời điểm giao dịch và không quá 24 giờ, nếu không -> AUTHORIZATION_EXPIRED.
+//    - Chữ ký phải hợp lệ trên payload chuẩn (transferPayload) gồm kênh, tài khoản nguồn, tài khoản đích,
+//      số điểm, đơn vị điểm, nonce và expiresAt. ECDSA ký trên SHA-256 của payload (ASN.1 DER),
+//      Ed25519 ký trực tiếp trên payload. Nếu không -> INVALID_SIGNATURE.
+//    - Nonce chưa được dùng cho tài khoản nguồn, nếu không -> NONCE_ALREADY_USED.
+// 3. Lưu TransferAuthorization dưới key transferAuth~customerID~nonce (bằng chứng và chống phát lại).
+// 4. Tài khoản chưa đăng ký khóa vẫn chuyển điểm không cần chữ ký (tương thích ngược).
+// 5. CreateGift và LockForSwap cũng yêu cầu ủy quyền như bước 2-3, với dòng đầu payload riêng
+//    và `target` là hash của quà tặng hoặc swapID. Payload của SWAP có thêm các dòng `recipient`,
+//    `hashLock` (hex chữ thường) và `timeLock` (RFC3339 UTC) sau `expiresAt`, nên chữ ký không dùng được
+//    cho một người nhận hoặc một khóa khác.
+// 6. Phần đóng góp của thành viên (khác chủ hộ) trong PooledRedeemReward yêu cầu ủy quyền HOUSEHOLD_CONTRIBUTION
+//    với `target` là householdID, `amount` là số điểm đóng góp và thêm các dòng `reward`, `owner`.
+//    TransferVoucher yêu cầu ủy quyền VOUCHER_TRANSFER của chủ sở hữu với `target` là voucherID,
+//    `amount=0`, `currency` rỗng và thêm dòng `recipient`.
+// =========================================================================================
+func (s *AccountContract) RegisterSigningKey(ctx contractapi.TransactionContextInterface, customerID string, publicKeyPEM string) (*LoyaltyAccount, error) {
+	// 1. Kiểm tra khóa công khai
+	if _, err := parseSigningKey(publicKeyPEM); err != nil {
+		return nil, err
+	}
+	if err := requireActingFor(ctx, customerID); err != nil {
+		return nil, err
+	}
+	account, err := getAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(account); err != nil {
+		return nil, err
+	}
+	if account.SigningKey != "" {
+		if err := requireRole(ctx, "admin"); err != nil {
+			return nil, errAccessDenied("account '%s' already has a signing key; only an admin can replace it", customerID)
+		}
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	account.SigningKey = strings.TrimSpace(publicKeyPEM)
+	account.LastUpdated = now.Format(time.RFC3339)
+	if err := putAccount(ctx, account); err != nil {
+		return nil, err
+	}
+
+	eventJSON, err := json.Marshal(map[string]interface{}{"customerID": customerID, "timestamp": account.LastUpdated})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signing key event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent("RegisterSigningKeyEvent", eventJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for signing key: %v", err)
+	}
+	return account, nil
+}
+
+// GetTransferAuthorization trả về ủy quyền chuyển điểm đã dùng của một khách hàng theo nonce
+func (s *AccountContract) GetTransferAuthorization(ctx contractapi.TransactionContextInterface, customerID string, nonce string) (*TransferAuthorization, error) {
+	authorizationKey, err := ledgerKey(ctx, transferAuthObjectType, customerID, nonce)
+	if err != nil {
+		return nil, err
+	}
+	authorizationJSON, err := ctx.GetStub().GetState(authorizationKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transfer authorization: %v", err)
+	}
+	if authorizationJSON == nil {
+		return nil, newChaincodeError(ErrCodeAuthorizationNotFound, map[string]interface{}{"customerID": customerID, "nonce": nonce},
+			"no transfer authorization with nonce '%s' for '%s'", nonce, customerID)
+	}
+	var authorization TransferAuthorization
+	if err := json.Unmarshal(authorizationJSON, &authorization); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transfer authorization: %v", err)
+	}
+	return &authorization, nil
+}
+
+// transferPayload tạo payload chuẩn mà khách hàng ký để ủy quyền một thao tác (xem UC-028).
+// Dòng đầu là "LOYALTY-<operation>-V1"; mỗi dòng sau là "tên=giá trị", kết thúc bằng ký tự xuống dòng; không có khoảng trắng thừa.
+// `details` là các trường riêng của thao tác, được ghi theo thứ tự sau `expiresAt`.
+func transferPayload(operation, channelID, sourceCustomerID, targetID string, amount int64, currency, nonce, expiresAt string, details ...[2]string) []byte {
+	var payload strings.Builder
+	payload.WriteString("LOYALTY-" + operation + "-V1\n")
+	for _, field := range [][2]string{
+		{"channel", channelID},
+		{"source", sourceCustomerID},
+		{"target", targetID},
+		{"amount", strconv.FormatInt(amount, 10)},
+		{"currency", currency},
+		{"nonce", nonce},
+		{"expiresAt", expiresAt},
+	} {
+		payload.WriteString(field[0] + "=" + field[1] + "\n")
+	}
+	for _, field := range details {
+		payload.WriteString(field[0] + "=" + field[1] + "\n")
+	}
+	return []byte(payload.String())
+}
+
+// verifyTransferAuthorization kiểm tra chữ ký của khách hàng trên một thao tác và ghi lại nonce (UC-028, bước 2-6).
+// `targetID` là tài khoản đích, hash của quà tặng, swapID, householdID hoặc voucherID tùy `operation`; `currency` đã được chuẩn hóa;
+// `details` là các trường riêng của thao tác (xem transferPayload). Tài khoản chưa đăng ký khóa không cần chữ ký.
+func verifyTransferAuthorization(ctx contractapi.TransactionContextInterface, operation string, source *LoyaltyAccount, targetID string, amount int64, currency, nonce, expiresAt, signature string, details ...[2]string) error {
+	// 4. Tài khoản chưa đăng ký khóa
+	if source.SigningKey == "" {
+		if nonce != "" || signature != "" {
+			return errInvalidArgument("account '%s' has no registered signing key", source.CustomerID)
+		}
+		return nil
+	}
+
+	// 2. Thời hạn, chữ ký và nonce
+	if signature == "" {
+		return newChaincodeError(ErrCodeInvalidSignature, map[string]interface{}{"customerID": source.CustomerID},
+			"%s from '%s' requires an authorization signed with the registered key", operation, source.CustomerID)
+	}
+	if !noncePattern.MatchString(nonce) {
+		return errInvalidArgument("nonce must be 16-64 letters, digits, '-' or '_', got: %q", nonce)
+	}
+	expiry, err := ParseTimestamp(expiresAt)
+	if err != nil {
+		return errInvalidArgument("expiresAt must be an RFC3339 timestamp, got: %q", expiresAt)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if !expiry.After(now) || expiry.Sub(now) > maxAuthorizationLifetime {
+		return newChaincodeError(ErrCodeAuthorizationExpired, map[string]interface{}{"expiresAt": expiresAt, "now": now.Format(time.RFC3339)},
+			"transfer authorization must expire within %s of the transaction, got: %s", maxAuthorizationLifetime, expiresAt)
+	}
+	publicKey, err := parseSigningKey(source.SigningKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse signing key of account '%s': %v", source.CustomerID, err)
+	}
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return newChaincodeError(ErrCodeInvalidSignature, map[string]interface{}{"customerID": source.CustomerID}, "signature must be base64 encoded")
+	}
+	payload := transferPayload(operation, ctx.GetStub().GetChannelID(), source.CustomerID, targetID, amount, currency, nonce, expiresAt, details...)
+	if !verifySignature(publicKey, payload, signatureBytes) {
+		return newChaincodeError(ErrCodeInvalidSignature, map[string]interface{}{"customerID": source.CustomerID},
+			"signature does not match the %s authorization of '%s'", operation, source.CustomerID)
+	}
+
+	authorizationKey, err := ledgerKey(ctx, transferAuthObjectType, source.CustomerID, nonce)
+	if err != nil {
+		return err
+	}
+	existing, err := ctx.GetStub().GetState(authorizationKey)
+	if err != nil {
+		return fmt.Errorf("failed to read transfer authorization: %v", err)
+	}
+	if existing != nil {
+		return newChaincodeError(ErrCodeNonceAlreadyUsed, map[string]interface{}{"customerID": source.CustomerID, "nonce": nonce},
+			"nonce '%s' was already used by '%s'", nonce, source.CustomerID)
+	}
+
+	// 3. Bằng chứng và chống phát lại
+	var signedDetails map[string]string
+	if len(details) > 0 {
+		signedDetails = make(map[string]string, len(details))
+		for _, field := range details {
+			signedDetails[field[0]] = field[1]
+		}
+	}
+	authorization := TransferAuthorization{
+		CustomerID:    source.CustomerID,
+		Nonce:         nonce,
+		Operation:     operation,
+		TargetID:      targetID,
+		Amount:        amount,
+		Currency:      currency,
+		ExpiresAt:     expiresAt,
+		Details:       signedDetails,
+		Signature:     signature,
+		TransactionID: ctx.GetStub().GetTxID(),
+		Timestamp:     now.Format(time.RFC3339),
+		SchemaVersion: transferAuthSchemaVersion,
+	}
+	authorizationJSON, err := json.Marshal(authorization)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transfer authorization: %v", err)
+	}
+	if err := ctx.GetStub().PutState(authorizationKey, authorizationJSON); err != nil {
+		return fmt.Errorf("failed to put state for transfer authorization: %v", err)
+	}
+	return nil
+}
+
+// parseSigningKey giải mã khóa công khai PEM (PKIX) của khách hàng: ECDSA P-256 hoặc Ed25519
+func parseSigningKey(publicKeyPEM string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(publicKeyPEM)))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errInvalidArgument("signing key must be a PEM encoded PUBLIC KEY")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errInvalidArgument("failed to parse signing key: %v", err)
+	}
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, errInvalidArgument("ECDSA signing keys must use the P-256 curve")
+		}
+	case ed25519.PublicKey:
+	default:
+		return nil, errInvalidArgument("signing key must be an ECDSA P-256 or Ed25519 key, got %T", publicKey)
+	}
+	return publicKey, nil
+}
+
+// verifySignature kiểm tra chữ ký ECDSA (ASN.1 DER trên SHA-256 của payload) hoặc Ed25519
+func verifySignature(publicKey interface{}, payload, signature []byte) bool {
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(payload)
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, payload, signature)
+	}
+	return false
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSigningKey tạo một khóa Ed25519 và trả về khóa công khai PEM cùng hàm ký payload (base64)
+func newTestSigningKey(t *testing.T) (string, func(payload []byte) string) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER}))
+	return publicKeyPEM, func(payload []byte) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, payload))
+	}
+}
+
+// swapTestDetails là các trường riêng của ủy quyền SWAP cho khoản khóa SWAP1 của ALICE trong các test
+func swapTestDetails(recipientID string) [][2]string {
+	return [][2]string{
+		{"recipient", recipientID},
+		{"hashLock", giftHash("swap")},
+		{"timeLock", testTime.Add(24 * time.Hour).Format(time.RFC3339)},
+	}
+}
+
+func TestSignedAuthorizations(t *testing.T) {
+	publicKeyPEM, signPayload := newTestSigningKey(t)
+
+	const nonce = "nonce-0123456789abcdef"
+	validUntil := testTime.Add(10 * time.Minute).Format(time.RFC3339)
+	sign := func(operation, targetID, expiresAt string) string {
+		var details [][2]string
+		if operation == authorizationSwap {
+			details = swapTestDetails("BOB")
+		}
+		return signPayload(transferPayload(operation, "loyaltychannel", "ALICE", targetID, 100, DefaultCurrency, nonce, expiresAt, details...))
+	}
+
+	operations := []struct {
+		name      string
+		operation string
+		targetID  string // target trong payload đã ký
+		replayErr string // lỗi khi gửi lại cùng ủy quyền
+		run       func(ctx *testContext, nonce, expiresAt, signature string) error
+	}{
+		{
+			name:      "TransferPoints",
+			operation: authorizationTransfer,
+			targetID:  "BOB",
+			replayErr: ErrCodeNonceAlreadyUsed,
+			run: func(ctx *testContext, nonce, expiresAt, signature string) error {
+				_, err := (&AccountContract{}).TransferPoints(ctx, "ALICE", "BOB", 100, "gift", "", nonce, expiresAt, signature)
+				return err
+			},
+		},
+		{
+			name:      "CreateGift",
+			operation: authorizationGift,
+			targetID:  giftHash("signed"),
+			replayErr: ErrCodeGiftAlreadyExists,
+			run: func(ctx *testContext, nonce, expiresAt, signature string) error {
+				_, err := (&AccountContract{}).CreateGift(ctx, "ALICE", 100, giftHash("signed"), testTime.AddDate(0, 0, 7).Format(time.RFC3339), nonce, expiresAt, signature)
+				return err
+			},
+		},
+		{
+			name:      "LockForSwap",
+			operation: authorizationSwap,
+			targetID:  "SWAP1",
+			replayErr: ErrCodeSwapAlreadyExists,
+			run: func(ctx *testContext, nonce, expiresAt, signature string) error {
+				_, err := (&SwapContract{}).LockForSwap(ctx, "SWAP1", "ALICE", "BOB", 100, giftHash("swap"), testTime.Add(24*time.Hour).Format(time.RFC3339), nonce, expiresAt, signature)
+				return err
+			},
+		},
+	}
+	tests := []struct {
+		name      string
+		noKey     bool
+		nonce     string
+		expiresAt string
+		signature func(operation, targetID string) string // nil = không ký
+		replay    bool                                    // gửi lại cùng ủy quyền sau lần đầu thành công
+		wantErr   string
+	}{
+		{name: "account without a key", noKey: true},
+		{name: "account without a key rejects a signature", noKey: true, nonce: nonce, expiresAt: validUntil,
+			signature: func(operation, targetID string) string { return sign(operation, targetID, validUntil) }, wantErr: ErrCodeInvalidArgument},
+		{name: "missing signature", wantErr: ErrCodeInvalidSignature},
+		{name: "valid signature", nonce: nonce, expiresAt: validUntil,
+			signature: func(operation, targetID string) string { return sign(operation, targetID, validUntil) }},
+		{name: "signature for another target", nonce: nonce, expiresAt: validUntil,
+			signature: func(operation, targetID string) string { return sign(operation, "CAROL", validUntil) }, wantErr: ErrCodeInvalidSignature},
+		{name: "signature for another operation", nonce: nonce, expiresAt: validUntil,
+			signature: func(operation, targetID string) string {
+				other := authorizationTransfer
+				if operation == authorizationTransfer {
+					other = authorizationGift
+				}
+				return sign(other, targetID, validUntil)
+			}, wantErr: ErrCodeInvalidSignature},
+		{name: "expired authorization", nonce: nonce, expiresAt: testTime.Format(time.RFC3339),
+			signature: func(operation, targetID string) string {
+				return sign(operation, targetID, testTime.Format(time.RFC3339))
+			}, wantErr: ErrCodeAuthorizationExpired},
+		{name: "replayed authorization", nonce: nonce, expiresAt: validUntil, replay: true,
+			signature: func(operation, targetID string) string { return sign(operation, targetID, validUntil) }},
+	}
+
+	for _, op := range operations {
+		for _, tt := range tests {
+			t.Run(op.name+"/"+tt.name, func(t *testing.T) {
+				ctx := newTestContext(t)
+				ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000, "BOB": 0})
+				if !tt.noKey {
+					ctx.mustTx(testTime, func() error {
+						_, err := (&AccountContract{}).RegisterSigningKey(ctx, "ALICE", publicKeyPEM)
+						return err
+					})
+				}
+				signature := ""
+				if tt.signature != nil {
+					signature = tt.signature(op.operation, op.targetID)
+				}
+
+				if tt.replay {
+					ctx.mustTx(testTime, func() error { return op.run(ctx, tt.nonce, tt.expiresAt, signature) })
+				}
+				wantErr := tt.wantErr
+				if tt.replay {
+					wantErr = op.replayErr
+				}
+				err := ctx.tx(testTime, func() error { return op.run(ctx, tt.nonce, tt.expiresAt, signature) })
+				checkErrorCode(t, err, wantErr)
+
+				wantBalance := int64(1000 - 100)
+				if wantErr != "" && !tt.replay {
+					wantBalance = 1000
+				}
+				if got := ctx.balance("ALICE"); got != wantBalance {
+					t.Errorf("balance of ALICE = %d, want %d", got, wantBalance)
+				}
+				if wantErr == "" && tt.nonce != "" {
+					authorization := ctx.ledgerState(transferAuthObjectType, "ALICE", tt.nonce)
+					if authorization == nil {
+						t.Errorf("authorization %s was not recorded", tt.nonce)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSwapAuthorizationBindsLock(t *testing.T) {
+	// ALICE ký ủy quyền khóa 100 điểm cho SWAP1 với người nhận BOB, hash giftHash("swap") và thời hạn 24 giờ
+	publicKeyPEM, signPayload := newTestSigningKey(t)
+	const nonce = "nonce-0123456789abcdef"
+	validUntil := testTime.Add(10 * time.Minute).Format(time.RFC3339)
+	signature := signPayload(transferPayload(authorizationSwap, "loyaltychannel", "ALICE", "SWAP1", 100, DefaultCurrency, nonce, validUntil, swapTestDetails("BOB")...))
+
+	tests := []struct {
+		name        string
+		recipientID string
+		hashLock    string
+		timeLock    time.Time
+		wantErr     string
+	}{
+		{name: "signed lock", recipientID: "BOB", hashLock: giftHash("swap"), timeLock: testTime.Add(24 * time.Hour)},
+		{name: "upper-case hash lock", recipientID: "BOB", hashLock: strings.ToUpper(giftHash("swap")), timeLock: testTime.Add(24 * time.Hour)},
+		{name: "recipient swapped", recipientID: "MALLORY", hashLock: giftHash("swap"), timeLock: testTime.Add(24 * time.Hour), wantErr: ErrCodeInvalidSignature},
+		{name: "hash lock swapped", recipientID: "BOB", hashLock: giftHash("mallory"), timeLock: testTime.Add(24 * time.Hour), wantErr: ErrCodeInvalidSignature},
+		{name: "time lock changed", recipientID: "BOB", hashLock: giftHash("swap"), timeLock: testTime.Add(7 * 24 * time.Hour), wantErr: ErrCodeInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000, "BOB": 0, "MALLORY": 0})
+			ctx.mustTx(testTime, func() error {
+				_, err := (&AccountContract{}).RegisterSigningKey(ctx, "ALICE", publicKeyPEM)
+				return err
+			})
+
+			err := ctx.tx(testTime, func() error {
+				_, err := (&SwapContract{}).LockForSwap(ctx, "SWAP1", "ALICE", tt.recipientID, 100, tt.hashLock, tt.timeLock.Format(time.RFC3339), nonce, validUntil, signature)
+				return err
+			})
+			checkErrorCode(t, err, tt.wantErr)
+			if err != nil {
+				if ctx.ledgerState(swapObjectType, "SWAP1") != nil {
+					t.Errorf("swap was locked without a matching authorization")
+				}
+				return
+			}
+			var authorization *TransferAuthorization
+			ctx.mustTx(testTime, func() error {
+				var err error
+				authorization, err = (&AccountContract{}).GetTransferAuthorization(ctx, "ALICE", nonce)
+				return err
+			})
+			if authorization.Details["recipient"] != "BOB" || authorization.Details["hashLock"] != giftHash("swap") {
+				t.Errorf("authorization details = %v, want the signed recipient and hash lock", authorization.Details)
+			}
+		})
+	}
+}
+
+func TestPointsLeavingWithoutSignature(t *testing.T) {
+	// ALICE đã đăng ký khóa; MALLORY lập hộ, mời ALICE và để BankOrgMSP đồng ý thay ALICE,
+	// rồi dùng 100 điểm của ALICE quy đổi COFFEE (voucher về MALLORY). ALICE cũng có một voucher COFFEE.
+	publicKeyPEM, signPayload := newTestSigningKey(t)
+	const nonce = "nonce-0123456789abcdef"
+	validUntil := testTime.Add(10 * time.Minute).Format(time.RFC3339)
+	contributionSignature := func(rewardID, ownerID string) string {
+		return signPayload(transferPayload(authorizationHouseholdContribution, "loyaltychannel", "ALICE", "HOME", 100, DefaultCurrency, nonce, validUntil,
+			[2]string{"reward", rewardID}, [2]string{"owner", ownerID}))
+	}
+	voucherSignature := func(voucherID, recipientID string) string {
+		return signPayload(transferPayload(authorizationVoucherTransfer, "loyaltychannel", "ALICE", voucherID, 0, "", nonce, validUntil,
+			[2]string{"recipient", recipientID}))
+	}
+
+	tests := []struct {
+		name      string
+		voucher   bool // chuyển voucher của ALICE cho MALLORY thay vì quy đổi chung
+		signature func(voucherID string) string
+		wantErr   string
+	}{
+		{name: "pooled redemption without the member's signature", wantErr: ErrCodeInvalidSignature},
+		{name: "pooled redemption signed by the member", signature: func(string) string { return contributionSignature("COFFEE", "MALLORY") }},
+		{name: "pooled redemption signed for another reward", signature: func(string) string { return contributionSignature("TEA", "MALLORY") }, wantErr: ErrCodeInvalidSignature},
+		{name: "pooled redemption signed for another owner", signature: func(string) string { return contributionSignature("COFFEE", "BOB") }, wantErr: ErrCodeInvalidSignature},
+		{name: "voucher transfer without the owner's signature", voucher: true, wantErr: ErrCodeInvalidSignature},
+		{name: "voucher transfer signed by the owner", voucher: true, signature: func(voucherID string) string { return voucherSignature(voucherID, "MALLORY") }},
+		{name: "voucher transfer signed for another recipient", voucher: true, signature: func(voucherID string) string { return voucherSignature(voucherID, "BOB") }, wantErr: ErrCodeInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			accounts := &AccountContract{}
+			rewards := &RewardContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000, "BOB": 0, "MALLORY": 0})
+			voucher := issueTestVoucher(ctx, "ALICE")
+			ctx.mustTx(testTime, func() error {
+				_, err := accounts.RegisterSigningKey(ctx, "ALICE", publicKeyPEM)
+				return err
+			})
+			for _, step := range []func() error{
+				func() error { _, err := accounts.CreateHousehold(ctx, "HOME", "MALLORY", 100, 0); return err },
+				func() error { _, err := accounts.InviteHouseholdMember(ctx, "HOME", "ALICE"); return err },
+				func() error { _, err := accounts.AcceptHouseholdInvite(ctx, "HOME", "ALICE"); return err },
+			} {
+				ctx.mustTx(testTime, step)
+			}
+
+			nonceArg, expiresAt, signature := "", "", ""
+			if tt.signature != nil {
+				nonceArg, expiresAt, signature = nonce, validUntil, tt.signature(voucher.VoucherID)
+			}
+			err := ctx.tx(testTime, func() error {
+				if tt.voucher {
+					_, err := rewards.TransferVoucher(ctx, voucher.VoucherID, "MALLORY", nonceArg, expiresAt, signature)
+					return err
+				}
+				authorizationsJSON := ""
+				if signature != "" {
+					authorizationsJSON = `{"ALICE": {"nonce": "` + nonceArg + `", "expiresAt": "` + expiresAt + `", "signature": "` + signature + `"}}`
+				}
+				_, err := rewards.PooledRedeemReward(ctx, "HOME", "COFFEE", `{"ALICE": 100}`, authorizationsJSON)
+				return err
+			})
+			checkErrorCode(t, err, tt.wantErr)
+
+			var malloryVouchers []*Voucher
+			ctx.mustTx(testTime, func() error {
+				var err error
+				malloryVouchers, err = rewards.GetVouchersByOwner(ctx, "MALLORY")
+				return err
+			})
+			wantBalance, wantVouchers := int64(900), 0
+			if tt.wantErr == "" {
+				wantVouchers = 1
+				if !tt.voucher {
+					wantBalance = 800
+				}
+			}
+			if got := ctx.balance("ALICE"); got != wantBalance {
+				t.Errorf("balance of ALICE = %d, want %d", got, wantBalance)
+			}
+			if len(malloryVouchers) != wantVouchers {
+				t.Errorf("MALLORY owns %d vouchers, want %d", len(malloryVouchers), wantVouchers)
+			}
+		})
+	}
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	LeaderboardMetricEarned   = "EARNED"
+	LeaderboardMetricRedeemed = "REDEEMED"
+	LeaderboardPeriodAll      = "ALL"
+
+	// countedAccountSchemaVersion là phiên bản schema tài khoản đầu tiên đã được tính vào thống kê;
+	// bản ghi cũ hơn được tính khi ghi lại lần đầu (MigrateAccounts ghi lại tất cả)
+	countedAccountSchemaVersion = 3
+
+	// defaultStatsPeriodDays áp dụng khi GetProgramStats được gọi không có ngày bắt đầu
+	defaultStatsPeriodDays = 30
+	// maxStatsPeriodDays giới hạn số bộ đếm ngày được đọc trong một lần truy vấn
+	maxStatsPeriodDays = 366
+	// maxLeaderboardLimit giới hạn số khách hàng trả về trong một bảng xếp hạng
+	maxLeaderboardLimit = 100
+	// defaultLeaderboardLimit áp dụng khi GetLeaderboard được gọi với limit <= 0
+	defaultLeaderboardLimit = 10
+
+	statsDateLayout  = "2006-01-02"
+	statsMonthLayout = "2006-01"
+)
+
+// leaderboardMetrics là các chỉ số có bảng xếp hạng
+var leaderboardMetrics = map[string]bool{LeaderboardMetricEarned: true, LeaderboardMetricRedeemed: true}
+
+// AccountStats định nghĩa số tài khoản theo trạng thái và hạng, cập nhật khi ghi tài khoản
+type AccountStats struct {
+	Total         int            `json:"total"`
+	ByStatus      map[string]int `json:"byStatus"`
+	ByTier        map[string]int `json:"byTier"`
+	SchemaVersion int            `json:"schemaVersion"`
+}
+
+// DailyTotals định nghĩa số điểm POINTS phát hành và quy đổi trong một ngày (UTC)
+type DailyTotals struct {
+	Date          string `json:"date"`
+	Issued        int64  `json:"issued"`
+	Redeemed      int64  `json:"redeemed"`
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+// ProgramStats định nghĩa thống kê chương trình trả về bởi GetProgramStats
+type ProgramStats struct {
+	Accounts    *AccountStats `json:"accounts"`
+	TotalSupply int64         `json:"totalSupply"` // số điểm POINTS đang lưu hành theo bộ đếm, xem supply.go
+	From        string        `json:"from"`        // ngày bắt đầu (bao gồm), dạng 2006-01-02
+	To          string        `json:"to"`          // ngày kết thúc (bao gồm)
+	Issued      int64         `json:"issued"`      // số điểm phát hành trong khoảng thời gian
+	Redeemed    int64         `json:"redeemed"`    // số điểm quy đổi trong khoảng thời gian
+}
+
+// LeaderboardEntry định nghĩa một dòng của bảng xếp hạng
+type LeaderboardEntry struct {
+	Rank       int    `json:"rank"`
+	CustomerID string `json:"customerID"`
+	Score      int64  `json:"score"`
+}
+
+// Leaderboard định nghĩa bảng xếp hạng của một chỉ số trong một kỳ
+type Leaderboard struct {
+	Metric  string              `json:"metric"`
+	Period  string              `json:"period"` // ALL hoặc tháng dạng 2006-01
+	Entries []*LeaderboardEntry `json:"entries"`
+}
+
+// =========================================================================================
+// UC-031: Thống kê chương trình và bảng xếp hạng
+// Các truy vấn chỉ đọc các số liệu tổng hợp được cập nhật khi ghi, không duyệt toàn bộ tài khoản.
+//
+// Logic chính:
+// 1. Mỗi lần ghi tài khoản (putAccount), so sánh với bản ghi đang lưu:
+//    - trạng thái hoặc hạng thay đổi (hoặc tài khoản chưa được tính) -> ghi phần số liệu accountStatsShard,
+//      CompactCounters gộp các phần của những ngày đã kết thúc vào stats~accounts;
+//    - LifetimeEarned/LifetimeRedeemed thay đổi -> cập nhật bảng xếp hạng ALL và phần tăng thêm
+//      được cộng vào bảng xếp hạng của tháng hiện tại (UTC). Điểm chuyển sang khi gộp tài khoản
+//      không tính là hoạt động trong tháng.
+// 2. Số điểm phát hành/quy đổi của một ngày là tổng các phần bộ đếm tổng cung POINTS của ngày đó (xem supply.go);
+//    CompactCounters gộp chúng vào bộ đếm ngày stats~day~<ngày>.
+// 3. GetProgramStats: số tài khoản theo trạng thái và hạng, tổng cung và tổng phát hành/quy đổi
+//    từ `fromDate` đến `toDate` (bao gồm, tối đa 366 ngày; mặc định 30 ngày gần nhất).
+// 4. GetLeaderboard: bảng xếp hạng được lưu dưới key leaderboardRank~metric~period~<điểm đảo>~customerID,
+//    nên `limit` khách hàng đứng đầu là `limit` key đầu tiên của range query.
+// Lưu ý: tài khoản ở chế độ delta chỉ được cập nhật khi gộp delta (xem delta.go).
+// =========================================================================================
+func (s *AdminContract) GetProgramStats(ctx contractapi.TransactionContextInterface, fromDate string, toDate string) (*ProgramStats, error) {
+	// 3. Khoảng thời gian
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	to := now.UTC().Truncate(24 * time.Hour)
+	if toDate != "" {
+		if to, err = time.Parse(statsDateLayout, toDate); err != nil {
+			return nil, errInvalidArgument("invalid toDate '%s': expected YYYY-MM-DD", toDate)
+		}
+	}
+	from := to.AddDate(0, 0, 1-defaultStatsPeriodDays)
+	if fromDate != "" {
+		if from, err = time.Parse(statsDateLayout, fromDate); err != nil {
+			return nil, errInvalidArgument("invalid fromDate '%s': expected YYYY-MM-DD", fromDate)
+		}
+	}
+	if from.After(to) {
+		return nil, errInvalidArgument("fromDate must not be after toDate")
+	}
+	if days := int(to.Sub(from).Hours()/24) + 1; days > maxStatsPeriodDays {
+		return nil, errInvalidArgument("period of %d days exceeds the maximum of %d days", days, maxStatsPeriodDays)
+	}
+
+	accounts, err := getAccountStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counters, err := getSupplyCounters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats := ProgramStats{
+		Accounts:    accounts,
+		TotalSupply: counters.Outstanding(),
+		From:        from.Format(statsDateLayout),
+		To:          to.Format(statsDateLayout),
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		totals, err := getDailyTotals(ctx, day.Format(statsDateLayout))
+		if err != nil {
+			return nil, err
+		}
+		if stats.Issued, err = addPoints(stats.Issued, totals.Issued); err != nil {
+			return nil, err
+		}
+		if stats.Redeemed, err = addPoints(stats.Redeemed, totals.Redeemed); err != nil {
+			return nil, err
+		}
+	}
+	return &stats, nil
+}
+
+// GetLeaderboard trả về tối đa `limit` khách hàng có `metric` (EARNED, REDEEMED) cao nhất trong `period`
+// (rỗng hoặc ALL = toàn thời gian, hoặc tháng dạng 2006-01), xem UC-031
+func (s *AdminContract) GetLeaderboard(ctx contractapi.TransactionContextInterface, metric string, period string, limit int) (*Leaderboard, error) {
+	if !leaderboardMetrics[metric] {
+		return nil, errInvalidArgument("invalid leaderboard metric '%s': expected EARNED or REDEEMED", metric)
+	}
+	if period == "" {
+		period = LeaderboardPeriodAll
+	}
+	if period != LeaderboardPeriodAll {
+		if _, err := time.Parse(statsMonthLayout, period); err != nil {
+			return nil, errInvalidArgument("invalid leaderboard period '%s': expected ALL or YYYY-MM", period)
+		}
+	}
+	if limit <= 0 {
+		limit = defaultLeaderboardLimit
+	}
+	if limit > maxLeaderboardLimit {
+		return nil, errInvalidArgument("limit cannot exceed %d", maxLeaderboardLimit)
+	}
+
+	// 4. Key xếp hạng được sắp theo điểm giảm dần
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(leaderboardRankObjectType, []string{metric, period})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query leaderboard: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	leaderboard := Leaderboard{Metric: metric, Period: period, Entries: []*LeaderboardEntry{}}
+	for resultsIterator.HasNext() && len(leaderboard.Entries) < limit {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate leaderboard: %v", err)
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split leaderboard key: %v", err)
+		}
+		score, err := strconv.ParseInt(string(queryResult.Value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse leaderboard score: %v", err)
+		}
+		leaderboard.Entries = append(leaderboard.Entries, &LeaderboardEntry{
+			Rank:       len(leaderboard.Entries) + 1,
+			CustomerID: keyParts[3],
+			Score:      score,
+		})
+	}
+	return &leaderboard, nil
+}
+
+// updateAccountStats cập nhật số liệu tổng hợp trước khi `account` được ghi (bước 1 của UC-031).
+// `carriedEarned`/`carriedRedeemed` là điểm chuyển từ tài khoản khác, không tính là hoạt động trong tháng.
+// Bản ghi đang lưu được đọc lại nên mỗi tài khoản chỉ được ghi một lần trong một giao dịch.
+// Số tài khoản theo trạng thái và hạng được ghi vào một phần số liệu riêng, xem putAccountStatsShard.
+func updateAccountStats(ctx contractapi.TransactionContextInterface, account *LoyaltyAccount, carriedEarned int64, carriedRedeemed int64) error {
+	previousJSON, err := getAccountState(ctx, account.CustomerID)
+	if err != nil {
+		return fmt.Errorf("failed to read account from world state: %v", err)
+	}
+	var previous LoyaltyAccount
+	counted := false
+	if previousJSON != nil {
+		if err := json.Unmarshal(previousJSON, &previous); err != nil {
+			return fmt.Errorf("failed to unmarshal account data: %v", err)
+		}
+		counted = previous.SchemaVersion >= countedAccountSchemaVersion
+		upgradeAccount(&previous)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Số tài khoản theo trạng thái và hạng: thay đổi được ghi vào phần số liệu riêng của tài khoản trong giao dịch
+	if !counted || previous.Status != account.Status || previous.Tier != account.Tier {
+		shard := AccountStats{ByStatus: map[string]int{}, ByTier: map[string]int{}}
+		if counted {
+			shard.Total--
+			shard.ByStatus[previous.Status]--
+			shard.ByTier[previous.Tier]--
+		}
+		shard.Total++
+		shard.ByStatus[account.Status]++
+		shard.ByTier[account.Tier]++
+		if err := putAccountStatsShard(ctx, now, account.CustomerID, &shard); err != nil {
+			return err
+		}
+	}
+
+	// Bảng xếp hạng
+	month := now.UTC().Format(statsMonthLayout)
+	scores := []struct {
+		metric            string
+		previous, current int64
+		carried           int64
+	}{
+		{LeaderboardMetricEarned, previous.LifetimeEarned, account.LifetimeEarned, carriedEarned},
+		{LeaderboardMetricRedeemed, previous.LifetimeRedeemed, account.LifetimeRedeemed, carriedRedeemed},
+	}
+	for _, score := range scores {
+		// Toàn thời gian: điểm xếp hạng là giá trị lifetime của tài khoản
+		if !counted || score.previous != score.current {
+			previousScore := score.previous
+			if !counted {
+				previousScore = 0
+			}
+			if err := moveLeaderboardRank(ctx, score.metric, LeaderboardPeriodAll, account.CustomerID, previousScore, score.current); err != nil {
+				return err
+			}
+		}
+		// Tháng hiện tại: cộng phần tăng thêm
+		if activity := score.current - score.previous - score.carried; activity > 0 {
+			if err := addLeaderboardScore(ctx, score.metric, month, account.CustomerID, activity); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addLeaderboardScore cộng `amount` vào điểm của khách hàng trong bảng xếp hạng của một kỳ
+func addLeaderboardScore(ctx contractapi.TransactionContextInterface, metric string, period string, customerID string, amount int64) error {
+	scoreKey, err := ledgerKey(ctx, leaderboardScoreObjectType, metric, period, customerID)
+	if err != nil {
+		return err
+	}
+	scoreValue, err := ctx.GetStub().GetState(scoreKey)
+	if err != nil {
+		return fmt.Errorf("failed to read leaderboard score from world state: %v", err)
+	}
+	var previous int64
+	if scoreValue != nil {
+		if previous, err = strconv.ParseInt(string(scoreValue), 10, 64); err != nil {
+			return fmt.Errorf("failed to parse leaderboard score: %v", err)
+		}
+	}
+	current, err := addPoints(previous, amount)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(scoreKey, []byte(strconv.FormatInt(current, 10))); err != nil {
+		return fmt.Errorf("failed to put leaderboard score: %v", err)
+	}
+	return moveLeaderboardRank(ctx, metric, period, customerID, previous, current)
+}
+
+// moveLeaderboardRank thay key xếp hạng của khách hàng từ điểm `previous` sang `current`; điểm 0 không được xếp hạng
+func moveLeaderboardRank(ctx contractapi.TransactionContextInterface, metric string, period string, customerID string, previous int64, current int64) error {
+	if previous > 0 {
+		previousKey, err := leaderboardRankKey(ctx, metric, period, customerID, previous)
+		if err != nil {
+			return err
+		}
+		if err := ctx.GetStub().DelState(previousKey); err != nil {
+			return fmt.Errorf("failed to delete leaderboard rank: %v", err)
+		}
+	}
+	if current > 0 {
+		currentKey, err := leaderboardRankKey(ctx, metric, period, customerID, current)
+		if err != nil {
+			return err
+		}
+		if err := ctx.GetStub().PutState(currentKey, []byte(strconv.FormatInt(current, 10))); err != nil {
+			return fmt.Errorf("failed to put leaderboard rank: %v", err)
+		}
+	}
+	return nil
+}
+
+// leaderboardRankKey tạo key xếp hạng; điểm được đảo và thêm số 0 ở đầu để thứ tự key là thứ tự điểm giảm dần
+func leaderboardRankKey(ctx contractapi.TransactionContextInterface, metric string, period string, customerID string, score int64) (string, error) {
+	return ledgerKey(ctx, leaderboardRankObjectType, metric, period, fmt.Sprintf("%019d", math.MaxInt64-score), customerID)
+}
+
+// getDailyTotals đọc bộ đếm của một ngày: bộ đếm đã gộp stats~day~<ngày> cộng các phần bộ đếm tổng cung
+// của POINTS chưa gộp trong ngày đó (bước 2 của UC-031)
+func getDailyTotals(ctx contractapi.TransactionContextInterface, date string) (*DailyTotals, error) {
+	totals, err := getCompactedDailyTotals(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+	shards, _, err := sumSupplyShards(ctx, false, DefaultCurrency, date)
+	if err != nil {
+		return nil, err
+	}
+	if err := totals.add(shards); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// getCompactedDailyTotals đọc bộ đếm đã gộp của một ngày (tất cả bằng 0 nếu chưa có)
+func getCompactedDailyTotals(ctx contractapi.TransactionContextInterface, date string) (*DailyTotals, error) {
+	totalsKey, err := ledgerKey(ctx, statsObjectType, "day", date)
+	if err != nil {
+		return nil, err
+	}
+	totalsJSON, err := ctx.GetStub().GetState(totalsKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read daily totals from world state: %v", err)
+	}
+
+	totals := DailyTotals{Date: date}
+	if totalsJSON != nil {
+		if err := json.Unmarshal(totalsJSON, &totals); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal daily totals: %v", err)
+		}
+	}
+	return &totals, nil
+}
+
+// putCompactedDailyTotals ghi bộ đếm đã gộp của một ngày
+func putCompactedDailyTotals(ctx contractapi.TransactionContextInterface, totals *DailyTotals) error {
+	totals.SchemaVersion = dailyTotalsSchemaVersion
+	totalsKey, err := ledgerKey(ctx, statsObjectType, "day", totals.Date)
+	if err != nil {
+		return err
+	}
+	totalsJSON, err := json.Marshal(totals)
+	if err != nil {
+		return fmt.Errorf("failed to marshal daily totals: %v", err)
+	}
+	if err := ctx.GetStub().PutState(totalsKey, totalsJSON); err != nil {
+		return fmt.Errorf("failed to put state for daily totals: %v", err)
+	}
+	return nil
+}
+
+// add cộng số điểm phát hành và quy đổi của các bộ đếm tổng cung POINTS vào bộ đếm ngày
+func (t *DailyTotals) add(counters *SupplyCounters) error {
+	var err error
+	if t.Issued, err = addPoints(t.Issued, counters.TotalIssued); err != nil {
+		return err
+	}
+	t.Redeemed, err = addPoints(t.Redeemed, counters.TotalRedeemed)
+	return err
+}
+
+// getAccountStats đọc số tài khoản theo trạng thái và hạng: số liệu đã gộp stats~accounts cộng các phần
+// số liệu chưa gộp (tất cả bằng 0 nếu chưa có)
+func getAccountStats(ctx contractapi.TransactionContextInterface) (*AccountStats, error) {
+	stats, err := getCompactedAccountStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sumAccountStatsShards(ctx, stats, false); err != nil {
+		return nil, err
+	}
+	for _, counts := range []map[string]int{stats.ByStatus, stats.ByTier} {
+		for key, count := range counts {
+			if count == 0 {
+				delete(counts, key)
+			}
+		}
+	}
+	return stats, nil
+}
+
+// getCompactedAccountStats đọc số liệu tài khoản đã gộp (tất cả bằng 0 nếu chưa có)
+func getCompactedAccountStats(ctx contractapi.TransactionContextInterface) (*AccountStats, error) {
+	statsKey, err := ledgerKey(ctx, statsObjectType, "accounts")
+	if err != nil {
+		return nil, err
+	}
+	statsJSON, err := ctx.GetStub().GetState(statsKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account stats from world state: %v", err)
+	}
+
+	var stats AccountStats
+	if statsJSON != nil {
+		if err := json.Unmarshal(statsJSON, &stats); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal account stats: %v", err)
+		}
+	}
+	if stats.ByStatus == nil {
+		stats.ByStatus = map[string]int{}
+	}
+	if stats.ByTier == nil {
+		stats.ByTier = map[string]int{}
+	}
+	return &stats, nil
+}
+
+// putAccountStatsShard ghi thay đổi số liệu của một tài khoản dưới accountStatsShard~<ngày>~<txID>~<customerID>.
+// Mỗi tài khoản chỉ được ghi một lần trong một giao dịch nên key là duy nhất: các giao dịch ghi nhiều tài khoản
+// không ghi đè thay đổi của nhau, và các giao dịch đồng thời không gây MVCC_READ_CONFLICT trên stats~accounts.
+func putAccountStatsShard(ctx contractapi.TransactionContextInterface, now time.Time, customerID string, shard *AccountStats) error {
+	shard.SchemaVersion = accountStatsSchemaVersion
+	shardKey, err := ledgerKey(ctx, accountStatsShardObjectType, now.UTC().Format(statsDateLayout), ctx.GetStub().GetTxID(), customerID)
+	if err != nil {
+		return err
+	}
+	shardJSON, err := json.Marshal(shard)
+	if err != nil {
+		return fmt.Errorf("failed to marshal account stats: %v", err)
+	}
+	if err := ctx.GetStub().PutState(shardKey, shardJSON); err != nil {
+		return fmt.Errorf("failed to put state for account stats: %v", err)
+	}
+	return nil
+}
+
+// sumAccountStatsShards cộng các phần số liệu tài khoản có tiền tố key `attributes` (ngày) vào `stats`.
+// consume = true xóa các phần đã cộng (chỉ dùng trong CompactCounters).
+func sumAccountStatsShards(ctx contractapi.TransactionContextInterface, stats *AccountStats, consume bool, attributes ...string) (int, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(accountStatsShardObjectType, attributes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query account stats shards: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	shards := 0
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return 0, fmt.Errorf("failed to iterate account stats shards: %v", err)
+		}
+		var shard AccountStats
+		if err := json.Unmarshal(queryResult.Value, &shard); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal account stats shard: %v", err)
+		}
+		stats.Total += shard.Total
+		for status, count := range shard.ByStatus {
+			stats.ByStatus[status] += count
+		}
+		for tier, count := range shard.ByTier {
+			stats.ByTier[tier] += count
+		}
+		if consume {
+			if err := ctx.GetStub().DelState(queryResult.Key); err != nil {
+				return 0, fmt.Errorf("failed to delete account stats shard: %v", err)
+			}
+		}
+		shards++
+	}
+	return shards, nil
+}
+
+// compactAccountStats gộp các phần số liệu tài khoản của ngày `date` vào stats~accounts (xem CompactCounters)
+func compactAccountStats(ctx contractapi.TransactionContextInterface, date string) (int, error) {
+	stats, err := getCompactedAccountStats(ctx)
+	if err != nil {
+		return 0, err
+	}
+	shards, err := sumAccountStatsShards(ctx, stats, true, date)
+	if err != nil || shards == 0 {
+		return 0, err
+	}
+	return shards, putAccountStats(ctx, stats)
+}
+
+// putAccountStats ghi số liệu tài khoản đã gộp, bỏ các nhóm đã về 0
+func putAccountStats(ctx contractapi.TransactionContextInterface, stats *AccountStats) error {
+	for _, counts := range []map[string]int{stats.ByStatus, stats.ByTier} {
+		for key, count := range counts {
+			if count == 0 {
+				delete(counts, key)
+			}
+		}
+	}
+	stats.SchemaVersion = accountStatsSchemaVersion
+
+	statsKey, err := ledgerKey(ctx, statsObjectType, "accounts")
+	if err != nil {
+		return err
+	}
+	statsJSON, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to marshal account stats: %v", err)
+	}
+	if err := ctx.GetStub().PutState(statsKey, statsJSON); err != nil {
+		return fmt.Errorf("failed to put state for account stats: %v", err)
+	}
+	return nil
+}
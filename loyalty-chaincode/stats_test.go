@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestGetProgramStatsAccounts(t *testing.T) {
+	contract := &AccountContract{}
+	tests := []struct {
+		name     string
+		balances map[string]int64
+		legacy   int // tài khoản cũ dưới key đơn giản, được chuyển bằng MigrateState
+		run      func(ctx *testContext) error
+		want     AccountStats
+	}{
+		{
+			name:     "accounts created",
+			balances: map[string]int64{"ALICE": 0, "BOB": 12000},
+			want:     AccountStats{Total: 2, ByStatus: map[string]int{"ACTIVE": 2}, ByTier: map[string]int{"BRONZE": 1, "SILVER": 1}},
+		},
+		{
+			name:     "merge writes two accounts",
+			balances: map[string]int64{"ALICE": 6000, "BOB": 6000},
+			run: func(ctx *testContext) error {
+				_, err := contract.MergeAccounts(ctx, "ALICE", "BOB")
+				return err
+			},
+			want: AccountStats{Total: 2, ByStatus: map[string]int{"ACTIVE": 1, "CLOSED": 1}, ByTier: map[string]int{"BRONZE": 1, "SILVER": 1}},
+		},
+		{
+			name:     "transfer writes two accounts",
+			balances: map[string]int64{"ALICE": 12000, "BOB": 0},
+			run: func(ctx *testContext) error {
+				_, err := contract.TransferPoints(ctx, "ALICE", "BOB", 500, "gift", "", "", "", "")
+				return err
+			},
+			want: AccountStats{Total: 2, ByStatus: map[string]int{"ACTIVE": 2}, ByTier: map[string]int{"BRONZE": 1, "SILVER": 1}},
+		},
+		{
+			name:   "legacy accounts migrated in one transaction",
+			legacy: 4,
+			run: func(ctx *testContext) error {
+				_, err := (&AdminContract{}).MigrateState(ctx, "", 10)
+				return err
+			},
+			want: AccountStats{Total: 4, ByStatus: map[string]int{"ACTIVE": 4}, ByTier: map[string]int{"BRONZE": 4}},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, compact := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/compacted=%v", tt.name, compact), func(t *testing.T) {
+				ctx := newTestContext(t)
+				admin := &AdminContract{}
+				ctx.setupAccounts(testTime, tt.balances)
+				ctx.mustTx(testTime, func() error {
+					for i := 0; i < tt.legacy; i++ {
+						customerID := fmt.Sprintf("OLD%02d", i)
+						if err := ctx.GetStub().PutState(customerID, []byte(`{"customerID":"`+customerID+`","balance":50}`)); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+				if tt.run != nil {
+					ctx.mustTx(testTime, func() error { return tt.run(ctx) })
+				}
+				readAt := testTime.AddDate(0, 0, 1)
+				if compact {
+					ctx.mustTx(readAt, func() error {
+						_, err := admin.CompactCounters(ctx, "")
+						return err
+					})
+				}
+
+				var stats *ProgramStats
+				ctx.mustTx(readAt, func() error {
+					var err error
+					stats, err = admin.GetProgramStats(ctx, "", "")
+					return err
+				})
+				got := AccountStats{Total: stats.Accounts.Total, ByStatus: stats.Accounts.ByStatus, ByTier: stats.Accounts.ByTier}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("account stats = %+v, want %+v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestGetProgramStatsPeriod(t *testing.T) {
+	// ALICE nhận 1000 điểm ngày 05/01, 200 điểm ngày 20/01 và quy đổi 300 ngày 20/01; đọc ngày 01/02
+	tests := []struct {
+		name         string
+		from, to     string
+		wantFrom     string
+		wantIssued   int64
+		wantRedeemed int64
+		wantCode     string
+	}{
+		{name: "last 30 days by default", wantFrom: "2026-01-03", wantIssued: 1200, wantRedeemed: 300},
+		{name: "single day", from: "2026-01-20", to: "2026-01-20", wantFrom: "2026-01-20", wantIssued: 200, wantRedeemed: 300},
+		{name: "day without activity", from: "2026-01-10", to: "2026-01-10", wantFrom: "2026-01-10"},
+		{name: "from after to", from: "2026-01-20", to: "2026-01-05", wantCode: ErrCodeInvalidArgument},
+		{name: "period too long", from: "2025-01-01", to: "2026-01-20", wantCode: ErrCodeInvalidArgument},
+		{name: "invalid date", from: "20/01/2026", wantCode: ErrCodeInvalidArgument},
+	}
+
+	ctx := newTestContext(t)
+	contract := &AccountContract{}
+	ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000})
+	ctx.mustTx(testTime.AddDate(0, 0, 15), func() error {
+		_, err := contract.IssuePoints(ctx, "ALICE", 200, "sale", "")
+		return err
+	})
+	ctx.mustTx(testTime.AddDate(0, 0, 15), func() error {
+		_, err := contract.RedeemPoints(ctx, "ALICE", 300, "coffee", "")
+		return err
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats *ProgramStats
+			err := ctx.tx(testTime.AddDate(0, 0, 27), func() error {
+				var err error
+				stats, err = (&AdminContract{}).GetProgramStats(ctx, tt.from, tt.to)
+				return err
+			})
+			checkErrorCode(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+			if stats.From != tt.wantFrom || stats.Issued != tt.wantIssued || stats.Redeemed != tt.wantRedeemed || stats.TotalSupply != 900 {
+				t.Errorf("stats = from %s, issued %d, redeemed %d, supply %d; want %s, %d, %d, 900",
+					stats.From, stats.Issued, stats.Redeemed, stats.TotalSupply, tt.wantFrom, tt.wantIssued, tt.wantRedeemed)
+			}
+		})
+	}
+}
+
+func TestGetLeaderboard(t *testing.T) {
+	// Tháng 01: ALICE nhận 300, BOB 500, CAROL 100; tháng 02: CAROL nhận thêm 1000 và quy đổi 50
+	tests := []struct {
+		name     string
+		metric   string
+		period   string
+		limit    int
+		want     []string // "customerID:score" theo thứ hạng
+		wantCode string
+	}{
+		{name: "all time", metric: LeaderboardMetricEarned, want: []string{"CAROL:1100", "BOB:500", "ALICE:300"}},
+		{name: "limit", metric: LeaderboardMetricEarned, period: LeaderboardPeriodAll, limit: 2, want: []string{"CAROL:1100", "BOB:500"}},
+		{name: "month", metric: LeaderboardMetricEarned, period: "2026-01", want: []string{"BOB:500", "ALICE:300", "CAROL:100"}},
+		{name: "next month", metric: LeaderboardMetricEarned, period: "2026-02", want: []string{"CAROL:1000"}},
+		{name: "redeemed", metric: LeaderboardMetricRedeemed, want: []string{"CAROL:50"}},
+		{name: "unknown metric", metric: "SPENT", wantCode: ErrCodeInvalidArgument},
+		{name: "invalid period", metric: LeaderboardMetricEarned, period: "2026", wantCode: ErrCodeInvalidArgument},
+		{name: "limit too high", metric: LeaderboardMetricEarned, limit: maxLeaderboardLimit + 1, wantCode: ErrCodeInvalidArgument},
+	}
+
+	ctx := newTestContext(t)
+	contract := &AccountContract{}
+	ctx.setupAccounts(testTime, map[string]int64{"ALICE": 300, "BOB": 500, "CAROL": 100})
+	nextMonth := testTime.AddDate(0, 1, 0)
+	ctx.mustTx(nextMonth, func() error {
+		_, err := contract.IssuePoints(ctx, "CAROL", 1000, "sale", "")
+		return err
+	})
+	ctx.mustTx(nextMonth, func() error {
+		_, err := contract.RedeemPoints(ctx, "CAROL", 50, "coffee", "")
+		return err
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var leaderboard *Leaderboard
+			err := ctx.tx(nextMonth, func() error {
+				var err error
+				leaderboard, err = (&AdminContract{}).GetLeaderboard(ctx, tt.metric, tt.period, tt.limit)
+				return err
+			})
+			checkErrorCode(t, err, tt.wantCode)
+			if err != nil {
+				return
+			}
+			got := []string{}
+			for i, entry := range leaderboard.Entries {
+				if entry.Rank != i+1 {
+					t.Errorf("entry %d has rank %d", i, entry.Rank)
+				}
+				got = append(got, fmt.Sprintf("%s:%d", entry.CustomerID, entry.Score))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("leaderboard = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
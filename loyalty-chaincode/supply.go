@@ -0,0 +1,454 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+const (
+	// defaultReconcilePageSize áp dụng khi ReconcileSupply được gọi với pageSize <= 0
+	defaultReconcilePageSize = 100
+)
+
+// SupplyCounters định nghĩa các bộ đếm toàn cục về lượng điểm phát hành và thu hồi.
+// Không có bộ đếm phí chuyển điểm: TransferPoints không thu phí (mức phí trong GetTierBenefits chỉ để hiển thị),
+// điểm chuyển giữa các tài khoản không làm thay đổi tổng cung.
+type SupplyCounters struct {
+	TotalIssued   int64 `json:"totalIssued"`
+	TotalRedeemed int64 `json:"totalRedeemed"`
+	TotalExpired  int64 `json:"totalExpired"`
+	TotalAdjusted int64 `json:"totalAdjusted"` // tổng có dấu của các điều chỉnh số dư (AdjustBalance)
+	SchemaVersion int   `json:"schemaVersion"`
+}
+
+// Outstanding trả về số điểm đang lưu hành theo bộ đếm: phát hành - quy đổi - hết hạn + điều chỉnh
+func (c *SupplyCounters) Outstanding() int64 {
+	return c.TotalIssued - c.TotalRedeemed - c.TotalExpired + c.TotalAdjusted
+}
+
+// SupplyReconciliation định nghĩa kết quả đối soát giữa bộ đếm và tổng số dư các tài khoản
+type SupplyReconciliation struct {
+	Counters            *SupplyCounters `json:"counters"`
+	ExpectedOutstanding int64           `json:"expectedOutstanding"`
+	ActualOutstanding   int64           `json:"actualOutstanding"` // gồm cả delta chưa gộp
+	PendingDeltas       int64           `json:"pendingDeltas"`     // tổng điểm trong các BalanceDelta chưa gộp
+	EscrowedGifts       int64           `json:"escrowedGifts"`     // tổng điểm đang giữ trong các quà tặng chờ nhận
+	LockedSwaps         int64           `json:"lockedSwaps"`       // tổng điểm đang khóa trong các giao dịch hoán đổi (HTLC)
+	HeldTransfers       int64           `json:"heldTransfers"`     // tổng điểm đang giữ trong các giao dịch chuyển chờ duyệt
+	Difference          int64           `json:"difference"`
+	AccountsScanned     int             `json:"accountsScanned"`
+	PagesScanned        int             `json:"pagesScanned"`
+	NegativeAccounts    []string        `json:"negativeAccounts"`
+	Balanced            bool            `json:"balanced"`
+}
+
+// CounterCompaction định nghĩa kết quả gộp các phần bộ đếm của một ngày
+type CounterCompaction struct {
+	Date         string `json:"date"`
+	SupplyShards int    `json:"supplyShards"` // số phần bộ đếm tổng cung đã gộp
+
+	AccountStatsShards   int `json:"accountStatsShards"`   // số phần số liệu tài khoản đã gộp, xem stats.go
+	CampaignBudgetShards int `json:"campaignBudgetShards"` // số phần ngân sách chương trình đã gộp, xem campaign.go
+}
+
+// GetSupplyCounters truy vấn các bộ đếm toàn cục
+func (s *AdminContract) GetSupplyCounters(ctx contractapi.TransactionContextInterface) (*SupplyCounters, error) {
+	return getSupplyCounters(ctx)
+}
+
+// GetCurrencySupplyCounters truy vấn các bộ đếm của một đơn vị điểm (rỗng = POINTS, xem currency.go)
+func (s *AdminContract) GetCurrencySupplyCounters(ctx contractapi.TransactionContextInterface, currency string) (*SupplyCounters, error) {
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	currency, _, err = config.currencyRule(currency)
+	if err != nil {
+		return nil, err
+	}
+	return getCurrencySupplyCounters(ctx, currency)
+}
+
+// CompactCounters gộp các phần bộ đếm tổng cung của ngày `date` (UTC, dạng 2006-01-02; rỗng = hôm qua)
+// vào bộ đếm chung của từng đơn vị điểm (POINTS: cả bộ đếm ngày của thống kê chương trình), gộp các phần
+// số liệu tài khoản của ngày đó vào stats~accounts, các phần ngân sách chương trình vào bản ghi chương trình và xóa chúng, để việc đọc bộ đếm chỉ phải cộng các phần chưa gộp.
+// Chỉ gộp được ngày đã kết thúc: không giao dịch nào còn ghi phần bộ đếm của ngày đó, nên việc gộp
+// không xung đột với các giao dịch đang làm thay đổi tổng cung. Backend gọi hàm này mỗi ngày.
+func (s *AdminContract) CompactCounters(ctx contractapi.TransactionContextInterface, date string) (*CounterCompaction, error) {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	day := today.AddDate(0, 0, -1)
+	if date != "" {
+		if day, err = time.Parse(statsDateLayout, date); err != nil {
+			return nil, errInvalidArgument("invalid date '%s': expected YYYY-MM-DD", date)
+		}
+	}
+	if !day.Before(today) {
+		return nil, errInvalidArgument("counters of %s cannot be compacted before the day has ended", day.Format(statsDateLayout))
+	}
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	compaction := CounterCompaction{Date: day.Format(statsDateLayout)}
+	currencies := make([]string, 0, len(config.Currencies))
+	for currency := range config.Currencies {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		shards, count, err := sumSupplyShards(ctx, true, currency, compaction.Date)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			continue
+		}
+		counters, err := getCompactedSupplyCounters(ctx, currency)
+		if err != nil {
+			return nil, err
+		}
+		if err := counters.add(shards); err != nil {
+			return nil, err
+		}
+		if err := putCompactedSupplyCounters(ctx, currency, counters); err != nil {
+			return nil, err
+		}
+		// Các phần bộ đếm POINTS cũng là bộ đếm ngày của thống kê chương trình (xem stats.go)
+		if currency == DefaultCurrency {
+			totals, err := getCompactedDailyTotals(ctx, compaction.Date)
+			if err != nil {
+				return nil, err
+			}
+			if err := totals.add(shards); err != nil {
+				return nil, err
+			}
+			if err := putCompactedDailyTotals(ctx, totals); err != nil {
+				return nil, err
+			}
+		}
+		compaction.SupplyShards += count
+	}
+	if compaction.AccountStatsShards, err = compactAccountStats(ctx, compaction.Date); err != nil {
+		return nil, err
+	}
+	if compaction.CampaignBudgetShards, err = compactCampaignBudgets(ctx, compaction.Date); err != nil {
+		return nil, err
+	}
+	return &compaction, nil
+}
+
+// =========================================================================================
+// UC-013: Đối soát tổng cung điểm
+//
+// Logic chính:
+// 1. Đọc các bộ đếm toàn cục, tính số điểm lưu hành kỳ vọng = phát hành - quy đổi - hết hạn - phí.
+// 2. Duyệt tất cả tài khoản bằng range query phân trang (`pageSize` bản ghi mỗi trang),
+//    gồm cả tài khoản cũ dưới key đơn giản chưa được MigrateState di chuyển.
+// 3. Cộng dồn số dư thực tế, các delta chưa gộp (xem delta.go) và điểm đang giữ trong quà tặng
+//    chờ nhận (xem gift.go), các khoản khóa hoán đổi (xem swap.go) và các giao dịch chuyển chờ duyệt
+//    (xem velocity.go), ghi nhận các tài khoản có số dư âm.
+// 4. Trả về báo cáo, `Balanced` = true khi không có chênh lệch và không có tài khoản âm.
+// Lưu ý: truy vấn phân trang chỉ được phép trong giao dịch chỉ đọc (evaluate).
+// =========================================================================================
+func (s *AdminContract) ReconcileSupply(ctx contractapi.TransactionContextInterface, pageSize int) (*SupplyReconciliation, error) {
+	if pageSize <= 0 {
+		pageSize = defaultReconcilePageSize
+	}
+
+	// 1. Bộ đếm toàn cục
+	counters, err := getSupplyCounters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := SupplyReconciliation{
+		Counters:            counters,
+		ExpectedOutstanding: counters.Outstanding(),
+		NegativeAccounts:    []string{},
+	}
+
+	// 2. & 3. Duyệt tài khoản theo trang
+	for _, legacy := range []bool{false, true} {
+		pages, err := scanAccountPages(ctx, legacy, pageSize, func(account *LoyaltyAccount) {
+			report.ActualOutstanding += account.Balance
+			report.AccountsScanned++
+			if account.Balance < 0 {
+				report.NegativeAccounts = append(report.NegativeAccounts, account.CustomerID)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		report.PagesScanned += pages
+	}
+	pendingDeltas, err := sumPendingDeltas(ctx, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	report.PendingDeltas = pendingDeltas
+	report.ActualOutstanding += pendingDeltas
+	escrowedGifts, err := sumEscrowedGifts(ctx, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	report.EscrowedGifts = escrowedGifts
+	report.ActualOutstanding += escrowedGifts
+	lockedSwaps, err := sumLockedSwaps(ctx, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	report.LockedSwaps = lockedSwaps
+	report.ActualOutstanding += lockedSwaps
+	heldTransfers, err := sumHeldTransfers(ctx, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	report.HeldTransfers = heldTransfers
+	report.ActualOutstanding += heldTransfers
+
+	// 4. Kết quả đối soát
+	report.Difference = report.ActualOutstanding - report.ExpectedOutstanding
+	report.Balanced = report.Difference == 0 && len(report.NegativeAccounts) == 0
+	return &report, nil
+}
+
+// scanAccountPages duyệt tất cả tài khoản theo trang và gọi visit cho từng tài khoản.
+// legacy = true duyệt các tài khoản cũ dưới key đơn giản (các đối tượng khác đều dùng composite key
+// nên range query rỗng chỉ trả về các tài khoản này).
+func scanAccountPages(ctx contractapi.TransactionContextInterface, legacy bool, pageSize int, visit func(account *LoyaltyAccount)) (int, error) {
+	pages := 0
+	bookmark := ""
+	for {
+		var resultsIterator shim.StateQueryIteratorInterface
+		var metadata *peer.QueryResponseMetadata
+		var err error
+		if legacy {
+			resultsIterator, metadata, err = ctx.GetStub().GetStateByRangeWithPagination("", "", int32(pageSize), bookmark)
+		} else {
+			resultsIterator, metadata, err = ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(accountObjectType, []string{}, int32(pageSize), bookmark)
+		}
+		if err != nil {
+			return pages, fmt.Errorf("failed to query accounts page: %v", err)
+		}
+
+		for resultsIterator.HasNext() {
+			queryResult, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return pages, fmt.Errorf("failed to iterate accounts: %v", err)
+			}
+			account, err := decodeAccount(queryResult.Value)
+			if err != nil {
+				resultsIterator.Close()
+				return pages, fmt.Errorf("failed to decode account '%s': %v", queryResult.Key, err)
+			}
+			visit(account)
+		}
+		resultsIterator.Close()
+		pages++
+
+		if metadata == nil || metadata.Bookmark == "" || metadata.FetchedRecordsCount < int32(pageSize) {
+			return pages, nil
+		}
+		bookmark = metadata.Bookmark
+	}
+}
+
+// sumObjectPages duyệt tất cả đối tượng của `objectType` (có tiền tố key `attributes`) theo trang
+// và cộng số điểm do amountOf trả về
+func sumObjectPages(ctx contractapi.TransactionContextInterface, objectType string, pageSize int, amountOf func(value []byte) (int64, error), attributes ...string) (int64, error) {
+	var total int64
+	bookmark := ""
+	for {
+		resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(objectType, attributes, int32(pageSize), bookmark)
+		if err != nil {
+			return total, fmt.Errorf("failed to query %s page: %v", objectType, err)
+		}
+		for resultsIterator.HasNext() {
+			queryResult, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return total, fmt.Errorf("failed to iterate %s: %v", objectType, err)
+			}
+			amount, err := amountOf(queryResult.Value)
+			if err != nil {
+				resultsIterator.Close()
+				return total, err
+			}
+			if total, err = addPoints(total, amount); err != nil {
+				resultsIterator.Close()
+				return total, err
+			}
+		}
+		resultsIterator.Close()
+
+		if metadata == nil || metadata.Bookmark == "" || metadata.FetchedRecordsCount < int32(pageSize) {
+			return total, nil
+		}
+		bookmark = metadata.Bookmark
+	}
+}
+
+// getSupplyCounters đọc các bộ đếm toàn cục của POINTS (tất cả bằng 0 nếu chưa có)
+func getSupplyCounters(ctx contractapi.TransactionContextInterface) (*SupplyCounters, error) {
+	return getCurrencySupplyCounters(ctx, DefaultCurrency)
+}
+
+// supplyCountersKey trả về key bộ đếm của một đơn vị điểm: supply~counters cho POINTS,
+// supply~counters~<currency> cho các đơn vị khác
+func supplyCountersKey(ctx contractapi.TransactionContextInterface, currency string) (string, error) {
+	if currency == DefaultCurrency {
+		return ledgerKey(ctx, supplyObjectType, "counters")
+	}
+	return ledgerKey(ctx, supplyObjectType, "counters", currency)
+}
+
+// getCurrencySupplyCounters đọc các bộ đếm của một đơn vị điểm: bộ đếm đã gộp cộng các phần bộ đếm chưa gộp
+func getCurrencySupplyCounters(ctx contractapi.TransactionContextInterface, currency string) (*SupplyCounters, error) {
+	counters, err := getCompactedSupplyCounters(ctx, currency)
+	if err != nil {
+		return nil, err
+	}
+	shards, _, err := sumSupplyShards(ctx, false, currency)
+	if err != nil {
+		return nil, err
+	}
+	if err := counters.add(shards); err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
+
+// getCompactedSupplyCounters đọc bộ đếm đã gộp của một đơn vị điểm (tất cả bằng 0 nếu chưa có)
+func getCompactedSupplyCounters(ctx contractapi.TransactionContextInterface, currency string) (*SupplyCounters, error) {
+	countersKey, err := supplyCountersKey(ctx, currency)
+	if err != nil {
+		return nil, err
+	}
+	countersJSON, err := ctx.GetStub().GetState(countersKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read supply counters from world state: %v", err)
+	}
+
+	var counters SupplyCounters
+	if countersJSON != nil {
+		if err := json.Unmarshal(countersJSON, &counters); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal supply counters: %v", err)
+		}
+	}
+	return &counters, nil
+}
+
+// putCompactedSupplyCounters ghi bộ đếm đã gộp của một đơn vị điểm
+func putCompactedSupplyCounters(ctx contractapi.TransactionContextInterface, currency string, counters *SupplyCounters) error {
+	counters.SchemaVersion = supplySchemaVersion
+	countersKey, err := supplyCountersKey(ctx, currency)
+	if err != nil {
+		return err
+	}
+	countersJSON, err := json.Marshal(counters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal supply counters: %v", err)
+	}
+	if err := ctx.GetStub().PutState(countersKey, countersJSON); err != nil {
+		return fmt.Errorf("failed to put state for supply counters: %v", err)
+	}
+	return nil
+}
+
+// sumSupplyShards cộng các phần bộ đếm có tiền tố key `attributes` (đơn vị điểm, ngày).
+// consume = true xóa các phần đã cộng (chỉ dùng trong CompactCounters).
+func sumSupplyShards(ctx contractapi.TransactionContextInterface, consume bool, attributes ...string) (*SupplyCounters, int, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(supplyShardObjectType, attributes)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query supply counter shards: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var total SupplyCounters
+	shards := 0
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to iterate supply counter shards: %v", err)
+		}
+		var shard SupplyCounters
+		if err := json.Unmarshal(queryResult.Value, &shard); err != nil {
+			return nil, 0, fmt.Errorf("failed to unmarshal supply counter shard: %v", err)
+		}
+		if err := total.add(&shard); err != nil {
+			return nil, 0, err
+		}
+		if consume {
+			if err := ctx.GetStub().DelState(queryResult.Key); err != nil {
+				return nil, 0, fmt.Errorf("failed to delete supply counter shard: %v", err)
+			}
+		}
+		shards++
+	}
+	return &total, shards, nil
+}
+
+// add cộng các bộ đếm của `other` vào bộ đếm (có kiểm tra tràn số)
+func (c *SupplyCounters) add(other *SupplyCounters) error {
+	var err error
+	if c.TotalIssued, err = addPoints(c.TotalIssued, other.TotalIssued); err != nil {
+		return err
+	}
+	if c.TotalRedeemed, err = addPoints(c.TotalRedeemed, other.TotalRedeemed); err != nil {
+		return err
+	}
+	if c.TotalExpired, err = addPoints(c.TotalExpired, other.TotalExpired); err != nil {
+		return err
+	}
+	c.TotalAdjusted, err = addPoints(c.TotalAdjusted, other.TotalAdjusted)
+	return err
+}
+
+// updateSupplyCounters cộng các thay đổi vào bộ đếm toàn cục của POINTS.
+// Mọi hàm làm thay đổi tổng cung (phát hành, quy đổi, hết hạn, điều chỉnh) phải gọi hàm này
+// hoặc updateCurrencySupplyCounters với đơn vị điểm của giao dịch.
+func updateSupplyCounters(ctx contractapi.TransactionContextInterface, issued, redeemed, expired, adjusted int64) error {
+	return updateCurrencySupplyCounters(ctx, DefaultCurrency, issued, redeemed, expired, adjusted)
+}
+
+// updateCurrencySupplyCounters cộng các thay đổi vào bộ đếm của một đơn vị điểm.
+// Các thay đổi được ghi vào phần bộ đếm riêng của giao dịch supplyShard~<currency>~<ngày>~<txID> thay vì
+// ghi đè bộ đếm chung, nên các giao dịch phát hành, quy đổi đồng thời không gây MVCC_READ_CONFLICT với nhau.
+// Một giao dịch chỉ được gọi hàm này một lần cho mỗi đơn vị điểm vì lần ghi sau sẽ ghi đè phần bộ đếm.
+func updateCurrencySupplyCounters(ctx contractapi.TransactionContextInterface, currency string, issued, redeemed, expired, adjusted int64) error {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	shard := SupplyCounters{
+		TotalIssued:   issued,
+		TotalRedeemed: redeemed,
+		TotalExpired:  expired,
+		TotalAdjusted: adjusted,
+		SchemaVersion: supplySchemaVersion,
+	}
+
+	shardKey, err := ledgerKey(ctx, supplyShardObjectType, currency, now.UTC().Format(statsDateLayout), ctx.GetStub().GetTxID())
+	if err != nil {
+		return err
+	}
+	shardJSON, err := json.Marshal(shard)
+	if err != nil {
+		return fmt.Errorf("failed to marshal supply counters: %v", err)
+	}
+	if err := ctx.GetStub().PutState(shardKey, shardJSON); err != nil {
+		return fmt.Errorf("failed to put state for supply counters: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCompactCounters(t *testing.T) {
+	// ALICE nhận 1000 điểm ngày 05/01, quy đổi 300 ngày 06/01; các bộ đếm được đọc ngày 07/01
+	tests := []struct {
+		name       string
+		dates      []string // các ngày được gộp, theo thứ tự
+		wantShards int      // tổng số phần bộ đếm đã gộp
+		wantIssued int64    // TotalIssued của bộ đếm đã gộp supply~counters
+		wantCode   string
+	}{
+		{name: "nothing compacted"},
+		{name: "first day", dates: []string{"2026-01-05"}, wantShards: 1, wantIssued: 1000},
+		{name: "both days", dates: []string{"2026-01-05", "2026-01-06"}, wantShards: 2, wantIssued: 1000},
+		{name: "compacted twice", dates: []string{"2026-01-06", "2026-01-06"}, wantShards: 1},
+		{name: "yesterday by default", dates: []string{""}, wantShards: 1},
+		{name: "today", dates: []string{"2026-01-07"}, wantCode: ErrCodeInvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			admin := &AdminContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000})
+			ctx.mustTx(testTime.AddDate(0, 0, 1), func() error {
+				_, err := (&AccountContract{}).RedeemPoints(ctx, "ALICE", 300, "coffee", "")
+				return err
+			})
+
+			readAt := testTime.AddDate(0, 0, 2)
+			shards := 0
+			for _, date := range tt.dates {
+				var compaction *CounterCompaction
+				err := ctx.tx(readAt, func() error {
+					var err error
+					compaction, err = admin.CompactCounters(ctx, date)
+					return err
+				})
+				checkErrorCode(t, err, tt.wantCode)
+				if err == nil {
+					shards += compaction.SupplyShards
+				}
+			}
+			if shards != tt.wantShards {
+				t.Errorf("compacted %d shards, want %d", shards, tt.wantShards)
+			}
+
+			var compacted SupplyCounters
+			if countersJSON := ctx.ledgerState(supplyObjectType, "counters"); countersJSON != nil {
+				if err := json.Unmarshal(countersJSON, &compacted); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if compacted.TotalIssued != tt.wantIssued {
+				t.Errorf("compacted TotalIssued = %d, want %d", compacted.TotalIssued, tt.wantIssued)
+			}
+
+			// Kết quả đọc không phụ thuộc vào việc đã gộp hay chưa
+			var counters *SupplyCounters
+			ctx.mustTx(readAt, func() error {
+				var err error
+				counters, err = admin.GetSupplyCounters(ctx)
+				return err
+			})
+			if counters.TotalIssued != 1000 || counters.TotalRedeemed != 300 || counters.Outstanding() != 700 {
+				t.Errorf("counters = issued %d, redeemed %d, outstanding %d; want 1000, 300, 700",
+					counters.TotalIssued, counters.TotalRedeemed, counters.Outstanding())
+			}
+			for _, day := range []struct {
+				date             string
+				issued, redeemed int64
+			}{{"2026-01-05", 1000, 0}, {"2026-01-06", 0, 300}} {
+				var stats *ProgramStats
+				ctx.mustTx(readAt, func() error {
+					var err error
+					stats, err = admin.GetProgramStats(ctx, day.date, day.date)
+					return err
+				})
+				if stats.Issued != day.issued || stats.Redeemed != day.redeemed {
+					t.Errorf("stats of %s = issued %d, redeemed %d; want %d, %d", day.date, stats.Issued, stats.Redeemed, day.issued, day.redeemed)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	SwapStatusLocked   = "LOCKED"
+	SwapStatusClaimed  = "CLAIMED"
+	SwapStatusRefunded = "REFUNDED"
+
+	// maxSwapLockHours giới hạn thời gian khóa điểm của một giao dịch hoán đổi
+	maxSwapLockHours = 7 * 24
+)
+
+// SwapLock định nghĩa một khoản điểm bị khóa bằng hash và thời hạn (HTLC) cho giao dịch hoán đổi
+// với chương trình đối tác trên kênh khác. Điểm chỉ được chuyển cho người nhận khi có bí mật khớp
+// với HashLock trước TimeLock, ngược lại được hoàn lại cho người gửi sau TimeLock.
+type SwapLock struct {
+	SwapID        string `json:"swapID"`
+	SenderID      string `json:"senderID"`
+	RecipientID   string `json:"recipientID"`
+	Amount        int64  `json:"amount"`
+	HashLock      string `json:"hashLock"`         // SHA-256 (hex) của bí mật
+	TimeLock      string `json:"timeLock"`         // RFC3339, hạn chót để nhận
+	Status        string `json:"status"`           // LOCKED, CLAIMED, REFUNDED
+	Secret        string `json:"secret,omitempty"` // được công khai khi nhận để bên kia dùng cho chân còn lại
+	LockedAt      string `json:"lockedAt"`
+	SettledAt     string `json:"settledAt,omitempty"`
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+// isExpiredAt kiểm tra thời hạn khóa đã qua tại thời điểm t hay chưa
+func (l *SwapLock) isExpiredAt(t time.Time) bool {
+	timeLock, err := ParseTimestamp(l.TimeLock)
+	if err != nil {
+		return true
+	}
+	return !t.Before(timeLock)
+}
+
+// signedDetails trả về các trường của khoản khóa mà người gửi ký thêm trong ủy quyền SWAP (UC-028, bước 5)
+func (l *SwapLock) signedDetails() [][2]string {
+	return [][2]string{
+		{"recipient", l.RecipientID},
+		{"hashLock", l.HashLock},
+		{"timeLock", l.TimeLock},
+	}
+}
+
+// =========================================================================================
+// UC-023: Hoán đổi điểm với chương trình đối tác bằng hợp đồng khóa hash và thời gian (HTLC)
+// Hai chân của giao dịch (điểm trên kênh này, dặm bay trên kênh của đối tác) dùng cùng một hash.
+// Bên biết bí mật khóa trước với thời hạn dài hơn; khi nhận chân của mình, bí mật được công khai
+// và bên kia dùng nó để nhận chân còn lại, nên không cần bên trung gian tin cậy.
+//
+// Logic chính:
+// 1. LockForSwap: người gọi phải được phép đại diện cho `senderID`. `hashLock` là SHA-256 (hex),
+//    `timeLock` (RFC3339) sau thời điểm giao dịch và trong vòng 7 ngày. Điểm được trừ khỏi
+//    tài khoản người gửi và giữ trong SwapLock trạng thái LOCKED. Người gửi đã đăng ký khóa phải kèm
+//    ủy quyền đã ký với `target` là swapID, kèm người nhận, HashLock và TimeLock (UC-028).
+// 2. Claim: trước TimeLock, bất kỳ ai có bí mật khớp HashLock đều có thể nhận; điểm chỉ được cộng
+//    cho RecipientID. Bí mật được lưu trong SwapLock và sự kiện.
+// 3. Refund: từ TimeLock trở đi, khoản khóa chưa được nhận được hoàn lại cho người gửi.
+// 4. Mỗi thao tác phát ra sự kiện "SwapLockedEvent", "SwapClaimedEvent" hoặc "SwapRefundedEvent".
+// =========================================================================================
+func (s *SwapContract) LockForSwap(ctx contractapi.TransactionContextInterface, swapID string, senderID string, recipientID string, amount int64, hashLock string, timeLock string, nonce string, expiresAt string, signature string) (*SwapLock, error) {
+	// 1. Kiểm tra đầu vào
+	if senderID == recipientID {
+		return nil, errInvalidArgument("sender and recipient must be different")
+	}
+	if amount <= 0 {
+		return nil, errInvalidArgument("amount must be a positive integer, got: %d", amount)
+	}
+	hashLock = strings.ToLower(hashLock)
+	if decoded, err := hex.DecodeString(hashLock); err != nil || len(decoded) != sha256.Size {
+		return nil, errInvalidArgument("hash lock must be a hex-encoded SHA-256 digest, got: %q", hashLock)
+	}
+	lockExpiresAt, err := ParseTimestamp(timeLock)
+	if err != nil {
+		return nil, errInvalidArgument("time lock must be an RFC3339 timestamp, got: %q", timeLock)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !lockExpiresAt.After(now) || lockExpiresAt.After(now.Add(maxSwapLockHours*time.Hour)) {
+		return nil, errInvalidArgument("time lock must be within %d hours after the transaction time, got: %s", maxSwapLockHours, timeLock)
+	}
+	if err := requireActingFor(ctx, senderID); err != nil {
+		return nil, err
+	}
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.checkTransactionAmount(amount); err != nil {
+		return nil, err
+	}
+
+	swapKey, err := ledgerKey(ctx, swapObjectType, swapID)
+	if err != nil {
+		return nil, err
+	}
+	existingSwapJSON, err := ctx.GetStub().GetState(swapKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existingSwapJSON != nil {
+		return nil, newChaincodeError(ErrCodeSwapAlreadyExists, map[string]interface{}{"swapID": swapID}, "swap with ID '%s' already exists", swapID)
+	}
+	recipient, err := getAccount(ctx, recipientID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(recipient); err != nil {
+		return nil, err
+	}
+
+	// Khóa điểm của người gửi
+	sender, err := getAccount(ctx, senderID)
+	if err != nil {
+		return nil, err
+	}
+	lock := SwapLock{
+		SwapID:      swapID,
+		SenderID:    senderID,
+		RecipientID: recipientID,
+		Amount:      amount,
+		HashLock:    hashLock,
+		TimeLock:    lockExpiresAt.UTC().Format(time.RFC3339),
+		Status:      SwapStatusLocked,
+	}
+	if err := verifyTransferAuthorization(ctx, authorizationSwap, sender, swapID, amount, DefaultCurrency, nonce, expiresAt, signature, lock.signedDetails()...); err != nil {
+		return nil, err
+	}
+	if err := config.Velocity.checkOutbound(sender, recipientID, now); err != nil {
+		return nil, err
+	}
+	if err := debitAccount(ctx, sender, amount); err != nil {
+		return nil, err
+	}
+	sender.LastUpdated = now.Format(time.RFC3339)
+	if err := putAccount(ctx, sender); err != nil {
+		return nil, err
+	}
+
+	lock.LockedAt = sender.LastUpdated
+	if err := putSwapLock(ctx, &lock); err != nil {
+		return nil, err
+	}
+
+	// 4. Sự kiện
+	if err := setSwapEvent(ctx, "SwapLockedEvent", &lock); err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// Claim chuyển điểm đang khóa cho người nhận khi `secret` khớp HashLock trước TimeLock (xem UC-023)
+func (s *SwapContract) Claim(ctx contractapi.TransactionContextInterface, swapID string, secret string) (*SwapLock, error) {
+	lock, err := getSwapLock(ctx, swapID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if lock.Status != SwapStatusLocked {
+		return nil, newChaincodeError(ErrCodeSwapNotClaimable, map[string]interface{}{"swapID": swapID, "status": lock.Status}, "swap '%s' is already %s", swapID, strings.ToLower(lock.Status))
+	}
+	if lock.isExpiredAt(now) {
+		return nil, newChaincodeError(ErrCodeSwapNotClaimable, map[string]interface{}{"swapID": swapID, "timeLock": lock.TimeLock}, "swap '%s' time lock expired at %s", swapID, lock.TimeLock)
+	}
+	hash := sha256.Sum256([]byte(secret))
+	if hex.EncodeToString(hash[:]) != lock.HashLock {
+		return nil, errInvalidArgument("secret does not match the hash lock of swap '%s'", swapID)
+	}
+
+	// 2. Cộng điểm cho người nhận
+	if err := releaseSwapLock(ctx, lock, lock.RecipientID, now); err != nil {
+		return nil, err
+	}
+	lock.Status = SwapStatusClaimed
+	lock.Secret = secret
+	if err := putSwapLock(ctx, lock); err != nil {
+		return nil, err
+	}
+
+	// 4. Sự kiện
+	if err := setSwapEvent(ctx, "SwapClaimedEvent", lock); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// Refund hoàn lại điểm đang khóa cho người gửi khi TimeLock đã qua mà chưa được nhận (xem UC-023)
+func (s *SwapContract) Refund(ctx contractapi.TransactionContextInterface, swapID string) (*SwapLock, error) {
+	lock, err := getSwapLock(ctx, swapID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if lock.Status != SwapStatusLocked {
+		return nil, newChaincodeError(ErrCodeSwapNotRefundable, map[string]interface{}{"swapID": swapID, "status": lock.Status}, "swap '%s' is already %s", swapID, strings.ToLower(lock.Status))
+	}
+	if !lock.isExpiredAt(now) {
+		return nil, newChaincodeError(ErrCodeSwapNotRefundable, map[string]interface{}{"swapID": swapID, "timeLock": lock.TimeLock}, "swap '%s' cannot be refunded before its time lock at %s", swapID, lock.TimeLock)
+	}
+
+	// 3. Hoàn điểm cho người gửi (hoặc tài khoản mà người gửi đã được gộp vào)
+	senderID, err := resolveAccountID(ctx, lock.SenderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := releaseSwapLock(ctx, lock, senderID, now); err != nil {
+		return nil, err
+	}
+	lock.Status = SwapStatusRefunded
+	if err := putSwapLock(ctx, lock); err != nil {
+		return nil, err
+	}
+
+	// 4. Sự kiện
+	if err := setSwapEvent(ctx, "SwapRefundedEvent", lock); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// GetSwap truy vấn một khoản khóa hoán đổi theo ID
+func (s *SwapContract) GetSwap(ctx contractapi.TransactionContextInterface, swapID string) (*SwapLock, error) {
+	return getSwapLock(ctx, swapID)
+}
+
+// releaseSwapLock ghi có số điểm đang khóa vào tài khoản `customerID`
+func releaseSwapLock(ctx contractapi.TransactionContextInterface, lock *SwapLock, customerID string, now time.Time) error {
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return err
+	}
+	account, err := getAccount(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	deferred, err := creditAccount(ctx, config, account, lock.Amount, false)
+	if err != nil {
+		return err
+	}
+	account.LastUpdated = now.Format(time.RFC3339)
+	if !deferred {
+		if err := putAccount(ctx, account); err != nil {
+			return err
+		}
+	}
+	lock.SettledAt = account.LastUpdated
+	return nil
+}
+
+// sumLockedSwaps cộng số điểm đang khóa trong các giao dịch hoán đổi chưa kết thúc (chỉ dùng khi evaluate)
+func sumLockedSwaps(ctx contractapi.TransactionContextInterface, pageSize int) (int64, error) {
+	return sumObjectPages(ctx, swapObjectType, pageSize, func(value []byte) (int64, error) {
+		var lock SwapLock
+		if err := json.Unmarshal(value, &lock); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal swap lock: %v", err)
+		}
+		if lock.Status != SwapStatusLocked {
+			return 0, nil
+		}
+		return lock.Amount, nil
+	})
+}
+
+// getSwapLock đọc một khoản khóa hoán đổi từ World State
+func getSwapLock(ctx contractapi.TransactionContextInterface, swapID string) (*SwapLock, error) {
+	swapKey, err := ledgerKey(ctx, swapObjectType, swapID)
+	if err != nil {
+		return nil, err
+	}
+	swapJSON, err := ctx.GetStub().GetState(swapKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read swap from world state: %v", err)
+	}
+	if swapJSON == nil {
+		return nil, newChaincodeError(ErrCodeSwapNotFound, map[string]interface{}{"swapID": swapID}, "swap with ID '%s' does not exist", swapID)
+	}
+
+	var lock SwapLock
+	if err := json.Unmarshal(swapJSON, &lock); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal swap data: %v", err)
+	}
+	return &lock, nil
+}
+
+// putSwapLock ghi một khoản khóa hoán đổi vào World State
+func putSwapLock(ctx contractapi.TransactionContextInterface, lock *SwapLock) error {
+	lock.SchemaVersion = swapSchemaVersion
+	swapKey, err := ledgerKey(ctx, swapObjectType, lock.SwapID)
+	if err != nil {
+		return err
+	}
+	swapJSON, err := json.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("failed to marshal swap: %v", err)
+	}
+	if err := ctx.GetStub().PutState(swapKey, swapJSON); err != nil {
+		return fmt.Errorf("failed to put state for swap: %v", err)
+	}
+	return nil
+}
+
+// setSwapEvent phát ra sự kiện với dữ liệu khoản khóa hoán đổi
+func setSwapEvent(ctx contractapi.TransactionContextInterface, eventName string, lock *SwapLock) error {
+	swapJSON, err := json.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("failed to marshal swap event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent(eventName, swapJSON); err != nil {
+		return fmt.Errorf("failed to set event for swap: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockForSwap(t *testing.T) {
+	// ALICE (1000 điểm) khóa `amount` điểm cho BOB với hash giftHash("swap"); mặc định 100 điểm, thời hạn 24 giờ
+	tests := []struct {
+		name        string
+		caller      *testIdentity
+		recipientID string
+		amount      int64
+		hashLock    string
+		timeLock    time.Duration // thời hạn tính từ testTime
+		wantCode    string
+	}{
+		{name: "locked"},
+		{name: "locked by the sender", caller: customer("ALICE")},
+		{name: "longest time lock", timeLock: maxSwapLockHours * time.Hour},
+		{name: "time lock too long", timeLock: maxSwapLockHours*time.Hour + time.Second, wantCode: ErrCodeInvalidArgument},
+		{name: "time lock passed", timeLock: -time.Hour, wantCode: ErrCodeInvalidArgument},
+		{name: "hash lock not SHA-256", hashLock: strings.Repeat("ab", 16), wantCode: ErrCodeInvalidArgument},
+		{name: "negative amount", amount: -100, wantCode: ErrCodeInvalidArgument},
+		{name: "insufficient balance", amount: 1001, wantCode: ErrCodeInsufficientBalance},
+		{name: "recipient is the sender", recipientID: "ALICE", wantCode: ErrCodeInvalidArgument},
+		{name: "unknown recipient", recipientID: "DAVE", wantCode: ErrCodeAccountNotFound},
+		{name: "locked by another customer", caller: customer("BOB"), wantCode: ErrCodeAccessDenied},
+		{name: "swap ID taken", recipientID: "CAROL", wantCode: ErrCodeSwapAlreadyExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000, "BOB": 0, "CAROL": 0})
+			if tt.wantCode == ErrCodeSwapAlreadyExists {
+				ctx.mustTx(testTime, func() error {
+					_, err := (&SwapContract{}).LockForSwap(ctx, "SWAP1", "ALICE", "BOB", 100, giftHash("swap"), testTime.Add(time.Hour).Format(time.RFC3339), "", "", "")
+					return err
+				})
+			}
+			recipientID, amount, hashLock, timeLock := "BOB", int64(100), giftHash("swap"), 24*time.Hour
+			if tt.recipientID != "" {
+				recipientID = tt.recipientID
+			}
+			if tt.amount != 0 {
+				amount = tt.amount
+			}
+			if tt.hashLock != "" {
+				hashLock = tt.hashLock
+			}
+			if tt.timeLock != 0 {
+				timeLock = tt.timeLock
+			}
+			if tt.caller != nil {
+				ctx.as(tt.caller)
+			}
+
+			var lock *SwapLock
+			err := ctx.tx(testTime, func() error {
+				var err error
+				lock, err = (&SwapContract{}).LockForSwap(ctx, "SWAP1", "ALICE", recipientID, amount, hashLock, testTime.Add(timeLock).Format(time.RFC3339), "", "", "")
+				return err
+			})
+			ctx.as(bankAdmin())
+			checkErrorCode(t, err, tt.wantCode)
+
+			wantBalance := int64(1000)
+			if err == nil || tt.wantCode == ErrCodeSwapAlreadyExists {
+				wantBalance = 900
+			}
+			if got := ctx.balance("ALICE"); got != wantBalance {
+				t.Errorf("sender balance = %d, want %d", got, wantBalance)
+			}
+			if err != nil {
+				return
+			}
+			if lock.Status != SwapStatusLocked || lock.RecipientID != recipientID || lock.Amount != amount || lock.LockedAt != testTime.Format(time.RFC3339) {
+				t.Errorf("lock = %+v, want %d points LOCKED for %s", lock, amount, recipientID)
+			}
+		})
+	}
+}
+
+func TestSwapSettlement(t *testing.T) {
+	// ALICE (1000 điểm) khóa 100 điểm cho BOB trong SWAP1 lúc testTime, thời hạn 24 giờ, bí mật "swap"
+	type step struct {
+		refund   bool
+		hours    int    // thời điểm tính từ testTime
+		secret   string // bí mật dùng để nhận
+		swapID   string // mặc định SWAP1
+		wantCode string
+	}
+	tests := []struct {
+		name       string
+		mergeInto  string // ALICE được gộp vào tài khoản này trước các bước
+		steps      []step
+		wantStatus string
+		want       map[string]int64
+	}{
+		{
+			name:       "claimed",
+			steps:      []step{{hours: 23, secret: "swap"}},
+			wantStatus: SwapStatusClaimed,
+			want:       map[string]int64{"ALICE": 900, "BOB": 100},
+		},
+		{
+			name:       "wrong secret",
+			steps:      []step{{hours: 1, secret: "guess", wantCode: ErrCodeInvalidArgument}},
+			wantStatus: SwapStatusLocked,
+			want:       map[string]int64{"ALICE": 900, "BOB": 0},
+		},
+		{
+			name:       "claimed at the time lock",
+			steps:      []step{{hours: 24, secret: "swap", wantCode: ErrCodeSwapNotClaimable}},
+			wantStatus: SwapStatusLocked,
+			want:       map[string]int64{"ALICE": 900, "BOB": 0},
+		},
+		{
+			name:       "claimed twice",
+			steps:      []step{{hours: 1, secret: "swap"}, {hours: 2, secret: "swap", wantCode: ErrCodeSwapNotClaimable}},
+			wantStatus: SwapStatusClaimed,
+			want:       map[string]int64{"ALICE": 900, "BOB": 100},
+		},
+		{
+			name:       "refunded",
+			steps:      []step{{refund: true, hours: 24}},
+			wantStatus: SwapStatusRefunded,
+			want:       map[string]int64{"ALICE": 1000, "BOB": 0},
+		},
+		{
+			name:       "refunded before the time lock",
+			steps:      []step{{refund: true, hours: 23, wantCode: ErrCodeSwapNotRefundable}},
+			wantStatus: SwapStatusLocked,
+			want:       map[string]int64{"ALICE": 900, "BOB": 0},
+		},
+		{
+			name:       "refunded after a claim",
+			steps:      []step{{hours: 1, secret: "swap"}, {refund: true, hours: 25, wantCode: ErrCodeSwapNotRefundable}},
+			wantStatus: SwapStatusClaimed,
+			want:       map[string]int64{"ALICE": 900, "BOB": 100},
+		},
+		{
+			name:       "claimed after a refund",
+			steps:      []step{{refund: true, hours: 24}, {hours: 25, secret: "swap", wantCode: ErrCodeSwapNotClaimable}},
+			wantStatus: SwapStatusRefunded,
+			want:       map[string]int64{"ALICE": 1000, "BOB": 0},
+		},
+		{
+			name:       "refunded to the account the sender was merged into",
+			mergeInto:  "CAROL",
+			steps:      []step{{refund: true, hours: 24}},
+			wantStatus: SwapStatusRefunded,
+			want:       map[string]int64{"CAROL": 1000, "BOB": 0},
+		},
+		{
+			name:       "unknown swap",
+			steps:      []step{{hours: 1, secret: "swap", swapID: "SWAP2", wantCode: ErrCodeSwapNotFound}},
+			wantStatus: SwapStatusLocked,
+			want:       map[string]int64{"ALICE": 900, "BOB": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			swaps := &SwapContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000, "BOB": 0, "CAROL": 0})
+			ctx.mustTx(testTime, func() error {
+				_, err := swaps.LockForSwap(ctx, "SWAP1", "ALICE", "BOB", 100, giftHash("swap"), testTime.Add(24*time.Hour).Format(time.RFC3339), "", "", "")
+				return err
+			})
+			if tt.mergeInto != "" {
+				ctx.mustTx(testTime, func() error {
+					_, err := (&AccountContract{}).MergeAccounts(ctx, tt.mergeInto, "ALICE")
+					return err
+				})
+			}
+
+			for i, s := range tt.steps {
+				swapID := "SWAP1"
+				if s.swapID != "" {
+					swapID = s.swapID
+				}
+				ctx.begin(testTime.Add(time.Duration(s.hours) * time.Hour))
+				var err error
+				if s.refund {
+					_, err = swaps.Refund(ctx, swapID)
+				} else {
+					_, err = swaps.Claim(ctx, swapID, s.secret)
+				}
+				if err != nil {
+					ctx.rollback()
+					checkErrorCode(t, err, s.wantCode)
+					continue
+				}
+				event := ctx.commit()
+				checkErrorCode(t, nil, s.wantCode)
+				wantEvent := "SwapClaimedEvent"
+				if s.refund {
+					wantEvent = "SwapRefundedEvent"
+				}
+				if event == nil || event.EventName != wantEvent {
+					t.Errorf("step %d event = %v, want %s", i, event, wantEvent)
+				}
+			}
+
+			var stored SwapLock
+			if err := json.Unmarshal(ctx.ledgerState(swapObjectType, "SWAP1"), &stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != tt.wantStatus {
+				t.Errorf("swap status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+			if (stored.Status == SwapStatusClaimed) != (stored.Secret == "swap") {
+				t.Errorf("swap secret = %q with status %s; the secret is published only by a claim", stored.Secret, stored.Status)
+			}
+			got := map[string]int64{}
+			for customerID := range tt.want {
+				got[customerID] = ctx.balance(customerID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("balances = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// customerIDAttribute là thuộc tính trong chứng chỉ của client liên kết identity với tài khoản loyalty
+	customerIDAttribute = "customerID"
+	// merchantIDAttribute là thuộc tính trong chứng chỉ của client liên kết identity với một merchant đối tác
+	merchantIDAttribute = "merchantID"
+
+	tokenName     = "Loyalty Points"
+	tokenSymbol   = "LPT"
+	tokenDecimals = 0
+)
+
+// Allowance định nghĩa hạn mức mà chủ tài khoản cho phép bên thứ ba (merchant) trừ điểm
+type Allowance struct {
+	Owner         string `json:"owner"`
+	Spender       string `json:"spender"`
+	Amount        int64  `json:"amount"`
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+// TransferEvent là payload của sự kiện "Transfer"
+type TransferEvent struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value int64  `json:"value"`
+}
+
+// ApprovalEvent là payload của sự kiện "Approval"
+type ApprovalEvent struct {
+	Owner   string `json:"owner"`
+	Spender string `json:"spender"`
+	Value   int64  `json:"value"`
+}
+
+// =========================================================================================
+// UC-012: Giao diện token fungible (tương tự ERC-20 trong fabric-samples)
+//
+// Điểm loyalty được công bố như một token fungible dựa trên số dư của LoyaltyAccount.
+// Tài khoản của người gọi được xác định bởi thuộc tính `customerID` trong chứng chỉ,
+// nhờ đó ví bên ngoài và merchant có thể giao dịch bằng identity của chính họ.
+// =========================================================================================
+
+// Name trả về tên của token
+func (s *AccountContract) Name(ctx contractapi.TransactionContextInterface) (string, error) {
+	return tokenName, nil
+}
+
+// Symbol trả về ký hiệu của token
+func (s *AccountContract) Symbol(ctx contractapi.TransactionContextInterface) (string, error) {
+	return tokenSymbol, nil
+}
+
+// Decimals trả về số chữ số thập phân của token (điểm là số nguyên)
+func (s *AccountContract) Decimals(ctx contractapi.TransactionContextInterface) (int, error) {
+	return tokenDecimals, nil
+}
+
+// TotalSupply trả về tổng số điểm đang lưu hành theo bộ đếm tổng cung (xem ReconcileSupply để đối soát)
+func (s *AccountContract) TotalSupply(ctx contractapi.TransactionContextInterface) (int64, error) {
+	counters, err := getSupplyCounters(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return counters.Outstanding(), nil
+}
+
+// BalanceOf trả về số dư của một tài khoản
+func (s *AccountContract) BalanceOf(ctx contractapi.TransactionContextInterface, account string) (int64, error) {
+	if account == "" {
+		return 0, errInvalidArgument("account cannot be empty")
+	}
+	accountID, err := resolveAccountID(ctx, account)
+	if err != nil {
+		return 0, err
+	}
+	loyaltyAccount, err := getAccount(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	if loyaltyAccount.DeltaMode {
+		if _, err := foldDeltas(ctx, loyaltyAccount, false); err != nil {
+			return 0, err
+		}
+	}
+	return loyaltyAccount.Balance, nil
+}
+
+// ClientAccountID trả về tài khoản loyalty gắn với identity của người gọi
+func (s *AccountContract) ClientAccountID(ctx contractapi.TransactionContextInterface) (string, error) {
+	return getClientAccountID(ctx)
+}
+
+// Transfer chuyển điểm từ tài khoản của người gọi sang `recipient` và phát ra sự kiện "Transfer".
+// Giao dịch vi phạm quy tắc tốc độ được giữ lại (UC-029) và phát ra "TransferFlaggedEvent" thay cho "Transfer".
+func (s *AccountContract) Transfer(ctx contractapi.TransactionContextInterface, recipient string, amount int64) error {
+	sender, err := getClientAccountID(ctx)
+	if err != nil {
+		return err
+	}
+	flagged, err := transferBalance(ctx, sender, recipient, amount, "Token transfer")
+	if err != nil || flagged != nil {
+		return err
+	}
+	return setTokenEvent(ctx, "Transfer", TransferEvent{From: sender, To: recipient, Value: amount})
+}
+
+// Approve cho phép `spender` trừ tối đa `amount` điểm từ tài khoản của người gọi và phát ra sự kiện "Approval".
+// Gọi lại Approve sẽ ghi đè hạn mức cũ; amount = 0 để thu hồi.
+func (s *AccountContract) Approve(ctx contractapi.TransactionContextInterface, spender string, amount int64) error {
+	owner, err := getClientAccountID(ctx)
+	if err != nil {
+		return err
+	}
+	if spender == "" {
+		return errInvalidArgument("spender cannot be empty")
+	}
+	if spender == owner {
+		return errInvalidArgument("cannot approve own account as spender")
+	}
+	if amount < 0 {
+		return errInvalidArgument("allowance amount cannot be negative, got: %d", amount)
+	}
+
+	if err := putAllowance(ctx, &Allowance{Owner: owner, Spender: spender, Amount: amount}); err != nil {
+		return err
+	}
+	return setTokenEvent(ctx, "Approval", ApprovalEvent{Owner: owner, Spender: spender, Value: amount})
+}
+
+// Allowance trả về số điểm mà `spender` còn được phép trừ từ tài khoản `owner`
+func (s *AccountContract) Allowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (int64, error) {
+	if owner == "" || spender == "" {
+		return 0, errInvalidArgument("owner and spender cannot be empty")
+	}
+	allowance, err := getAllowance(ctx, owner, spender)
+	if err != nil {
+		return 0, err
+	}
+	return allowance.Amount, nil
+}
+
+// TransferFrom cho phép người gọi (spender, ví dụ merchant) trừ điểm của `from` trong phạm vi hạn mức đã được chấp thuận.
+// Giao dịch bị giữ lại vẫn dùng hạn mức, vì điểm đã rời tài khoản `from`; nếu bị từ chối, điểm được hoàn cho `from`.
+func (s *AccountContract) TransferFrom(ctx contractapi.TransactionContextInterface, from string, to string, amount int64) error {
+	spender, err := getClientAccountID(ctx)
+	if err != nil {
+		return err
+	}
+
+	allowance, err := getAllowance(ctx, from, spender)
+	if err != nil {
+		return err
+	}
+	if allowance.Amount < amount {
+		return newChaincodeError(ErrCodeInsufficientAllowance, map[string]interface{}{"owner": from, "spender": spender, "allowance": allowance.Amount, "requested": amount},
+			"insufficient allowance: spender '%s' may transfer %d from '%s', requested %d", spender, allowance.Amount, from, amount)
+	}
+
+	flagged, err := transferBalance(ctx, from, to, amount, fmt.Sprintf("Token transfer by %s", spender))
+	if err != nil {
+		return err
+	}
+
+	if allowance.Amount, err = subPoints(allowance.Amount, amount); err != nil {
+		return err
+	}
+	if err := putAllowance(ctx, allowance); err != nil {
+		return err
+	}
+	if flagged != nil {
+		return nil
+	}
+	return setTokenEvent(ctx, "Transfer", TransferEvent{From: from, To: to, Value: amount})
+}
+
+// getClientAccountID đọc thuộc tính customerID trong chứng chỉ của người gọi
+func getClientAccountID(ctx contractapi.TransactionContextInterface) (string, error) {
+	customerID, found, err := ctx.GetClientIdentity().GetAttributeValue(customerIDAttribute)
+	if err != nil {
+		return "", fmt.Errorf("failed to read client identity attribute: %v", err)
+	}
+	if !found || customerID == "" {
+		return "", errAccessDenied("client identity is not linked to a loyalty account: missing '%s' attribute", customerIDAttribute)
+	}
+	return customerID, nil
+}
+
+// transferBalance chuyển điểm giữa hai tài khoản sau khi kiểm tra đầu vào, giới hạn trong LedgerConfig, quy tắc tốc độ và số dư.
+// Giao dịch vi phạm quy tắc tốc độ được giữ lại như TransferPoints (UC-029) và bản ghi FlaggedTransfer được trả về.
+func transferBalance(ctx contractapi.TransactionContextInterface, fromID, toID string, amount int64, description string) (*FlaggedTransfer, error) {
+	if fromID == "" || toID == "" {
+		return nil, errInvalidArgument("source and target accounts cannot be empty")
+	}
+	if fromID == toID {
+		return nil, errInvalidArgument("source and target accounts must be different")
+	}
+	if amount <= 0 {
+		return nil, errInvalidArgument("amount must be a positive integer, got: %d", amount)
+	}
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.checkTransactionAmount(amount); err != nil {
+		return nil, err
+	}
+
+	source, err := getAccount(ctx, fromID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := getAccount(ctx, toID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rules := config.Velocity.recordTransfer(source, toID, now); len(rules) > 0 {
+		return holdFlaggedTransfer(ctx, source, target, amount, DefaultCurrency, description, rules, now)
+	}
+	if err := debitAccount(ctx, source, amount); err != nil {
+		return nil, err
+	}
+	source.LastUpdated = now.Format(time.RFC3339)
+	targetDeferred, err := creditAccount(ctx, config, target, amount, false)
+	if err != nil {
+		return nil, err
+	}
+	target.LastUpdated = source.LastUpdated
+
+	if err := putAccount(ctx, source); err != nil {
+		return nil, err
+	}
+	if targetDeferred {
+		return nil, nil
+	}
+	return nil, putAccount(ctx, target)
+}
+
+// getAllowance đọc hạn mức từ World State (0 nếu chưa được chấp thuận)
+func getAllowance(ctx contractapi.TransactionContextInterface, owner, spender string) (*Allowance, error) {
+	allowanceKey, err := ledgerKey(ctx, allowanceObjectType, owner, spender)
+	if err != nil {
+		return nil, err
+	}
+	allowanceJSON, err := ctx.GetStub().GetState(allowanceKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowance from world state: %v", err)
+	}
+
+	allowance := Allowance{Owner: owner, Spender: spender}
+	if allowanceJSON != nil {
+		if err := json.Unmarshal(allowanceJSON, &allowance); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal allowance: %v", err)
+		}
+	}
+	return &allowance, nil
+}
+
+// putAllowance ghi hạn mức vào World State
+func putAllowance(ctx contractapi.TransactionContextInterface, allowance *Allowance) error {
+	allowance.SchemaVersion = allowanceSchemaVersion
+	allowanceKey, err := ledgerKey(ctx, allowanceObjectType, allowance.Owner, allowance.Spender)
+	if err != nil {
+		return err
+	}
+	allowanceJSON, err := json.Marshal(allowance)
+	if err != nil {
+		return fmt.Errorf("failed to marshal allowance: %v", err)
+	}
+	if err := ctx.GetStub().PutState(allowanceKey, allowanceJSON); err != nil {
+		return fmt.Errorf("failed to put state for allowance: %v", err)
+	}
+	return nil
+}
+
+// setTokenEvent phát ra sự kiện Transfer/Approval
+func setTokenEvent(ctx contractapi.TransactionContextInterface, eventName string, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v", eventName, err)
+	}
+	if err := ctx.GetStub().SetEvent(eventName, payloadJSON); err != nil {
+		return fmt.Errorf("failed to set %s event: %v", eventName, err)
+	}
+	return nil
+}
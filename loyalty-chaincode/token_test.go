@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestTokenTransfer(t *testing.T) {
+	// ALICE có 1000 điểm, BOB có 0; người gọi mặc định là ALICE
+	tests := []struct {
+		name      string
+		caller    *testIdentity
+		recipient string
+		amount    int64
+		deltaMode bool   // BOB ở chế độ delta
+		closed    string // tài khoản bị đóng trước khi chuyển
+		wantCode  string
+		want      map[string]int64
+	}{
+		{name: "transfer", recipient: "BOB", amount: 100, want: map[string]int64{"ALICE": 900, "BOB": 100}},
+		{name: "whole balance", recipient: "BOB", amount: 1000, want: map[string]int64{"ALICE": 0, "BOB": 1000}},
+		{name: "recipient in delta mode", recipient: "BOB", amount: 100, deltaMode: true, want: map[string]int64{"ALICE": 900, "BOB": 100}},
+		{name: "insufficient balance", recipient: "BOB", amount: 1001, wantCode: ErrCodeInsufficientBalance},
+		{name: "zero amount", recipient: "BOB", wantCode: ErrCodeInvalidArgument},
+		{name: "own account", recipient: "ALICE", amount: 100, wantCode: ErrCodeInvalidArgument},
+		{name: "unknown recipient", recipient: "DAVE", amount: 100, wantCode: ErrCodeAccountNotFound},
+		{name: "closed recipient", recipient: "BOB", amount: 100, closed: "BOB", wantCode: ErrCodeAccountClosed},
+		{name: "caller without account", caller: bankAdmin(), recipient: "BOB", amount: 100, wantCode: ErrCodeAccessDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			contract := &AccountContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000, "BOB": 0})
+			if tt.deltaMode {
+				ctx.mustTx(testTime, func() error {
+					_, err := (&AdminContract{}).SetDeltaMode(ctx, "BOB", true)
+					return err
+				})
+			}
+			if tt.closed != "" {
+				setAccountStatus(ctx, tt.closed, "CLOSED")
+			}
+
+			caller := customer("ALICE")
+			if tt.caller != nil {
+				caller = tt.caller
+			}
+			ctx.as(caller).begin(testTime)
+			err := contract.Transfer(ctx, tt.recipient, tt.amount)
+			if err != nil {
+				ctx.rollback()
+			} else {
+				event := ctx.commit()
+				var transfer TransferEvent
+				if event == nil || event.EventName != "Transfer" || json.Unmarshal(event.Payload, &transfer) != nil ||
+					transfer != (TransferEvent{From: "ALICE", To: tt.recipient, Value: tt.amount}) {
+					t.Errorf("event = %v, want Transfer of %d from ALICE to %s", event, tt.amount, tt.recipient)
+				}
+			}
+			ctx.as(bankAdmin())
+			checkErrorCode(t, err, tt.wantCode)
+
+			want := tt.want
+			if want == nil {
+				want = map[string]int64{"ALICE": 1000, "BOB": 0}
+			}
+			got := map[string]int64{"ALICE": ctx.balance("ALICE"), "BOB": ctx.balance("BOB")}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("balances = %v, want %v", got, want)
+			}
+			var supply int64
+			ctx.mustTx(testTime, func() error {
+				var err error
+				supply, err = contract.TotalSupply(ctx)
+				return err
+			})
+			if supply != 1000 {
+				t.Errorf("total supply = %d, want 1000", supply)
+			}
+		})
+	}
+}
+
+func TestTokenAllowance(t *testing.T) {
+	// ALICE có 1000 điểm và lần lượt chấp thuận các hạn mức `approve` cho `spender` (mặc định SHOP);
+	// SHOP sau đó chuyển `amount` điểm của ALICE cho BOB nếu amount > 0
+	tests := []struct {
+		name          string
+		spender       string
+		approve       []int64
+		approveCode   string
+		amount        int64
+		wantCode      string
+		wantAllowance int64
+		wantBOB       int64
+	}{
+		{name: "within allowance", approve: []int64{300}, amount: 100, wantAllowance: 200, wantBOB: 100},
+		{name: "whole allowance", approve: []int64{300}, amount: 300, wantBOB: 300},
+		{name: "above allowance", approve: []int64{300}, amount: 301, wantCode: ErrCodeInsufficientAllowance, wantAllowance: 300},
+		{name: "without approval", amount: 100, wantCode: ErrCodeInsufficientAllowance},
+		{name: "allowance above balance", approve: []int64{2000}, amount: 1500, wantCode: ErrCodeInsufficientBalance, wantAllowance: 2000},
+		{name: "approval overwritten", approve: []int64{300, 50}, wantAllowance: 50},
+		{name: "approval revoked", approve: []int64{300, 0}, amount: 100, wantCode: ErrCodeInsufficientAllowance},
+		{name: "negative approval", approve: []int64{-1}, approveCode: ErrCodeInvalidArgument},
+		{name: "own account as spender", spender: "ALICE", approve: []int64{300}, approveCode: ErrCodeInvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			contract := &AccountContract{}
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000, "BOB": 0})
+			spender := "SHOP"
+			if tt.spender != "" {
+				spender = tt.spender
+			}
+
+			for _, amount := range tt.approve {
+				ctx.as(customer("ALICE")).begin(testTime)
+				err := contract.Approve(ctx, spender, amount)
+				if err != nil {
+					ctx.rollback()
+					ctx.as(bankAdmin())
+					checkErrorCode(t, err, tt.approveCode)
+					return
+				}
+				event := ctx.commit()
+				var approval ApprovalEvent
+				if event == nil || event.EventName != "Approval" || json.Unmarshal(event.Payload, &approval) != nil ||
+					approval != (ApprovalEvent{Owner: "ALICE", Spender: spender, Value: amount}) {
+					t.Errorf("event = %v, want Approval of %d for %s", event, amount, spender)
+				}
+			}
+			checkErrorCode(t, nil, tt.approveCode)
+
+			if tt.amount > 0 {
+				err := ctx.as(customer(spender)).tx(testTime, func() error {
+					return contract.TransferFrom(ctx, "ALICE", "BOB", tt.amount)
+				})
+				checkErrorCode(t, err, tt.wantCode)
+			}
+			ctx.as(bankAdmin())
+
+			var allowance int64
+			ctx.mustTx(testTime, func() error {
+				var err error
+				allowance, err = contract.Allowance(ctx, "ALICE", spender)
+				return err
+			})
+			if allowance != tt.wantAllowance {
+				t.Errorf("allowance = %d, want %d", allowance, tt.wantAllowance)
+			}
+			if ctx.balance("ALICE") != 1000-tt.wantBOB || ctx.balance("BOB") != tt.wantBOB {
+				t.Errorf("balances = ALICE %d, BOB %d; want %d, %d", ctx.balance("ALICE"), ctx.balance("BOB"), 1000-tt.wantBOB, tt.wantBOB)
+			}
+		})
+	}
+}
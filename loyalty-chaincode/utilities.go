@@ -1,9 +1,12 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
 // =========================================================================================
@@ -81,6 +84,48 @@ func ValidateRewardData(reward *Reward) error {
 	return nil
 }
 
+// ValidateCampaignData validates campaign data
+func ValidateCampaignData(campaign *Campaign) error {
+	if campaign.CampaignID == "" {
+		return fmt.Errorf("campaign ID is required")
+	}
+	if campaign.Name == "" {
+		return fmt.Errorf("campaign name is required")
+	}
+	start, err := ParseTimestamp(campaign.StartDate)
+	if err != nil {
+		return fmt.Errorf("invalid start date '%s': %v", campaign.StartDate, err)
+	}
+	end, err := ParseTimestamp(campaign.EndDate)
+	if err != nil {
+		return fmt.Errorf("invalid end date '%s': %v", campaign.EndDate, err)
+	}
+	if !end.After(start) {
+		return fmt.Errorf("end date must be after start date")
+	}
+	for _, tier := range campaign.EligibleTiers {
+		if !isValidTier(tier) {
+			return fmt.Errorf("invalid tier: %s", tier)
+		}
+	}
+	if campaign.Multiplier != 0 && campaign.Multiplier < 1 {
+		return fmt.Errorf("multiplier must be 0 (none) or at least 1, got: %.2f", campaign.Multiplier)
+	}
+	if campaign.FixedBonus < 0 {
+		return fmt.Errorf("fixed bonus cannot be negative")
+	}
+	if campaign.Multiplier <= 1 && campaign.FixedBonus == 0 {
+		return fmt.Errorf("campaign must define a multiplier above 1 or a fixed bonus")
+	}
+	if campaign.BudgetCap < 0 {
+		return fmt.Errorf("budget cap cannot be negative")
+	}
+	if campaign.PerCustomerCap < 0 {
+		return fmt.Errorf("per-customer cap cannot be negative")
+	}
+	return nil
+}
+
 // isValidTier checks if a tier is valid
 func isValidTier(tier string) bool {
 	validTiers := []string{"BRONZE", "SILVER", "GOLD", "PLATINUM"}
@@ -103,8 +148,37 @@ func isValidStatus(status string) bool {
 	return false
 }
 
+// containsOrEmpty checks if value is in list, treating an empty list as "all values"
+func containsOrEmpty(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// minInt returns the smaller of two integers
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// minInt64 returns the smaller of two int64 values
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 // CalculateTierFromPoints determines customer tier based on lifetime points
-func CalculateTierFromPoints(lifetimeEarned int) string {
+func CalculateTierFromPoints(lifetimeEarned int64) string {
 	if lifetimeEarned >= 50000 {
 		return "PLATINUM"
 	} else if lifetimeEarned >= 25000 {
@@ -156,13 +230,13 @@ func GetTierBenefits(tier string) map[string]interface{} {
 }
 
 // CalculatePointsEarned calculates points earned based on spend amount and tier
-func CalculatePointsEarned(spendAmount float64, tier string) int {
+func CalculatePointsEarned(spendAmount float64, tier string) int64 {
 	benefits := GetTierBenefits(tier)
 	multiplier := benefits["pointsMultiplier"].(float64)
 	
 	// Base rate: 1 point per dollar spent
-	basePoints := int(spendAmount)
-	return int(float64(basePoints) * multiplier)
+	basePoints := int64(spendAmount)
+	return int64(float64(basePoints) * multiplier)
 }
 
 // FormatCurrency formats a float64 as currency string
@@ -326,15 +400,19 @@ func ValidateBusinessRules(transactionType string, amount int, customerTier stri
 // =========================================================================================
 
 // CreateAuditLog creates an audit log entry (placeholder for future implementation)
-func CreateAuditLog(action, entityType, entityID, userID, details string) map[string]interface{} {
+func CreateAuditLog(ctx contractapi.TransactionContextInterface, action, entityType, entityID, userID, details string) (map[string]interface{}, error) {
+	timestamp, err := GetCurrentTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return map[string]interface{}{
 		"action":     action,
 		"entityType": entityType,
 		"entityID":   entityID,
 		"userID":     userID,
 		"details":    details,
-		"timestamp":  GetCurrentTimestamp(),
-	}
+		"timestamp":  timestamp,
+	}, nil
 }
 
 // ValidateMetadata validates metadata structure
@@ -359,3 +437,123 @@ func ValidateMetadata(metadata map[string]string) error {
 	
 	return nil
 }
+
+// =========================================================================================
+// LEDGER HELPER FUNCTIONS
+// =========================================================================================
+
+// getTxTime returns the transaction timestamp proposed by the client. Unlike time.Now(),
+// it is identical on every endorsing peer, so it is safe to use in time-window checks.
+func getTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	return txTimestamp.AsTime().UTC(), nil
+}
+
+// getAccount reads and deserializes a loyalty account from world state
+func getAccount(ctx contractapi.TransactionContextInterface, customerID string) (*LoyaltyAccount, error) {
+	accountJSON, err := getAccountState(ctx, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account from world state: %v", err)
+	}
+	if accountJSON == nil {
+		return nil, errAccountNotFound(customerID)
+	}
+
+	return decodeAccount(accountJSON)
+}
+
+// putAccount serializes a loyalty account and writes it to world state
+func putAccount(ctx contractapi.TransactionContextInterface, account *LoyaltyAccount) error {
+	return putAccountCarrying(ctx, account, 0, 0)
+}
+
+// putAccountCarrying writes an account that took over carriedEarned/carriedRedeemed lifetime points from
+// another account (MergeAccounts); carried points are ranked but not counted as activity of the month
+func putAccountCarrying(ctx contractapi.TransactionContextInterface, account *LoyaltyAccount, carriedEarned int64, carriedRedeemed int64) error {
+	account.SchemaVersion = accountSchemaVersion
+	account.setIndexedFields()
+	if err := updateAccountStats(ctx, account, carriedEarned, carriedRedeemed); err != nil {
+		return err
+	}
+	accountJSON, err := json.Marshal(account)
+	if err != nil {
+		return fmt.Errorf("failed to marshal account: %v", err)
+	}
+	if err := putAccountState(ctx, account.CustomerID, accountJSON); err != nil {
+		return fmt.Errorf("failed to update account in world state: %v", err)
+	}
+	return nil
+}
+
+// roleAttribute is the certificate attribute carrying the caller's loyalty role (admin, ...)
+const roleAttribute = "role"
+
+// requireBankOrg ensures the caller belongs to BankOrgMSP
+func requireBankOrg(ctx contractapi.TransactionContextInterface, action string) error {
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	if clientMSPID != "BankOrgMSP" {
+		return errAccessDenied("only BankOrgMSP can %s, got MSP ID: %s", action, clientMSPID)
+	}
+	return nil
+}
+
+// requireActingFor ensures the caller may act for the given customer: either its certificate carries the
+// matching customerID attribute, or it belongs to BankOrgMSP (the backend acting for an authenticated customer)
+func requireActingFor(ctx contractapi.TransactionContextInterface, customerID string) error {
+	callerCustomerID, found, err := ctx.GetClientIdentity().GetAttributeValue(customerIDAttribute)
+	if err != nil {
+		return fmt.Errorf("failed to read client identity attribute: %v", err)
+	}
+	if found && callerCustomerID == customerID {
+		return nil
+	}
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	if clientMSPID != "BankOrgMSP" {
+		return errAccessDenied("caller cannot act for customer '%s'", customerID)
+	}
+	return nil
+}
+
+// requireMerchant ensures the caller may act for the given merchant: either its certificate carries the
+// matching merchantID attribute, or it belongs to BankOrgMSP (the backend acting for an authenticated merchant)
+func requireMerchant(ctx contractapi.TransactionContextInterface, merchantID string) error {
+	callerMerchantID, found, err := ctx.GetClientIdentity().GetAttributeValue(merchantIDAttribute)
+	if err != nil {
+		return fmt.Errorf("failed to read client identity attribute: %v", err)
+	}
+	if found && callerMerchantID == merchantID {
+		return nil
+	}
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	if clientMSPID != "BankOrgMSP" {
+		return errAccessDenied("caller cannot act for merchant '%s'", merchantID)
+	}
+	return nil
+}
+
+// requireRole ensures the caller belongs to BankOrgMSP and carries the given `role` certificate attribute
+func requireRole(ctx contractapi.TransactionContextInterface, role string) error {
+	if err := requireBankOrg(ctx, "perform "+role+" operations"); err != nil {
+		return err
+	}
+	callerRole, found, err := ctx.GetClientIdentity().GetAttributeValue(roleAttribute)
+	if err != nil {
+		return fmt.Errorf("failed to read client identity attribute: %v", err)
+	}
+	if !found || callerRole != role {
+		return errAccessDenied("role '%s' is required", role)
+	}
+	return nil
+}
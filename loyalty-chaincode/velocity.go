@@ -0,0 +1,458 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	FlaggedStatusPending  = "FLAGGED"
+	FlaggedStatusApproved = "APPROVED"
+	FlaggedStatusRejected = "REJECTED"
+
+	// Tên các quy tắc tốc độ, ghi vào FlaggedTransfer.Rules khi bị vi phạm
+	VelocityRuleTransfersPerHour = "MAX_TRANSFERS_PER_HOUR"
+	VelocityRuleTransfersPerDay  = "MAX_TRANSFERS_PER_DAY"
+	VelocityRuleRecipientsPerDay = "MAX_RECIPIENTS_PER_DAY"
+	VelocityRuleSecurityCooldown = "SECURITY_COOLDOWN"
+
+	// velocityWindow là cửa sổ đếm số lần chuyển và người nhận của một tài khoản
+	velocityWindow = 24 * time.Hour
+	// maxSecurityCooldownHours giới hạn thời gian chờ sau khi đổi thông tin bảo mật (30 ngày)
+	maxSecurityCooldownHours = 30 * 24
+	// maxReviewNoteLength giới hạn độ dài lý do từ chối của người duyệt
+	maxReviewNoteLength = 256
+	// maxFlaggedTransferPage giới hạn số giao dịch chờ duyệt trả về trong một lần truy vấn
+	maxFlaggedTransferPage = 100
+)
+
+// securityChanges là các thay đổi bảo mật của khách hàng kích hoạt thời gian chờ chuyển điểm
+var securityChanges = map[string]bool{"PASSWORD": true, "PHONE": true}
+
+// VelocityRules định nghĩa quy tắc tốc độ chuyển điểm trong cấu hình sổ cái; giá trị 0 = không giới hạn
+type VelocityRules struct {
+	MaxTransfersPerHour   int `json:"maxTransfersPerHour"`   // số lần chuyển tối đa của một tài khoản trong 1 giờ
+	MaxTransfersPerDay    int `json:"maxTransfersPerDay"`    // số lần chuyển tối đa của một tài khoản trong 24 giờ
+	MaxRecipientsPerDay   int `json:"maxRecipientsPerDay"`   // số người nhận khác nhau tối đa trong 24 giờ
+	SecurityCooldownHours int `json:"securityCooldownHours"` // thời gian chờ sau khi đổi mật khẩu/số điện thoại
+}
+
+// TransferStamp ghi nhận một lần chuyển điểm gần đây của tài khoản, dùng để đếm theo cửa sổ 24 giờ
+type TransferStamp struct {
+	TargetID  string `json:"targetID"`
+	Timestamp string `json:"timestamp"`
+}
+
+// FlaggedTransfer định nghĩa một giao dịch chuyển điểm vi phạm quy tắc tốc độ. Điểm đã được trừ khỏi
+// tài khoản nguồn và được giữ cho đến khi người duyệt chấp thuận (chuyển cho người nhận) hoặc từ chối (hoàn lại).
+type FlaggedTransfer struct {
+	TransferID    string   `json:"transferID"` // ID giao dịch TransferPoints đã bị giữ lại
+	SourceID      string   `json:"sourceID"`
+	TargetID      string   `json:"targetID"`
+	Amount        int64    `json:"amount"`
+	Currency      string   `json:"currency"`
+	Description   string   `json:"description"`
+	Rules         []string `json:"rules"`  // các quy tắc bị vi phạm
+	Status        string   `json:"status"` // FLAGGED, APPROVED, REJECTED
+	CreatedAt     string   `json:"createdAt"`
+	ReviewedBy    string   `json:"reviewedBy,omitempty"` // ID của identity đã duyệt
+	ReviewedAt    string   `json:"reviewedAt,omitempty"`
+	ReviewNote    string   `json:"reviewNote,omitempty"`
+	SchemaVersion int      `json:"schemaVersion"`
+}
+
+// enabled cho biết có quy tắc đếm số lần chuyển/người nhận nào được bật hay không
+func (r VelocityRules) enabled() bool {
+	return r.MaxTransfersPerHour > 0 || r.MaxTransfersPerDay > 0 || r.MaxRecipientsPerDay > 0
+}
+
+// =========================================================================================
+// UC-029: Quy tắc tốc độ chuyển điểm và giữ lại giao dịch đáng ngờ
+// Tài khoản bị chiếm đoạt thường bị rút điểm bằng nhiều lần chuyển nhỏ. Giao dịch TransferPoints
+// vi phạm quy tắc không bị từ chối mà được giữ lại (FLAGGED) chờ người duyệt xem xét.
+//
+// Logic chính:
+// 1. SetVelocityRules (chỉ quản trị viên): số lần chuyển tối đa mỗi giờ/ngày, số người nhận khác nhau
+//    tối đa mỗi ngày và số giờ chờ sau khi đổi mật khẩu/số điện thoại; 0 = không giới hạn.
+// 2. FlagSecurityChange (chỉ BankOrgMSP): backend ghi nhận khách hàng vừa đổi mật khẩu hoặc số điện thoại.
+// 3. TransferPoints và các hàm token Transfer/TransferFrom ghi các lần chuyển trong 24 giờ gần nhất vào LoyaltyAccount.RecentTransfers
+//    (kể cả giao dịch bị giữ lại) khi có quy tắc đếm được bật. Nếu giao dịch vượt giới hạn hoặc nằm trong
+//    thời gian chờ, điểm được trừ khỏi tài khoản nguồn và giữ trong bản ghi FlaggedTransfer, người nhận
+//    chưa được cộng điểm; hàm trả về bản ghi và phát ra sự kiện "TransferFlaggedEvent".
+// 4. ApproveFlagged / RejectFlagged (BankOrgMSP với role=reviewer): chuyển điểm đang giữ cho người nhận
+//    hoặc hoàn lại cho người gửi, ghi người duyệt và phát ra sự kiện "ApproveFlaggedEvent" / "RejectFlaggedEvent".
+// 5. Điểm đang giữ được ReconcileSupply cộng vào số dư thực tế (HeldTransfers), tương tự quà tặng.
+// 6. Các đường chuyển điểm khác được đếm theo cùng quy tắc nhưng bị từ chối với VELOCITY_LIMIT_EXCEEDED
+//    khi vi phạm (checkOutbound): CreateGift không có tài khoản người nhận; điểm của LockForSwap đã được giữ
+//    trong khoản khóa có hạn chót nên giữ thêm sẽ làm hỏng giao dịch hoán đổi; phần đóng góp của thành viên
+//    trong PooledRedeemReward thuộc một lần quy đổi phát hành voucher ngay trong giao dịch.
+// =========================================================================================
+func (s *AdminContract) SetVelocityRules(ctx contractapi.TransactionContextInterface, maxTransfersPerHour int, maxTransfersPerDay int, maxRecipientsPerDay int, securityCooldownHours int) (*LedgerConfig, error) {
+	// 1. Kiểm tra quy tắc
+	if maxTransfersPerHour < 0 || maxTransfersPerDay < 0 || maxRecipientsPerDay < 0 || securityCooldownHours < 0 {
+		return nil, errInvalidArgument("velocity limits cannot be negative")
+	}
+	if maxTransfersPerHour > 0 && maxTransfersPerDay > 0 && maxTransfersPerHour > maxTransfersPerDay {
+		return nil, errInvalidArgument("maxTransfersPerHour %d cannot exceed maxTransfersPerDay %d", maxTransfersPerHour, maxTransfersPerDay)
+	}
+	if securityCooldownHours > maxSecurityCooldownHours {
+		return nil, errInvalidArgument("security cooldown cannot exceed %d hours, got: %d", maxSecurityCooldownHours, securityCooldownHours)
+	}
+
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config.Velocity = VelocityRules{
+		MaxTransfersPerHour:   maxTransfersPerHour,
+		MaxTransfersPerDay:    maxTransfersPerDay,
+		MaxRecipientsPerDay:   maxRecipientsPerDay,
+		SecurityCooldownHours: securityCooldownHours,
+	}
+	if err := putLedgerConfig(ctx, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// FlagSecurityChange ghi nhận khách hàng vừa đổi mật khẩu hoặc số điện thoại (xem UC-029).
+// Các giao dịch chuyển điểm trong SecurityCooldownHours giờ sau đó bị giữ lại chờ duyệt.
+func (s *AccountContract) FlagSecurityChange(ctx contractapi.TransactionContextInterface, customerID string, change string) (*LoyaltyAccount, error) {
+	// 2. Kiểm tra loại thay đổi
+	if !securityChanges[change] {
+		return nil, errInvalidArgument("security change must be PASSWORD or PHONE, got: %q", change)
+	}
+	account, err := getAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(account); err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	account.SecurityChange = change
+	account.SecurityChangedAt = now.Format(time.RFC3339)
+	if err := putAccount(ctx, account); err != nil {
+		return nil, err
+	}
+
+	eventJSON, err := json.Marshal(map[string]string{"customerID": customerID, "change": change, "changedAt": account.SecurityChangedAt})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal security change event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent("SecurityChangeEvent", eventJSON); err != nil {
+		return nil, fmt.Errorf("failed to set event for security change: %v", err)
+	}
+	return account, nil
+}
+
+// ApproveFlagged chuyển điểm đang giữ của một giao dịch bị giữ lại cho người nhận (xem UC-029)
+func (s *AccountContract) ApproveFlagged(ctx contractapi.TransactionContextInterface, transferID string) (*FlaggedTransfer, error) {
+	transfer, err := getPendingFlaggedTransfer(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	// Tài khoản đích có thể đã được gộp vào tài khoản khác trong khi chờ duyệt
+	if err := releaseFlaggedTransfer(ctx, transfer, transfer.TargetID); err != nil {
+		return nil, err
+	}
+	if err := closeFlaggedTransfer(ctx, transfer, FlaggedStatusApproved, ""); err != nil {
+		return nil, err
+	}
+	if err := setFlaggedTransferEvent(ctx, "ApproveFlaggedEvent", transfer); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// RejectFlagged hoàn lại điểm đang giữ của một giao dịch bị giữ lại cho người gửi (xem UC-029)
+func (s *AccountContract) RejectFlagged(ctx contractapi.TransactionContextInterface, transferID string, reason string) (*FlaggedTransfer, error) {
+	if len(reason) > maxReviewNoteLength {
+		return nil, errInvalidArgument("reason cannot exceed %d characters", maxReviewNoteLength)
+	}
+	transfer, err := getPendingFlaggedTransfer(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if err := releaseFlaggedTransfer(ctx, transfer, transfer.SourceID); err != nil {
+		return nil, err
+	}
+	if err := closeFlaggedTransfer(ctx, transfer, FlaggedStatusRejected, reason); err != nil {
+		return nil, err
+	}
+	if err := setFlaggedTransferEvent(ctx, "RejectFlaggedEvent", transfer); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// GetFlaggedTransfer truy vấn một giao dịch bị giữ lại theo ID giao dịch TransferPoints
+func (s *AccountContract) GetFlaggedTransfer(ctx contractapi.TransactionContextInterface, transferID string) (*FlaggedTransfer, error) {
+	return getFlaggedTransfer(ctx, transferID)
+}
+
+// QueryFlaggedTransfers trả về tối đa `limit` giao dịch đang chờ duyệt (limit <= 0 hoặc lớn hơn 100 dùng 100)
+func (s *AccountContract) QueryFlaggedTransfers(ctx contractapi.TransactionContextInterface, limit int) ([]*FlaggedTransfer, error) {
+	if limit <= 0 || limit > maxFlaggedTransferPage {
+		limit = maxFlaggedTransferPage
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(flaggedHoldObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query flagged transfer holds: %v", err)
+	}
+	var transferIDs []string
+	for resultsIterator.HasNext() && len(transferIDs) < limit {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			resultsIterator.Close()
+			return nil, fmt.Errorf("failed to iterate flagged transfer holds: %v", err)
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			resultsIterator.Close()
+			return nil, fmt.Errorf("failed to split flagged transfer hold key: %v", err)
+		}
+		transferIDs = append(transferIDs, keyParts[1])
+	}
+	resultsIterator.Close()
+
+	transfers := []*FlaggedTransfer{}
+	for _, transferID := range transferIDs {
+		transfer, err := getFlaggedTransfer(ctx, transferID)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers, nil
+}
+
+// recordTransfer ghi lần chuyển tới `targetID` vào cửa sổ 24 giờ của tài khoản nguồn (chỉ khi có quy tắc đếm)
+// và trả về các quy tắc bị vi phạm. Người gọi phải ghi lại tài khoản.
+func (r VelocityRules) recordTransfer(account *LoyaltyAccount, targetID string, now time.Time) []string {
+	var rules []string
+	if r.SecurityCooldownHours > 0 && account.SecurityChangedAt != "" {
+		changedAt, err := ParseTimestamp(account.SecurityChangedAt)
+		if err != nil || now.Before(changedAt.Add(time.Duration(r.SecurityCooldownHours)*time.Hour)) {
+			rules = append(rules, VelocityRuleSecurityCooldown)
+		}
+	}
+	if !r.enabled() {
+		account.RecentTransfers = nil
+		return rules
+	}
+
+	// Bỏ các lần chuyển ngoài cửa sổ 24 giờ, đếm lần chuyển trong giờ qua và người nhận khác nhau
+	recent := []TransferStamp{}
+	transfersLastHour := 0
+	recipients := map[string]bool{targetID: true}
+	for _, stamp := range account.RecentTransfers {
+		timestamp, err := ParseTimestamp(stamp.Timestamp)
+		if err != nil || !timestamp.After(now.Add(-velocityWindow)) {
+			continue
+		}
+		if timestamp.After(now.Add(-time.Hour)) {
+			transfersLastHour++
+		}
+		recipients[stamp.TargetID] = true
+		recent = append(recent, stamp)
+	}
+	if r.MaxTransfersPerHour > 0 && transfersLastHour+1 > r.MaxTransfersPerHour {
+		rules = append(rules, VelocityRuleTransfersPerHour)
+	}
+	if r.MaxTransfersPerDay > 0 && len(recent)+1 > r.MaxTransfersPerDay {
+		rules = append(rules, VelocityRuleTransfersPerDay)
+	}
+	if r.MaxRecipientsPerDay > 0 && len(recipients) > r.MaxRecipientsPerDay {
+		rules = append(rules, VelocityRuleRecipientsPerDay)
+	}
+	account.RecentTransfers = append(recent, TransferStamp{TargetID: targetID, Timestamp: now.Format(time.RFC3339)})
+	return rules
+}
+
+// checkOutbound ghi lần chuyển tới `targetID` như recordTransfer và trả về lỗi VELOCITY_LIMIT_EXCEEDED nếu
+// vi phạm quy tắc (bước 6 của UC-029). Người gọi phải ghi lại tài khoản.
+func (r VelocityRules) checkOutbound(account *LoyaltyAccount, targetID string, now time.Time) error {
+	rules := r.recordTransfer(account, targetID, now)
+	if len(rules) == 0 {
+		return nil
+	}
+	return newChaincodeError(ErrCodeVelocityLimitExceeded, map[string]interface{}{"customerID": account.CustomerID, "rules": rules},
+		"transfers from '%s' are blocked by velocity rules: %s", account.CustomerID, strings.Join(rules, ", "))
+}
+
+// holdFlaggedTransfer trừ điểm khỏi tài khoản nguồn và giữ trong bản ghi FlaggedTransfer (bước 3 của UC-029)
+func holdFlaggedTransfer(ctx contractapi.TransactionContextInterface, source *LoyaltyAccount, target *LoyaltyAccount, amount int64, currency string, description string, rules []string, now time.Time) (*FlaggedTransfer, error) {
+	if err := requireOpenAccount(target); err != nil {
+		return nil, err
+	}
+	if err := debitCurrency(ctx, source, currency, amount, false); err != nil {
+		return nil, err
+	}
+	source.LastUpdated = now.Format(time.RFC3339)
+	if err := putAccount(ctx, source); err != nil {
+		return nil, err
+	}
+
+	transfer := FlaggedTransfer{
+		TransferID:  ctx.GetStub().GetTxID(),
+		SourceID:    source.CustomerID,
+		TargetID:    target.CustomerID,
+		Amount:      amount,
+		Currency:    currency,
+		Description: description,
+		Rules:       rules,
+		Status:      FlaggedStatusPending,
+		CreatedAt:   source.LastUpdated,
+	}
+	if err := putFlaggedTransfer(ctx, &transfer); err != nil {
+		return nil, err
+	}
+	holdKey, err := ledgerKey(ctx, flaggedHoldObjectType, currency, transfer.TransferID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(holdKey, []byte(strconv.FormatInt(amount, 10))); err != nil {
+		return nil, fmt.Errorf("failed to put flagged transfer hold: %v", err)
+	}
+	if err := setFlaggedTransferEvent(ctx, "TransferFlaggedEvent", &transfer); err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// releaseFlaggedTransfer ghi có số điểm đang giữ vào tài khoản `customerID` (hoặc tài khoản đã gộp nó)
+func releaseFlaggedTransfer(ctx contractapi.TransactionContextInterface, transfer *FlaggedTransfer, customerID string) error {
+	config, err := getLedgerConfig(ctx)
+	if err != nil {
+		return err
+	}
+	accountID, err := resolveAccountID(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	account, err := getAccount(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	deferred, err := creditCurrency(ctx, config, account, transfer.Currency, transfer.Amount, false)
+	if err != nil {
+		return err
+	}
+	account.LastUpdated = now.Format(time.RFC3339)
+	if !deferred {
+		return putAccount(ctx, account)
+	}
+	return nil
+}
+
+// closeFlaggedTransfer ghi kết quả duyệt và xóa bản ghi giữ điểm của giao dịch
+func closeFlaggedTransfer(ctx contractapi.TransactionContextInterface, transfer *FlaggedTransfer, status string, note string) error {
+	reviewedBy, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client identity: %v", err)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	holdKey, err := ledgerKey(ctx, flaggedHoldObjectType, transfer.Currency, transfer.TransferID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(holdKey); err != nil {
+		return fmt.Errorf("failed to delete flagged transfer hold: %v", err)
+	}
+	transfer.Status = status
+	transfer.ReviewedBy = reviewedBy
+	transfer.ReviewedAt = now.Format(time.RFC3339)
+	transfer.ReviewNote = note
+	return putFlaggedTransfer(ctx, transfer)
+}
+
+// getPendingFlaggedTransfer đọc một giao dịch bị giữ lại và kiểm tra nó vẫn đang chờ duyệt
+func getPendingFlaggedTransfer(ctx contractapi.TransactionContextInterface, transferID string) (*FlaggedTransfer, error) {
+	transfer, err := getFlaggedTransfer(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.Status != FlaggedStatusPending {
+		return nil, newChaincodeError(ErrCodeTransferNotFlagged, map[string]interface{}{"transferID": transferID, "status": transfer.Status},
+			"transfer '%s' has already been reviewed: %s", transferID, transfer.Status)
+	}
+	return transfer, nil
+}
+
+// getFlaggedTransfer đọc một giao dịch bị giữ lại từ World State
+func getFlaggedTransfer(ctx contractapi.TransactionContextInterface, transferID string) (*FlaggedTransfer, error) {
+	transferKey, err := ledgerKey(ctx, flaggedTransferObjectType, transferID)
+	if err != nil {
+		return nil, err
+	}
+	transferJSON, err := ctx.GetStub().GetState(transferKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flagged transfer from world state: %v", err)
+	}
+	if transferJSON == nil {
+		return nil, newChaincodeError(ErrCodeFlaggedTransferNotFound, map[string]interface{}{"transferID": transferID}, "flagged transfer '%s' does not exist", transferID)
+	}
+
+	var transfer FlaggedTransfer
+	if err := json.Unmarshal(transferJSON, &transfer); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal flagged transfer data: %v", err)
+	}
+	return &transfer, nil
+}
+
+// putFlaggedTransfer ghi một giao dịch bị giữ lại vào World State
+func putFlaggedTransfer(ctx contractapi.TransactionContextInterface, transfer *FlaggedTransfer) error {
+	transfer.SchemaVersion = flaggedTransferSchemaVersion
+	transferKey, err := ledgerKey(ctx, flaggedTransferObjectType, transfer.TransferID)
+	if err != nil {
+		return err
+	}
+	transferJSON, err := json.Marshal(transfer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal flagged transfer: %v", err)
+	}
+	if err := ctx.GetStub().PutState(transferKey, transferJSON); err != nil {
+		return fmt.Errorf("failed to put state for flagged transfer: %v", err)
+	}
+	return nil
+}
+
+// sumHeldTransfers cộng số điểm POINTS đang giữ trong các giao dịch chờ duyệt (chỉ dùng khi evaluate)
+func sumHeldTransfers(ctx contractapi.TransactionContextInterface, pageSize int) (int64, error) {
+	return sumObjectPages(ctx, flaggedHoldObjectType, pageSize, func(value []byte) (int64, error) {
+		amount, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse flagged transfer hold amount: %v", err)
+		}
+		return amount, nil
+	}, DefaultCurrency)
+}
+
+// setFlaggedTransferEvent phát ra sự kiện với dữ liệu giao dịch bị giữ lại
+func setFlaggedTransferEvent(ctx contractapi.TransactionContextInterface, eventName string, transfer *FlaggedTransfer) error {
+	transferJSON, err := json.Marshal(transfer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal flagged transfer event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent(eventName, transferJSON); err != nil {
+		return fmt.Errorf("failed to set event for flagged transfer: %v", err)
+	}
+	return nil
+}
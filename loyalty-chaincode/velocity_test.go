@@ -0,0 +1,361 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordTransferRules(t *testing.T) {
+	now := testTime
+	stamp := func(targetID string, ago time.Duration) TransferStamp {
+		return TransferStamp{TargetID: targetID, Timestamp: now.Add(-ago).Format(time.RFC3339)}
+	}
+	tests := []struct {
+		name       string
+		rules      VelocityRules
+		recent     []TransferStamp
+		changedAt  time.Time // zero = chưa đổi thông tin bảo mật
+		target     string
+		wantRules  []string
+		wantRecent int
+	}{
+		{
+			name:       "no rules",
+			recent:     []TransferStamp{stamp("BOB", time.Minute)},
+			target:     "BOB",
+			wantRecent: 0,
+		},
+		{
+			name:       "within limits",
+			rules:      VelocityRules{MaxTransfersPerHour: 2, MaxTransfersPerDay: 5, MaxRecipientsPerDay: 2},
+			recent:     []TransferStamp{stamp("BOB", time.Minute)},
+			target:     "BOB",
+			wantRecent: 2,
+		},
+		{
+			name:       "transfers per hour",
+			rules:      VelocityRules{MaxTransfersPerHour: 2},
+			recent:     []TransferStamp{stamp("BOB", time.Minute), stamp("BOB", 30*time.Minute)},
+			target:     "BOB",
+			wantRules:  []string{VelocityRuleTransfersPerHour},
+			wantRecent: 3,
+		},
+		{
+			name:       "transfers per day",
+			rules:      VelocityRules{MaxTransfersPerDay: 2},
+			recent:     []TransferStamp{stamp("BOB", 2*time.Hour), stamp("BOB", 20*time.Hour)},
+			target:     "BOB",
+			wantRules:  []string{VelocityRuleTransfersPerDay},
+			wantRecent: 3,
+		},
+		{
+			name:       "recipients per day",
+			rules:      VelocityRules{MaxRecipientsPerDay: 2},
+			recent:     []TransferStamp{stamp("BOB", 2*time.Hour), stamp("CAROL", 3*time.Hour)},
+			target:     "DAVE",
+			wantRules:  []string{VelocityRuleRecipientsPerDay},
+			wantRecent: 3,
+		},
+		{
+			name:       "stamps outside the window are dropped",
+			rules:      VelocityRules{MaxTransfersPerDay: 2, MaxRecipientsPerDay: 1},
+			recent:     []TransferStamp{stamp("BOB", 25*time.Hour), stamp("CAROL", 30*time.Hour)},
+			target:     "DAVE",
+			wantRecent: 1,
+		},
+		{
+			name:       "security cooldown",
+			rules:      VelocityRules{SecurityCooldownHours: 24},
+			changedAt:  now.Add(-time.Hour),
+			target:     "BOB",
+			wantRules:  []string{VelocityRuleSecurityCooldown},
+			wantRecent: 0,
+		},
+		{
+			name:       "security cooldown has passed",
+			rules:      VelocityRules{SecurityCooldownHours: 24},
+			changedAt:  now.Add(-25 * time.Hour),
+			target:     "BOB",
+			wantRecent: 0,
+		},
+		{
+			name:       "several rules at once",
+			rules:      VelocityRules{MaxTransfersPerHour: 1, MaxRecipientsPerDay: 1, SecurityCooldownHours: 1},
+			recent:     []TransferStamp{stamp("BOB", time.Minute)},
+			changedAt:  now.Add(-time.Minute),
+			target:     "CAROL",
+			wantRules:  []string{VelocityRuleSecurityCooldown, VelocityRuleTransfersPerHour, VelocityRuleRecipientsPerDay},
+			wantRecent: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := &LoyaltyAccount{CustomerID: "ALICE", RecentTransfers: tt.recent}
+			if !tt.changedAt.IsZero() {
+				account.SecurityChange = "PASSWORD"
+				account.SecurityChangedAt = tt.changedAt.Format(time.RFC3339)
+			}
+			rules := tt.rules.recordTransfer(account, tt.target, now)
+			if !reflect.DeepEqual(rules, tt.wantRules) {
+				t.Errorf("rules = %v, want %v", rules, tt.wantRules)
+			}
+			if len(account.RecentTransfers) != tt.wantRecent {
+				t.Errorf("recent transfers = %d, want %d", len(account.RecentTransfers), tt.wantRecent)
+			}
+		})
+	}
+}
+
+func TestTransferPointsHoldsFlaggedTransfers(t *testing.T) {
+	ctx := newTestContext(t)
+	contract := &AccountContract{}
+	ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000, "BOB": 0, "CAROL": 0})
+	ctx.mustTx(testTime, func() error {
+		_, err := (&AdminContract{}).SetVelocityRules(ctx, 2, 0, 0, 0)
+		return err
+	})
+
+	transfer := func(at time.Time, targetID string) *FlaggedTransfer {
+		t.Helper()
+		var flagged *FlaggedTransfer
+		ctx.mustTx(at, func() error {
+			var err error
+			flagged, err = contract.TransferPoints(ctx, "ALICE", targetID, 100, "gift", "", "", "", "")
+			return err
+		})
+		return flagged
+	}
+	for i := 0; i < 2; i++ {
+		if flagged := transfer(testTime.Add(time.Duration(i)*time.Minute), "BOB"); flagged != nil {
+			t.Fatalf("transfer %d was held: %+v", i+1, flagged)
+		}
+	}
+	approved := transfer(testTime.Add(2*time.Minute), "BOB")
+	rejected := transfer(testTime.Add(3*time.Minute), "CAROL")
+	if approved == nil || rejected == nil {
+		t.Fatal("transfers over the hourly limit were not held")
+	}
+	if !reflect.DeepEqual(approved.Rules, []string{VelocityRuleTransfersPerHour}) {
+		t.Errorf("rules = %v, want [%s]", approved.Rules, VelocityRuleTransfersPerHour)
+	}
+	if got := ctx.balance("ALICE"); got != 600 {
+		t.Errorf("held points must leave the source: balance = %d, want 600", got)
+	}
+
+	ctx.as(bankReviewer())
+	ctx.mustTx(testTime.Add(time.Hour), func() error {
+		_, err := contract.ApproveFlagged(ctx, approved.TransferID)
+		return err
+	})
+	ctx.mustTx(testTime.Add(time.Hour), func() error {
+		_, err := contract.RejectFlagged(ctx, rejected.TransferID, "not recognised")
+		return err
+	})
+	err := ctx.tx(testTime.Add(time.Hour), func() error {
+		_, err := contract.RejectFlagged(ctx, rejected.TransferID, "twice")
+		return err
+	})
+	checkErrorCode(t, err, ErrCodeTransferNotFlagged)
+
+	for customerID, want := range map[string]int64{"ALICE": 700, "BOB": 300, "CAROL": 0} {
+		if got := ctx.balance(customerID); got != want {
+			t.Errorf("balance of %s = %d, want %d", customerID, got, want)
+		}
+	}
+}
+
+func TestOutboundPathsFollowVelocityRules(t *testing.T) {
+	paths := []struct {
+		name string
+		run  func(ctx *testContext) error
+	}{
+		{
+			name: "CreateGift",
+			run: func(ctx *testContext) error {
+				_, err := (&AccountContract{}).CreateGift(ctx, "ALICE", 100, giftHash("velocity"), testTime.AddDate(0, 0, 7).Format(time.RFC3339), "", "", "")
+				return err
+			},
+		},
+		{
+			name: "LockForSwap",
+			run: func(ctx *testContext) error {
+				_, err := (&SwapContract{}).LockForSwap(ctx, "SWAP1", "ALICE", "BOB", 100, giftHash("swap"), testTime.Add(24*time.Hour).Format(time.RFC3339), "", "", "")
+				return err
+			},
+		},
+		{
+			name: "PooledRedeemReward",
+			run: func(ctx *testContext) error {
+				_, err := (&RewardContract{}).PooledRedeemReward(ctx, "HOME", "COFFEE", `{"ALICE": 50, "BOB": 50}`, "")
+				return err
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		flag    bool
+		wantErr string
+	}{
+		{name: "no security change"},
+		{name: "during the security cooldown", flag: true, wantErr: ErrCodeVelocityLimitExceeded},
+	}
+
+	for _, path := range paths {
+		for _, tt := range tests {
+			t.Run(path.name+"/"+tt.name, func(t *testing.T) {
+				ctx := newTestContext(t)
+				ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000, "BOB": 1000})
+				households := &AccountContract{}
+				ctx.mustTx(testTime, func() error {
+					if _, err := (&RewardContract{}).CreateReward(ctx, "COFFEE", "Coffee", 100, 5, 10, 30); err != nil {
+						return err
+					}
+					_, err := (&AdminContract{}).SetVelocityRules(ctx, 0, 0, 0, 24)
+					return err
+				})
+				ctx.mustTx(testTime, func() error {
+					_, err := households.CreateHousehold(ctx, "HOME", "BOB", 100, 0)
+					return err
+				})
+				ctx.mustTx(testTime, func() error {
+					_, err := households.InviteHouseholdMember(ctx, "HOME", "ALICE")
+					return err
+				})
+				ctx.mustTx(testTime, func() error {
+					_, err := households.AcceptHouseholdInvite(ctx, "HOME", "ALICE")
+					return err
+				})
+				if tt.flag {
+					ctx.mustTx(testTime, func() error {
+						_, err := households.FlagSecurityChange(ctx, "ALICE", "PASSWORD")
+						return err
+					})
+				}
+
+				err := ctx.tx(testTime.Add(time.Hour), func() error { return path.run(ctx) })
+				checkErrorCode(t, err, tt.wantErr)
+				wantBalance := int64(1000)
+				if tt.wantErr == "" {
+					wantBalance = 1000 - 100
+					if path.name == "PooledRedeemReward" {
+						wantBalance = 1000 - 50
+					}
+				}
+				if got := ctx.balance("ALICE"); got != wantBalance {
+					t.Errorf("balance of ALICE = %d, want %d", got, wantBalance)
+				}
+			})
+		}
+	}
+}
+
+func TestTokenTransfersHoldFlaggedTransfers(t *testing.T) {
+	// ALICE cho SHOP hạn mức 300 điểm; ALICE vừa đổi mật khẩu khi `flag`, nên mọi lần chuyển bị giữ lại
+	paths := []struct {
+		name string
+		run  func(ctx *testContext) error
+	}{
+		{
+			name: "Transfer",
+			run: func(ctx *testContext) error {
+				ctx.as(customer("ALICE"))
+				defer ctx.as(bankAdmin())
+				return (&AccountContract{}).Transfer(ctx, "BOB", 100)
+			},
+		},
+		{
+			name: "TransferFrom",
+			run: func(ctx *testContext) error {
+				ctx.as(customer("SHOP"))
+				defer ctx.as(bankAdmin())
+				return (&AccountContract{}).TransferFrom(ctx, "ALICE", "BOB", 100)
+			},
+		},
+	}
+	tests := []struct {
+		name   string
+		flag   bool
+		review func(ctx *testContext, transferID string) error // nil = không duyệt
+		want   map[string]int64
+	}{
+		{name: "no security change", want: map[string]int64{"ALICE": 900, "BOB": 100}},
+		{name: "held during the security cooldown", flag: true, want: map[string]int64{"ALICE": 900, "BOB": 0}},
+		{name: "held and approved", flag: true, want: map[string]int64{"ALICE": 900, "BOB": 100},
+			review: func(ctx *testContext, transferID string) error {
+				_, err := (&AccountContract{}).ApproveFlagged(ctx, transferID)
+				return err
+			}},
+		{name: "held and rejected", flag: true, want: map[string]int64{"ALICE": 1000, "BOB": 0},
+			review: func(ctx *testContext, transferID string) error {
+				_, err := (&AccountContract{}).RejectFlagged(ctx, transferID, "not recognised")
+				return err
+			}},
+	}
+
+	for _, path := range paths {
+		for _, tt := range tests {
+			t.Run(path.name+"/"+tt.name, func(t *testing.T) {
+				ctx := newTestContext(t)
+				contract := &AccountContract{}
+				ctx.setupAccounts(testTime, map[string]int64{"ALICE": 1000, "BOB": 0})
+				ctx.mustTx(testTime, func() error {
+					_, err := (&AdminContract{}).SetVelocityRules(ctx, 0, 0, 0, 24)
+					return err
+				})
+				ctx.as(customer("ALICE")).mustTx(testTime, func() error { return contract.Approve(ctx, "SHOP", 300) })
+				ctx.as(bankAdmin())
+				if tt.flag {
+					ctx.mustTx(testTime, func() error {
+						_, err := contract.FlagSecurityChange(ctx, "ALICE", "PASSWORD")
+						return err
+					})
+				}
+
+				ctx.mustTx(testTime.Add(time.Hour), func() error { return path.run(ctx) })
+
+				var held []*FlaggedTransfer
+				ctx.as(bankReviewer()).mustTx(testTime.Add(time.Hour), func() error {
+					var err error
+					held, err = contract.QueryFlaggedTransfers(ctx, 0)
+					return err
+				})
+				wantHeld := 0
+				if tt.flag {
+					wantHeld = 1
+				}
+				if len(held) != wantHeld {
+					t.Fatalf("%d transfers held, want %d", len(held), wantHeld)
+				}
+				if wantHeld == 1 {
+					if held[0].SourceID != "ALICE" || held[0].TargetID != "BOB" || held[0].Amount != 100 ||
+						!reflect.DeepEqual(held[0].Rules, []string{VelocityRuleSecurityCooldown}) {
+						t.Errorf("held transfer = %+v, want 100 from ALICE to BOB under %s", held[0], VelocityRuleSecurityCooldown)
+					}
+					if tt.review != nil {
+						ctx.mustTx(testTime.Add(2*time.Hour), func() error { return tt.review(ctx, held[0].TransferID) })
+					}
+				}
+				ctx.as(bankAdmin())
+
+				for customerID, want := range tt.want {
+					if got := ctx.balance(customerID); got != want {
+						t.Errorf("balance of %s = %d, want %d", customerID, got, want)
+					}
+				}
+				if path.name == "TransferFrom" {
+					var allowance int64
+					ctx.mustTx(testTime.Add(2*time.Hour), func() error {
+						var err error
+						allowance, err = contract.Allowance(ctx, "ALICE", "SHOP")
+						return err
+					})
+					if allowance != 200 {
+						t.Errorf("allowance = %d, want 200", allowance)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	VoucherStatusIssued   = "ISSUED"
+	VoucherStatusConsumed = "CONSUMED"
+	VoucherStatusExpired  = "EXPIRED"
+)
+
+// Voucher định nghĩa cấu trúc cho một voucher (token không thể thay thế) phát hành khi quy đổi phần thưởng
+type Voucher struct {
+	VoucherID     string  `json:"voucherID"`
+	OwnerID       string  `json:"ownerID"`
+	RewardID      string  `json:"rewardID"`
+	Value         float64 `json:"value"`
+	PointsCost    int64   `json:"pointsCost"`
+	IssuedAt      string  `json:"issuedAt"`
+	ExpiresAt     string  `json:"expiresAt"`
+	Status        string  `json:"status"` // ISSUED, CONSUMED, EXPIRED
+	ConsumedBy    string  `json:"consumedBy,omitempty"`
+	ConsumedAt    string  `json:"consumedAt,omitempty"`
+	ConsumedVia   string  `json:"consumedVia,omitempty"` // ID của identity đã gửi giao dịch ConsumeVoucher
+	SchemaVersion int     `json:"schemaVersion"`
+}
+
+// VoucherVerification định nghĩa kết quả kiểm tra voucher tại điểm bán
+type VoucherVerification struct {
+	Voucher *Voucher `json:"voucher"`
+	Valid   bool     `json:"valid"`
+	Reason  string   `json:"reason,omitempty"`
+}
+
+// isExpiredAt kiểm tra voucher đã hết hạn tại thời điểm t hay chưa
+func (v *Voucher) isExpiredAt(t time.Time) bool {
+	expiresAt, err := ParseTimestamp(v.ExpiresAt)
+	if err != nil {
+		return true
+	}
+	return !t.Before(expiresAt)
+}
+
+// checkUsable trả về lỗi nếu voucher không còn sử dụng được tại thời điểm t
+func (v *Voucher) checkUsable(t time.Time) error {
+	if v.Status != VoucherStatusIssued {
+		return newChaincodeError(ErrCodeVoucherNotUsable, map[string]interface{}{"voucherID": v.VoucherID, "status": v.Status}, "voucher '%s' is %s", v.VoucherID, strings.ToLower(v.Status))
+	}
+	if v.isExpiredAt(t) {
+		return newChaincodeError(ErrCodeVoucherNotUsable, map[string]interface{}{"voucherID": v.VoucherID, "status": VoucherStatusExpired, "expiresAt": v.ExpiresAt}, "voucher '%s' expired at %s", v.VoucherID, v.ExpiresAt)
+	}
+	return nil
+}
+
+// =========================================================================================
+// UC-010: Chuyển nhượng voucher
+//
+// Logic chính:
+// 1. Tìm voucher theo `voucherID`, người gọi phải được phép đại diện cho chủ sở hữu hiện tại.
+// 2. Voucher phải ở trạng thái ISSUED và chưa hết hạn.
+// 3. Tài khoản đích phải tồn tại và khác chủ sở hữu hiện tại; chủ sở hữu đã đăng ký khóa phải ký (UC-028, bước 6).
+// 4. Cập nhật chủ sở hữu và chỉ mục voucher theo chủ sở hữu.
+// 5. Phát ra sự kiện "TransferVoucherEvent".
+// =========================================================================================
+func (s *RewardContract) TransferVoucher(ctx contractapi.TransactionContextInterface, voucherID string, newOwnerID string, nonce string, expiresAt string, signature string) (*Voucher, error) {
+	if voucherID == "" {
+		return nil, errInvalidArgument("voucher ID cannot be empty")
+	}
+	if newOwnerID == "" {
+		return nil, errInvalidArgument("new owner ID cannot be empty")
+	}
+
+	voucher, err := getVoucher(ctx, voucherID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireActingFor(ctx, voucher.OwnerID); err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := voucher.checkUsable(now); err != nil {
+		return nil, err
+	}
+	if voucher.OwnerID == newOwnerID {
+		return nil, errInvalidArgument("voucher '%s' is already owned by '%s'", voucherID, newOwnerID)
+	}
+	newOwner, err := getAccount(ctx, newOwnerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireOpenAccount(newOwner); err != nil {
+		return nil, err
+	}
+	owner, err := getAccount(ctx, voucher.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyTransferAuthorization(ctx, authorizationVoucherTransfer, owner, voucherID, 0, "", nonce, expiresAt, signature, [2]string{"recipient", newOwnerID}); err != nil {
+		return nil, err
+	}
+
+	if err := deleteVoucherOwnerIndex(ctx, voucher.OwnerID, voucherID); err != nil {
+		return nil, err
+	}
+	voucher.OwnerID = newOwnerID
+	if err := putVoucher(ctx, voucher); err != nil {
+		return nil, err
+	}
+	if err := putVoucherOwnerIndex(ctx, newOwnerID, voucherID); err != nil {
+		return nil, err
+	}
+
+	if err := setVoucherEvent(ctx, "TransferVoucherEvent", voucher); err != nil {
+		return nil, err
+	}
+	return voucher, nil
+}
+
+// =========================================================================================
+// UC-011: Sử dụng (burn) voucher tại điểm bán
+//
+// Logic chính:
+// 1. Người gọi phải là merchant `merchantID` (thuộc tính merchantID trong chứng chỉ) hoặc BankOrgMSP.
+// 2. Tìm voucher theo `voucherID`, voucher phải ở trạng thái ISSUED và chưa hết hạn.
+// 3. Chuyển trạng thái sang CONSUMED, ghi nhận merchant, identity đã gửi giao dịch và thời điểm sử dụng.
+// 4. Phát ra sự kiện "ConsumeVoucherEvent".
+// =========================================================================================
+func (s *RewardContract) ConsumeVoucher(ctx contractapi.TransactionContextInterface, voucherID string, merchantID string) (*Voucher, error) {
+	if voucherID == "" {
+		return nil, errInvalidArgument("voucher ID cannot be empty")
+	}
+	if merchantID == "" {
+		return nil, errInvalidArgument("merchant ID cannot be empty")
+	}
+	if err := requireMerchant(ctx, merchantID); err != nil {
+		return nil, err
+	}
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client identity: %v", err)
+	}
+
+	voucher, err := getVoucher(ctx, voucherID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := voucher.checkUsable(now); err != nil {
+		return nil, err
+	}
+
+	voucher.Status = VoucherStatusConsumed
+	voucher.ConsumedBy = merchantID
+	voucher.ConsumedVia = clientID
+	voucher.ConsumedAt = now.Format(time.RFC3339)
+	if err := putVoucher(ctx, voucher); err != nil {
+		return nil, err
+	}
+
+	if err := setVoucherEvent(ctx, "ConsumeVoucherEvent", voucher); err != nil {
+		return nil, err
+	}
+	return voucher, nil
+}
+
+// VerifyVoucher kiểm tra voucher có hợp lệ để sử dụng tại thời điểm giao dịch hay không (không thay đổi sổ cái)
+func (s *RewardContract) VerifyVoucher(ctx contractapi.TransactionContextInterface, voucherID string) (*VoucherVerification, error) {
+	if voucherID == "" {
+		return nil, errInvalidArgument("voucher ID cannot be empty")
+	}
+
+	voucher, err := getVoucher(ctx, voucherID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := VoucherVerification{Voucher: voucher, Valid: true}
+	if err := voucher.checkUsable(now); err != nil {
+		verification.Valid = false
+		verification.Reason = err.Error()
+		if voucher.Status == VoucherStatusIssued {
+			voucher.Status = VoucherStatusExpired
+		}
+	}
+	return &verification, nil
+}
+
+// GetVoucher truy vấn một voucher theo ID
+func (s *RewardContract) GetVoucher(ctx contractapi.TransactionContextInterface, voucherID string) (*Voucher, error) {
+	if voucherID == "" {
+		return nil, errInvalidArgument("voucher ID cannot be empty")
+	}
+	return getVoucher(ctx, voucherID)
+}
+
+// GetVouchersByOwner trả về tất cả voucher thuộc về một khách hàng (ID đã gộp được phân giải sang tài khoản giữ lại)
+func (s *RewardContract) GetVouchersByOwner(ctx contractapi.TransactionContextInterface, ownerID string) ([]*Voucher, error) {
+	if ownerID == "" {
+		return nil, errInvalidArgument("owner ID cannot be empty")
+	}
+	ownerID, err := resolveAccountID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(voucherOwnerObjectType, []string{ownerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vouchers by owner: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var vouchers []*Voucher
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate vouchers: %v", err)
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split voucher owner key: %v", err)
+		}
+		voucher, err := getVoucher(ctx, keyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		vouchers = append(vouchers, voucher)
+	}
+	return vouchers, nil
+}
+
+// newVoucherID sinh mã voucher từ ID giao dịch (mỗi giao dịch quy đổi phát hành một voucher)
+func newVoucherID(txID string) string {
+	if len(txID) > 16 {
+		txID = txID[:16]
+	}
+	return "VCH-" + strings.ToUpper(txID)
+}
+
+// createVoucher lưu voucher mới cùng chỉ mục theo chủ sở hữu
+func createVoucher(ctx contractapi.TransactionContextInterface, voucher *Voucher) error {
+	voucherKey, err := ledgerKey(ctx, voucherObjectType, voucher.VoucherID)
+	if err != nil {
+		return err
+	}
+	existingVoucherJSON, err := ctx.GetStub().GetState(voucherKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existingVoucherJSON != nil {
+		return newChaincodeError(ErrCodeVoucherAlreadyExists, map[string]interface{}{"voucherID": voucher.VoucherID}, "voucher with ID '%s' already exists", voucher.VoucherID)
+	}
+
+	if err := putVoucher(ctx, voucher); err != nil {
+		return err
+	}
+	return putVoucherOwnerIndex(ctx, voucher.OwnerID, voucher.VoucherID)
+}
+
+// getVoucher đọc một voucher từ World State
+func getVoucher(ctx contractapi.TransactionContextInterface, voucherID string) (*Voucher, error) {
+	voucherKey, err := ledgerKey(ctx, voucherObjectType, voucherID)
+	if err != nil {
+		return nil, err
+	}
+	voucherJSON, err := ctx.GetStub().GetState(voucherKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read voucher from world state: %v", err)
+	}
+	if voucherJSON == nil {
+		return nil, newChaincodeError(ErrCodeVoucherNotFound, map[string]interface{}{"voucherID": voucherID}, "voucher with ID '%s' does not exist", voucherID)
+	}
+
+	var voucher Voucher
+	if err := json.Unmarshal(voucherJSON, &voucher); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal voucher data: %v", err)
+	}
+	return &voucher, nil
+}
+
+// putVoucher ghi một voucher vào World State
+func putVoucher(ctx contractapi.TransactionContextInterface, voucher *Voucher) error {
+	voucher.SchemaVersion = voucherSchemaVersion
+	voucherKey, err := ledgerKey(ctx, voucherObjectType, voucher.VoucherID)
+	if err != nil {
+		return err
+	}
+	voucherJSON, err := json.Marshal(voucher)
+	if err != nil {
+		return fmt.Errorf("failed to marshal voucher: %v", err)
+	}
+	if err := ctx.GetStub().PutState(voucherKey, voucherJSON); err != nil {
+		return fmt.Errorf("failed to put state for voucher: %v", err)
+	}
+	return nil
+}
+
+// putVoucherOwnerIndex ghi chỉ mục owner~voucher (giá trị rỗng, chỉ dùng key để truy vấn)
+func putVoucherOwnerIndex(ctx contractapi.TransactionContextInterface, ownerID, voucherID string) error {
+	indexKey, err := ledgerKey(ctx, voucherOwnerObjectType, ownerID, voucherID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(indexKey, []byte{0x00}); err != nil {
+		return fmt.Errorf("failed to put voucher owner index: %v", err)
+	}
+	return nil
+}
+
+// deleteVoucherOwnerIndex xóa chỉ mục owner~voucher
+func deleteVoucherOwnerIndex(ctx contractapi.TransactionContextInterface, ownerID, voucherID string) error {
+	indexKey, err := ledgerKey(ctx, voucherOwnerObjectType, ownerID, voucherID)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().DelState(indexKey); err != nil {
+		return fmt.Errorf("failed to delete voucher owner index: %v", err)
+	}
+	return nil
+}
+
+// setVoucherEvent phát ra sự kiện với dữ liệu voucher
+func setVoucherEvent(ctx contractapi.TransactionContextInterface, eventName string, voucher *Voucher) error {
+	voucherJSON, err := json.Marshal(voucher)
+	if err != nil {
+		return fmt.Errorf("failed to marshal voucher event: %v", err)
+	}
+	if err := ctx.GetStub().SetEvent(eventName, voucherJSON); err != nil {
+		return fmt.Errorf("failed to set event for voucher: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+)
+
+// issueTestVoucher tạo phần thưởng và quy đổi nó cho `ownerID`, trả về voucher đã phát hành
+func issueTestVoucher(ctx *testContext, ownerID string) *Voucher {
+	ctx.t.Helper()
+	rewards := &RewardContract{}
+	var redemption *RewardRedemption
+	ctx.as(bankAdmin()).mustTx(testTime, func() error {
+		_, err := rewards.CreateReward(ctx, "COFFEE", "Coffee", 100, 5, 10, 30)
+		return err
+	})
+	ctx.mustTx(testTime, func() error {
+		var err error
+		redemption, err = rewards.RedeemReward(ctx, ownerID, "COFFEE")
+		return err
+	})
+	return redemption.Voucher
+}
+
+func TestTransferVoucherAccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  *testIdentity
+		wantErr string
+	}{
+		{name: "owner", caller: customer("ALICE")},
+		{name: "bank acting for the owner", caller: bankAdmin()},
+		{name: "new owner cannot take the voucher", caller: customer("BOB"), wantErr: ErrCodeAccessDenied},
+		{name: "other customer", caller: customer("CAROL"), wantErr: ErrCodeAccessDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 500, "BOB": 0, "CAROL": 0})
+			voucher := issueTestVoucher(ctx, "ALICE")
+
+			err := ctx.as(tt.caller).tx(testTime, func() error {
+				_, err := (&RewardContract{}).TransferVoucher(ctx, voucher.VoucherID, "BOB", "", "", "")
+				return err
+			})
+			checkErrorCode(t, err, tt.wantErr)
+
+			var stored *Voucher
+			ctx.as(bankAdmin()).mustTx(testTime, func() error {
+				var err error
+				stored, err = (&RewardContract{}).GetVoucher(ctx, voucher.VoucherID)
+				return err
+			})
+			wantOwner := "BOB"
+			if tt.wantErr != "" {
+				wantOwner = "ALICE"
+			}
+			if stored.OwnerID != wantOwner {
+				t.Errorf("owner = %s, want %s", stored.OwnerID, wantOwner)
+			}
+		})
+	}
+}
+
+func TestConsumeVoucherAccess(t *testing.T) {
+	merchant := func(merchantID string) *testIdentity {
+		return &testIdentity{mspID: "MerchantOrgMSP", attrs: map[string]string{"merchantID": merchantID}}
+	}
+	tests := []struct {
+		name    string
+		caller  *testIdentity
+		wantErr string
+	}{
+		{name: "merchant identity", caller: merchant("SHOP")},
+		{name: "bank acting for the merchant", caller: bankAdmin()},
+		{name: "another merchant", caller: merchant("OTHER"), wantErr: ErrCodeAccessDenied},
+		{name: "voucher owner", caller: customer("ALICE"), wantErr: ErrCodeAccessDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t)
+			ctx.setupAccounts(testTime, map[string]int64{"ALICE": 500})
+			voucher := issueTestVoucher(ctx, "ALICE")
+
+			var consumed *Voucher
+			err := ctx.as(tt.caller).tx(testTime, func() error {
+				var err error
+				consumed, err = (&RewardContract{}).ConsumeVoucher(ctx, voucher.VoucherID, "SHOP")
+				return err
+			})
+			checkErrorCode(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				return
+			}
+			wantVia, _ := tt.caller.GetID()
+			if consumed.Status != VoucherStatusConsumed || consumed.ConsumedBy != "SHOP" || consumed.ConsumedVia != wantVia {
+				t.Errorf("voucher = %+v, want CONSUMED by SHOP via %s", consumed, wantVia)
+			}
+		})
+	}
+}
@@ -1,8 +1,8 @@
 // File: src/pages/Customer/Dashboard/index.tsx
 
 import React, { useEffect, useState } from 'react';
-import { Row, Col, Card, Statistic, Typography, Button, Table, Spin, Alert, message } from 'antd';
-import { WalletOutlined, GiftOutlined, SwapOutlined, EyeOutlined } from '@ant-design/icons';
+import { Row, Col, Card, Statistic, Typography, Button, Table, Spin, Alert, Tag, message } from 'antd';
+import { WalletOutlined, GiftOutlined, SwapOutlined, EyeOutlined, UserAddOutlined } from '@ant-design/icons';
 import { Link } from 'react-router-dom';
 import { useSelector } from 'react-redux';
 import axiosClient from '../../../api/axiosClient';
@@ -26,6 +26,24 @@ interface Transaction {
     type: 'earn' | 'redeem' | 'transfer' | 'bonus';
 }
 
+// Interface cho lượt giới thiệu
+interface Referral {
+    refereeID: string;
+    referrerID: string;
+    status: 'PENDING' | 'REWARDED';
+    createdAt: string;
+    rewardedAt?: string;
+    referrerReward?: number;
+}
+
+// Interface cho thông tin giới thiệu của khách hàng
+interface ReferralSummary {
+    customerID: string;
+    referralCode: string;
+    referredBy?: string;
+    referrals: Referral[];
+}
+
 const CustomerDashboard: React.FC = () => {
     // Lấy thông tin người dùng từ Redux store
     const { user } = useSelector((state: RootState) => state.auth);
@@ -33,6 +51,7 @@ const CustomerDashboard: React.FC = () => {
     // State management
     const [accountData, setAccountData] = useState<AccountData | null>(null);
     const [recentTransactions, setRecentTransactions] = useState<Transaction[]>([]);
+    const [referralData, setReferralData] = useState<ReferralSummary | null>(null);
     const [loading, setLoading] = useState<boolean>(true);
     const [error, setError] = useState<string | null>(null);
 
@@ -67,6 +86,15 @@ const CustomerDashboard: React.FC = () => {
                 const transactionsApiData = transactionsResponse.data?.data || transactionsResponse.data;
                 console.log('Parsed transactions data:', transactionsApiData);
                 setRecentTransactions(Array.isArray(transactionsApiData) ? transactionsApiData : []);
+
+                // Thông tin giới thiệu cần mạng blockchain; lỗi không làm hỏng dashboard
+                try {
+                    const referralResponse = await axiosClient.get(`/accounts/${user.username}/referrals`);
+                    setReferralData(referralResponse.data?.data || null);
+                } catch (referralErr) {
+                    console.error('Referral API Error:', referralErr);
+                    setReferralData(null);
+                }
                 
             } catch (err: any) {
                 console.error('API Error:', err);
@@ -113,6 +141,25 @@ const CustomerDashboard: React.FC = () => {
         },
     ];
 
+    // Cấu hình columns cho bảng giới thiệu
+    const referralColumns = [
+        {
+            title: 'Bạn bè',
+            dataIndex: 'refereeID',
+            key: 'refereeID',
+        },
+        {
+            title: 'Trạng thái',
+            dataIndex: 'status',
+            key: 'status',
+            render: (status: Referral['status'], referral: Referral) => (
+                status === 'REWARDED'
+                    ? <Tag color="green">Đã thưởng +{referral.referrerReward || 0}</Tag>
+                    : <Tag color="gold">Chờ đạt ngưỡng</Tag>
+            ),
+        },
+    ];
+
     // Hiển thị loading
     if (loading) {
         return (
@@ -227,6 +274,37 @@ const CustomerDashboard: React.FC = () => {
                             </Link>
                         </div>
                     </Card>
+
+                    {/* Card Giới thiệu bạn bè */}
+                    {referralData && (
+                        <Card
+                            title={<span><UserAddOutlined /> Giới thiệu bạn bè</span>}
+                            style={{ marginTop: '24px' }}
+                        >
+                            <Text type="secondary">Mã giới thiệu của bạn</Text>
+                            <div style={{ marginBottom: '16px' }}>
+                                <Text strong copyable style={{ fontSize: '18px' }}>
+                                    {referralData.referralCode}
+                                </Text>
+                            </div>
+                            {referralData.referredBy && (
+                                <div style={{ marginBottom: '16px' }}>
+                                    <Text type="secondary">Được giới thiệu bởi: </Text>
+                                    <Text>{referralData.referredBy}</Text>
+                                </div>
+                            )}
+                            <Table
+                                columns={referralColumns}
+                                dataSource={referralData.referrals}
+                                rowKey="refereeID"
+                                pagination={false}
+                                size="small"
+                                locale={{
+                                    emptyText: 'Chưa giới thiệu bạn bè nào'
+                                }}
+                            />
+                        </Card>
+                    )}
                 </Col>
             </Row>
         </div>